@@ -18,17 +18,22 @@ limitations under the License.
 import (
 	"context"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	cruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/crunchydata/postgres-operator/internal/controller/postgrescluster"
 	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/upgradecheck"
 	"github.com/crunchydata/postgres-operator/internal/util"
 )
@@ -115,7 +120,56 @@ func addControllersToManager(ctx context.Context, mgr manager.Manager) error {
 		Recorder:    mgr.GetEventRecorderFor(postgrescluster.ControllerName),
 		Tracer:      otel.Tracer(postgrescluster.ControllerName),
 		IsOpenShift: isOpenshift(ctx, mgr.GetConfig()),
+
+		// Wait longer after each transient error, up to five minutes.
+		Backoff: postgrescluster.Backoff{Base: time.Second, Max: 5 * time.Minute},
+	}
+
+	// Warn about certificates that expire within this duration, e.g. "720h".
+	if value := os.Getenv("PGO_CERTIFICATE_EXPIRY_WARNING"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		r.CertificateExpiryWarning = window
+	}
+
+	// Prefix the names of objects generated for PostgresClusters, e.g. "team-a-".
+	if value := os.Getenv("PGO_RESOURCE_NAME_PREFIX"); value != "" {
+		if err := naming.ValidateResourceNamePrefix(value); err != nil {
+			return errors.Wrap(err, "PGO_RESOURCE_NAME_PREFIX")
+		}
+	}
+
+	// Reconcile this many PostgresClusters at the same time, e.g. "4".
+	if value := os.Getenv("PGO_WORKERS"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err == nil && workers < 1 {
+			err = errors.New("must be at least 1")
+		}
+		if err != nil {
+			return errors.Wrap(err, "PGO_WORKERS")
+		}
+		r.Workers = workers
 	}
+
+	// Serve controller metrics alongside those of controller-runtime.
+	r.Metrics = postgrescluster.NewMetrics(mgr.GetClient())
+	if err := r.Metrics.Register(metrics.Registry); err != nil {
+		return err
+	}
+
+	// Default and validate PostgresClusters before they are stored when there is
+	// a serving certificate for the webhook in this directory, e.g. "/etc/pgo/webhook".
+	if dir := os.Getenv("PGO_WEBHOOK_CERT_DIR"); dir != "" {
+		mgr.GetWebhookServer().CertDir = dir
+
+		v := &postgrescluster.Validator{Client: mgr.GetClient()}
+		if err := v.SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+	}
+
 	return r.SetupWithManager(mgr)
 }
 
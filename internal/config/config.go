@@ -67,6 +67,19 @@ func PGBouncerContainerImage(cluster *v1beta1.PostgresCluster) string {
 	return defaultFromEnv(image, "RELATED_IMAGE_PGBOUNCER")
 }
 
+// PGBouncerExporterContainerImage returns the container image to use for
+// pgbouncer_exporter.
+func PGBouncerExporterContainerImage(cluster *v1beta1.PostgresCluster) string {
+	var image string
+	if cluster.Spec.Proxy != nil &&
+		cluster.Spec.Proxy.PGBouncer != nil &&
+		cluster.Spec.Proxy.PGBouncer.Exporter != nil {
+		image = cluster.Spec.Proxy.PGBouncer.Exporter.Image
+	}
+
+	return defaultFromEnv(image, "RELATED_IMAGE_PGBOUNCER_EXPORTER")
+}
+
 // PGExporterContainerImage returns the container image to use for the
 // PostgreSQL Exporter.
 func PGExporterContainerImage(cluster *v1beta1.PostgresCluster) string {
@@ -80,6 +93,16 @@ func PGExporterContainerImage(cluster *v1beta1.PostgresCluster) string {
 	return defaultFromEnv(image, "RELATED_IMAGE_PGEXPORTER")
 }
 
+// PGUpgradeContainerImage returns the container image to use for pg_upgrade.
+func PGUpgradeContainerImage(cluster *v1beta1.PostgresCluster) string {
+	var image string
+	if cluster.Spec.Upgrade != nil {
+		image = cluster.Spec.Upgrade.Image
+	}
+
+	return defaultFromEnv(image, "RELATED_IMAGE_PGUPGRADE")
+}
+
 // PostgresContainerImage returns the container image to use for PostgreSQL.
 func PostgresContainerImage(cluster *v1beta1.PostgresCluster) string {
 	image := cluster.Spec.Image
@@ -98,3 +121,10 @@ func PostgresContainerImage(cluster *v1beta1.PostgresCluster) string {
 func PGONamespace() string {
 	return os.Getenv("PGO_NAMESPACE")
 }
+
+// ResourceNamePrefix returns the prefix PGO adds to the names of all objects
+// it generates for a PostgresCluster, based on the PGO_RESOURCE_NAME_PREFIX
+// env var. If no env var is found, returns ""
+func ResourceNamePrefix() string {
+	return os.Getenv("PGO_RESOURCE_NAME_PREFIX")
+}
@@ -103,6 +103,24 @@ func TestPGBouncerContainerImage(t *testing.T) {
 	assert.Equal(t, PGBouncerContainerImage(cluster), "spec-image")
 }
 
+func TestPGBouncerExporterContainerImage(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+
+	unsetEnv(t, "RELATED_IMAGE_PGBOUNCER_EXPORTER")
+	assert.Equal(t, PGBouncerExporterContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGBOUNCER_EXPORTER", "")
+	assert.Equal(t, PGBouncerExporterContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGBOUNCER_EXPORTER", "env-var-pgbouncer-exporter")
+	assert.Equal(t, PGBouncerExporterContainerImage(cluster), "env-var-pgbouncer-exporter")
+
+	assert.NilError(t, yaml.Unmarshal([]byte(`{
+		proxy: { pgBouncer: { exporter: { image: spec-image } } },
+	}`), &cluster.Spec))
+	assert.Equal(t, PGBouncerExporterContainerImage(cluster), "spec-image")
+}
+
 func TestPGExporterContainerImage(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 
@@ -121,6 +139,24 @@ func TestPGExporterContainerImage(t *testing.T) {
 	assert.Equal(t, PGExporterContainerImage(cluster), "spec-image")
 }
 
+func TestPGUpgradeContainerImage(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+
+	unsetEnv(t, "RELATED_IMAGE_PGUPGRADE")
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGUPGRADE", "")
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "")
+
+	setEnv(t, "RELATED_IMAGE_PGUPGRADE", "env-var-pgupgrade")
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "env-var-pgupgrade")
+
+	assert.NilError(t, yaml.Unmarshal([]byte(`{
+		upgrade: { image: spec-image },
+	}`), &cluster.Spec))
+	assert.Equal(t, PGUpgradeContainerImage(cluster), "spec-image")
+}
+
 func TestPostgresContainerImage(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 	cluster.Spec.PostgresVersion = 12
@@ -145,3 +181,11 @@ func TestPostgresContainerImage(t *testing.T) {
 	cluster.Spec.Image = "spec-image"
 	assert.Equal(t, PostgresContainerImage(cluster), "spec-image")
 }
+
+func TestResourceNamePrefix(t *testing.T) {
+	unsetEnv(t, "PGO_RESOURCE_NAME_PREFIX")
+	assert.Equal(t, ResourceNamePrefix(), "")
+
+	setEnv(t, "PGO_RESOURCE_NAME_PREFIX", "team-a-")
+	assert.Equal(t, ResourceNamePrefix(), "team-a-")
+}
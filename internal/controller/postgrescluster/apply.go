@@ -31,6 +31,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/kubeapi"
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
 )
 
 // apply sends an apply patch to object's endpoint in the Kubernetes API and
@@ -39,6 +41,16 @@ import (
 // - https://docs.k8s.io/reference/using-api/server-side-apply/#managers
 // - https://docs.k8s.io/reference/using-api/server-side-apply/#conflicts
 func (r *Reconciler) apply(ctx context.Context, object client.Object) error {
+	// Kubernetes rejects some objects with long names or their Pods. Reconcile
+	// reports these names in an event, so skip the object and let the rest of
+	// the cluster be reconciled.
+	kind := object.GetObjectKind().GroupVersionKind().Kind
+	if limit, ok := naming.NameLengthLimit(kind); ok && len(object.GetName()) > limit {
+		logging.FromContext(ctx).V(1).Info("name too long; skipping",
+			"kind", kind, "name", object.GetName())
+		return nil
+	}
+
 	// Generate an apply-patch by comparing the object to its zero value.
 	zero := reflect.New(reflect.TypeOf(object).Elem()).Interface()
 	data, err := client.MergeFrom(zero.(client.Object)).Data(object)
@@ -29,6 +29,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -41,6 +42,19 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 )
 
+func TestApplyLongName(t *testing.T) {
+	ctx := context.Background()
+
+	// No client is necessary to skip an object.
+	r := &Reconciler{}
+
+	cronjob := &batchv1.CronJob{}
+	cronjob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("CronJob"))
+	cronjob.Namespace, cronjob.Name = "ns1", strings.Repeat("x", 53)
+
+	assert.NilError(t, r.apply(ctx, cronjob))
+}
+
 func TestServerSideApply(t *testing.T) {
 	ctx := context.Background()
 	env, cc := setupKubernetes(t)
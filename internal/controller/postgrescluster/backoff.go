@@ -0,0 +1,96 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+)
+
+// Backoff is how long to wait before reconciling a PostgresCluster again after
+// consecutive transient errors.
+type Backoff struct {
+	// Base is how long to wait after the first transient error. This doubles
+	// after each consecutive transient error.
+	Base time.Duration
+
+	// Max is the longest to wait after any transient error. When it is less
+	// than Base, every wait is Base.
+	Max time.Duration
+}
+
+// rateLimiter returns a workqueue.RateLimiter that tracks consecutive transient
+// errors using b. It returns nil when b is zero.
+func (b Backoff) rateLimiter() workqueue.RateLimiter {
+	if b.Base <= 0 {
+		return nil
+	}
+
+	// The limiter returns zero when the delay exceeds its maximum, which
+	// would retry immediately.
+	if b.Max < b.Base {
+		b.Max = b.Base
+	}
+	return workqueue.NewItemExponentialFailureRateLimiter(b.Base, b.Max)
+}
+
+// isTransient returns true when err is likely to go away without any change to
+// the PostgresCluster, such as when the API is overloaded or when the namespace
+// is being deleted.
+func isTransient(err error) bool {
+	// HasStatusCause does not look through wrapped errors.
+	var status *apierrors.StatusError
+	if errors.As(err, &status) &&
+		apierrors.HasStatusCause(status, corev1.NamespaceTerminatingCause) {
+		return true
+	}
+
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err)
+}
+
+// requeueTransient converts a transient err into a result that reconciles
+// request again after a delay that grows with each consecutive transient
+// error. Other results and errors are returned unchanged. It does nothing
+// until SetupWithManager is called with a Backoff.
+func (r *Reconciler) requeueTransient(
+	ctx context.Context, request reconcile.Request,
+	result reconcile.Result, err error,
+) (reconcile.Result, error) {
+	if r.backoff == nil {
+		return result, err
+	}
+
+	if err == nil || !isTransient(err) {
+		r.backoff.Forget(request)
+		return result, err
+	}
+
+	delay := r.backoff.When(request)
+	logging.FromContext(ctx).Error(err, "transient error", "requeueAfter", delay)
+
+	return reconcile.Result{RequeueAfter: delay}, nil
+}
@@ -0,0 +1,136 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestIsTransient(t *testing.T) {
+	resource := schema.GroupResource{Resource: "services"}
+	terminating := apierrors.NewForbidden(resource, "", errors.New("namespace is being terminated"))
+	terminating.ErrStatus.Details.Causes = []metav1.StatusCause{{
+		Type: corev1.NamespaceTerminatingCause,
+	}}
+
+	for _, tt := range []struct {
+		err       error
+		transient bool
+	}{
+		{err: errors.New("boom"), transient: false},
+		{err: apierrors.NewNotFound(resource, "some"), transient: false},
+		{err: apierrors.NewForbidden(resource, "some", errors.New("nope")), transient: false},
+		{err: terminating, transient: true},
+		{err: errors.WithStack(terminating), transient: true},
+		{err: apierrors.NewServerTimeout(resource, "create", 1), transient: true},
+		{err: apierrors.NewServiceUnavailable("down"), transient: true},
+		{err: apierrors.NewTimeoutError("slow", 1), transient: true},
+		{err: apierrors.NewTooManyRequests("slow down", 1), transient: true},
+	} {
+		assert.Equal(t, isTransient(tt.err), tt.transient, "%v", tt.err)
+	}
+}
+
+func TestReconcilerRequeueTransient(t *testing.T) {
+	ctx := context.Background()
+	request := reconcile.Request{}
+	request.Namespace, request.Name = "ns1", "one"
+	transient := errors.WithStack(apierrors.NewServiceUnavailable("down"))
+
+	t.Run("NoBackoff", func(t *testing.T) {
+		r := &Reconciler{}
+		assert.Assert(t, r.Backoff.rateLimiter() == nil)
+
+		result, err := r.requeueTransient(ctx, request, reconcile.Result{}, transient)
+		assert.Equal(t, err, transient)
+		assert.Equal(t, result, reconcile.Result{})
+	})
+
+	t.Run("Increasing", func(t *testing.T) {
+		r := &Reconciler{Backoff: Backoff{Base: time.Second, Max: 10 * time.Second}}
+		r.backoff = r.Backoff.rateLimiter()
+
+		var intervals []time.Duration
+		for i := 0; i < 6; i++ {
+			result, err := r.requeueTransient(ctx, request, reconcile.Result{}, transient)
+			assert.NilError(t, err)
+			intervals = append(intervals, result.RequeueAfter)
+		}
+
+		// Each interval doubles until it reaches the maximum.
+		assert.DeepEqual(t, intervals, []time.Duration{
+			1 * time.Second, 2 * time.Second, 4 * time.Second,
+			8 * time.Second, 10 * time.Second, 10 * time.Second,
+		})
+
+		// Other clusters have their own intervals.
+		other := reconcile.Request{}
+		other.Namespace, other.Name = "ns1", "two"
+		result, err := r.requeueTransient(ctx, other, reconcile.Result{}, transient)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, time.Second)
+	})
+
+	t.Run("NoMax", func(t *testing.T) {
+		r := &Reconciler{Backoff: Backoff{Base: 2 * time.Second}}
+		r.backoff = r.Backoff.rateLimiter()
+
+		// Every interval is the base when there is no larger maximum.
+		for i := 0; i < 3; i++ {
+			result, err := r.requeueTransient(ctx, request, reconcile.Result{}, transient)
+			assert.NilError(t, err)
+			assert.Equal(t, result.RequeueAfter, 2*time.Second)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		r := &Reconciler{Backoff: Backoff{Base: time.Second, Max: time.Minute}}
+		r.backoff = r.Backoff.rateLimiter()
+
+		for i := 0; i < 3; i++ {
+			_, _ = r.requeueTransient(ctx, request, reconcile.Result{}, transient)
+		}
+
+		// Success is returned unchanged and starts over.
+		expected := reconcile.Result{RequeueAfter: 5 * time.Second}
+		result, err := r.requeueTransient(ctx, request, expected, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, result, expected)
+
+		result, err = r.requeueTransient(ctx, request, reconcile.Result{}, transient)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, time.Second)
+
+		// Other errors are returned unchanged and also start over.
+		other := errors.New("boom")
+		_, err = r.requeueTransient(ctx, request, reconcile.Result{}, other)
+		assert.Equal(t, err, other)
+
+		result, err = r.requeueTransient(ctx, request, reconcile.Result{}, transient)
+		assert.NilError(t, err)
+		assert.Equal(t, result.RequeueAfter, time.Second)
+	})
+}
@@ -17,16 +17,23 @@ package postgrescluster
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/pgmonitor"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -38,7 +45,7 @@ import (
 // files (etc) that apply to the entire cluster.
 func (r *Reconciler) reconcileClusterConfigMap(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
-	pgHBAs postgres.HBAs, pgParameters postgres.Parameters,
+	pgHBAs postgres.HBAs, pgIdents postgres.Idents, pgParameters postgres.Parameters,
 ) (*corev1.ConfigMap, error) {
 	clusterConfigMap := &corev1.ConfigMap{ObjectMeta: naming.ClusterConfigMap(cluster)}
 	clusterConfigMap.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
@@ -52,9 +59,22 @@ func (r *Reconciler) reconcileClusterConfigMap(
 		})
 
 	if err == nil {
-		err = patroni.ClusterConfigMap(ctx, cluster, pgHBAs, pgParameters,
+		err = patroni.ClusterConfigMap(ctx, cluster, pgHBAs, pgIdents, pgParameters,
 			clusterConfigMap)
 	}
+
+	// PostgreSQL reads some parameters only when it starts. Record a hash of
+	// them so that instances are recreated when they change. Other changes are
+	// reloaded and do not affect the hash. See [Reconciler.reconcileInstance].
+	if err == nil {
+		var hash string
+		hash, err = safeHash32(func(w io.Writer) error {
+			_, err := io.WriteString(w, patroni.RestartParameters(cluster, pgParameters))
+			return err
+		})
+		clusterConfigMap.Annotations = naming.Merge(clusterConfigMap.Annotations,
+			map[string]string{naming.PostgresConfigHash: hash})
+	}
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, clusterConfigMap))
 	}
@@ -83,6 +103,8 @@ func (r *Reconciler) reconcileClusterPodService(
 	// Allocate no IP address (headless) and match any Pod with the cluster
 	// label, regardless of its readiness. Not particularly useful by itself, but
 	// this allows a properly configured Pod to get a DNS record based on its name.
+	// Instance StatefulSets use this Service, so each instance has a stable DNS
+	// name. See [naming.InstanceHostname].
 	// - https://docs.k8s.io/concepts/services-networking/service/#headless-services
 	// - https://docs.k8s.io/concepts/services-networking/dns-pod-service/#pods
 	clusterPodService.Spec.ClusterIP = corev1.ClusterIPNone
@@ -141,18 +163,27 @@ func (r *Reconciler) generateClusterPrimaryService(
 	// - https://docs.k8s.io/concepts/services-networking/service/#services-without-selectors
 	service.Spec.ClusterIP = corev1.ClusterIPNone
 	service.Spec.Selector = nil
+	addServiceIPFamilies(postgresServiceSpec(cluster), service)
 
+	// Clients connect to the leader Service on the same port as this one. That
+	// Service forwards connections to the PostgreSQL ContainerPort.
 	service.Spec.Ports = []corev1.ServicePort{{
 		Name:       naming.PortPostgreSQL,
-		Port:       *cluster.Spec.Port,
+		Port:       postgres.ServicePort(cluster),
 		Protocol:   corev1.ProtocolTCP,
 		TargetPort: intstr.FromString(naming.PortPostgreSQL),
 	}}
 
-	// Resolve to the ClusterIP for which Patroni has configured the Endpoints.
-	endpoints.Subsets = []corev1.EndpointSubset{{
-		Addresses: []corev1.EndpointAddress{{IP: leader.Spec.ClusterIP}},
-	}}
+	// Resolve to the ClusterIPs for which Patroni has configured the Endpoints.
+	// A dual-stack leader Service has one of each IP family.
+	endpoints.Subsets = []corev1.EndpointSubset{{}}
+	for _, ip := range leader.Spec.ClusterIPs {
+		endpoints.Subsets[0].Addresses = append(endpoints.Subsets[0].Addresses,
+			corev1.EndpointAddress{IP: ip})
+	}
+	if len(endpoints.Subsets[0].Addresses) == 0 {
+		endpoints.Subsets[0].Addresses = []corev1.EndpointAddress{{IP: leader.Spec.ClusterIP}}
+	}
 
 	// Copy the EndpointPorts from the ServicePorts.
 	for _, sp := range service.Spec.Ports {
@@ -216,6 +247,13 @@ func (r *Reconciler) generateClusterReplicaService(
 		naming.LabelCluster: cluster.Name,
 		naming.LabelRole:    naming.RolePatroniReplica,
 	}
+	addServiceIPFamilies(postgresServiceSpec(cluster), service)
+
+	// Leave out replicas that are too far behind, when there is a limit.
+	// See Reconciler.reconcileReplicationLagLabels.
+	if maxReplicationLag(cluster) != nil {
+		service.Spec.Selector[naming.LabelReplicationLag] = naming.ReplicationLagWithinLimit
+	}
 
 	// The TargetPort must be the name (not the number) of the PostgreSQL
 	// ContainerPort. This name allows the port number to differ between Pods,
@@ -247,14 +285,153 @@ func (r *Reconciler) reconcileClusterReplicaService(
 	return err
 }
 
+// maxReplicationLag returns the most replication lag, in bytes, that replicas
+// of cluster may have and still be part of the replica Service. It returns nil
+// when there is no limit.
+func maxReplicationLag(cluster *v1beta1.PostgresCluster) *int64 {
+	if cluster.Spec.ReadReplicaService != nil {
+		return cluster.Spec.ReadReplicaService.MaxLagBytes
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={patch}
+
+// reconcileReplicationLagLabels labels each instance Pod according to the
+// replication lag that Patroni reports in cluster.Status.InstanceSets. The
+// replica Service selects only those Pods that are within the limit. Pods that
+// Patroni did not report on keep their labels until it does.
+func (r *Reconciler) reconcileReplicationLagLabels(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, observed *observedInstances,
+) (reconcile.Result, error) {
+	limit := maxReplicationLag(cluster)
+	if limit == nil {
+		return reconcile.Result{}, nil
+	}
+
+	var err error
+	for _, set := range cluster.Status.InstanceSets {
+		for _, status := range set.Instances {
+			instance := observed.byName[status.Name]
+			if err != nil || instance == nil || len(instance.Pods) != 1 {
+				continue
+			}
+			pod := instance.Pods[0]
+
+			// The primary is never excluded, and neither is a replica that
+			// reports less lag than the limit. Lag is unknown to Patroni when
+			// a replica is not replicating; consider that too far behind.
+			value := naming.ReplicationLagExceeded
+			if pod.Labels[naming.LabelRole] == naming.RolePatroniLeader ||
+				status.Role == "leader" || status.Role == "standby_leader" ||
+				(status.ReplicationLagBytes != nil && *status.ReplicationLagBytes <= *limit) {
+				value = naming.ReplicationLagWithinLimit
+			}
+
+			if pod.Labels[naming.LabelReplicationLag] != value {
+				patch := kubeapi.NewMergePatch()
+				patch.Add("metadata", "labels", naming.LabelReplicationLag)(value)
+				err = errors.WithStack(r.patch(ctx, pod, patch))
+			}
+		}
+	}
+
+	// Patroni does not notify us when lag changes, so look again soon.
+	return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+}
+
+// validateStandby returns an error when cluster asks to be a standby without
+// saying what to follow. Such a cluster would be created as a non-standby.
+func validateStandby(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	if cluster.Spec.Standby != nil &&
+		cluster.Spec.Standby.Enabled &&
+		cluster.Spec.Standby.Host == "" &&
+		cluster.Spec.Standby.RepoName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "standby"), cluster.Name,
+			"Standby requires a host or repoName to be enabled"))
+	}
+
+	return errs
+}
+
+// validateDataSource returns an error when cluster has more than one data
+// source. Only one of them would be used.
+func validateDataSource(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec", "dataSource")
+
+	if source := cluster.Spec.DataSource; source != nil &&
+		source.PostgresCluster != nil && source.PGBackRest != nil {
+		errs = append(errs, field.Forbidden(path,
+			"only one of pgbackrest or postgresCluster may be specified"))
+	}
+
+	return errs
+}
+
+// validateImages returns an error for every container image that cluster
+// needs but neither specifies nor has a default for. There is no implicit
+// registry, so images must come from the spec or from the RELATED_IMAGE_*
+// environment of the operator; this matters most in air-gapped installations.
+func validateImages(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	required := func(path *field.Path, image, key string) {
+		if image == "" {
+			errs = append(errs, field.Required(path,
+				"required when the "+key+" environment variable is not set"))
+		}
+	}
+
+	key := "RELATED_IMAGE_POSTGRES_" + fmt.Sprint(cluster.Spec.PostgresVersion)
+	if version := cluster.Spec.PostGISVersion; version != "" {
+		key += "_GIS_" + version
+	}
+	required(spec.Child("image"), config.PostgresContainerImage(cluster), key)
+
+	required(spec.Child("backups", "pgbackrest", "image"),
+		config.PGBackRestContainerImage(cluster), "RELATED_IMAGE_PGBACKREST")
+
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		path := spec.Child("proxy", "pgBouncer")
+		required(path.Child("image"),
+			config.PGBouncerContainerImage(cluster), "RELATED_IMAGE_PGBOUNCER")
+
+		if cluster.Spec.Proxy.PGBouncer.Exporter != nil {
+			required(path.Child("exporter", "image"),
+				config.PGBouncerExporterContainerImage(cluster),
+				"RELATED_IMAGE_PGBOUNCER_EXPORTER")
+		}
+	}
+
+	if cluster.Spec.UserInterface != nil && cluster.Spec.UserInterface.PGAdmin != nil {
+		required(spec.Child("userInterface", "pgAdmin", "image"),
+			config.PGAdminContainerImage(cluster), "RELATED_IMAGE_PGADMIN")
+	}
+
+	if pgmonitor.ExporterEnabled(cluster) {
+		required(spec.Child("monitoring", "pgmonitor", "exporter", "image"),
+			config.PGExporterContainerImage(cluster), "RELATED_IMAGE_PGEXPORTER")
+	}
+
+	if cluster.Spec.Upgrade != nil && cluster.Spec.Upgrade.Enabled {
+		required(spec.Child("upgrade", "image"),
+			config.PGUpgradeContainerImage(cluster), "RELATED_IMAGE_PGUPGRADE")
+	}
+
+	return errs
+}
+
 // reconcileDataSource is responsible for reconciling the data source for a PostgreSQL cluster.
 // This involves ensuring the PostgreSQL data directory for the cluster is properly populated
 // prior to bootstrapping the cluster, specifically according to any data source configured in the
 // PostgresCluster spec.
-// TODO(benjaminjb): Right now the spec will accept a dataSource with both a PostgresCluster and
-// a PGBackRest section, but the code will only honor the PostgresCluster in that case; this would
-// be better handled with a webhook to reject a spec with both `dataSource.postgresCluster` and
-// `dataSource.pgbackrest` fields
+// NOTE(benjaminjb): The spec accepts a dataSource with both a PostgresCluster and a PGBackRest
+// section, but the code will only honor the PostgresCluster in that case. The Validator webhook
+// rejects such a spec; see validateDataSource.
 func (r *Reconciler) reconcileDataSource(ctx context.Context,
 	cluster *v1beta1.PostgresCluster, observed *observedInstances,
 	clusterVolumes []corev1.PersistentVolumeClaim,
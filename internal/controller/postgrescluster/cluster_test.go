@@ -25,6 +25,7 @@ import (
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -35,6 +36,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -596,6 +598,72 @@ func TestCustomAnnotations(t *testing.T) {
 	})
 }
 
+func TestValidateImages(t *testing.T) {
+	for _, key := range []string{
+		"RELATED_IMAGE_POSTGRES_14",
+		"RELATED_IMAGE_POSTGRES_14_GIS_3.1",
+		"RELATED_IMAGE_PGBACKREST",
+		"RELATED_IMAGE_PGBOUNCER",
+		"RELATED_IMAGE_PGBOUNCER_EXPORTER",
+		"RELATED_IMAGE_PGADMIN",
+		"RELATED_IMAGE_PGEXPORTER",
+	} {
+		t.Setenv(key, "")
+	}
+
+	t.Run("Required", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.PostgresVersion = 14
+
+		errs := validateImages(cluster)
+		assert.Equal(t, len(errs), 2)
+		assert.Equal(t, errs[0].Field, "spec.image")
+		assert.Assert(t, cmp.Contains(errs[0].Detail, "RELATED_IMAGE_POSTGRES_14 "))
+		assert.Equal(t, errs[1].Field, "spec.backups.pgbackrest.image")
+
+		cluster.Spec.PostGISVersion = "3.1"
+		errs = validateImages(cluster)
+		assert.Assert(t, cmp.Contains(errs[0].Detail, "RELATED_IMAGE_POSTGRES_14_GIS_3.1 "))
+	})
+
+	t.Run("Optional", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.Image = "postgres"
+		cluster.Spec.Backups.PGBackRest.Image = "pgbackrest"
+		cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{
+			PGBouncer: &v1beta1.PGBouncerPodSpec{
+				Exporter: &v1beta1.PGBouncerExporterSpec{},
+			},
+		}
+		cluster.Spec.UserInterface = &v1beta1.UserInterfaceSpec{
+			PGAdmin: &v1beta1.PGAdminPodSpec{},
+		}
+		cluster.Spec.Monitoring = &v1beta1.MonitoringSpec{
+			PGMonitor: &v1beta1.PGMonitorSpec{
+				Exporter: &v1beta1.ExporterSpec{},
+			},
+		}
+
+		var fields []string
+		for _, err := range validateImages(cluster) {
+			fields = append(fields, err.Field)
+		}
+		assert.DeepEqual(t, fields, []string{
+			"spec.proxy.pgBouncer.image",
+			"spec.proxy.pgBouncer.exporter.image",
+			"spec.userInterface.pgAdmin.image",
+			"spec.monitoring.pgmonitor.exporter.image",
+		})
+
+		// Defaults from the environment are enough.
+		t.Setenv("RELATED_IMAGE_PGBOUNCER", "pgbouncer")
+		t.Setenv("RELATED_IMAGE_PGBOUNCER_EXPORTER", "pgbouncer-exporter")
+		t.Setenv("RELATED_IMAGE_PGADMIN", "pgadmin")
+		t.Setenv("RELATED_IMAGE_PGEXPORTER", "pgexporter")
+		assert.Assert(t, len(validateImages(cluster)) == 0)
+	})
+}
+
 func TestGenerateClusterPrimaryService(t *testing.T) {
 	_, cc := setupKubernetes(t)
 	require.ParallelCapacity(t, 0)
@@ -694,6 +762,64 @@ subsets:
 		assert.Equal(t, len(service.Spec.ExternalIPs), 0)
 		assert.Equal(t, service.Spec.ExternalName, "")
 	})
+
+	t.Run("CustomPort", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{Port: initialize.Int32(15432)}
+
+		service, endpoints, err := reconciler.generateClusterPrimaryService(cluster, leader)
+		assert.NilError(t, err)
+
+		// The port differs from that of PostgreSQL, and the target port is
+		// still the PostgreSQL ContainerPort.
+		assert.Assert(t, marshalMatches(service.Spec.Ports, `
+- name: postgres
+  port: 15432
+  protocol: TCP
+  targetPort: postgres
+		`))
+
+		// Clients reach the leader Service on the same port.
+		assert.Assert(t, marshalMatches(endpoints.Subsets, `
+- addresses:
+  - ip: 1.9.8.3
+  ports:
+  - name: postgres
+    port: 15432
+    protocol: TCP
+		`))
+	})
+
+	t.Run("DualStack", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		policy := corev1.IPFamilyPolicyPreferDualStack
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
+			IPFamilyPolicy: &policy,
+			IPFamilies:     []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol},
+		}}
+
+		leader := leader.DeepCopy()
+		leader.Spec.ClusterIP = "2001:db8::10"
+		leader.Spec.ClusterIPs = []string{"2001:db8::10", "1.9.8.3"}
+
+		service, endpoints, err := reconciler.generateClusterPrimaryService(cluster, leader)
+		assert.NilError(t, err)
+
+		assert.Equal(t, *service.Spec.IPFamilyPolicy, corev1.IPFamilyPolicyPreferDualStack)
+		assert.DeepEqual(t, service.Spec.IPFamilies,
+			[]corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol})
+
+		// Resolves to every ClusterIP of the leader Service.
+		assert.Assert(t, marshalMatches(endpoints.Subsets, `
+- addresses:
+  - ip: 2001:db8::10
+  - ip: 1.9.8.3
+  ports:
+  - name: postgres
+    port: 2600
+    protocol: TCP
+		`))
+	})
 }
 
 func TestReconcileClusterPrimaryService(t *testing.T) {
@@ -763,6 +889,21 @@ selector:
 type: ClusterIP
 	`))
 
+	t.Run("PrimaryPort", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{Port: initialize.Int32(15432)}
+
+		// The port of the primary Services does not apply to replicas.
+		service, err := reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, marshalMatches(service.Spec.Ports, `
+- name: postgres
+  port: 9876
+  protocol: TCP
+  targetPort: postgres
+		`))
+	})
+
 	t.Run("AnnotationsLabels", func(t *testing.T) {
 		cluster := cluster.DeepCopy()
 		cluster.Spec.Metadata = &v1beta1.Metadata{
@@ -791,4 +932,230 @@ postgres-operator.crunchydata.com/cluster: pg2
 postgres-operator.crunchydata.com/role: replica
 		`))
 	})
+
+	t.Run("MaxLagBytes", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.ReadReplicaService = &v1beta1.PostgresReadReplicaServiceSpec{
+			MaxLagBytes: initialize.Int64(1024 * 1024),
+		}
+
+		service, err := reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+
+		// Only replicas within the limit are selected.
+		assert.Assert(t, marshalMatches(service.Spec.Selector, `
+postgres-operator.crunchydata.com/cluster: pg2
+postgres-operator.crunchydata.com/replication-lag: within-limit
+postgres-operator.crunchydata.com/role: replica
+		`))
+	})
+
+	t.Run("IPFamilies", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		policy := corev1.IPFamilyPolicySingleStack
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
+			IPFamilyPolicy: &policy,
+			IPFamilies:     []corev1.IPFamily{corev1.IPv6Protocol},
+		}}
+
+		service, err := reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+
+		// The replica Service follows the settings of the primary Service.
+		assert.Assert(t, marshalMatches(service.Spec, `
+ipFamilies:
+- IPv6
+ipFamilyPolicy: SingleStack
+ports:
+- name: postgres
+  port: 9876
+  protocol: TCP
+  targetPort: postgres
+selector:
+  postgres-operator.crunchydata.com/cluster: pg2
+  postgres-operator.crunchydata.com/role: replica
+type: ClusterIP
+		`))
+	})
+}
+
+func TestReconcileClusterReplicaService(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	reconciler := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, cc.Create(ctx, cluster))
+	cluster.Default()
+
+	assert.NilError(t, reconciler.reconcileClusterReplicaService(ctx, cluster))
+
+	service := &corev1.Service{ObjectMeta: naming.ClusterReplicaService(cluster)}
+	assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(service), service))
+
+	// Patroni sets the role label of each instance Pod.
+	pod := func(name, role string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = cluster.Namespace, name
+		pod.Labels = map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    role,
+		}
+		pod.Spec.Containers = []corev1.Container{{Name: "database", Image: "postgres"}}
+		assert.NilError(t, cc.Create(ctx, pod))
+		return pod
+	}
+	one := pod("one", naming.RolePatroniLeader)
+	two := pod("two", naming.RolePatroniReplica)
+	pod("other", "")
+
+	selected := func(t *testing.T) []string {
+		pods := &corev1.PodList{}
+		assert.NilError(t, cc.List(ctx, pods,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabels(service.Spec.Selector)))
+
+		var names []string
+		for _, pod := range pods.Items {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	t.Run("ExcludesPrimary", func(t *testing.T) {
+		assert.DeepEqual(t, selected(t), []string{"two"})
+	})
+
+	t.Run("FollowsRoles", func(t *testing.T) {
+		// Swap the roles the way a switchover does.
+		one.Labels[naming.LabelRole] = naming.RolePatroniReplica
+		two.Labels[naming.LabelRole] = naming.RolePatroniLeader
+		assert.NilError(t, cc.Update(ctx, one))
+		assert.NilError(t, cc.Update(ctx, two))
+
+		assert.DeepEqual(t, selected(t), []string{"one"})
+	})
+}
+
+func TestReconcileReplicationLagLabels(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+	cluster.Name = "pg2"
+
+	pod := func(name, role string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = cluster.Namespace, name+"-0"
+		pod.Labels = map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstance:    name,
+			naming.LabelInstanceSet: "00",
+			naming.LabelRole:        role,
+		}
+		return pod
+	}
+	// Patches change the Pods of observedInstances, so each test needs its own.
+	setup := func() (*Reconciler, *observedInstances) {
+		pods := []corev1.Pod{
+			*pod("primary", naming.RolePatroniLeader),
+			*pod("behind", naming.RolePatroniReplica),
+			*pod("current", naming.RolePatroniReplica),
+		}
+		builder := fake.NewClientBuilder()
+		for i := range pods {
+			builder = builder.WithObjects(pods[i].DeepCopy())
+		}
+		return &Reconciler{Client: builder.Build()}, newObservedInstances(cluster, nil, pods)
+	}
+
+	lagged := func(t *testing.T, r *Reconciler, name string) string {
+		pod := &corev1.Pod{}
+		assert.NilError(t, r.Client.Get(ctx,
+			client.ObjectKey{Namespace: cluster.Namespace, Name: name + "-0"}, pod))
+		return pod.Labels[naming.LabelReplicationLag]
+	}
+
+	cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{{
+		Name: "00",
+		Instances: []v1beta1.PostgresInstanceStatus{
+			{Name: "behind", Role: "replica", ReplicationLagBytes: initialize.Int64(20 << 20)},
+			{Name: "current", Role: "replica", ReplicationLagBytes: initialize.Int64(0)},
+			{Name: "primary", Role: "leader"},
+		},
+	}}
+
+	t.Run("NoLimit", func(t *testing.T) {
+		r, observed := setup()
+
+		result, err := r.reconcileReplicationLagLabels(ctx, cluster, observed)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{})
+
+		assert.Equal(t, lagged(t, r, "behind"), "")
+		assert.Equal(t, lagged(t, r, "current"), "")
+		assert.Equal(t, lagged(t, r, "primary"), "")
+	})
+
+	cluster.Spec.ReadReplicaService = &v1beta1.PostgresReadReplicaServiceSpec{
+		MaxLagBytes: initialize.Int64(10 * 1024 * 1024),
+	}
+
+	t.Run("Lagging", func(t *testing.T) {
+		r, observed := setup()
+
+		result, err := r.reconcileReplicationLagLabels(ctx, cluster, observed)
+		assert.NilError(t, err)
+		assert.Assert(t, result.RequeueAfter > 0, "expected to check lag again")
+
+		assert.Equal(t, lagged(t, r, "behind"), naming.ReplicationLagExceeded)
+		assert.Equal(t, lagged(t, r, "current"), naming.ReplicationLagWithinLimit)
+		assert.Equal(t, lagged(t, r, "primary"), naming.ReplicationLagWithinLimit)
+
+		t.Run("CaughtUp", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Status.InstanceSets[0].Instances[0].ReplicationLagBytes = initialize.Int64(2 << 20)
+
+			_, err := r.reconcileReplicationLagLabels(ctx, cluster, observed)
+			assert.NilError(t, err)
+
+			assert.Equal(t, lagged(t, r, "behind"), naming.ReplicationLagWithinLimit)
+		})
+	})
+
+	t.Run("UnknownLag", func(t *testing.T) {
+		r, observed := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Status.InstanceSets[0].Instances[1].ReplicationLagBytes = nil
+
+		_, err := r.reconcileReplicationLagLabels(ctx, cluster, observed)
+		assert.NilError(t, err)
+
+		assert.Equal(t, lagged(t, r, "current"), naming.ReplicationLagExceeded)
+	})
+
+	t.Run("PrimaryNeverExcluded", func(t *testing.T) {
+		r, observed := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Status.InstanceSets[0].Instances[2].ReplicationLagBytes = initialize.Int64(50 << 20)
+
+		_, err := r.reconcileReplicationLagLabels(ctx, cluster, observed)
+		assert.NilError(t, err)
+
+		assert.Equal(t, lagged(t, r, "primary"), naming.ReplicationLagWithinLimit)
+	})
+
+	t.Run("NotReported", func(t *testing.T) {
+		r, observed := setup()
+		cluster := cluster.DeepCopy()
+		cluster.Status.InstanceSets[0].Instances = nil
+
+		_, err := r.reconcileReplicationLagLabels(ctx, cluster, observed)
+		assert.NilError(t, err)
+
+		assert.Equal(t, lagged(t, r, "behind"), "")
+	})
 }
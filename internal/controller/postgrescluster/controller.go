@@ -19,8 +19,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/trace"
@@ -32,8 +31,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -43,9 +42,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
 	"github.com/crunchydata/postgres-operator/internal/pgaudit"
 	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
-	"github.com/crunchydata/postgres-operator/internal/pgbouncer"
 	"github.com/crunchydata/postgres-operator/internal/pgmonitor"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
@@ -57,6 +57,10 @@ const (
 	ControllerName = "postgrescluster-controller"
 )
 
+// defaultWorkers is how many PostgresClusters can be reconciled at the same
+// time when the Reconciler does not specify.
+const defaultWorkers = 2
+
 // Reconciler holds resources for the PostgresCluster reconciler
 type Reconciler struct {
 	Client      client.Client
@@ -65,6 +69,26 @@ type Reconciler struct {
 	Tracer      trace.Tracer
 	IsOpenShift bool
 
+	// CertificateExpiryWarning is how long before a certificate expires to
+	// warn about it. When zero, defaultCertificateExpiryWarning is used.
+	CertificateExpiryWarning time.Duration
+
+	// Metrics measures the steps of Reconcile. When nil, nothing is measured.
+	Metrics *Metrics
+
+	// Workers is how many PostgresClusters can be reconciled at the same time.
+	// When zero, defaultWorkers is used.
+	Workers int
+
+	// Backoff is how long to wait before reconciling a PostgresCluster again
+	// after transient errors. When zero, these errors are returned to
+	// controller-runtime like any other.
+	Backoff Backoff
+	backoff workqueue.RateLimiter
+
+	// expiring remembers certificates that have been reported as expiring.
+	expiring *expiringCertificates
+
 	PodExec func(
 		namespace, pod, container string,
 		stdin io.Reader, stdout, stderr io.Writer, command ...string,
@@ -78,6 +102,14 @@ type Reconciler struct {
 // Reconcile reconciles a ConfigMap in a namespace managed by the PostgreSQL Operator
 func (r *Reconciler) Reconcile(
 	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
+) {
+	result, err := r.reconcile(ctx, request)
+	return r.requeueTransient(ctx, request, result, err)
+}
+
+// reconcile performs each step of Reconcile and returns the first error.
+func (r *Reconciler) reconcile(
+	ctx context.Context, request reconcile.Request) (reconcile.Result, error,
 ) {
 	ctx, span := r.Tracer.Start(ctx, "Reconcile")
 	log := logging.FromContext(ctx)
@@ -142,22 +174,55 @@ func (r *Reconciler) Reconcile(
 		return *result, nil
 	}
 
-	// Perform initial validation on a cluster
-	// TODO: Move this to a defaulting (mutating admission) webhook
-	// to leverage regular validation.
-	if cluster.Spec.Standby != nil &&
-		cluster.Spec.Standby.Enabled &&
-		cluster.Spec.Standby.Host == "" &&
-		cluster.Spec.Standby.RepoName == "" {
-		// When a standby cluster is requested but a repoName or host is not provided
-		// the cluster will be created as a non-standby. Reject any clusters with
-		// this configuration and provide an event
-		path := field.NewPath("spec", "standby")
-		err := field.Invalid(path, cluster.Name, "Standby requires a host or repoName to be enabled")
+	// When the cluster asks for a dry run, log what the steps below would change
+	// rather than change it. The finalizer above is still set, so a cluster that
+	// is deleted during a dry run is still cleaned up.
+	if _, ok := cluster.GetAnnotations()[naming.DryRun]; ok {
+		if _, ok := r.Client.(*dryRunClient); !ok {
+			changes, err := r.dryRun(ctx, request)
+			for _, change := range changes {
+				log.Info("dry run",
+					"operation", change.Operation, "kind", change.Kind,
+					"namespace", change.Namespace, "name", change.Name,
+					"patch", change.Patch, "command", change.Command,
+					"event", change.Event)
+			}
+			return result, err
+		}
+	}
+
+	// Perform initial validation on a cluster. The Validator webhook rejects
+	// these before they are stored, when it is enabled.
+	//
+	// Names can become too long after a cluster is bootstrapped, e.g. when a
+	// backup schedule is added to a long-named repository. Objects with names
+	// that are too long are skipped, so only refuse to bootstrap.
+	if errs := naming.ValidateClusterNames(cluster); len(errs) > 0 {
+		err := errs.ToAggregate()
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidName",
+			err.Error())
+		if !patroni.ClusterBootstrapped(cluster) {
+			return result, err
+		}
+	}
+	if errs := validateStandby(cluster); len(errs) > 0 {
+		err := errs[0]
 		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidStandbyConfiguration",
 			err.Error())
 		return result, err
 	}
+	if errs := validatePostgresVersion(cluster); len(errs) > 0 {
+		err := errs.ToAggregate()
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidPostgresVersion",
+			err.Error())
+		return result, err
+	}
+	if errs := validateImages(cluster); len(errs) > 0 {
+		err := errs.ToAggregate()
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "MissingRequiredImage",
+			err.Error())
+		return result, err
+	}
 
 	var (
 		clusterConfigMap         *corev1.ConfigMap
@@ -202,22 +267,39 @@ func (r *Reconciler) Reconcile(
 
 			ObservedGeneration: cluster.GetGeneration(),
 		})
+		log.V(1).Info("paused")
 		return patchClusterStatus()
 	} else {
 		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1beta1.PostgresClusterProgressing)
 	}
 
-	pgHBAs := postgres.NewHBAs()
-	pgmonitor.PostgreSQLHBAs(cluster, &pgHBAs)
-	pgbouncer.PostgreSQL(cluster, &pgHBAs)
+	// Hold the cluster at its current PostgreSQL version until a major upgrade
+	// is acknowledged. An acknowledged upgrade shuts the cluster down first.
+	upgradeVersion, returnEarly := r.prepareMajorUpgrade(cluster)
+	if returnEarly {
+		return patchClusterStatus()
+	}
+
+	pgHBAs := r.generatePostgresHBAs(cluster)
+	pgIdents := r.generatePostgresIdents(cluster)
+
+	pgParameters := postgresParameters(cluster)
+
+	if errs := patroni.ValidateDynamicConfiguration(cluster, pgParameters); len(errs) > 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidPatroniConfiguration",
+			errs.ToAggregate().Error())
+	}
+	r.warnSynchronousReplication(cluster)
 
-	pgParameters := postgres.NewParameters()
-	pgaudit.PostgreSQLParameters(&pgParameters)
-	pgbackrest.PostgreSQL(cluster, &pgParameters)
-	pgmonitor.PostgreSQLParameters(cluster, &pgParameters)
+	if errs := patroni.ValidateProbeTiming(cluster); len(errs) > 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "SlowLivenessProbe",
+			errs.ToAggregate().Error())
+	}
 
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileRootCertificate")
 		rootCA, err = r.reconcileRootCertificate(ctx, cluster)
+		done(err)
 	}
 
 	if err == nil {
@@ -228,39 +310,72 @@ func (r *Reconciler) Reconcile(
 		// required Jobs are running, after which it will indicate that an early
 		// return is no longer needed, and reconciliation can proceed normally.
 		var returnEarly bool
+		ctx, done := r.step(ctx, "reconcileDirMoveJobs")
 		returnEarly, err = r.reconcileDirMoveJobs(ctx, cluster)
+		done(err)
 		if err != nil || returnEarly {
 			return patchClusterStatus()
 		}
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "observePersistentVolumeClaims")
 		clusterVolumes, err = r.observePersistentVolumeClaims(ctx, cluster)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "configureExistingPVCs")
 		clusterVolumes, err = r.configureExistingPVCs(ctx, cluster, clusterVolumes)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "observeInstances")
 		instances, err = r.observeInstances(ctx, cluster)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniStatus")
 		err = updateResult(r.reconcilePatroniStatus(ctx, cluster, instances))
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniSwitchover")
 		err = r.reconcilePatroniSwitchover(ctx, cluster, instances)
+		done(err)
+	}
+	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniFailover")
+		err = r.reconcilePatroniFailover(ctx, cluster, instances)
+		done(err)
 	}
 	// reconcile the Pod service before reconciling any data source in case it is necessary
 	// to start Pods during data source reconciliation that require network connections (e.g.
 	// if it is necessary to start a dedicated repo host to bootstrap a new cluster using its
 	// own existing backups).
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileClusterPodService")
 		clusterPodService, err = r.reconcileClusterPodService(ctx, cluster)
+		done(err)
 	}
 	// reconcile the RBAC resources before reconciling any data source in case
 	// restore/move Job pods require the ServiceAccount to access any data source.
 	// e.g., we are restoring from an S3 source using an IAM for access
 	// - https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts-technical-overview.html
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileRBACResources")
 		instanceServiceAccount, err = r.reconcileRBACResources(ctx, cluster)
+		done(err)
+	}
+	if err == nil {
+		// Once every instance has stopped, further reconciliation will not
+		// occur until the pg_upgrade Job has completed.
+		var returnEarly bool
+		ctx, done := r.step(ctx, "reconcileMajorUpgrade")
+		returnEarly, err = r.reconcileMajorUpgrade(ctx, cluster, instances, clusterVolumes,
+			upgradeVersion)
+		done(err)
+		if err != nil || returnEarly {
+			return patchClusterStatus()
+		}
 	}
 	// First handle reconciling any data source configured for the PostgresCluster.  This includes
 	// reconciling the data source defined to bootstrap a new cluster, as well as a reconciling
@@ -273,74 +388,124 @@ func (r *Reconciler) Reconcile(
 		// which it will indicate that an early return is no longer needed, and reconciliation
 		// can proceed normally.
 		var returnEarly bool
+		ctx, done := r.step(ctx, "reconcileDataSource")
 		returnEarly, err = r.reconcileDataSource(ctx, cluster, instances, clusterVolumes, rootCA)
+		done(err)
 		if err != nil || returnEarly {
 			return patchClusterStatus()
 		}
 	}
 	if err == nil {
-		clusterConfigMap, err = r.reconcileClusterConfigMap(ctx, cluster, pgHBAs, pgParameters)
+		ctx, done := r.step(ctx, "reconcileClusterConfigMap")
+		clusterConfigMap, err = r.reconcileClusterConfigMap(ctx, cluster, pgHBAs, pgIdents, pgParameters)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileReplicationSecret")
 		clusterReplicationSecret, err = r.reconcileReplicationSecret(ctx, cluster, rootCA)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniLeaderLease")
 		patroniLeaderService, err = r.reconcilePatroniLeaderLease(ctx, cluster)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileClusterPrimaryService")
 		primaryService, err = r.reconcileClusterPrimaryService(ctx, cluster, patroniLeaderService)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileClusterReplicaService")
 		err = r.reconcileClusterReplicaService(ctx, cluster)
+		done(err)
+	}
+	if err == nil {
+		ctx, done := r.step(ctx, "reconcileReplicationLagLabels")
+		err = updateResult(r.reconcileReplicationLagLabels(ctx, cluster, instances))
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileClusterCertificate")
 		primaryCertificate, err = r.reconcileClusterCertificate(ctx, rootCA, cluster, primaryService)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniDistributedConfiguration")
 		err = r.reconcilePatroniDistributedConfiguration(ctx, cluster)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniDynamicConfiguration")
 		err = r.reconcilePatroniDynamicConfiguration(ctx, cluster, instances, pgHBAs, pgParameters)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileMonitoringSecret")
 		monitoringSecret, err = r.reconcileMonitoringSecret(ctx, cluster)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileExporterWebConfig")
 		exporterWebConfig, err = r.reconcileExporterWebConfig(ctx, cluster)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileInstanceSets")
 		err = r.reconcileInstanceSets(
 			ctx, cluster, clusterConfigMap, clusterReplicationSecret,
 			rootCA, clusterPodService, instanceServiceAccount, instances,
 			patroniLeaderService, primaryCertificate, clusterVolumes, exporterWebConfig)
+		done(err)
 	}
 
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePostgresDatabases")
 		err = r.reconcilePostgresDatabases(ctx, cluster, instances)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePostgresUsers")
 		err = r.reconcilePostgresUsers(ctx, cluster, instances)
+		done(err)
 	}
 
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePGBackRest")
 		err = updateResult(r.reconcilePGBackRest(ctx, cluster, instances, rootCA))
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePGBouncer")
 		err = r.reconcilePGBouncer(ctx, cluster, instances, primaryCertificate, rootCA)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePGMonitor")
 		err = r.reconcilePGMonitor(ctx, cluster, instances, monitoringSecret)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcileDatabaseInitSQL")
 		err = r.reconcileDatabaseInitSQL(ctx, cluster, instances)
+		done(err)
 	}
 	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePGAdmin")
 		err = r.reconcilePGAdmin(ctx, cluster)
+		done(err)
+	}
+	if err == nil {
+		ctx, done := r.step(ctx, "reconcilePatroniRestart")
+		err = r.reconcilePatroniRestart(ctx, cluster, instances)
+		done(err)
 	}
 	if err == nil {
 		// This is after [Reconciler.rolloutInstances] to ensure that recreating
 		// Pods takes precedence.
+		ctx, done := r.step(ctx, "handlePatroniRestarts")
 		err = r.handlePatroniRestarts(ctx, cluster, instances)
+		done(err)
 	}
 
 	// at this point everything reconciled successfully, and we can update the
@@ -352,6 +517,23 @@ func (r *Reconciler) Reconcile(
 	return patchClusterStatus()
 }
 
+// step begins the step of Reconcile called name as a child span of any span in
+// ctx. Pass the returned context to the step and call done with its result.
+func (r *Reconciler) step(ctx context.Context, name string) (
+	_ context.Context, done func(error),
+) {
+	ctx, span := r.Tracer.Start(ctx, name)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		r.Metrics.observeStep(name, time.Since(start), err)
+	}
+}
+
 // deleteControlled safely deletes object when it is controlled by cluster.
 func (r *Reconciler) deleteControlled(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, object client.Object,
@@ -417,6 +599,35 @@ func (r *Reconciler) setOwnerReference(
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
 
+// postgresParameters returns the PostgreSQL parameters that the operator
+// requires of cluster.
+func postgresParameters(cluster *v1beta1.PostgresCluster) postgres.Parameters {
+	parameters := postgres.NewParameters()
+	postgres.TLSParameters(cluster, &parameters)
+	patroni.PostgreSQL(cluster, &parameters)
+	pgaudit.PostgreSQLParameters(&parameters)
+	pgbackrest.PostgreSQL(cluster, &parameters)
+	pgmonitor.PostgreSQLParameters(cluster, &parameters)
+	postgres.ExtensionParameters(cluster, &parameters)
+	return parameters
+}
+
+// controllerOptions returns the options of the PostgresCluster controller.
+func (r *Reconciler) controllerOptions() (controller.Options, error) {
+	var opts controller.Options
+
+	switch {
+	case r.Workers < 0:
+		return opts, errors.Errorf("workers must be at least 1, got %d", r.Workers)
+	case r.Workers == 0:
+		opts.MaxConcurrentReconciles = defaultWorkers
+	default:
+		opts.MaxConcurrentReconciles = r.Workers
+	}
+
+	return opts, nil
+}
+
 // SetupWithManager adds the PostgresCluster controller to the provided runtime manager
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	if r.PodExec == nil {
@@ -427,19 +638,16 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 		}
 	}
 
-	var opts controller.Options
-
-	// TODO(cbandy): Move this to main with controller-runtime v0.9+
-	// - https://github.com/kubernetes-sigs/controller-runtime/commit/82fc2564cf
-	if s := os.Getenv("PGO_WORKERS"); s != "" {
-		if i, err := strconv.Atoi(s); err == nil && i > 0 {
-			opts.MaxConcurrentReconciles = i
-		} else {
-			mgr.GetLogger().Error(err, "PGO_WORKERS must be a positive number")
-		}
+	if r.backoff == nil {
+		r.backoff = r.Backoff.rateLimiter()
+	}
+	if r.expiring == nil {
+		r.expiring = new(expiringCertificates)
 	}
-	if opts.MaxConcurrentReconciles == 0 {
-		opts.MaxConcurrentReconciles = 2
+
+	opts, err := r.controllerOptions()
+	if err != nil {
+		return err
 	}
 
 	return builder.ControllerManagedBy(mgr).
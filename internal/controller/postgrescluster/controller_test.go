@@ -28,11 +28,15 @@ import (
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/version"
@@ -47,6 +51,25 @@ import (
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+func TestReconcilerControllerOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		opts, err := (&Reconciler{}).controllerOptions()
+		assert.NilError(t, err)
+		assert.Equal(t, opts.MaxConcurrentReconciles, 2)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		opts, err := (&Reconciler{Workers: 10}).controllerOptions()
+		assert.NilError(t, err)
+		assert.Equal(t, opts.MaxConcurrentReconciles, 10)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := (&Reconciler{Workers: -1}).controllerOptions()
+		assert.ErrorContains(t, err, "at least 1")
+	})
+}
+
 func TestDeleteControlled(t *testing.T) {
 	ctx := context.Background()
 	_, cc := setupKubernetes(t)
@@ -101,6 +124,64 @@ func TestDeleteControlled(t *testing.T) {
 	})
 }
 
+func TestReconcileSteps(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(recorder))
+
+	reconciler := &Reconciler{
+		Client:   cc,
+		Metrics:  NewMetrics(cc),
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: new(record.FakeRecorder),
+		Tracer:   provider.Tracer(t.Name()),
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, reconciler.Client.Create(ctx, cluster))
+	t.Cleanup(func() {
+		// Remove finalizers, if any, so the namespace can terminate.
+		assert.Check(t, client.IgnoreNotFound(
+			reconciler.Client.Patch(ctx, cluster, client.RawPatch(
+				client.Merge.Type(), []byte(`{"metadata":{"finalizers":[]}}`)))))
+	})
+
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{
+		NamespacedName: client.ObjectKeyFromObject(cluster),
+	})
+	assert.NilError(t, err)
+
+	var parent string
+	children := map[string]string{}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "Reconcile" {
+			parent = span.SpanContext().SpanID().String()
+		} else {
+			children[span.Name()] = span.Parent().SpanID().String()
+		}
+	}
+	assert.Assert(t, parent != "")
+
+	// Each step has a span that is a child of the Reconcile span.
+	for _, step := range []string{
+		"reconcileRootCertificate",
+		"reconcileClusterConfigMap",
+		"reconcileInstanceSets",
+		"reconcilePostgresUsers",
+		"reconcilePGBackRest",
+		"reconcilePGBouncer",
+	} {
+		assert.Equal(t, children[step], parent, "expected a span for %q", step)
+	}
+
+	// Each step is measured.
+	assert.Assert(t, testutil.CollectAndCount(reconciler.Metrics.StepSeconds) >= 6)
+}
+
 var _ = Describe("PostgresCluster Reconciler", func() {
 	var test struct {
 		Namespace  *corev1.Namespace
@@ -234,6 +315,7 @@ spec:
 			))
 
 			Expect(ccm.Data["patroni.yaml"]).ToNot(BeZero())
+			Expect(ccm.Data["pg_ident.conf"]).ToNot(BeZero())
 		})
 
 		Specify("Cluster Pod Service", func() {
@@ -494,5 +576,101 @@ spec:
 			)).To(Succeed())
 			Expect(instance.Spec.Replicas).To(PointTo(BeEquivalentTo(1)))
 		})
+
+		It("leaves Instance StatefulSet.Spec.Replicas while paused", func() {
+			ctx := context.Background()
+			patch := client.MergeFrom(instance.DeepCopy())
+			*instance.Spec.Replicas = 2
+
+			Expect(suite.Client.Patch(ctx, &instance, patch)).To(Succeed())
+			Expect(suite.Client.Patch(ctx, cluster, client.RawPatch(
+				client.Merge.Type(), []byte(`{"spec":{"paused":true}}`),
+			))).To(Succeed())
+
+			Expect(reconcile(cluster)).To(BeZero())
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(&instance), &instance,
+			)).To(Succeed())
+			Expect(instance.Spec.Replicas).To(PointTo(BeEquivalentTo(2)))
+
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(cluster), cluster,
+			)).To(Succeed())
+			Expect(meta.FindStatusCondition(cluster.Status.Conditions,
+				v1beta1.PostgresClusterProgressing,
+			)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionFalse),
+				"Reason": Equal("Paused"),
+			})))
+
+			// The change is reverted when the cluster is no longer paused.
+			Expect(suite.Client.Patch(ctx, cluster, client.RawPatch(
+				client.Merge.Type(), []byte(`{"spec":{"paused":false}}`),
+			))).To(Succeed())
+
+			Expect(reconcile(cluster)).To(BeZero())
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(&instance), &instance,
+			)).To(Succeed())
+			Expect(instance.Spec.Replicas).To(PointTo(BeEquivalentTo(1)))
+
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(cluster), cluster,
+			)).To(Succeed())
+			Expect(meta.FindStatusCondition(cluster.Status.Conditions,
+				v1beta1.PostgresClusterProgressing,
+			)).To(BeNil())
+		})
+
+		It("scales Instance StatefulSet.Spec.Replicas to zero while shutdown", func() {
+			ctx := context.Background()
+
+			// There are no Pods in this environment, so no primary is ever
+			// observed. Record the instance as the one that stopped last.
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(cluster), cluster,
+			)).To(Succeed())
+			cluster.Status.StartupInstance = instance.Name
+			cluster.Status.StartupInstanceSet = "samba"
+			Expect(suite.Client.Status().Update(ctx, cluster)).To(Succeed())
+
+			Expect(suite.Client.Patch(ctx, cluster, client.RawPatch(
+				client.Merge.Type(), []byte(`{"spec":{"shutdown":true}}`),
+			))).To(Succeed())
+
+			Expect(reconcile(cluster)).To(BeZero())
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(&instance), &instance,
+			)).To(Succeed())
+			Expect(instance.Spec.Replicas).To(PointTo(BeEquivalentTo(0)))
+
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(cluster), cluster,
+			)).To(Succeed())
+			Expect(meta.FindStatusCondition(cluster.Status.Conditions,
+				v1beta1.PostgresClusterShutdown,
+			)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionTrue),
+				"Reason": Equal("Stopped"),
+			})))
+
+			// The instance starts again when the cluster is no longer shutdown.
+			Expect(suite.Client.Patch(ctx, cluster, client.RawPatch(
+				client.Merge.Type(), []byte(`{"spec":{"shutdown":false}}`),
+			))).To(Succeed())
+
+			Expect(reconcile(cluster)).To(BeZero())
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(&instance), &instance,
+			)).To(Succeed())
+			Expect(instance.Spec.Replicas).To(PointTo(BeEquivalentTo(1)))
+
+			Expect(suite.Client.Get(
+				ctx, client.ObjectKeyFromObject(cluster), cluster,
+			)).To(Succeed())
+			Expect(meta.FindStatusCondition(cluster.Status.Conditions,
+				v1beta1.PostgresClusterShutdown,
+			)).To(BeNil())
+		})
 	})
 })
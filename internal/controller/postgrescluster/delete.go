@@ -23,14 +23,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crunchydata/postgres-operator/internal/config"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=patch
 
-// handleDelete sets a finalizer on cluster and performs the finalization of
-// cluster when it is being deleted. It returns (nil, nil) when cluster is
+// handleDelete sets a finalizer on cluster, records the prefix of the names
+// generated for cluster, and performs the finalization of cluster when it is
+// being deleted. It returns (nil, nil) when cluster is
 // not being deleted. The caller is responsible for returning other values to
 // controller-runtime.
 func (r *Reconciler) handleDelete(
@@ -51,13 +53,16 @@ func (r *Reconciler) handleDelete(
 	// - https://docs.k8s.io/concepts/workloads/controllers/garbage-collection/#foreground-cascading-deletion
 
 	if cluster.DeletionTimestamp.IsZero() {
-		if finalizers.Has(naming.Finalizer) {
+		_, recorded := cluster.GetAnnotations()[naming.ResourceNamePrefix]
+		if finalizers.Has(naming.Finalizer) && recorded {
 			// The cluster is not being deleted and the finalizer is set.
 			// The caller can do what they like.
 			return nil, nil
 		}
 
 		// The cluster is not being deleted and needs a finalizer; set it.
+		// Record the prefix of generated names at the same time so that a
+		// later change to the operator's environment does not rename them.
 
 		// The Finalizers field is shared by multiple controllers, but the
 		// server-side merge strategy does not work on our custom resource due
@@ -68,7 +73,14 @@ func (r *Reconciler) handleDelete(
 		before := cluster.DeepCopy()
 		// Make another copy so that Patch doesn't write back to cluster.
 		intent := before.DeepCopy()
-		intent.Finalizers = append(intent.Finalizers, naming.Finalizer)
+		if !finalizers.Has(naming.Finalizer) {
+			intent.Finalizers = append(intent.Finalizers, naming.Finalizer)
+		}
+		if !recorded {
+			intent.Annotations = naming.Merge(intent.Annotations, map[string]string{
+				naming.ResourceNamePrefix: config.ResourceNamePrefix(),
+			})
+		}
 		err := errors.WithStack(r.patch(ctx, intent,
 			client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{})))
 
@@ -76,6 +88,11 @@ func (r *Reconciler) handleDelete(
 		return nil, err
 	}
 
+	// The cluster is being deleted; its certificates will not be reported again.
+	if r.expiring != nil {
+		r.expiring.forgetCluster(cluster.UID)
+	}
+
 	if !finalizers.Has(naming.Finalizer) {
 		// The cluster is being deleted and there is no finalizer.
 		// The caller should listen for another event.
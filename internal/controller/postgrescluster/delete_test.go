@@ -0,0 +1,113 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestHandleDeleteResourceNamePrefix(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	reconcileWith := func(t *testing.T, cluster *v1beta1.PostgresCluster) *v1beta1.PostgresCluster {
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+		r := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+		result, err := r.handleDelete(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, result == nil)
+
+		stored := &v1beta1.PostgresCluster{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), stored))
+		return stored
+	}
+
+	t.Run("New", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "team-a-")
+
+		cluster := testCluster()
+		cluster.Namespace = "ns1"
+
+		stored := reconcileWith(t, cluster)
+		assert.DeepEqual(t, stored.Finalizers, []string{naming.Finalizer})
+		assert.Equal(t, stored.Annotations[naming.ResourceNamePrefix], "team-a-")
+		assert.Equal(t, naming.ClusterPrimaryService(stored).Name, "team-a-hippo-primary")
+	})
+
+	t.Run("Recorded", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "team-b-")
+
+		cluster := testCluster()
+		cluster.Namespace = "ns1"
+		cluster.Finalizers = []string{naming.Finalizer}
+		cluster.Annotations = map[string]string{naming.ResourceNamePrefix: "team-a-"}
+
+		// The prefix recorded earlier is kept.
+		stored := reconcileWith(t, cluster)
+		assert.Equal(t, stored.Annotations[naming.ResourceNamePrefix], "team-a-")
+		assert.Equal(t, naming.ClusterPrimaryService(stored).Name, "team-a-hippo-primary")
+	})
+
+	t.Run("Finalized", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "")
+
+		cluster := testCluster()
+		cluster.Namespace = "ns1"
+		cluster.Finalizers = []string{naming.Finalizer}
+
+		// Clusters reconciled before the prefix was recorded get it now.
+		stored := reconcileWith(t, cluster)
+		assert.DeepEqual(t, stored.Finalizers, []string{naming.Finalizer})
+		value, ok := stored.Annotations[naming.ResourceNamePrefix]
+		assert.Assert(t, ok)
+		assert.Equal(t, value, "")
+	})
+}
+
+func TestHandleDeleteExpiringCertificates(t *testing.T) {
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+
+	r := &Reconciler{expiring: new(expiringCertificates)}
+	assert.Assert(t, r.expiring.firstReport("uid1/root", expires))
+	assert.Assert(t, r.expiring.firstReport("uid2/root", expires))
+
+	cluster := testCluster()
+	cluster.UID = "uid1"
+	cluster.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	// There is no finalizer, so there is nothing else to do.
+	result, err := r.handleDelete(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, result != nil)
+
+	// Only the certificates of the deleted cluster are forgotten.
+	assert.Assert(t, r.expiring.firstReport("uid1/root", expires))
+	assert.Assert(t, !r.expiring.firstReport("uid2/root", expires))
+}
@@ -0,0 +1,256 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Change describes something Reconcile would have done during a dry run.
+type Change struct {
+	// Operation is one of "create", "delete", "deletecollection", "event",
+	// "exec", "patch", "patch status", "update", or "update status".
+	Operation string
+
+	Kind      string
+	Namespace string
+	Name      string
+
+	// Patch is a JSON merge patch from the live object to the object that
+	// would be stored. It is empty when there is no live object to compare.
+	Patch string
+
+	// Command is what would run in the database container of a Pod.
+	Command []string
+
+	// Event is the type, reason, and message of an event that would be
+	// emitted about the object.
+	Event string
+}
+
+// dryRun performs the steps of Reconcile for request without writing to the
+// Kubernetes API or running commands in Pods. It returns what would change.
+func (r *Reconciler) dryRun(
+	ctx context.Context, request reconcile.Request,
+) ([]Change, error) {
+	c := &dryRunClient{Client: r.Client}
+
+	preview := *r
+	preview.Client = c
+	preview.Metrics = nil
+	preview.PodExec = c.exec
+	preview.Recorder = dryRunRecorder{c}
+
+	_, err := preview.reconcile(ctx, request)
+	return c.changes, err
+}
+
+// dryRunClient is a client.Client that sends every write to the Kubernetes API
+// with DryRunAll and remembers those that would change something.
+type dryRunClient struct {
+	client.Client
+
+	changes []Change
+}
+
+var _ client.Client = (*dryRunClient)(nil)
+
+func (c *dryRunClient) Create(
+	ctx context.Context, obj client.Object, opts ...client.CreateOption,
+) error {
+	err := c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+	if err == nil {
+		c.record("create", nil, obj)
+	}
+	return err
+}
+
+func (c *dryRunClient) Delete(
+	ctx context.Context, obj client.Object, opts ...client.DeleteOption,
+) error {
+	err := c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+	if err == nil {
+		c.record("delete", nil, obj)
+	}
+	return err
+}
+
+func (c *dryRunClient) DeleteAllOf(
+	ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption,
+) error {
+	err := c.Client.DeleteAllOf(ctx, obj, append(opts, client.DryRunAll)...)
+	if err == nil {
+		c.record("deletecollection", nil, obj)
+	}
+	return err
+}
+
+func (c *dryRunClient) Patch(
+	ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption,
+) error {
+	live := c.live(ctx, obj)
+	err := c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+	if err == nil && live == nil {
+		// An apply-patch creates objects that do not exist.
+		c.record("create", nil, obj)
+	} else if err == nil {
+		c.record("patch", live, obj)
+	}
+	return err
+}
+
+func (c *dryRunClient) Update(
+	ctx context.Context, obj client.Object, opts ...client.UpdateOption,
+) error {
+	live := c.live(ctx, obj)
+	err := c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+	if err == nil {
+		c.record("update", live, obj)
+	}
+	return err
+}
+
+func (c *dryRunClient) Status() client.StatusWriter { return dryRunStatusWriter{c} }
+
+// exec is a podExecutor that remembers command rather than run it.
+func (c *dryRunClient) exec(
+	namespace, pod, container string,
+	stdin io.Reader, stdout, stderr io.Writer, command ...string,
+) error {
+	c.changes = append(c.changes, Change{
+		Operation: "exec", Kind: "Pod", Namespace: namespace, Name: pod,
+		Command: command,
+	})
+	return nil
+}
+
+// dryRunRecorder is a record.EventRecorder that remembers events rather than
+// emit them.
+type dryRunRecorder struct{ c *dryRunClient }
+
+var _ record.EventRecorder = dryRunRecorder{}
+
+func (r dryRunRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	change := Change{Operation: "event", Event: eventtype + " " + reason + ": " + message}
+	if obj, ok := object.(client.Object); ok {
+		change.Namespace, change.Name = obj.GetNamespace(), obj.GetName()
+	}
+	if gvk, err := apiutil.GVKForObject(object, r.c.Scheme()); err == nil {
+		change.Kind = gvk.Kind
+	}
+	r.c.changes = append(r.c.changes, change)
+}
+
+func (r dryRunRecorder) Eventf(
+	object runtime.Object, eventtype, reason, messageFmt string, args ...interface{},
+) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r dryRunRecorder) AnnotatedEventf(
+	object runtime.Object, _ map[string]string,
+	eventtype, reason, messageFmt string, args ...interface{},
+) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+// live returns a copy of obj as it is in the Kubernetes API, or nil when it
+// cannot be read.
+func (c *dryRunClient) live(ctx context.Context, obj client.Object) client.Object {
+	live := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		return nil
+	}
+	return live
+}
+
+// record remembers that operation changes before into after. It does nothing
+// when before and after are the same.
+func (c *dryRunClient) record(operation string, before, after client.Object) {
+	change := Change{
+		Operation: operation,
+		Namespace: after.GetNamespace(),
+		Name:      after.GetName(),
+	}
+	if gvk, err := apiutil.GVKForObject(after, c.Scheme()); err == nil {
+		change.Kind = gvk.Kind
+	}
+	if before != nil {
+		patch, err := mergePatch(before, after)
+		if err == nil && patch == "{}" {
+			return
+		}
+		change.Patch = patch
+	}
+
+	c.changes = append(c.changes, change)
+}
+
+// dryRunStatusWriter is a client.StatusWriter that sends every write to the
+// Kubernetes API with DryRunAll and remembers those that would change something.
+type dryRunStatusWriter struct{ c *dryRunClient }
+
+func (w dryRunStatusWriter) Patch(
+	ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption,
+) error {
+	live := w.c.live(ctx, obj)
+	err := w.c.Client.Status().Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+	if err == nil {
+		w.c.record("patch status", live, obj)
+	}
+	return err
+}
+
+func (w dryRunStatusWriter) Update(
+	ctx context.Context, obj client.Object, opts ...client.UpdateOption,
+) error {
+	live := w.c.live(ctx, obj)
+	err := w.c.Client.Status().Update(ctx, obj, append(opts, client.DryRunAll)...)
+	if err == nil {
+		w.c.record("update status", live, obj)
+	}
+	return err
+}
+
+// mergePatch returns a JSON merge patch from before to after. It ignores
+// metadata that changes on every write.
+func mergePatch(before, after client.Object) (string, error) {
+	documents := make([][]byte, 2)
+	for i, object := range []client.Object{before, after} {
+		object = object.DeepCopyObject().(client.Object)
+		object.SetGeneration(0)
+		object.SetManagedFields(nil)
+		object.SetResourceVersion("")
+
+		var err error
+		if documents[i], err = json.Marshal(object); err != nil {
+			return "", err
+		}
+	}
+
+	patch, err := jsonpatch.CreateMergePatch(documents[0], documents[1])
+	return string(patch), err
+}
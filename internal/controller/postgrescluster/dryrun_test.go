@@ -0,0 +1,229 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/util"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestDryRunClient(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	existing := &corev1.ConfigMap{Data: map[string]string{"a": "1"}}
+	existing.Namespace, existing.Name = "ns1", "existing"
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+
+	// stored returns the ConfigMap named name as it is in the fake client.
+	stored := func(t *testing.T, name string) (*corev1.ConfigMap, error) {
+		cm := &corev1.ConfigMap{}
+		err := cc.Get(ctx, client.ObjectKey{Namespace: "ns1", Name: name}, cm)
+		return cm, err
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		c := &dryRunClient{Client: cc}
+
+		cm := &corev1.ConfigMap{}
+		cm.Namespace, cm.Name = "ns1", "new"
+		assert.NilError(t, c.Create(ctx, cm))
+
+		assert.DeepEqual(t, c.changes, []Change{{
+			Operation: "create", Kind: "ConfigMap", Namespace: "ns1", Name: "new",
+		}})
+
+		_, err := stored(t, "new")
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		c := &dryRunClient{Client: cc}
+
+		cm, err := stored(t, "existing")
+		assert.NilError(t, err)
+
+		before := client.MergeFrom(cm.DeepCopy())
+		cm.Data["a"] = "2"
+		assert.NilError(t, c.Patch(ctx, cm, before))
+
+		assert.DeepEqual(t, c.changes, []Change{{
+			Operation: "patch", Kind: "ConfigMap", Namespace: "ns1", Name: "existing",
+			Patch: `{"data":{"a":"2"}}`,
+		}})
+
+		cm, err = stored(t, "existing")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cm.Data, map[string]string{"a": "1"})
+	})
+
+	t.Run("PatchUnchanged", func(t *testing.T) {
+		c := &dryRunClient{Client: cc}
+
+		cm, err := stored(t, "existing")
+		assert.NilError(t, err)
+		assert.NilError(t, c.Patch(ctx, cm, client.MergeFrom(cm.DeepCopy())))
+
+		assert.Equal(t, len(c.changes), 0, "expected no changes, got %v", c.changes)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := &dryRunClient{Client: cc}
+
+		cm, err := stored(t, "existing")
+		assert.NilError(t, err)
+		assert.NilError(t, c.Delete(ctx, cm))
+
+		assert.DeepEqual(t, c.changes, []Change{{
+			Operation: "delete", Kind: "ConfigMap", Namespace: "ns1", Name: "existing",
+		}})
+
+		_, err = stored(t, "existing")
+		assert.NilError(t, err)
+	})
+
+	t.Run("Exec", func(t *testing.T) {
+		c := &dryRunClient{Client: cc}
+
+		assert.NilError(t, c.exec("ns1", "pod", "database", nil, nil, nil, "psql", "-c", "SELECT 1"))
+		assert.DeepEqual(t, c.changes, []Change{{
+			Operation: "exec", Kind: "Pod", Namespace: "ns1", Name: "pod",
+			Command: []string{"psql", "-c", "SELECT 1"},
+		}})
+	})
+}
+
+func TestReconcilerDryRun(t *testing.T) {
+	ctx := context.Background()
+	assert.NilError(t, util.AddAndSetFeatureGates(""))
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Annotations = map[string]string{naming.DryRun: ""}
+
+	cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+	r := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: record.NewFakeRecorder(100),
+		Tracer:   otel.Tracer(t.Name()),
+		PodExec: func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+			return errors.New("unexpected call to PodExec")
+		},
+	}
+	request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(cluster)}
+
+	t.Run("Reconcile", func(t *testing.T) {
+		result, err := r.Reconcile(ctx, request)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{})
+
+		// Only the finalizer and the resource name prefix are written.
+		stored := &v1beta1.PostgresCluster{}
+		assert.NilError(t, cc.Get(ctx, request.NamespacedName, stored))
+		assert.DeepEqual(t, stored.Finalizers, []string{naming.Finalizer})
+		assert.Assert(t, stored.Annotations[naming.ResourceNamePrefix] == "")
+		assert.Equal(t, len(stored.Status.Conditions), 0)
+
+		// No events are emitted.
+		assert.Equal(t, len(r.Recorder.(*record.FakeRecorder).Events), 0)
+
+		var configmaps corev1.ConfigMapList
+		assert.NilError(t, cc.List(ctx, &configmaps))
+		assert.Equal(t, len(configmaps.Items), 0)
+
+		var statefulsets appsv1.StatefulSetList
+		assert.NilError(t, cc.List(ctx, &statefulsets))
+		assert.Equal(t, len(statefulsets.Items), 0)
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		changes, err := r.dryRun(ctx, request)
+		assert.NilError(t, err)
+
+		created := map[string]bool{}
+		for _, change := range changes {
+			if change.Operation == "create" {
+				created[change.Kind+"/"+change.Name] = true
+			}
+		}
+
+		// Some of the objects that would be created.
+		assert.Assert(t, created["ConfigMap/hippo-config"], "got %v", created)
+		assert.Assert(t, created["Service/hippo-primary"], "got %v", created)
+		assert.Assert(t, created["StatefulSet/hippo-repo-host"], "got %v", created)
+		assert.Assert(t, created["Deployment/hippo-pgbouncer"], "got %v", created)
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		stored := &v1beta1.PostgresCluster{}
+		assert.NilError(t, cc.Get(ctx, request.NamespacedName, stored))
+		stored.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+		assert.NilError(t, cc.Update(ctx, stored))
+		t.Cleanup(func() {
+			stored.Spec.Standby = nil
+			assert.NilError(t, cc.Update(ctx, stored))
+		})
+
+		changes, err := r.dryRun(ctx, request)
+		assert.ErrorContains(t, err, "spec.standby")
+
+		// The event is remembered rather than emitted.
+		assert.Equal(t, len(r.Recorder.(*record.FakeRecorder).Events), 0)
+		assert.Equal(t, len(changes), 1)
+		assert.Equal(t, changes[0].Operation, "event")
+		assert.Equal(t, changes[0].Kind, "PostgresCluster")
+		assert.Equal(t, changes[0].Name, "hippo")
+		assert.Assert(t, strings.HasPrefix(changes[0].Event, "Warning InvalidStandbyConfiguration: "),
+			"got %q", changes[0].Event)
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		stored := &v1beta1.PostgresCluster{}
+		assert.NilError(t, cc.Get(ctx, request.NamespacedName, stored))
+		assert.NilError(t, cc.Delete(ctx, stored))
+
+		// A cluster deleted during a dry run is finalized.
+		_, err := r.Reconcile(ctx, request)
+		assert.NilError(t, err)
+
+		err = cc.Get(ctx, request.NamespacedName, stored)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+	})
+}
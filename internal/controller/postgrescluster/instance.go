@@ -29,11 +29,13 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -45,9 +47,14 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// defaultTerminationGracePeriodSeconds is how long PostgreSQL pods have to
+// stop when the instance set does not specify.
+const defaultTerminationGracePeriodSeconds = 60
+
 // Instance represents a single PostgreSQL instance of a PostgresCluster.
 type Instance struct {
 	Name   string
@@ -339,9 +346,42 @@ func (r *Reconciler) observeInstances(
 		cluster.Status.InstanceSets = append(cluster.Status.InstanceSets, status)
 	}
 
+	if err == nil {
+		setPrimaryReadyCondition(cluster, observed)
+	}
+
 	return observed, err
 }
 
+// setPrimaryReadyCondition reports whether or not the Patroni leader among
+// observed is ready to receive PostgreSQL connections.
+func setPrimaryReadyCondition(cluster *v1beta1.PostgresCluster, observed *observedInstances) {
+	condition := metav1.Condition{
+		Type:    v1beta1.PrimaryReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoPrimary",
+		Message: "No instance is the Patroni leader",
+
+		ObservedGeneration: cluster.Generation,
+	}
+
+	for _, instance := range observed.forCluster {
+		if primary, known := instance.IsPrimary(); primary && known {
+			if ready, known := instance.IsReady(); ready && known {
+				condition.Status = metav1.ConditionTrue
+				condition.Reason = "PrimaryReady"
+				condition.Message = fmt.Sprintf("Instance %q is ready", instance.Name)
+			} else {
+				condition.Reason = "PrimaryNotReady"
+				condition.Message = fmt.Sprintf("Instance %q is not ready", instance.Name)
+			}
+			break
+		}
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
 // +kubebuilder:rbac:groups="",resources=pods,verbs=list
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=patch
 
@@ -528,11 +568,52 @@ func (r *Reconciler) reconcileInstanceSets(
 		numInstancePods += len(instances.forCluster[i].Pods)
 	}
 
+	// Report whether a cluster that is being shutdown has stopped. The
+	// condition is removed once the cluster is asked to start again.
+	if cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown {
+		shutdown := metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               v1beta1.PostgresClusterShutdown,
+			Status:             metav1.ConditionFalse,
+			Reason:             "ShuttingDown",
+			Message:            fmt.Sprintf("%d PostgreSQL instance pods are running", numInstancePods),
+		}
+		if numInstancePods == 0 {
+			shutdown.Status = metav1.ConditionTrue
+			shutdown.Reason = "Stopped"
+			shutdown.Message = "All PostgreSQL instances are stopped"
+		}
+		meta.SetStatusCondition(&cluster.Status.Conditions, shutdown)
+	} else {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1beta1.PostgresClusterShutdown)
+	}
+
 	// Range over instance sets to scale up and ensure that each set has
 	// at least the number of replicas defined in the spec. The set can
 	// have more replicas than defined
 	for i := range cluster.Spec.InstanceSets {
 		set := &cluster.Spec.InstanceSets[i]
+		path := field.NewPath("spec", "instances").Index(i)
+
+		// Leave the instances of a set as they are when its custom containers
+		// or environment cannot be added to their Pods.
+		errs := validateInstanceSetEnvironment(set, path)
+		if util.DefaultMutableFeatureGate.Enabled(util.InstanceSidecars) {
+			errs = append(errs, validateInstanceSetContainers(set, path)...)
+		}
+		if len(errs) > 0 {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidInstanceSet",
+				errs.ToAggregate().Error())
+			continue
+		}
+
+		if warnings := validateSecurityContexts(
+			set.SecurityContext, set.ContainerSecurityContext, path,
+		); len(warnings) > 0 {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidSecurityContext",
+				warnings.ToAggregate().Error())
+		}
+
 		_, err := r.scaleUpInstances(
 			ctx, cluster, instances, set,
 			clusterConfigMap, clusterReplicationSecret,
@@ -572,6 +653,107 @@ func (r *Reconciler) reconcileInstanceSets(
 	return err
 }
 
+// validateInstanceSetContainers returns any custom containers of set that
+// would collide with the containers the operator adds to instance Pods.
+func validateInstanceSetContainers(
+	set *v1beta1.PostgresInstanceSetSpec, path *field.Path,
+) field.ErrorList {
+	var errs field.ErrorList
+
+	// Init containers and containers share names within a Pod.
+	reserved := sets.NewString(
+		naming.ContainerClientCertCopy,
+		naming.ContainerDatabase,
+		naming.ContainerNSSWrapperInit,
+		naming.ContainerPGBackRestConfig,
+		naming.ContainerPGMonitorExporter,
+		naming.ContainerPostgresPermissions,
+		naming.ContainerPostgresStartup,
+		naming.PGBackRestRepoContainerName,
+	)
+
+	for i := range set.Containers {
+		if name := set.Containers[i].Name; reserved.Has(name) {
+			errs = append(errs, field.Invalid(
+				path.Child("containers").Index(i).Child("name"), name,
+				"the operator manages a container with this name"))
+		}
+	}
+	for i := range set.InitContainers {
+		if name := set.InitContainers[i].Name; reserved.Has(name) {
+			errs = append(errs, field.Invalid(
+				path.Child("initContainers").Index(i).Child("name"), name,
+				"the operator manages a container with this name"))
+		}
+	}
+
+	return errs
+}
+
+// reservedInstanceEnvironment are the names of environment variables the
+// operator sets on the database container.
+var reservedInstanceEnvironment = sets.NewString(
+	"KRB5_CONFIG", "KRB5RCACHEDIR",
+	"LD_PRELOAD", "NSS_WRAPPER_GROUP", "NSS_WRAPPER_PASSWD",
+	"PGDATA", "PGHOST", "PGPORT",
+)
+
+// validateInstanceSetEnvironment returns any custom environment variables of
+// set that would override those the operator adds to the database container.
+// The contents of EnvFrom sources are not known, but a prefix could turn their
+// keys into names the operator manages, so prefixes are not allowed.
+func validateInstanceSetEnvironment(
+	set *v1beta1.PostgresInstanceSetSpec, path *field.Path,
+) field.ErrorList {
+	var errs field.ErrorList
+
+	for i := range set.Env {
+		if name := set.Env[i].Name; reservedInstanceEnvironment.Has(name) ||
+			strings.HasPrefix(name, "PATRONI") {
+			errs = append(errs, field.Invalid(
+				path.Child("env").Index(i).Child("name"), name,
+				"the operator manages an environment variable with this name"))
+		}
+	}
+	for i := range set.EnvFrom {
+		if set.EnvFrom[i].Prefix != "" {
+			errs = append(errs, field.Forbidden(
+				path.Child("envFrom").Index(i).Child("prefix"),
+				"a prefix can produce an environment variable the operator manages"))
+		}
+	}
+
+	return errs
+}
+
+// addInstanceEnvironment appends the custom environment of spec to the database
+// container in template. Variables the operator already set are left as they
+// are. Kubernetes gives variables in Env precedence over those in EnvFrom.
+// - https://docs.k8s.io/tasks/inject-data-application/define-environment-variable-container/
+func addInstanceEnvironment(
+	template *corev1.PodTemplateSpec, spec *v1beta1.PostgresInstanceSetSpec,
+) {
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		if container.Name != naming.ContainerDatabase {
+			continue
+		}
+
+		existing := sets.NewString()
+		for _, v := range container.Env {
+			existing.Insert(v.Name)
+		}
+		for _, v := range spec.Env {
+			if !existing.Has(v.Name) {
+				container.Env = append(container.Env, *v.DeepCopy())
+			}
+		}
+		for _, source := range spec.EnvFrom {
+			container.EnvFrom = append(container.EnvFrom, *source.DeepCopy())
+		}
+	}
+}
+
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=list
 
 // cleanupPodDisruptionBudgets removes pdbs that do not have an
@@ -792,7 +974,8 @@ func (r *Reconciler) rolloutInstance(
 
 // rolloutInstances compares instances to cluster and calls redeploy on those
 // that need their Pod recreated. It considers the overall availability of
-// cluster and minimizes Patroni failovers.
+// cluster and minimizes Patroni failovers. Instances of sets with the
+// "OnDelete" update strategy are never redeployed.
 func (r *Reconciler) rolloutInstances(
 	ctx context.Context,
 	cluster *v1beta1.PostgresCluster,
@@ -828,6 +1011,11 @@ func (r *Reconciler) rolloutInstances(
 			numAvailable++
 		}
 
+		// Leave outdated Pods of an "OnDelete" set for someone else to delete.
+		if instance.Spec.UpdateStrategy == string(appsv1.OnDeleteStatefulSetStrategyType) {
+			continue
+		}
+
 		if matches, known := instance.PodMatchesPodTemplate(); known && !matches {
 			consider = append(consider, instance)
 			continue
@@ -1101,6 +1289,14 @@ func (r *Reconciler) reconcileInstance(
 			spec, instanceCertificates, instanceConfigMap, &instance.Spec.Template)
 	}
 
+	// Recreate the Pod when PostgreSQL parameters that require a restart change.
+	// See [Reconciler.rolloutInstances] and [Reconciler.handlePatroniRestarts].
+	if hash := clusterConfigMap.Annotations[naming.PostgresConfigHash]; err == nil && hash != "" {
+		instance.Spec.Template.Annotations = naming.Merge(
+			instance.Spec.Template.Annotations,
+			map[string]string{naming.PostgresConfigHash: hash})
+	}
+
 	// Add pgMonitor resources to the instance Pod spec
 	if err == nil {
 		err = addPGMonitorToInstancePodSpec(cluster, &instance.Spec.Template, exporterWebConfig)
@@ -1126,6 +1322,25 @@ func (r *Reconciler) reconcileInstance(
 		addDevSHM(&instance.Spec.Template)
 	}
 
+	// add custom environment variables after those of the operator
+	if err == nil {
+		addInstanceEnvironment(&instance.Spec.Template, spec)
+	}
+
+	// Apply custom security settings to the containers the operator manages.
+	// The permissions container needs root, and custom containers have their
+	// own settings.
+	if err == nil {
+		skip := sets.NewString(naming.ContainerPostgresPermissions)
+		for i := range spec.Containers {
+			skip.Insert(spec.Containers[i].Name)
+		}
+		for i := range spec.InitContainers {
+			skip.Insert(spec.InitContainers[i].Name)
+		}
+		mergeContainerSecurityContext(&instance.Spec.Template, spec.ContainerSecurityContext, skip)
+	}
+
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, instance))
 	}
@@ -1194,6 +1409,7 @@ func generateInstanceStatefulSetIntent(_ context.Context,
 
 	// Use scheduling constraints from the cluster spec.
 	sts.Spec.Template.Spec.Affinity = spec.Affinity
+	sts.Spec.Template.Spec.NodeSelector = spec.NodeSelector
 	sts.Spec.Template.Spec.Tolerations = spec.Tolerations
 	sts.Spec.Template.Spec.TopologySpreadConstraints = spec.TopologySpreadConstraints
 	if spec.PriorityClassName != nil {
@@ -1210,6 +1426,13 @@ func generateInstanceStatefulSetIntent(_ context.Context,
 			defaultTopologySpreadConstraints(
 				naming.ClusterDataForPostgresAndPGBackRest(cluster.Name),
 			)...)
+
+		// prefer to schedule instances of the same set on different nodes
+		// unless the set already has its own scheduling constraints
+		if spec.Affinity == nil {
+			sts.Spec.Template.Spec.Affinity = defaultPodAntiAffinity(
+				naming.ClusterInstanceSet(cluster.Name, spec.Name))
+		}
 	}
 
 	// Though we use a StatefulSet to keep an instance running, we only ever
@@ -1239,6 +1462,17 @@ func generateInstanceStatefulSetIntent(_ context.Context,
 	// - https://docs.k8s.io/concepts/workloads/pods/pod-lifecycle/#restart-policy
 	sts.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
 
+	// Give PostgreSQL time to write a shutdown checkpoint before kubelet sends
+	// SIGKILL. The Kubernetes default of 30 seconds is often too short for a
+	// busy server.
+	// - https://docs.k8s.io/concepts/workloads/pods/pod-lifecycle/#pod-termination
+	sts.Spec.Template.Spec.TerminationGracePeriodSeconds = initialize.Int64(
+		defaultTerminationGracePeriodSeconds)
+	if spec.TerminationGracePeriodSeconds != nil {
+		sts.Spec.Template.Spec.TerminationGracePeriodSeconds = initialize.Int64(
+			*spec.TerminationGracePeriodSeconds)
+	}
+
 	// ShareProcessNamespace makes Kubernetes' pause process PID 1 and lets
 	// containers see each other's processes.
 	// - https://docs.k8s.io/tasks/configure-pod-container/share-process-namespace/
@@ -1257,12 +1491,15 @@ func generateInstanceStatefulSetIntent(_ context.Context,
 	sts.Spec.Template.Spec.EnableServiceLinks = initialize.Bool(false)
 
 	sts.Spec.Template.Spec.SecurityContext = postgres.PodSecurityContext(cluster)
+	mergePodSecurityContext(sts.Spec.Template.Spec.SecurityContext, spec.SecurityContext)
 
 	// Set the image pull secrets, if any exist.
 	// This is set here rather than using the service account due to the lack
 	// of propagation to existing pods when the CRD is updated:
 	// https://github.com/kubernetes/kubernetes/issues/88456
 	sts.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
+
+	addDNSConfig(cluster, &sts.Spec.Template.Spec)
 }
 
 // addPGBackRestToInstancePodSpec adds pgBackRest configurations and sidecars
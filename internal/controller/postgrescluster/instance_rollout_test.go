@@ -35,6 +35,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -442,6 +443,113 @@ func TestReconcilerRolloutInstances(t *testing.T) {
 		assert.Equal(t, redeploys[0].Name, "not-primary")
 	})
 
+	// Replicas are updated before the primary. Once they match PodTemplate,
+	// redeploy the primary.
+	t.Run("PrimaryOutdatedAfterReplicas", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "00", Replicas: initialize.Int32(3)},
+		}
+
+		instance := func(name, revision string, labels map[string]string) *Instance {
+			labels = naming.Merge(labels, map[string]string{
+				"controller-revision-hash": revision,
+			})
+			return &Instance{
+				Name: name,
+				Spec: &cluster.Spec.InstanceSets[0],
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{
+							Type:   corev1.PodReady,
+							Status: corev1.ConditionTrue,
+						}},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Generation: 1,
+					},
+					Status: appsv1.StatefulSetStatus{
+						ObservedGeneration: 1,
+						UpdateRevision:     "gamma",
+					},
+				},
+			}
+		}
+
+		primary := map[string]string{"postgres-operator.crunchydata.com/role": "master"}
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("a-primary", "beta", primary),
+			instance("b-replica", "beta", nil),
+			instance("c-replica", "beta", nil),
+		}}
+
+		// Each replica is redeployed, one at a time, before the primary.
+		var order []string
+		for len(order) < 3 {
+			var redeploys []*Instance
+
+			logSpanAttributes(t)
+			assert.NilError(t, reconciler.rolloutInstances(ctx, cluster, observed, accumulate(&redeploys)))
+			assert.Equal(t, len(redeploys), 1)
+
+			order = append(order, redeploys[0].Name)
+			redeploys[0].Pods[0].Labels["controller-revision-hash"] = "gamma"
+		}
+		assert.DeepEqual(t, order, []string{"b-replica", "c-replica", "a-primary"})
+	})
+
+	// Outdated instances of a set with the "OnDelete" strategy stay as they are.
+	t.Run("ManyOutdatedOnDelete", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "00", Replicas: initialize.Int32(1), UpdateStrategy: "OnDelete"},
+			{Name: "01", Replicas: initialize.Int32(1)},
+		}
+
+		instance := func(name string, set int) *Instance {
+			return &Instance{
+				Name: name,
+				Spec: &cluster.Spec.InstanceSets[set],
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							"controller-revision-hash": "beta",
+						},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{
+							Type:   corev1.PodReady,
+							Status: corev1.ConditionTrue,
+						}},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Generation: 1,
+					},
+					Status: appsv1.StatefulSetStatus{
+						ObservedGeneration: 1,
+						UpdateRevision:     "gamma",
+					},
+				},
+			}
+		}
+
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("manual", 0), instance("rolling", 1),
+		}}
+
+		var redeploys []*Instance
+
+		logSpanAttributes(t)
+		assert.NilError(t, reconciler.rolloutInstances(ctx, cluster, observed, accumulate(&redeploys)))
+		assert.Equal(t, len(redeploys), 1)
+		assert.Equal(t, redeploys[0].Name, "rolling")
+	})
+
 	// Two instances do not match PodTemplate, one is not ready. Redeploy that one.
 	t.Run("ManyOutdatedWithNotReady", func(t *testing.T) {
 		cluster := new(v1beta1.PostgresCluster)
@@ -35,12 +35,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -269,6 +271,99 @@ func TestNewObservedInstances(t *testing.T) {
 	})
 }
 
+func TestObserveInstances(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	reconciler := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	addPod := func(t *testing.T, name, role string, ready corev1.ConditionStatus) {
+		pod := &corev1.Pod{}
+		pod.Namespace = cluster.Namespace
+		pod.Name = name
+		pod.Labels = map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: "00",
+			naming.LabelInstance:    name,
+			naming.LabelRole:        role,
+		}
+		pod.Spec.Containers = []corev1.Container{{Name: "database", Image: "test"}}
+		assert.NilError(t, cc.Create(ctx, pod))
+
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: ready,
+		}}
+		assert.NilError(t, cc.Status().Update(ctx, pod))
+	}
+
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.PrimaryReady)
+	}
+
+	t.Run("NoInstances", func(t *testing.T) {
+		_, err := reconciler.observeInstances(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cluster.Status.InstanceSets), 0)
+
+		assert.Assert(t, condition() != nil)
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+		assert.Equal(t, condition().Reason, "NoPrimary")
+	})
+
+	t.Run("PrimaryNotReady", func(t *testing.T) {
+		addPod(t, "one", naming.RolePatroniLeader, corev1.ConditionFalse)
+
+		_, err := reconciler.observeInstances(ctx, cluster)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cluster.Status.InstanceSets, []v1beta1.PostgresInstanceSetStatus{
+			{Name: "00", Replicas: 1},
+		})
+
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+		assert.Equal(t, condition().Reason, "PrimaryNotReady")
+	})
+
+	t.Run("Scaled", func(t *testing.T) {
+		addPod(t, "two", naming.RolePatroniReplica, corev1.ConditionTrue)
+		addPod(t, "three", naming.RolePatroniReplica, corev1.ConditionTrue)
+
+		_, err := reconciler.observeInstances(ctx, cluster)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cluster.Status.InstanceSets, []v1beta1.PostgresInstanceSetStatus{
+			{Name: "00", Replicas: 3, ReadyReplicas: 2},
+		})
+
+		// Replicas do not make the primary ready.
+		assert.Equal(t, condition().Status, metav1.ConditionFalse)
+		assert.Equal(t, condition().Reason, "PrimaryNotReady")
+	})
+
+	t.Run("PrimaryReady", func(t *testing.T) {
+		pod := &corev1.Pod{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKey{
+			Namespace: cluster.Namespace, Name: "one",
+		}, pod))
+		pod.Status.Conditions[0].Status = corev1.ConditionTrue
+		assert.NilError(t, cc.Status().Update(ctx, pod))
+
+		cluster.Generation = 2
+		_, err := reconciler.observeInstances(ctx, cluster)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cluster.Status.InstanceSets, []v1beta1.PostgresInstanceSetStatus{
+			{Name: "00", Replicas: 3, ReadyReplicas: 3},
+		})
+
+		assert.Equal(t, condition().Status, metav1.ConditionTrue)
+		assert.Equal(t, condition().Reason, "PrimaryReady")
+		assert.Equal(t, condition().ObservedGeneration, int64(2))
+	})
+}
+
 func TestWritablePod(t *testing.T) {
 	container := "container"
 
@@ -1208,6 +1303,89 @@ func TestDeleteInstance(t *testing.T) {
 	}
 }
 
+func TestScaleDownInstances(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	reconciler := &Reconciler{
+		Client: cc,
+		Owner:  client.FieldOwner(t.Name()),
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, reconciler.Client.Create(ctx, cluster))
+
+	// Each instance is its own StatefulSet. Create three in one set and label
+	// the Pod of the second one as the Patroni leader.
+	names := []string{"instance1-aaaa", "instance1-bbbb", "instance1-cccc"}
+	runners := make([]appsv1.StatefulSet, len(names))
+	pods := make([]corev1.Pod, len(names))
+	for i, name := range names {
+		labels := map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: "instance1",
+			naming.LabelInstance:    name,
+		}
+
+		runner := &runners[i]
+		runner.Namespace, runner.Name = cluster.Namespace, name
+		runner.Labels = labels
+		runner.Spec.Replicas = initialize.Int32(1)
+		runner.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+		runner.Spec.Template.Labels = labels
+		runner.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "database", Image: "postgres",
+		}}
+		assert.NilError(t, reconciler.setControllerReference(cluster, runner))
+		assert.NilError(t, reconciler.Client.Create(ctx, runner))
+
+		pods[i].Name = name + "-0"
+		pods[i].Labels = map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: "instance1",
+			naming.LabelInstance:    name,
+			naming.LabelRole:        "replica",
+		}
+	}
+	pods[1].Labels[naming.LabelRole] = "master"
+
+	exists := func(t testing.TB, name string) bool {
+		t.Helper()
+		sts := &appsv1.StatefulSet{}
+		err := reconciler.Client.Get(ctx,
+			client.ObjectKey{Namespace: cluster.Namespace, Name: name}, sts)
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		assert.NilError(t, err)
+		return sts.DeletionTimestamp == nil
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		cluster.Spec.InstanceSets[0].Replicas = initialize.Int32(3)
+		observed := newObservedInstances(cluster, runners, pods)
+
+		assert.NilError(t, reconciler.scaleDownInstances(ctx, cluster, observed))
+
+		for _, name := range names {
+			assert.Assert(t, exists(t, name), "expected %q to remain", name)
+		}
+	})
+
+	t.Run("KeepsPrimary", func(t *testing.T) {
+		cluster.Spec.InstanceSets[0].Replicas = initialize.Int32(1)
+		observed := newObservedInstances(cluster, runners, pods)
+
+		assert.NilError(t, reconciler.scaleDownInstances(ctx, cluster, observed))
+
+		assert.Assert(t, !exists(t, names[0]), "expected replica to be deleted")
+		assert.Assert(t, exists(t, names[1]), "expected primary to remain")
+		assert.Assert(t, !exists(t, names[2]), "expected replica to be deleted")
+	})
+}
+
 func TestGenerateInstanceStatefulSetIntent(t *testing.T) {
 	type intentParams struct {
 		cluster                    *v1beta1.PostgresCluster
@@ -1250,6 +1428,17 @@ func TestGenerateInstanceStatefulSetIntent(t *testing.T) {
 		run: func(t *testing.T, ss *appsv1.StatefulSet) {
 			assert.Assert(t, ss.Spec.Template.Spec.Affinity != nil)
 		},
+	}, {
+		name: "custom node selector",
+		ip: intentParams{
+			spec: &v1beta1.PostgresInstanceSetSpec{
+				NodeSelector: map[string]string{"workload": "postgres"},
+			},
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.DeepEqual(t, ss.Spec.Template.Spec.NodeSelector,
+				map[string]string{"workload": "postgres"})
+		},
 	}, {
 		name: "custom tolerations",
 		ip: intentParams{
@@ -1345,6 +1534,61 @@ func TestGenerateInstanceStatefulSetIntent(t *testing.T) {
 			assert.Equal(t, ss.Spec.Template.Spec.PriorityClassName,
 				"some-priority-class")
 		},
+	}, {
+		name: "custom pod security context",
+		ip: intentParams{
+			spec: &v1beta1.PostgresInstanceSetSpec{
+				SecurityContext: &corev1.PodSecurityContext{
+					FSGroup:   initialize.Int64(2000),
+					RunAsUser: initialize.Int64(0),
+				},
+			},
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Assert(t, ss.Spec.Template.Spec.SecurityContext != nil)
+			assert.Equal(t, *ss.Spec.Template.Spec.SecurityContext.FSGroup, int64(2000))
+
+			// Root is ignored.
+			assert.Assert(t, ss.Spec.Template.Spec.SecurityContext.RunAsUser == nil)
+		},
+	}, {
+		name: "default termination grace period",
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Assert(t, ss.Spec.Template.Spec.TerminationGracePeriodSeconds != nil)
+			assert.Equal(t, *ss.Spec.Template.Spec.TerminationGracePeriodSeconds, int64(60))
+		},
+	}, {
+		name: "custom termination grace period",
+		ip: intentParams{
+			spec: &v1beta1.PostgresInstanceSetSpec{
+				TerminationGracePeriodSeconds: initialize.Int64(300),
+			},
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Assert(t, ss.Spec.Template.Spec.TerminationGracePeriodSeconds != nil)
+			assert.Equal(t, *ss.Spec.Template.Spec.TerminationGracePeriodSeconds, int64(300))
+		},
+	}, {
+		name: "custom dns config and host aliases",
+		ip: intentParams{
+			cluster: func() *v1beta1.PostgresCluster {
+				cluster := testCluster()
+				cluster.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+				cluster.Spec.DNSConfig = &corev1.PodDNSConfig{
+					Searches: []string{"corp.example.com"},
+				}
+				cluster.Spec.HostAliases = []corev1.HostAlias{{
+					IP: "10.1.2.3", Hostnames: []string{"kms.corp.example.com"},
+				}}
+				return cluster
+			}(),
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Equal(t, ss.Spec.Template.Spec.DNSPolicy, corev1.DNSClusterFirstWithHostNet)
+			assert.DeepEqual(t, ss.Spec.Template.Spec.DNSConfig.Searches, []string{"corp.example.com"})
+			assert.Equal(t, len(ss.Spec.Template.Spec.HostAliases), 1)
+			assert.Equal(t, ss.Spec.Template.Spec.HostAliases[0].IP, "10.1.2.3")
+		},
 	}, {
 		name: "check default scheduling constraints are added",
 		run: func(t *testing.T, ss *appsv1.StatefulSet) {
@@ -1493,6 +1737,80 @@ func TestGenerateInstanceStatefulSetIntent(t *testing.T) {
   whenUnsatisfiable: ScheduleAnyway
 `))
 		},
+	}, {
+		name: "check default affinity is added",
+		ip: intentParams{
+			spec: &v1beta1.PostgresInstanceSetSpec{
+				Name: "instance1",
+			},
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Assert(t, marshalMatches(ss.Spec.Template.Spec.Affinity, `
+podAntiAffinity:
+  preferredDuringSchedulingIgnoredDuringExecution:
+  - podAffinityTerm:
+      labelSelector:
+        matchLabels:
+          postgres-operator.crunchydata.com/cluster: hippo
+          postgres-operator.crunchydata.com/instance-set: instance1
+      topologyKey: kubernetes.io/hostname
+    weight: 1
+			`))
+		},
+	}, {
+		name: "check defined affinity replaces default",
+		ip: intentParams{
+			spec: &v1beta1.PostgresInstanceSetSpec{
+				Name: "instance1",
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+								MatchExpressions: []corev1.NodeSelectorRequirement{{
+									Key: "disktype", Operator: "In", Values: []string{"ssd"},
+								}},
+							}},
+						},
+					},
+				},
+			},
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Assert(t, marshalMatches(ss.Spec.Template.Spec.Affinity, `
+nodeAffinity:
+  requiredDuringSchedulingIgnoredDuringExecution:
+    nodeSelectorTerms:
+    - matchExpressions:
+      - key: disktype
+        operator: In
+        values:
+        - ssd
+			`))
+		},
+	}, {
+		name: "check no default affinity when defaults disabled",
+		ip: intentParams{
+			cluster: &v1beta1.PostgresCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "hippo",
+				},
+				Spec: v1beta1.PostgresClusterSpec{
+					PostgresVersion:             13,
+					DisableDefaultPodScheduling: initialize.Bool(true),
+					InstanceSets: []v1beta1.PostgresInstanceSetSpec{{
+						Name:                "instance1",
+						Replicas:            initialize.Int32(1),
+						DataVolumeClaimSpec: testVolumeClaimSpec(),
+					}},
+				},
+			},
+			spec: &v1beta1.PostgresInstanceSetSpec{
+				Name: "instance1",
+			},
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			assert.Assert(t, ss.Spec.Template.Spec.Affinity == nil)
+		},
 	}} {
 		t.Run(test.name, func(t *testing.T) {
 
@@ -1719,6 +2037,173 @@ func TestFindAvailableInstanceNames(t *testing.T) {
 	}
 }
 
+func TestValidateInstanceSetContainers(t *testing.T) {
+	path := field.NewPath("spec", "instances").Index(0)
+
+	t.Run("Empty", func(t *testing.T) {
+		set := new(v1beta1.PostgresInstanceSetSpec)
+		assert.Assert(t, len(validateInstanceSetContainers(set, path)) == 0)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		set := &v1beta1.PostgresInstanceSetSpec{
+			Containers:     []corev1.Container{{Name: "logger"}, {Name: "vault-agent"}},
+			InitContainers: []corev1.Container{{Name: "vault-agent-init"}},
+		}
+		assert.Assert(t, len(validateInstanceSetContainers(set, path)) == 0)
+	})
+
+	t.Run("Collision", func(t *testing.T) {
+		set := &v1beta1.PostgresInstanceSetSpec{
+			Containers: []corev1.Container{
+				{Name: "logger"}, {Name: "database"}, {Name: "pgbackrest"},
+			},
+			InitContainers: []corev1.Container{{Name: "postgres-startup"}},
+		}
+
+		errs := validateInstanceSetContainers(set, path)
+		fields := make([]string, len(errs))
+		for i := range errs {
+			fields[i] = errs[i].Field
+		}
+		assert.DeepEqual(t, fields, []string{
+			"spec.instances[0].containers[1].name",
+			"spec.instances[0].containers[2].name",
+			"spec.instances[0].initContainers[0].name",
+		})
+	})
+}
+
+func TestValidateInstanceSetEnvironment(t *testing.T) {
+	path := field.NewPath("spec", "instances").Index(0)
+
+	t.Run("Empty", func(t *testing.T) {
+		set := new(v1beta1.PostgresInstanceSetSpec)
+		assert.Assert(t, len(validateInstanceSetEnvironment(set, path)) == 0)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		set := &v1beta1.PostgresInstanceSetSpec{
+			Env: []corev1.EnvVar{{Name: "TZ", Value: "UTC"}, {Name: "PGAPPNAME", Value: "x"}},
+		}
+		assert.Assert(t, len(validateInstanceSetEnvironment(set, path)) == 0)
+	})
+
+	t.Run("Reserved", func(t *testing.T) {
+		set := &v1beta1.PostgresInstanceSetSpec{
+			Env: []corev1.EnvVar{
+				{Name: "TZ"}, {Name: "PGDATA"}, {Name: "PATRONI_SCOPE"},
+				{Name: "PATRONICTL_CONFIG_FILE"}, {Name: "LD_PRELOAD"},
+			},
+		}
+
+		errs := validateInstanceSetEnvironment(set, path)
+		fields := make([]string, len(errs))
+		for i := range errs {
+			fields[i] = errs[i].Field
+		}
+		assert.DeepEqual(t, fields, []string{
+			"spec.instances[0].env[1].name",
+			"spec.instances[0].env[2].name",
+			"spec.instances[0].env[3].name",
+			"spec.instances[0].env[4].name",
+		})
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		source := func(prefix string) corev1.EnvFromSource {
+			return corev1.EnvFromSource{Prefix: prefix,
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "bundle"},
+				}}
+		}
+
+		set := &v1beta1.PostgresInstanceSetSpec{}
+		set.EnvFrom = []corev1.EnvFromSource{source("")}
+		assert.Assert(t, len(validateInstanceSetEnvironment(set, path)) == 0)
+
+		// A key such as "SCOPE" would become PATRONI_SCOPE.
+		set.EnvFrom = append(set.EnvFrom, source("PATRONI_"), source("APP_"))
+		errs := validateInstanceSetEnvironment(set, path)
+		assert.Equal(t, len(errs), 2)
+		assert.Equal(t, errs[0].Field, "spec.instances[0].envFrom[1].prefix")
+		assert.Equal(t, errs[1].Field, "spec.instances[0].envFrom[2].prefix")
+	})
+}
+
+func TestAddInstanceEnvironment(t *testing.T) {
+	template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "database", Env: []corev1.EnvVar{{Name: "PGDATA", Value: "/pgdata/pg13"}}},
+			{Name: "pgbackrest"},
+		},
+	}}
+
+	spec := &v1beta1.PostgresInstanceSetSpec{}
+	spec.Env = []corev1.EnvVar{
+		{Name: "TZ", Value: "UTC"},
+		{Name: "PGDATA", Value: "/tmp"},
+		{Name: "VAULT_TOKEN", ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "vault"},
+				Key:                  "token",
+			},
+		}},
+		{Name: "LOCALE", ValueFrom: &corev1.EnvVarSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "settings"},
+				Key:                  "locale",
+			},
+		}},
+	}
+	spec.EnvFrom = []corev1.EnvFromSource{
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "bundle"},
+		}},
+		{SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "app"},
+		}},
+	}
+	addInstanceEnvironment(template, spec)
+
+	// Custom variables come after those of the operator, which are not replaced.
+	assert.Assert(t, marshalMatches(template.Spec.Containers[0].Env, `
+- name: PGDATA
+  value: /pgdata/pg13
+- name: TZ
+  value: UTC
+- name: VAULT_TOKEN
+  valueFrom:
+    secretKeyRef:
+      key: token
+      name: vault
+- name: LOCALE
+  valueFrom:
+    configMapKeyRef:
+      key: locale
+      name: settings
+	`))
+
+	// Sources keep their order; later sources win but every variable above
+	// takes precedence over them.
+	assert.Assert(t, marshalMatches(template.Spec.Containers[0].EnvFrom, `
+- configMapRef:
+    name: bundle
+- secretRef:
+    name: app
+	`))
+
+	// Other containers are unchanged.
+	assert.Assert(t, template.Spec.Containers[1].Env == nil)
+	assert.Assert(t, template.Spec.Containers[1].EnvFrom == nil)
+
+	// The input is not shared with the template.
+	spec.Env[2].ValueFrom.SecretKeyRef.Key = "changed"
+	spec.EnvFrom[0].ConfigMapRef.Name = "changed"
+	assert.Equal(t, template.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name, "bundle")
+	assert.Equal(t, template.Spec.Containers[0].Env[2].ValueFrom.SecretKeyRef.Key, "token")
+}
+
 func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 	ctx := context.Background()
 	_, cc := setupKubernetes(t)
@@ -1835,6 +2320,46 @@ func TestReconcileInstanceSetPodDisruptionBudget(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("replicas changed", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Name = "replicas-changed"
+		cluster.Namespace = ns.Name
+		spec := &cluster.Spec.InstanceSets[0]
+		spec.MinAvailable = nil
+
+		assert.NilError(t, r.Client.Create(ctx, cluster))
+		t.Cleanup(func() { assert.Check(t, r.Client.Delete(ctx, cluster)) })
+
+		// A single replica has nothing to protect by default.
+		spec.Replicas = initialize.Int32(1)
+		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec))
+		assert.Assert(t, !foundPDB(cluster, spec))
+
+		spec.Replicas = initialize.Int32(3)
+		assert.NilError(t, r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec))
+
+		pdb := &policyv1.PodDisruptionBudget{}
+		assert.NilError(t, r.Client.Get(ctx,
+			naming.AsObjectKey(naming.InstanceSet(cluster, spec)), pdb))
+		assert.DeepEqual(t, pdb.Spec.MinAvailable, initialize.IntOrStringInt32(1))
+		assert.DeepEqual(t, pdb.Spec.Selector, &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				naming.LabelCluster:     cluster.Name,
+				naming.LabelInstanceSet: spec.Name,
+			},
+		})
+		assert.Assert(t, metav1.IsControlledBy(pdb, cluster))
+
+		// Scaling back down removes the budget.
+		spec.Replicas = initialize.Int32(1)
+		err := r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+		if apierrors.IsConflict(err) {
+			err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, spec)
+		}
+		assert.NilError(t, err, errors.Unwrap(err))
+		assert.Assert(t, !foundPDB(cluster, spec))
+	})
 }
 
 func TestCleanupDisruptionBudgets(t *testing.T) {
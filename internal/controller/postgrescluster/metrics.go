@@ -0,0 +1,98 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// Metrics are measurements of the PostgresCluster controller in addition to
+// those controller-runtime keeps for every controller, such as the duration,
+// errors, and requeues of each call to Reconcile.
+// - https://book.kubebuilder.io/reference/metrics-reference.html
+type Metrics struct {
+	// Clusters reports the number of PostgresClusters in the cache.
+	Clusters prometheus.GaugeFunc
+
+	// StepErrors counts the steps of Reconcile that returned an error.
+	StepErrors *prometheus.CounterVec
+
+	// StepSeconds measures how long each step of Reconcile takes.
+	StepSeconds *prometheus.HistogramVec
+}
+
+// NewMetrics returns Metrics that count PostgresClusters using reader.
+func NewMetrics(reader client.Reader) *Metrics {
+	return &Metrics{
+		Clusters: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "postgrescluster",
+			Name:      "managed_clusters",
+			Help:      "Number of PostgresClusters managed by the controller",
+		}, func() float64 {
+			clusters := &v1beta1.PostgresClusterList{}
+			if err := reader.List(context.Background(), clusters); err != nil {
+				return math.NaN()
+			}
+			return float64(len(clusters.Items))
+		}),
+
+		StepErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "postgrescluster",
+			Name:      "reconcile_step_errors_total",
+			Help:      "Total number of errors returned by each step of Reconcile",
+		}, []string{"step"}),
+
+		StepSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "postgrescluster",
+			Name:      "reconcile_step_seconds",
+			Help:      "Length of time taken by each step of Reconcile",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"step"}),
+	}
+}
+
+// Register registers all of m with registerer.
+func (m *Metrics) Register(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		m.Clusters, m.StepErrors, m.StepSeconds,
+	} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeStep records that the step of Reconcile called name took duration
+// and returned err. It does nothing when m is nil.
+func (m *Metrics) observeStep(name string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.StepSeconds.WithLabelValues(name).Observe(duration.Seconds())
+
+	if err != nil {
+		m.StepErrors.WithLabelValues(name).Inc()
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gotest.tools/v3/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestMetrics(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	t.Run("Clusters", func(t *testing.T) {
+		one, two := new(v1beta1.PostgresCluster), new(v1beta1.PostgresCluster)
+		one.Namespace, one.Name = "ns1", "one"
+		two.Namespace, two.Name = "ns2", "two"
+
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(one, two).Build()
+		assert.Equal(t, testutil.ToFloat64(NewMetrics(reader).Clusters), float64(2))
+	})
+
+	t.Run("ClustersUnknown", func(t *testing.T) {
+		// The PostgresCluster kind is not in this scheme.
+		reader := fake.NewClientBuilder().Build()
+		assert.Assert(t, math.IsNaN(testutil.ToFloat64(NewMetrics(reader).Clusters)))
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		metrics := NewMetrics(fake.NewClientBuilder().WithScheme(scheme).Build())
+
+		assert.NilError(t, metrics.Register(registry))
+		assert.ErrorContains(t, metrics.Register(registry), "duplicate")
+	})
+}
+
+func TestReconcilerStep(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("NoMetrics", func(t *testing.T) {
+		r := &Reconciler{Tracer: otel.Tracer(t.Name())}
+
+		_, done := r.step(ctx, "some-step")
+		done(errors.New("boom"))
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		metrics := NewMetrics(fake.NewClientBuilder().Build())
+		r := &Reconciler{Metrics: metrics, Tracer: otel.Tracer(t.Name())}
+
+		_, done := r.step(ctx, "reconcileSomething")
+		done(nil)
+		_, done = r.step(ctx, "reconcileSomething")
+		done(errors.New("boom"))
+		_, done = r.step(ctx, "reconcileOther")
+		done(nil)
+
+		// One histogram for each step name.
+		assert.Equal(t, testutil.CollectAndCount(metrics.StepSeconds), 2)
+
+		// Only the error is counted.
+		assert.Equal(t, testutil.CollectAndCount(metrics.StepErrors), 1)
+		assert.Equal(t, testutil.ToFloat64(
+			metrics.StepErrors.WithLabelValues("reconcileSomething")), float64(1))
+	})
+
+	t.Run("Spans", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := trace.NewTracerProvider(trace.WithSpanProcessor(recorder))
+		r := &Reconciler{Tracer: provider.Tracer(t.Name())}
+
+		ctx, parent := r.Tracer.Start(ctx, "Reconcile")
+
+		stepCtx, done := r.step(ctx, "reconcileSomething")
+		assert.Assert(t, stepCtx != ctx)
+		done(nil)
+
+		_, done = r.step(ctx, "reconcileOther")
+		done(errors.New("boom"))
+
+		parent.End()
+
+		spans := recorder.Ended()
+		assert.Equal(t, len(spans), 3)
+
+		// Each step is a child of the span in ctx.
+		assert.Equal(t, spans[0].Name(), "reconcileSomething")
+		assert.Equal(t, spans[0].Parent().SpanID(), parent.SpanContext().SpanID())
+		assert.Equal(t, len(spans[0].Events()), 0)
+
+		// Errors are recorded on the span of the step.
+		assert.Equal(t, spans[1].Name(), "reconcileOther")
+		assert.Equal(t, spans[1].Parent().SpanID(), parent.SpanContext().SpanID())
+		assert.Equal(t, len(spans[1].Events()), 1)
+		assert.Equal(t, spans[1].Events()[0].Name, "exception")
+	})
+}
@@ -19,16 +19,22 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
@@ -62,13 +68,16 @@ func (r *Reconciler) handlePatroniRestarts(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
 ) error {
 	const container = naming.ContainerDatabase
-	var primaryNeedsRestart, replicaNeedsRestart *Instance
+	var primaryNeedsRestart, replicaNeedsRestart, replicaRequestedRestart *Instance
 
 	// Look for one primary and one replica that need to restart. Ignore
 	// containers that are terminating or not running; Kubernetes will start
 	// them again, and calls to their Patroni API will likely be interrupted anyway.
 	for _, instance := range instances.forCluster {
 		if len(instance.Pods) > 0 && patroni.PodRequiresRestart(instance.Pods[0]) {
+			if awaitingRollout(instance) {
+				continue
+			}
 			if terminating, known := instance.IsTerminating(); terminating || !known {
 				continue
 			}
@@ -78,15 +87,27 @@ func (r *Reconciler) handlePatroniRestarts(
 
 			if primary, _ := instance.IsPrimary(); primary {
 				primaryNeedsRestart = instance
+			} else if restartRequested(instance.Pods[0]) {
+				replicaRequestedRestart = instance
 			} else {
 				replicaNeedsRestart = instance
 			}
-			if primaryNeedsRestart != nil && replicaNeedsRestart != nil {
-				break
-			}
 		}
 	}
 
+	// Restarts requested by the operator happen one instance at a time, with
+	// replicas ahead of the primary. See [Reconciler.restartPatroniMember].
+	if replicaRequestedRestart != nil {
+		pod := replicaRequestedRestart.Pods[0]
+		exec := patroni.Executor(func(
+			ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
+		})
+
+		return r.restartPatroniMember(ctx, cluster, instances, pod, exec)
+	}
+
 	// When the primary instance needs to restart, restart it and return early.
 	// Some PostgreSQL settings must be changed on the primary before any
 	// progress can be made on the replicas, e.g. decreasing "max_connections".
@@ -99,13 +120,16 @@ func (r *Reconciler) handlePatroniRestarts(
 	// replicas here, replicas will typically restart first because we see them
 	// first.
 	if primaryNeedsRestart != nil {
+		pod := primaryNeedsRestart.Pods[0]
 		exec := patroni.Executor(func(
 			ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
 		) error {
-			pod := primaryNeedsRestart.Pods[0]
 			return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
 		})
 
+		if restartRequested(pod) {
+			return r.restartPatroniMember(ctx, cluster, instances, pod, exec)
+		}
 		return errors.WithStack(exec.RestartPendingMembers(ctx, "master", naming.PatroniScope(cluster)))
 	}
 
@@ -124,10 +148,10 @@ func (r *Reconciler) handlePatroniRestarts(
 	// how we decide when to restart.
 	// - https://www.postgresql.org/docs/current/runtime-config-replication.html
 	if replicaNeedsRestart != nil {
+		pod := replicaNeedsRestart.Pods[0]
 		exec := patroni.Executor(func(
 			ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
 		) error {
-			pod := replicaNeedsRestart.Pods[0]
 			return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
 		})
 
@@ -138,6 +162,102 @@ func (r *Reconciler) handlePatroniRestarts(
 	return nil
 }
 
+// reconcilePatroniRestart starts a rolling restart of the instances of cluster
+// when its [naming.PatroniRestart] annotation changes. It records the
+// annotation in cluster.Status.Patroni.Restart once every instance is marked.
+func (r *Reconciler) reconcilePatroniRestart(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	annotation := cluster.GetAnnotations()[naming.PatroniRestart]
+	status := cluster.Status.Patroni.Restart
+
+	if annotation == "" || (status != nil && *status == annotation) {
+		return nil
+	}
+
+	err := r.requestPatroniRestarts(ctx, instances, annotation)
+	if err == nil {
+		cluster.Status.Patroni.Restart = initialize.String(annotation)
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={patch}
+
+// requestPatroniRestarts adds the [naming.PatroniPendingRestart] annotation
+// with value to every instance Pod so that [Reconciler.handlePatroniRestarts]
+// restarts them in turn.
+func (r *Reconciler) requestPatroniRestarts(
+	ctx context.Context, instances *observedInstances, value string,
+) error {
+	var err error
+	for _, instance := range instances.forCluster {
+		for _, pod := range instance.Pods {
+			if err == nil && pod.Annotations[naming.PatroniPendingRestart] != value {
+				patch := kubeapi.NewMergePatch()
+				patch.Add("metadata", "annotations", naming.PatroniPendingRestart)(value)
+				err = errors.WithStack(r.patch(ctx, pod, patch))
+			}
+		}
+	}
+	return err
+}
+
+// restartRequested returns whether or not pod has the
+// [naming.PatroniPendingRestart] annotation.
+func restartRequested(pod *corev1.Pod) bool {
+	_, requested := pod.Annotations[naming.PatroniPendingRestart]
+	return requested
+}
+
+// awaitingRollout returns whether or not the Pod of instance has a different
+// [naming.PostgresConfigHash] than its StatefulSet. Such a Pod will be recreated
+// by [Reconciler.rolloutInstances], which also restarts PostgreSQL.
+func awaitingRollout(instance *Instance) bool {
+	if instance.Spec == nil || instance.Runner == nil || len(instance.Pods) == 0 {
+		return false
+	}
+
+	// Outdated Pods of an "OnDelete" set are left for someone else to delete.
+	if instance.Spec.UpdateStrategy == string(appsv1.OnDeleteStatefulSetStrategyType) {
+		return false
+	}
+
+	return instance.Pods[0].Annotations[naming.PostgresConfigHash] !=
+		instance.Runner.Spec.Template.Annotations[naming.PostgresConfigHash]
+}
+
+// +kubebuilder:rbac:groups="",resources="pods",verbs={patch}
+
+// restartPatroniMember restarts the Patroni member in pod and then removes the
+// [naming.PatroniPendingRestart] annotation from pod. The change in annotations
+// triggers another reconcile that restarts the next instance, if any.
+//
+// When pod is the primary and there are other instances, Patroni performs a
+// controlled switchover instead. Demoting the primary restarts its PostgreSQL.
+func (r *Reconciler) restartPatroniMember(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	instances *observedInstances, pod *corev1.Pod, exec patroni.Executor,
+) error {
+	var err error
+	if pod.Labels[naming.LabelRole] == naming.RolePatroniLeader && len(instances.forCluster) > 1 {
+		var success bool
+		success, err = exec.ChangePrimaryAndWait(ctx, pod.Name, "")
+		if err = errors.WithStack(err); err == nil && !success {
+			err = errors.New("unable to switchover")
+		}
+	} else {
+		err = errors.WithStack(exec.RestartMember(ctx, naming.PatroniScope(cluster), pod.Name))
+	}
+
+	if err == nil {
+		patch := kubeapi.NewMergePatch()
+		patch.Add("metadata", "annotations", naming.PatroniPendingRestart)(nil)
+		err = errors.WithStack(r.patch(ctx, pod, patch))
+	}
+	return err
+}
+
 // +kubebuilder:rbac:groups="",resources=services,verbs=create;patch
 
 // reconcilePatroniDistributedConfiguration sets labels and ownership on the
@@ -182,6 +302,7 @@ func (r *Reconciler) reconcilePatroniDistributedConfiguration(
 }
 
 // +kubebuilder:rbac:resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources="pods",verbs={patch}
 
 func (r *Reconciler) reconcilePatroniDynamicConfiguration(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
@@ -222,8 +343,43 @@ func (r *Reconciler) reconcilePatroniDynamicConfiguration(
 	}
 	configuration = patroni.DynamicConfiguration(cluster, configuration, pgHBAs, pgParameters)
 
-	return errors.WithStack(
+	// Read the current configuration to see which PostgreSQL parameters change.
+	current, readErr := patroni.NewExecClient(exec, *cluster.Spec.Patroni.Port).Configuration(ctx)
+
+	err := errors.WithStack(
 		patroni.Executor(exec).ReplaceConfiguration(ctx, configuration))
+	if err != nil {
+		return err
+	}
+
+	// Without the previous configuration, it is unknown which parameters
+	// changed. Patroni still applies them on its own, eventually.
+	if readErr != nil {
+		logging.FromContext(ctx).V(1).Info("unable to read Patroni configuration",
+			"error", readErr.Error())
+		return nil
+	}
+
+	var changed, restart []string
+	changed = patroni.ChangedParameters(current, configuration)
+	for _, name := range changed {
+		if postgres.ParameterRequiresRestart(name) {
+			restart = append(restart, name)
+		}
+	}
+
+	// Patroni applies reloadable parameters on its own, eventually. Reload now
+	// so they take effect without waiting.
+	if len(restart) == 0 {
+		if len(changed) > 0 {
+			err = errors.WithStack(patroni.Executor(exec).ReloadMembers(ctx,
+				naming.PatroniScope(cluster)))
+		}
+		return err
+	}
+
+	// Some parameters take effect only after PostgreSQL restarts.
+	return r.requestPatroniRestarts(ctx, instances, strings.Join(restart, ","))
 }
 
 // generatePatroniLeaderLeaseService returns a v1.Service that exposes the
@@ -260,10 +416,11 @@ func (r *Reconciler) generatePatroniLeaderLeaseService(
 
 	// The TargetPort must be the name (not the number) of the PostgreSQL
 	// ContainerPort. This name allows the port number to differ between
-	// instances, which can happen during a rolling update.
+	// instances, which can happen during a rolling update. The Port can differ
+	// from the ContainerPort when spec.service.port is set.
 	servicePort := corev1.ServicePort{
 		Name:       naming.PortPostgreSQL,
-		Port:       *cluster.Spec.Port,
+		Port:       postgres.ServicePort(cluster),
 		Protocol:   corev1.ProtocolTCP,
 		TargetPort: intstr.FromString(naming.PortPostgreSQL),
 	}
@@ -286,7 +443,12 @@ func (r *Reconciler) generatePatroniLeaderLeaseService(
 			}
 			servicePort.NodePort = *spec.NodePort
 		}
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+		}
 	}
+	addServiceIPFamilies(postgresServiceSpec(cluster), service)
+	addServiceSessionAffinity(postgresServiceSpec(cluster), service)
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
@@ -329,6 +491,8 @@ func (r *Reconciler) reconcilePatroniStatus(
 		}
 	}
 
+	r.observePatroniMembers(ctx, cluster, observedInstances)
+
 	dcs := &corev1.Endpoints{ObjectMeta: naming.PatroniDistributedConfiguration(cluster)}
 	err := errors.WithStack(client.IgnoreNotFound(
 		r.Client.Get(ctx, client.ObjectKeyFromObject(dcs), dcs)))
@@ -354,6 +518,133 @@ func (r *Reconciler) reconcilePatroniStatus(
 	return result, err
 }
 
+// warnSynchronousReplication emits a Warning event when cluster asks for
+// synchronous replication but has only one instance to replicate.
+func (r *Reconciler) warnSynchronousReplication(cluster *v1beta1.PostgresCluster) {
+	if !patroni.SynchronousReplication(cluster) {
+		return
+	}
+
+	var instances int32
+	for _, set := range cluster.Spec.InstanceSets {
+		if set.Replicas != nil {
+			instances += *set.Replicas
+		}
+	}
+	if instances > 1 {
+		return
+	}
+
+	if cluster.Spec.Patroni.SyncReplication.Strict {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "SynchronousReplicationUnavailable",
+			"Synchronous replication is strict but there is only one instance;"+
+				" writes to the primary will block until a replica is available")
+	} else {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "SynchronousReplicationUnavailable",
+			"Synchronous replication is enabled but there is only one instance;"+
+				" transactions will commit without a synchronous replica")
+	}
+}
+
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// observePatroniMembers asks Patroni for the members of cluster and adds their
+// roles, timelines, replication lag, and pending restarts to
+// cluster.Status.InstanceSets. It summarizes the latter in the
+// PostgresRestartPending condition. These are only observations, so any
+// problem is logged rather than returned.
+func (r *Reconciler) observePatroniMembers(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	observed *observedInstances,
+) {
+	var runningPod *corev1.Pod
+	for _, instance := range observed.forCluster {
+		if running, known := instance.IsRunning(naming.ContainerDatabase); running && known {
+			runningPod = instance.Pods[0]
+			break
+		}
+	}
+	if runningPod == nil {
+		return
+	}
+
+	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(runningPod.Namespace, runningPod.Name, naming.ContainerDatabase, stdin,
+			stdout, stderr, command...)
+	}
+
+	client := patroni.NewExecClient(exec, *cluster.Spec.Patroni.Port)
+	reported, err := client.Cluster(ctx)
+	if err != nil {
+		logging.FromContext(ctx).V(1).Info("unable to list Patroni members", "error", err.Error())
+
+		// Pending restarts are no longer known. Keep the instance statuses
+		// as they were observed last.
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:    v1beta1.PostgresRestartPending,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "PatroniUnavailable",
+			Message: "Unable to ask Patroni which instances need to restart",
+
+			ObservedGeneration: cluster.Generation,
+		})
+		return
+	}
+
+	// Patroni members are named after the Pod they run in.
+	byPod := make(map[string]patroni.ClusterMember, len(reported.Members))
+	for _, member := range reported.Members {
+		byPod[member.Name] = member
+	}
+
+	var pending []string
+	for i := range cluster.Status.InstanceSets {
+		status := &cluster.Status.InstanceSets[i]
+		status.Instances = nil
+
+		for _, instance := range observed.bySet[status.Name] {
+			if len(instance.Pods) != 1 {
+				continue
+			}
+			if member, ok := byPod[instance.Pods[0].Name]; ok {
+				status.Instances = append(status.Instances, v1beta1.PostgresInstanceStatus{
+					Name:                instance.Name,
+					Role:                member.Role,
+					State:               member.State,
+					Timeline:            member.Timeline,
+					ReplicationLagBytes: member.Lag,
+					PendingRestart:      member.PendingRestart,
+				})
+				if member.PendingRestart {
+					pending = append(pending, instance.Name)
+				}
+			}
+		}
+
+		sort.Slice(status.Instances, func(a, b int) bool {
+			return status.Instances[a].Name < status.Instances[b].Name
+		})
+	}
+
+	condition := metav1.Condition{
+		Type:    v1beta1.PostgresRestartPending,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoRestartPending",
+		Message: "No instance needs to restart",
+
+		ObservedGeneration: cluster.Generation,
+	}
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RestartPending"
+		condition.Message = fmt.Sprintf(
+			"Instances need to restart to apply parameters: %s", strings.Join(pending, ", "))
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
 // reconcileReplicationSecret creates a secret containing the TLS
 // certificate, key and CA certificate for use with the replication and
 // pg_rewind accounts in Postgres.
@@ -453,6 +744,26 @@ func replicationCertSecretProjection(certificate *corev1.Secret) *corev1.SecretP
 	}
 }
 
+// validatePatroniSwitchover returns an error when cluster asks for a failover
+// without saying which instance should become primary.
+func validatePatroniSwitchover(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	if cluster.Spec.Patroni == nil || cluster.Spec.Patroni.Switchover == nil {
+		return errs
+	}
+
+	spec := cluster.Spec.Patroni.Switchover
+	if spec.Enabled && spec.Type == v1beta1.PatroniSwitchoverTypeFailover &&
+		(spec.TargetInstance == nil || *spec.TargetInstance == "") {
+		errs = append(errs, field.Required(
+			field.NewPath("spec", "patroni", "switchover", "targetInstance"),
+			"TargetInstance required when running failover"))
+	}
+
+	return errs
+}
+
 func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 	cluster *v1beta1.PostgresCluster, instances *observedInstances) error {
 	log := logging.FromContext(ctx)
@@ -490,7 +801,7 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 		return errors.New("Need more than one instance to switchover")
 	}
 
-	// 	 TODO: Add webhook validation that requires a targetInstance when requesting failover
+	// The Validator webhook also rejects this; see validatePatroniSwitchover.
 	if spec.Type == v1beta1.PatroniSwitchoverTypeFailover {
 		if spec.TargetInstance == nil || *spec.TargetInstance == "" {
 			// TODO: event
@@ -516,6 +827,19 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 			return errors.Errorf(
 				"TargetInstance should have one pod. Pods (%d)", len(targetInstance.Pods))
 		}
+
+		// The target must be a running replica; Patroni cannot change the
+		// leader to an instance that is not running. When the target is
+		// already the leader, there is nothing to do; the request is complete.
+		if running, known := targetInstance.IsRunning(naming.ContainerDatabase); !running || !known {
+			return errors.New("TargetInstance is not running")
+		}
+		if primary, _ := targetInstance.IsPrimary(); primary {
+			log.V(1).Info("TargetInstance is already the primary, assuming completed switchover")
+			cluster.Status.Patroni.Switchover = initialize.String(annotation)
+			cluster.Status.Patroni.SwitchoverTimeline = nil
+			return nil
+		}
 	} else {
 		log.V(1).Info("TargetInstance not provided")
 	}
@@ -613,3 +937,70 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 
 	return err
 }
+
+// reconcilePatroniFailover forces a Patroni failover when cluster has the
+// failover annotation and none of its primary instances are ready. The
+// annotation is removed once the failover succeeds or is rejected so that it
+// is not acted on again later. When the failover fails, the annotation stays
+// and the error is returned so the failover is tried again.
+func (r *Reconciler) reconcilePatroniFailover(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, instances *observedInstances) error {
+	candidate, requested := cluster.GetAnnotations()[naming.PatroniFailover]
+	if !requested {
+		return nil
+	}
+
+	// Patch a copy of cluster so its status is not replaced by the response.
+	removeAnnotation := func() error {
+		return errors.WithStack(r.patch(ctx, cluster.DeepCopy(),
+			kubeapi.NewMergePatch().Remove("metadata", "annotations", naming.PatroniFailover)))
+	}
+
+	// A healthy primary should be changed gracefully using a switchover.
+	for _, instance := range instances.forCluster {
+		primary, _ := instance.IsPrimary()
+		ready, _ := instance.IsReady()
+
+		if primary && ready {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "FailoverRejected",
+				"Primary instance %q is healthy; use a switchover instead", instance.Name)
+			return removeAnnotation()
+		}
+	}
+
+	// Promote the requested instance or any running replica.
+	var target *Instance
+	for _, instance := range instances.forCluster {
+		running, _ := instance.IsRunning(naming.ContainerDatabase)
+		primary, _ := instance.IsPrimary()
+
+		if running && !primary && (candidate == "" || candidate == instance.Name) {
+			target = instance
+			break
+		}
+	}
+	if target == nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "FailoverRejected",
+			"Found no running replica to promote named %q", candidate)
+		return removeAnnotation()
+	}
+
+	pod := target.Pods[0]
+	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin,
+			stdout, stderr, command...)
+	}
+
+	success, err := patroni.Executor(exec).FailoverAndWait(ctx, pod.Name)
+	if err = errors.WithStack(err); err == nil && !success {
+		err = errors.New("unable to failover")
+	}
+	if err == nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "Failover",
+			"Promoted instance %q", target.Name)
+		err = removeAnnotation()
+	}
+
+	return err
+}
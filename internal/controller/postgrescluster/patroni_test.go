@@ -33,14 +33,20 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -127,13 +133,13 @@ ownerReferences:
 			"got %v", service.Spec.Selector)
 
 		// Add metadata to individual service
-		cluster.Spec.Service = &v1beta1.ServiceSpec{
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
 			Metadata: &v1beta1.Metadata{
 				Annotations: map[string]string{"c": "v3"},
 				Labels: map[string]string{"d": "v4",
 					"postgres-operator.crunchydata.com/cluster": "wrongName"},
 			},
-		}
+		}}
 
 		service, err = reconciler.generatePatroniLeaderLeaseService(cluster)
 		assert.NilError(t, err)
@@ -175,7 +181,7 @@ ownerReferences:
 	for _, test := range types {
 		t.Run(test.Type, func(t *testing.T) {
 			cluster := cluster.DeepCopy()
-			cluster.Spec.Service = &v1beta1.ServiceSpec{Type: test.Type}
+			cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{Type: test.Type}}
 
 			service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
 			assert.NilError(t, err)
@@ -232,12 +238,104 @@ ownerReferences:
 	for _, test := range typesAndPort {
 		t.Run(test.Description, func(t *testing.T) {
 			cluster := cluster.DeepCopy()
-			cluster.Spec.Service = &v1beta1.ServiceSpec{Type: test.Type, NodePort: test.NodePort}
+			cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{Type: test.Type, NodePort: test.NodePort}}
 
 			service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
 			test.Expect(t, service, err)
 		})
 	}
+
+	t.Run("LoadBalancerSourceRanges", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
+			Type:                     "LoadBalancer",
+			LoadBalancerSourceRanges: []string{"192.0.2.0/24", "198.51.100.7/32"},
+		}}
+
+		service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
+		assert.NilError(t, err)
+		alwaysExpect(t, service)
+		assert.DeepEqual(t, service.Spec.LoadBalancerSourceRanges,
+			[]string{"192.0.2.0/24", "198.51.100.7/32"})
+
+		// Other types of Service do not accept source ranges.
+		for _, serviceType := range []string{"ClusterIP", "NodePort"} {
+			cluster.Spec.Service.Type = serviceType
+
+			service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
+			assert.NilError(t, err)
+			assert.Assert(t, service.Spec.LoadBalancerSourceRanges == nil)
+		}
+	})
+
+	t.Run("Port", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{
+			ServiceSpec: v1beta1.ServiceSpec{
+				Type:     "NodePort",
+				NodePort: initialize.Int32(32003),
+			},
+			Port: initialize.Int32(6543),
+		}
+
+		service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
+		assert.NilError(t, err)
+		alwaysExpect(t, service)
+
+		// The port is set apart from the target port, which is always the name
+		// of the PostgreSQL ContainerPort.
+		assert.Assert(t, marshalMatches(service.Spec.Ports, `
+- name: postgres
+  nodePort: 32003
+  port: 6543
+  protocol: TCP
+  targetPort: postgres
+		`))
+	})
+
+	t.Run("IPFamilies", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		policy := corev1.IPFamilyPolicyRequireDualStack
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
+			Type:           "ClusterIP",
+			IPFamilyPolicy: &policy,
+			IPFamilies:     []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol},
+		}}
+
+		service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
+		assert.NilError(t, err)
+		alwaysExpect(t, service)
+
+		assert.Equal(t, *service.Spec.IPFamilyPolicy, corev1.IPFamilyPolicyRequireDualStack)
+		assert.DeepEqual(t, service.Spec.IPFamilies,
+			[]corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol})
+	})
+
+	t.Run("SessionAffinity", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
+		assert.NilError(t, err)
+
+		// Kubernetes decides by default, which is None.
+		assert.Equal(t, service.Spec.SessionAffinity, corev1.ServiceAffinity(""))
+		assert.Assert(t, service.Spec.SessionAffinityConfig == nil)
+
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
+			Type:                          "LoadBalancer",
+			SessionAffinity:               "ClientIP",
+			SessionAffinityTimeoutSeconds: initialize.Int32(1800),
+		}}
+
+		service, err = reconciler.generatePatroniLeaderLeaseService(cluster)
+		assert.NilError(t, err)
+		alwaysExpect(t, service)
+
+		assert.Equal(t, service.Spec.SessionAffinity, corev1.ServiceAffinityClientIP)
+		assert.Assert(t, marshalMatches(service.Spec.SessionAffinityConfig, `
+clientIP:
+  timeoutSeconds: 1800
+		`))
+	})
 }
 
 func TestReconcilePatroniLeaderLease(t *testing.T) {
@@ -268,7 +366,7 @@ func TestReconcilePatroniLeaderLease(t *testing.T) {
 	for _, serviceType := range serviceTypes {
 		t.Run(serviceType, func(t *testing.T) {
 			cluster := cluster.DeepCopy()
-			cluster.Spec.Service = &v1beta1.ServiceSpec{Type: serviceType}
+			cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{Type: serviceType}}
 
 			service, err := reconciler.reconcilePatroniLeaderLease(ctx, cluster)
 			assert.NilError(t, err)
@@ -287,7 +385,7 @@ func TestReconcilePatroniLeaderLease(t *testing.T) {
 		for _, changeType := range serviceTypes {
 			t.Run(beforeType+"To"+changeType, func(t *testing.T) {
 				cluster := cluster.DeepCopy()
-				cluster.Spec.Service = &v1beta1.ServiceSpec{Type: beforeType}
+				cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{Type: beforeType}}
 
 				before, err := reconciler.reconcilePatroniLeaderLease(ctx, cluster)
 				assert.NilError(t, err)
@@ -312,6 +410,32 @@ func TestReconcilePatroniLeaderLease(t *testing.T) {
 			})
 		}
 	}
+
+	t.Run("LoadBalancerSourceRanges", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Service = &v1beta1.PostgresServiceSpec{ServiceSpec: v1beta1.ServiceSpec{
+			Type:                     "LoadBalancer",
+			LoadBalancerSourceRanges: []string{"192.0.2.0/24"},
+		}}
+
+		before, err := reconciler.reconcilePatroniLeaderLease(ctx, cluster)
+		assert.NilError(t, err)
+		t.Cleanup(func() { assert.Check(t, cc.Delete(ctx, before)) })
+		assert.DeepEqual(t, before.Spec.LoadBalancerSourceRanges, []string{"192.0.2.0/24"})
+
+		// Changing the type removes the source ranges from the same Service.
+		cluster.Spec.Service.Type = "ClusterIP"
+
+		after, err := reconciler.reconcilePatroniLeaderLease(ctx, cluster)
+		if apierrors.IsConflict(err) {
+			t.Log("conflict:", err)
+			after, err = reconciler.reconcilePatroniLeaderLease(ctx, cluster)
+		}
+		assert.NilError(t, err, "\n%#v", errors.Unwrap(err))
+		assert.Equal(t, after.UID, before.UID)
+		assert.Equal(t, after.Spec.Type, corev1.ServiceTypeClusterIP)
+		assert.Assert(t, after.Spec.LoadBalancerSourceRanges == nil)
+	})
 }
 
 func TestPatroniReplicationSecret(t *testing.T) {
@@ -324,7 +448,11 @@ func TestPatroniReplicationSecret(t *testing.T) {
 	require.ParallelCapacity(t, 0)
 
 	ctx := context.Background()
-	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name())}
+	r := &Reconciler{
+		Client:   tClient,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: new(record.FakeRecorder),
+	}
 
 	// test postgrescluster values
 	var (
@@ -527,17 +655,559 @@ func TestReconcilePatroniStatus(t *testing.T) {
 	}
 }
 
+func TestWarnSynchronousReplication(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+	cluster.Default()
+
+	warnings := func(cluster *v1beta1.PostgresCluster) []string {
+		recorder := record.NewFakeRecorder(1)
+		(&Reconciler{Recorder: recorder}).warnSynchronousReplication(cluster)
+		close(recorder.Events)
+
+		var events []string
+		for event := range recorder.Events {
+			events = append(events, event)
+		}
+		return events
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Equal(t, len(warnings(cluster)), 0)
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.SyncReplication = &v1beta1.PatroniSyncReplication{Enabled: false}
+		assert.Equal(t, len(warnings(cluster)), 0)
+	})
+
+	cluster.Spec.Patroni.SyncReplication = &v1beta1.PatroniSyncReplication{Enabled: true}
+	cluster.Default()
+
+	t.Run("SingleInstance", func(t *testing.T) {
+		events := warnings(cluster)
+		assert.Equal(t, len(events), 1)
+		assert.Assert(t, strings.HasPrefix(events[0], "Warning SynchronousReplicationUnavailable"))
+		assert.Assert(t, strings.Contains(events[0], "without a synchronous replica"), events[0])
+
+		t.Run("Strict", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Patroni.SyncReplication.Strict = true
+
+			events := warnings(cluster)
+			assert.Equal(t, len(events), 1)
+			assert.Assert(t, strings.Contains(events[0], "writes to the primary will block"), events[0])
+		})
+	})
+
+	t.Run("Replicas", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets[0].Replicas = initialize.Int32(2)
+		assert.Equal(t, len(warnings(cluster)), 0)
+
+		cluster.Spec.InstanceSets[0].Replicas = initialize.Int32(1)
+		cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+			v1beta1.PostgresInstanceSetSpec{Name: "01", Replicas: initialize.Int32(1)})
+		assert.Equal(t, len(warnings(cluster)), 0)
+	})
+}
+
+func TestObservePatroniMembers(t *testing.T) {
+	ctx := context.Background()
+
+	running := func(name string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = "ns1", name
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+		return pod
+	}
+
+	newObserved := func() (*v1beta1.PostgresCluster, *observedInstances) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Default()
+		cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{
+			{Name: "00"}, {Name: "01"},
+		}
+
+		observed := &observedInstances{bySet: map[string][]*Instance{
+			"00": {
+				{Name: "hippo-00-wxyz", Pods: []*corev1.Pod{running("hippo-00-wxyz-0")}},
+				{Name: "hippo-00-abcd", Pods: []*corev1.Pod{running("hippo-00-abcd-0")}},
+			},
+			"01": {
+				{Name: "hippo-01-mnop"},
+			},
+		}}
+		observed.forCluster = append(observed.bySet["00"], observed.bySet["01"]...)
+
+		return cluster, observed
+	}
+
+	t.Run("NoRunningPods", func(t *testing.T) {
+		r := &Reconciler{PodExec: func(
+			string, string, string, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			t.Fatal("expected no exec")
+			return nil
+		}}
+
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Default()
+		cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{{Name: "00"}}
+		observed := &observedInstances{forCluster: []*Instance{{Name: "nothing"}}}
+
+		r.observePatroniMembers(ctx, cluster, observed)
+		assert.Assert(t, cluster.Status.InstanceSets[0].Instances == nil)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		r := &Reconciler{PodExec: func(
+			string, string, string, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			return errors.New("boom")
+		}}
+
+		cluster, observed := newObserved()
+		r.observePatroniMembers(ctx, cluster, observed)
+		assert.Assert(t, cluster.Status.InstanceSets[0].Instances == nil)
+		assert.Assert(t, cluster.Status.InstanceSets[1].Instances == nil)
+
+		condition := meta.FindStatusCondition(
+			cluster.Status.Conditions, v1beta1.PostgresRestartPending)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionUnknown)
+		assert.Equal(t, condition.Reason, "PatroniUnavailable")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		var calls int
+		r := &Reconciler{PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			calls++
+			assert.Equal(t, namespace, "ns1")
+			assert.Equal(t, container, naming.ContainerDatabase)
+			assert.Equal(t, command[0], "python3")
+			assert.DeepEqual(t, command[3:5], []string{"GET", "https://localhost:8008/cluster"})
+
+			_, err := stdout.Write([]byte(`200
+{"members": [
+{"name": "hippo-00-abcd-0", "role": "leader", "state": "running", "host": "hippo-00-abcd-0.hippo-pods", "timeline": 5},
+{"name": "hippo-00-wxyz-0", "role": "replica", "state": "streaming", "host": "hippo-00-wxyz-0.hippo-pods", "timeline": 5, "lag": 50331648}
+]}`))
+			return err
+		}}
+
+		cluster, observed := newObserved()
+		r.observePatroniMembers(ctx, cluster, observed)
+		assert.Equal(t, calls, 1)
+
+		// Instances are sorted by name.
+		assert.DeepEqual(t, cluster.Status.InstanceSets[0].Instances,
+			[]v1beta1.PostgresInstanceStatus{
+				{Name: "hippo-00-abcd", Role: "leader", State: "running", Timeline: 5},
+				{
+					Name: "hippo-00-wxyz", Role: "replica", State: "streaming", Timeline: 5,
+					ReplicationLagBytes: initialize.Int64(50331648),
+				},
+			})
+
+		// An instance without a Pod is not a member.
+		assert.Assert(t, cluster.Status.InstanceSets[1].Instances == nil)
+
+		condition := meta.FindStatusCondition(
+			cluster.Status.Conditions, v1beta1.PostgresRestartPending)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "NoRestartPending")
+	})
+
+	t.Run("PendingRestart", func(t *testing.T) {
+		r := &Reconciler{PodExec: func(
+			_, _, _ string, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, err := stdout.Write([]byte(`200
+{"members": [
+{"name": "hippo-00-abcd-0", "role": "leader", "state": "running", "timeline": 5},
+{"name": "hippo-00-wxyz-0", "role": "replica", "state": "streaming", "timeline": 5, "lag": 0, "pending_restart": true}
+]}`))
+			return err
+		}}
+
+		cluster, observed := newObserved()
+		r.observePatroniMembers(ctx, cluster, observed)
+
+		instances := cluster.Status.InstanceSets[0].Instances
+		assert.Equal(t, len(instances), 2)
+		assert.Assert(t, !instances[0].PendingRestart)
+		assert.Assert(t, instances[1].PendingRestart)
+
+		condition := meta.FindStatusCondition(
+			cluster.Status.Conditions, v1beta1.PostgresRestartPending)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "RestartPending")
+		assert.Equal(t, condition.Message,
+			"Instances need to restart to apply parameters: hippo-00-wxyz")
+	})
+}
+
+func TestReconcilePatroniDynamicConfiguration(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Status.Patroni.SystemIdentifier = "123"
+
+	pod := func(name string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = cluster.Namespace, name+"-0"
+		pod.Labels = map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstance:    name,
+			naming.LabelInstanceSet: "00",
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+		return pod
+	}
+
+	// Patroni currently has these PostgreSQL parameters.
+	current := `200
+{"loop_wait": 10, "postgresql": {"parameters": {"shared_buffers": "128MB", "work_mem": "4MB"}}}`
+
+	// setup returns a Reconciler with running Pods and the commands it executes.
+	// Requests to the Patroni API get response, or an error when it is empty.
+	setup := func(response string) (*Reconciler, *observedInstances, *[]string) {
+		pods := []corev1.Pod{*pod("hippo-00-abcd"), *pod("hippo-00-wxyz")}
+		builder := fake.NewClientBuilder()
+		for i := range pods {
+			builder = builder.WithObjects(pods[i].DeepCopy())
+		}
+
+		var commands []string
+		r := &Reconciler{Client: builder.Build(), PodExec: func(
+			_, _, _ string, _ io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			if command[0] == "python3" {
+				commands = append(commands, command[3]+" "+command[4])
+				if response == "" {
+					return errors.New("container not running")
+				}
+				_, err := stdout.Write([]byte(response))
+				return err
+			}
+			commands = append(commands, strings.Join(command, " "))
+			return nil
+		}}
+		return r, newObservedInstances(cluster, nil, pods), &commands
+	}
+
+	annotations := func(t *testing.T, r *Reconciler) []string {
+		var values []string
+		for _, name := range []string{"hippo-00-abcd-0", "hippo-00-wxyz-0"} {
+			pod := &corev1.Pod{}
+			assert.NilError(t, r.Client.Get(ctx,
+				client.ObjectKey{Namespace: cluster.Namespace, Name: name}, pod))
+			values = append(values, pod.Annotations[naming.PatroniPendingRestart])
+		}
+		return values
+	}
+
+	configure := func(parameters map[string]interface{}) *v1beta1.PostgresCluster {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{"parameters": parameters},
+		}
+		return cluster
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		r, observed, commands := setup(current)
+		cluster := configure(map[string]interface{}{
+			"shared_buffers": "128MB", "work_mem": "4MB",
+		})
+
+		assert.NilError(t, r.reconcilePatroniDynamicConfiguration(
+			ctx, cluster, observed, postgres.HBAs{}, postgres.Parameters{}))
+		assert.DeepEqual(t, *commands, []string{
+			"GET https://localhost:8008/config",
+			"patronictl edit-config --replace=- --force",
+		})
+		assert.DeepEqual(t, annotations(t, r), []string{"", ""})
+	})
+
+	t.Run("Reloadable", func(t *testing.T) {
+		r, observed, commands := setup(current)
+		cluster := configure(map[string]interface{}{
+			"shared_buffers": "128MB", "work_mem": "8MB",
+		})
+
+		assert.NilError(t, r.reconcilePatroniDynamicConfiguration(
+			ctx, cluster, observed, postgres.HBAs{}, postgres.Parameters{}))
+		assert.DeepEqual(t, *commands, []string{
+			"GET https://localhost:8008/config",
+			"patronictl edit-config --replace=- --force",
+			"patronictl reload --force hippo-ha",
+		})
+		assert.DeepEqual(t, annotations(t, r), []string{"", ""})
+	})
+
+	t.Run("RestartRequired", func(t *testing.T) {
+		r, observed, commands := setup(current)
+		cluster := configure(map[string]interface{}{
+			"shared_buffers": "256MB", "work_mem": "8MB",
+		})
+
+		assert.NilError(t, r.reconcilePatroniDynamicConfiguration(
+			ctx, cluster, observed, postgres.HBAs{}, postgres.Parameters{}))
+		assert.DeepEqual(t, *commands, []string{
+			"GET https://localhost:8008/config",
+			"patronictl edit-config --replace=- --force",
+		})
+		assert.DeepEqual(t, annotations(t, r), []string{"shared_buffers", "shared_buffers"})
+	})
+
+	t.Run("UnchangedLargeNumber", func(t *testing.T) {
+		// Patroni returns numbers that Go decodes as float64.
+		r, observed, commands := setup(`200
+{"postgresql": {"parameters": {"autovacuum_freeze_max_age": 200000000}}}`)
+		cluster := configure(map[string]interface{}{
+			"autovacuum_freeze_max_age": int64(200000000),
+		})
+
+		assert.NilError(t, r.reconcilePatroniDynamicConfiguration(
+			ctx, cluster, observed, postgres.HBAs{}, postgres.Parameters{}))
+		assert.DeepEqual(t, *commands, []string{
+			"GET https://localhost:8008/config",
+			"patronictl edit-config --replace=- --force",
+		})
+		assert.DeepEqual(t, annotations(t, r), []string{"", ""})
+	})
+
+	t.Run("ReadFailed", func(t *testing.T) {
+		r, observed, commands := setup("")
+		cluster := configure(map[string]interface{}{
+			"shared_buffers": "256MB", "work_mem": "8MB",
+		})
+
+		// The configuration is applied without a reload or restart.
+		assert.NilError(t, r.reconcilePatroniDynamicConfiguration(
+			ctx, cluster, observed, postgres.HBAs{}, postgres.Parameters{}))
+		assert.DeepEqual(t, *commands, []string{
+			"GET https://localhost:8008/config",
+			"patronictl edit-config --replace=- --force",
+		})
+		assert.DeepEqual(t, annotations(t, r), []string{"", ""})
+	})
+}
+
+func TestReconcilePatroniRestart(t *testing.T) {
+	ctx := context.Background()
+
+	pod := func(name string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = "ns1", name+"-0"
+		pod.Labels = map[string]string{
+			naming.LabelCluster:     "hippo",
+			naming.LabelInstance:    name,
+			naming.LabelInstanceSet: "00",
+		}
+		return pod
+	}
+
+	setup := func() (*Reconciler, *v1beta1.PostgresCluster, *observedInstances) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+		pods := []corev1.Pod{*pod("hippo-00-abcd"), *pod("hippo-00-wxyz")}
+		builder := fake.NewClientBuilder()
+		for i := range pods {
+			builder = builder.WithObjects(pods[i].DeepCopy())
+		}
+		return &Reconciler{Client: builder.Build()}, cluster,
+			newObservedInstances(cluster, nil, pods)
+	}
+
+	requested := func(t *testing.T, r *Reconciler) []string {
+		pods := &corev1.PodList{}
+		assert.NilError(t, r.Client.List(ctx, pods, client.InNamespace("ns1")))
+
+		var values []string
+		for _, pod := range pods.Items {
+			values = append(values, pod.Annotations[naming.PatroniPendingRestart])
+		}
+		return values
+	}
+
+	t.Run("NoAnnotation", func(t *testing.T) {
+		r, cluster, observed := setup()
+
+		assert.NilError(t, r.reconcilePatroniRestart(ctx, cluster, observed))
+		assert.DeepEqual(t, requested(t, r), []string{"", ""})
+		assert.Assert(t, cluster.Status.Patroni.Restart == nil)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		r, cluster, observed := setup()
+		cluster.Annotations = map[string]string{naming.PatroniRestart: "2022-10-01T12:00:00Z"}
+		cluster.Status.Patroni.Restart = initialize.String("2022-09-01T12:00:00Z")
+
+		assert.NilError(t, r.reconcilePatroniRestart(ctx, cluster, observed))
+		assert.DeepEqual(t, requested(t, r),
+			[]string{"2022-10-01T12:00:00Z", "2022-10-01T12:00:00Z"})
+		assert.DeepEqual(t, cluster.Status.Patroni.Restart,
+			initialize.String("2022-10-01T12:00:00Z"))
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		r, cluster, observed := setup()
+		cluster.Annotations = map[string]string{naming.PatroniRestart: "2022-10-01T12:00:00Z"}
+		cluster.Status.Patroni.Restart = initialize.String("2022-10-01T12:00:00Z")
+
+		assert.NilError(t, r.reconcilePatroniRestart(ctx, cluster, observed))
+		assert.DeepEqual(t, requested(t, r), []string{"", ""})
+	})
+}
+
+func TestHandlePatroniRestarts(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	pod := func(name, role string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = cluster.Namespace, name+"-0"
+		pod.Annotations = map[string]string{naming.PatroniPendingRestart: "shared_buffers"}
+		pod.Labels = map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstance:    name,
+			naming.LabelInstanceSet: "00",
+			naming.LabelRole:        role,
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+		return pod
+	}
+
+	// setup returns a Reconciler for pods and the commands it executes.
+	setup := func(pods ...client.Object) (*Reconciler, *[]string) {
+		var commands []string
+		return &Reconciler{
+			Client: fake.NewClientBuilder().WithObjects(pods...).Build(),
+			PodExec: func(
+				_, pod, _ string, _ io.Reader, stdout, _ io.Writer, command ...string,
+			) error {
+				commands = append(commands, pod+": "+strings.Join(command, " "))
+				if command[1] == "switchover" {
+					_, err := stdout.Write([]byte(`Successfully switched over to "hippo-00-wxyz-0"`))
+					return err
+				}
+				return nil
+			},
+		}, &commands
+	}
+
+	observe := func(t *testing.T, r *Reconciler) *observedInstances {
+		pods := &corev1.PodList{}
+		assert.NilError(t, r.Client.List(ctx, pods, client.InNamespace(cluster.Namespace)))
+		return newObservedInstances(cluster, nil, pods.Items)
+	}
+
+	t.Run("ReplicasFirst", func(t *testing.T) {
+		r, commands := setup(
+			pod("hippo-00-abcd", naming.RolePatroniLeader),
+			pod("hippo-00-wxyz", naming.RolePatroniReplica))
+
+		assert.NilError(t, r.handlePatroniRestarts(ctx, cluster, observe(t, r)))
+		assert.DeepEqual(t, *commands, []string{
+			"hippo-00-wxyz-0: patronictl restart --force hippo-ha hippo-00-wxyz-0",
+		})
+
+		// The primary switches over rather than restarting in place.
+		assert.NilError(t, r.handlePatroniRestarts(ctx, cluster, observe(t, r)))
+		assert.DeepEqual(t, *commands, []string{
+			"hippo-00-wxyz-0: patronictl restart --force hippo-ha hippo-00-wxyz-0",
+			"hippo-00-abcd-0: patronictl switchover --scheduled=now --force --master=hippo-00-abcd-0 --candidate=",
+		})
+
+		// Nothing else needs to restart.
+		assert.NilError(t, r.handlePatroniRestarts(ctx, cluster, observe(t, r)))
+		assert.Equal(t, len(*commands), 2)
+
+		for _, instance := range observe(t, r).forCluster {
+			assert.Assert(t, !patroni.PodRequiresRestart(instance.Pods[0]))
+		}
+	})
+
+	t.Run("OnlyInstance", func(t *testing.T) {
+		r, commands := setup(pod("hippo-00-abcd", naming.RolePatroniLeader))
+
+		assert.NilError(t, r.handlePatroniRestarts(ctx, cluster, observe(t, r)))
+		assert.DeepEqual(t, *commands, []string{
+			"hippo-00-abcd-0: patronictl restart --force hippo-ha hippo-00-abcd-0",
+		})
+		assert.Assert(t, !patroni.PodRequiresRestart(observe(t, r).forCluster[0].Pods[0]))
+	})
+
+	t.Run("AwaitingRollout", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}}
+
+		leader := pod("hippo-00-abcd", naming.RolePatroniLeader)
+		leader.Annotations[naming.PostgresConfigHash] = "before"
+
+		runner := appsv1.StatefulSet{}
+		runner.Name = "hippo-00-abcd"
+		runner.Labels = map[string]string{naming.LabelInstanceSet: "00"}
+		runner.Spec.Template.Annotations = map[string]string{
+			naming.PostgresConfigHash: "after",
+		}
+
+		r, commands := setup(leader)
+		observed := newObservedInstances(cluster,
+			[]appsv1.StatefulSet{runner}, []corev1.Pod{*leader})
+
+		// Recreating the Pod restarts PostgreSQL, so Patroni does not.
+		assert.NilError(t, r.handlePatroniRestarts(ctx, cluster, observed))
+		assert.Equal(t, len(*commands), 0)
+
+		// Patroni restarts PostgreSQL when the Pod is left for someone else.
+		cluster.Spec.InstanceSets[0].UpdateStrategy =
+			string(appsv1.OnDeleteStatefulSetStrategyType)
+		observed = newObservedInstances(cluster,
+			[]appsv1.StatefulSet{runner}, []corev1.Pod{*leader})
+
+		assert.NilError(t, r.handlePatroniRestarts(ctx, cluster, observed))
+		assert.DeepEqual(t, *commands, []string{
+			"hippo-00-abcd-0: patronictl restart --force hippo-ha hippo-00-abcd-0",
+		})
+	})
+}
+
 func TestReconcilePatroniSwitchover(t *testing.T) {
 	_, client := setupKubernetes(t)
 	require.ParallelCapacity(t, 0)
 
 	var called, failover, callError, callFails bool
 	var timelineCallNoLeader, timelineCall bool
+	var lastCommand []string
 	r := Reconciler{
 		Client: client,
 		PodExec: func(namespace, pod, container string,
 			stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
 			called = true
+			lastCommand = command
 			switch {
 			case timelineCall:
 				timelineCall = false
@@ -741,10 +1411,46 @@ func TestReconcilePatroniSwitchover(t *testing.T) {
 			observed := &observedInstances{forCluster: instances}
 
 			assert.Error(t, r.reconcilePatroniSwitchover(ctx, cluster, observed),
-				"Could not find a running pod when attempting switchover.")
+				"TargetInstance is not running")
+		})
+
+		t.Run("is primary", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Status.Patroni.SwitchoverTimeline = initialize.Int64(2)
+
+			observed := getObserved()
+			observed.forCluster[0].Pods[0].Labels = map[string]string{
+				naming.LabelRole: naming.RolePatroniLeader,
+			}
+
+			// The request is complete without calling Patroni.
+			r := r
+			r.PodExec = func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+				panic("unexpected call to PodExec")
+			}
+
+			assert.NilError(t, r.reconcilePatroniSwitchover(ctx, cluster, observed))
+			assert.Equal(t, *cluster.Status.Patroni.Switchover, "trigger")
+			assert.Assert(t, cluster.Status.Patroni.SwitchoverTimeline == nil)
 		})
 	})
 
+	t.Run("no running pod", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Annotations = map[string]string{
+			naming.PatroniSwitchover: "trigger",
+		}
+		cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+			Switchover: &v1beta1.PatroniSwitchover{Enabled: true},
+		}
+
+		observed := &observedInstances{forCluster: []*Instance{
+			{Name: "one"}, {Name: "two"},
+		}}
+		assert.Error(t, r.reconcilePatroniSwitchover(ctx, cluster, observed),
+			"Could not find a running pod when attempting switchover.")
+	})
+
 	t.Run("need replica to switch", func(t *testing.T) {
 		cluster := testCluster()
 		cluster.Annotations = map[string]string{
@@ -975,6 +1681,9 @@ func TestReconcilePatroniSwitchover(t *testing.T) {
 		called, failover, callError, callFails = false, false, false, false
 		assert.NilError(t, r.reconcilePatroniSwitchover(ctx, cluster, getObserved()))
 		assert.Assert(t, called)
+		assert.DeepEqual(t, lastCommand, []string{
+			"patronictl", "switchover", "--scheduled=now", "--force", "--candidate=pod",
+		})
 		assert.Equal(t, *cluster.Status.Patroni.Switchover, "trigger")
 		assert.Assert(t, cluster.Status.Patroni.SwitchoverTimeline == nil)
 	})
@@ -1005,3 +1714,157 @@ func TestReconcilePatroniSwitchover(t *testing.T) {
 		assert.Assert(t, cluster.Status.Patroni.SwitchoverTimeline == nil)
 	})
 }
+
+func TestReconcilePatroniFailover(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	var commands [][]string
+	output := "failed over"
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+		PodExec: func(namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+			commands = append(commands, command)
+			_, err := stdout.Write([]byte(output))
+			return err
+		},
+	}
+
+	// instance returns an instance with one running Pod.
+	instance := func(name string, leader, ready bool) *Instance {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = "some-ns", name+"-0"
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionFalse,
+		}}
+		if leader {
+			pod.Labels = map[string]string{naming.LabelRole: naming.RolePatroniLeader}
+		}
+		if ready {
+			pod.Status.Conditions[0].Status = corev1.ConditionTrue
+		}
+		return &Instance{Name: name, Pods: []*corev1.Pod{pod}}
+	}
+
+	// setup creates a cluster that requests a failover to candidate.
+	setup := func(t *testing.T, candidate string) *v1beta1.PostgresCluster {
+		t.Cleanup(func() {
+			commands, output = nil, "failed over"
+			recorder.Events = recorder.Events[:0]
+		})
+
+		cluster := testCluster()
+		cluster.Namespace = setupNamespace(t, cc).Name
+		cluster.Annotations = map[string]string{naming.PatroniFailover: candidate}
+		assert.NilError(t, cc.Create(ctx, cluster))
+		return cluster
+	}
+
+	// annotated returns whether or not cluster still requests a failover.
+	annotated := func(t *testing.T, cluster *v1beta1.PostgresCluster) bool {
+		stored := &v1beta1.PostgresCluster{}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(cluster), stored))
+		_, ok := stored.Annotations[naming.PatroniFailover]
+		return ok
+	}
+
+	t.Run("NotRequested", func(t *testing.T) {
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("one", true, false), instance("two", false, true),
+		}}
+
+		assert.NilError(t, r.reconcilePatroniFailover(ctx, testCluster(), observed))
+		assert.Equal(t, len(commands), 0)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("HealthyPrimary", func(t *testing.T) {
+		cluster := setup(t, "")
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("one", true, true), instance("two", false, true),
+		}}
+
+		assert.NilError(t, r.reconcilePatroniFailover(ctx, cluster, observed))
+		assert.Equal(t, len(commands), 0, "expected no call to Patroni")
+		assert.Assert(t, !annotated(t, cluster))
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "FailoverRejected")
+		assert.Assert(t, strings.Contains(recorder.Events[0].Note, `"one"`),
+			"expected primary name, got %q", recorder.Events[0].Note)
+	})
+
+	t.Run("UnhealthyPrimary", func(t *testing.T) {
+		cluster := setup(t, "")
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("one", true, false), instance("two", false, true),
+		}}
+
+		assert.NilError(t, r.reconcilePatroniFailover(ctx, cluster, observed))
+		assert.DeepEqual(t, commands, [][]string{
+			{"patronictl", "failover", "--force", "--candidate=two-0"},
+		})
+		assert.Assert(t, !annotated(t, cluster))
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeNormal)
+		assert.Equal(t, recorder.Events[0].Reason, "Failover")
+	})
+
+	t.Run("Candidate", func(t *testing.T) {
+		cluster := setup(t, "three")
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("one", true, false),
+			instance("two", false, true),
+			instance("three", false, true),
+		}}
+
+		assert.NilError(t, r.reconcilePatroniFailover(ctx, cluster, observed))
+		assert.DeepEqual(t, commands, [][]string{
+			{"patronictl", "failover", "--force", "--candidate=three-0"},
+		})
+		assert.Assert(t, !annotated(t, cluster))
+	})
+
+	t.Run("CandidateNotFound", func(t *testing.T) {
+		cluster := setup(t, "missing")
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("one", true, false), instance("two", false, true),
+		}}
+
+		assert.NilError(t, r.reconcilePatroniFailover(ctx, cluster, observed))
+		assert.Equal(t, len(commands), 0, "expected no call to Patroni")
+		assert.Assert(t, !annotated(t, cluster))
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "FailoverRejected")
+	})
+
+	t.Run("FailoverFails", func(t *testing.T) {
+		cluster := setup(t, "")
+		observed := &observedInstances{forCluster: []*Instance{
+			instance("one", true, false), instance("two", false, true),
+		}}
+
+		output = "bang"
+		assert.Error(t, r.reconcilePatroniFailover(ctx, cluster, observed), "unable to failover")
+		assert.Equal(t, len(commands), 1)
+
+		// The annotation remains so the failover is tried again.
+		assert.Assert(t, annotated(t, cluster))
+	})
+}
@@ -191,7 +191,12 @@ func (r *Reconciler) generatePGAdminService(
 			}
 			servicePort.NodePort = *spec.NodePort
 		}
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+		}
 	}
+	addServiceIPFamilies(cluster.Spec.UserInterface.PGAdmin.Service, service)
+	addServiceSessionAffinity(cluster.Spec.UserInterface.PGAdmin.Service, service)
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
@@ -328,6 +333,8 @@ func (r *Reconciler) reconcilePGAdminStatefulSet(
 	// set the image pull secrets, if any exist
 	sts.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
 
+	addDNSConfig(cluster, &sts.Spec.Template.Spec)
+
 	if err := errors.WithStack(r.setControllerReference(cluster, sts)); err != nil {
 		return err
 	}
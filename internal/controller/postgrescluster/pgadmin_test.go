@@ -360,6 +360,20 @@ ownerReferences:
 
 		})
 	}
+
+	t.Run("LoadBalancerSourceRanges", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.UserInterface.PGAdmin.Service = &v1beta1.ServiceSpec{
+			Type:                     "LoadBalancer",
+			LoadBalancerSourceRanges: []string{"192.0.2.0/24"},
+		}
+
+		service, specified, err := reconciler.generatePGAdminService(cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, specified)
+		alwaysExpect(t, service)
+		assert.DeepEqual(t, service.Spec.LoadBalancerSourceRanges, []string{"192.0.2.0/24"})
+	})
 }
 
 func TestReconcilePGAdminService(t *testing.T) {
@@ -396,6 +410,25 @@ func TestReconcilePGAdminService(t *testing.T) {
 			"expected to be assigned a ClusterIP")
 	})
 
+	t.Run("Removed", func(t *testing.T) {
+		service, err := reconciler.reconcilePGAdminService(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, service != nil)
+
+		key := client.ObjectKeyFromObject(service)
+		assert.NilError(t, cc.Get(ctx, key, new(corev1.Service)))
+
+		disabled := cluster.DeepCopy()
+		disabled.Spec.UserInterface = nil
+
+		removed, err := reconciler.reconcilePGAdminService(ctx, disabled)
+		assert.NilError(t, err)
+		assert.Assert(t, removed == nil)
+
+		err = cc.Get(ctx, key, new(corev1.Service))
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+	})
+
 	serviceTypes := []string{"ClusterIP", "NodePort", "LoadBalancer"}
 
 	// Confirm that each ServiceType can be reconciled.
@@ -656,6 +689,23 @@ topologySpreadConstraints:
 
 		assert.Assert(t, cmp.MarshalMatches(template.Spec, compare))
 	})
+
+	t.Run("verify StatefulSet removed", func(t *testing.T) {
+		assert.NilError(t, reconciler.reconcilePGAdminStatefulSet(ctx, cluster, configmap, pvc))
+
+		disabled := cluster.DeepCopy()
+		disabled.Spec.UserInterface = nil
+		assert.NilError(t, reconciler.reconcilePGAdminStatefulSet(ctx, disabled, configmap, pvc))
+
+		sts := &appsv1.StatefulSet{ObjectMeta: naming.ClusterPGAdmin(cluster)}
+		err := cc.Get(ctx, client.ObjectKeyFromObject(sts), sts)
+		if err == nil {
+			// The StatefulSet may linger while it is being deleted.
+			assert.Assert(t, sts.DeletionTimestamp != nil)
+		} else {
+			assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+		}
+	})
 }
 
 func TestReconcilePGAdminDataVolume(t *testing.T) {
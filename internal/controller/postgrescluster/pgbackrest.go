@@ -543,6 +543,8 @@ func (r *Reconciler) generateRepoHostIntent(postgresCluster *v1beta1.PostgresClu
 	// https://github.com/kubernetes/kubernetes/issues/88456
 	repo.Spec.Template.Spec.ImagePullSecrets = postgresCluster.Spec.ImagePullSecrets
 
+	addDNSConfig(postgresCluster, &repo.Spec.Template.Spec)
+
 	// determine if any PG Pods still exist
 	var instancePodExists bool
 	for _, instance := range observedInstances.forCluster {
@@ -574,9 +576,13 @@ func (r *Reconciler) generateRepoHostIntent(postgresCluster *v1beta1.PostgresClu
 	// - https://docs.k8s.io/tasks/configure-pod-container/share-process-namespace/
 	repo.Spec.Template.Spec.ShareProcessNamespace = initialize.Bool(true)
 
-	// pgBackRest does not make any Kubernetes API calls. Use the default
-	// ServiceAccount and do not mount its credentials.
+	// pgBackRest does not make any Kubernetes API calls, but it may interact
+	// with a cloud storage provider. Use the instance ServiceAccount for its
+	// possible cloud identity without mounting its Kubernetes API credentials.
+	// - https://cloud.google.com/kubernetes-engine/docs/concepts/workload-identity
+	// - https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts.html
 	repo.Spec.Template.Spec.AutomountServiceAccountToken = initialize.Bool(false)
+	repo.Spec.Template.Spec.ServiceAccountName = naming.ClusterInstanceRBAC(postgresCluster).Name
 
 	// Do not add environment variables describing services in this namespace.
 	repo.Spec.Template.Spec.EnableServiceLinks = initialize.Bool(false)
@@ -736,6 +742,8 @@ func generateBackupJobSpecIntent(postgresCluster *v1beta1.PostgresCluster,
 	// https://github.com/kubernetes/kubernetes/issues/88456
 	jobSpec.Template.Spec.ImagePullSecrets = postgresCluster.Spec.ImagePullSecrets
 
+	addDNSConfig(postgresCluster, &jobSpec.Template.Spec)
+
 	// add pgBackRest configs to template
 	if containerName == naming.PGBackRestRepoContainerName {
 		pgbackrest.AddConfigToRepoPod(postgresCluster, &jobSpec.Template.Spec)
@@ -1180,6 +1188,8 @@ func (r *Reconciler) generateRestoreJobIntent(cluster *v1beta1.PostgresCluster,
 	// https://github.com/kubernetes/kubernetes/issues/88456
 	job.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
 
+	addDNSConfig(cluster, &job.Spec.Template.Spec)
+
 	// pgBackRest does not make any Kubernetes API calls, but it may interact
 	// with a cloud storage provider. Use the instance ServiceAccount for its
 	// possible cloud identity without mounting its Kubernetes API credentials.
@@ -1225,6 +1235,12 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		postgresCluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{}
 	}
 
+	// report any repositories that pgBackRest will be unable to use
+	if errs := pgbackrest.ValidateRepos(postgresCluster); len(errs) > 0 {
+		r.Recorder.Event(postgresCluster, corev1.EventTypeWarning, "InvalidBackupRepo",
+			errs.ToAggregate().Error())
+	}
+
 	// create the Result that will be updated while reconciling any/all pgBackRest resources
 	result := reconcile.Result{}
 
@@ -2006,7 +2022,7 @@ func (r *Reconciler) reconcileDedicatedRepoHost(ctx context.Context,
 	}()
 	var isCreate bool
 	if len(repoResources.hosts) == 0 {
-		name := fmt.Sprintf("%s-%s", postgresCluster.GetName(), "repo-host")
+		name := naming.PGBackRestRepoHost(postgresCluster).Name
 		repoResources.hosts = append(repoResources.hosts, &appsv1.StatefulSet{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: name,
@@ -2530,7 +2546,7 @@ func (r *Reconciler) reconcileStanzaCreate(ctx context.Context,
 		writable, known := instance.IsWritable()
 		if writable && known {
 			clusterWritable = true
-			writableInstanceName = instance.Name + "-0"
+			writableInstanceName = naming.InstancePodName(instance.Name)
 			break
 		}
 	}
@@ -2892,6 +2908,8 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 	pgBackRestCronJob.Spec.JobTemplate.Spec.Template.Spec.ImagePullSecrets =
 		cluster.Spec.ImagePullSecrets
 
+	addDNSConfig(cluster, &pgBackRestCronJob.Spec.JobTemplate.Spec.Template.Spec)
+
 	// set metadata
 	pgBackRestCronJob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("CronJob"))
 	err = errors.WithStack(r.setControllerReference(cluster, pgBackRestCronJob))
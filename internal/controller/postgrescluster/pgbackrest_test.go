@@ -46,6 +46,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -1467,6 +1468,7 @@ func TestGetPGBackRestResources(t *testing.T) {
 
 	type testResult struct {
 		jobCount, hostCount, pvcCount int
+		cronJobCount                  int
 	}
 
 	testCases := []struct {
@@ -1736,6 +1738,98 @@ func TestGetPGBackRestResources(t *testing.T) {
 		result: testResult{
 			jobCount: 0, pvcCount: 0, hostCount: 0,
 		},
+	}, {
+		desc: "schedule still defined keep cronjob",
+		createResources: []client.Object{
+			&batchv1.CronJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "keep-cronjob",
+					Namespace: namespace,
+					Labels:    naming.PGBackRestCronJobLabels(clusterName, "repo1", full),
+				},
+				Spec: batchv1.CronJobSpec{
+					Schedule: "@daily",
+					JobTemplate: batchv1.JobTemplateSpec{
+						Spec: batchv1.JobSpec{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers:    []corev1.Container{{Name: "test", Image: "test"}},
+									RestartPolicy: corev1.RestartPolicyNever,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		cluster: &v1beta1.PostgresCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: namespace,
+				UID:       types.UID(clusterUID),
+			},
+			Spec: v1beta1.PostgresClusterSpec{
+				Backups: v1beta1.Backups{
+					PGBackRest: v1beta1.PGBackRestArchive{
+						Repos: []v1beta1.PGBackRestRepo{{
+							Name: "repo1",
+							BackupSchedules: &v1beta1.PGBackRestBackupSchedules{
+								Full: initialize.String("@daily"),
+							},
+						}},
+					},
+				},
+			},
+		},
+		result: testResult{
+			jobCount: 0, pvcCount: 0, hostCount: 0, cronJobCount: 1,
+		},
+	}, {
+		desc: "schedule no longer exists delete cronjob",
+		createResources: []client.Object{
+			&batchv1.CronJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "delete-cronjob",
+					Namespace: namespace,
+					Labels:    naming.PGBackRestCronJobLabels(clusterName, "repo1", incremental),
+				},
+				Spec: batchv1.CronJobSpec{
+					Schedule: "@hourly",
+					JobTemplate: batchv1.JobTemplateSpec{
+						Spec: batchv1.JobSpec{
+							Template: corev1.PodTemplateSpec{
+								Spec: corev1.PodSpec{
+									Containers:    []corev1.Container{{Name: "test", Image: "test"}},
+									RestartPolicy: corev1.RestartPolicyNever,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		cluster: &v1beta1.PostgresCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName,
+				Namespace: namespace,
+				UID:       types.UID(clusterUID),
+			},
+			Spec: v1beta1.PostgresClusterSpec{
+				Backups: v1beta1.Backups{
+					PGBackRest: v1beta1.PGBackRestArchive{
+						Repos: []v1beta1.PGBackRestRepo{{
+							Name: "repo1",
+							BackupSchedules: &v1beta1.PGBackRestBackupSchedules{
+								Full: initialize.String("@daily"),
+							},
+						}},
+					},
+				},
+			},
+		},
+		result: testResult{
+			jobCount: 0, pvcCount: 0, hostCount: 0, cronJobCount: 0,
+		},
 	}}
 
 	for _, tc := range testCases {
@@ -1753,6 +1847,7 @@ func TestGetPGBackRestResources(t *testing.T) {
 				assert.Assert(t, tc.result.jobCount == len(resources.replicaCreateBackupJobs))
 				assert.Assert(t, tc.result.hostCount == len(resources.hosts))
 				assert.Assert(t, tc.result.pvcCount == len(resources.pvcs))
+				assert.Assert(t, tc.result.cronJobCount == len(resources.cronjobs))
 			}
 		})
 	}
@@ -1993,6 +2088,12 @@ func TestReconcilePostgresClusterDataSource(t *testing.T) {
 				if len(restoreJobs.Items) == 1 {
 					assert.Assert(t, restoreJobs.Items[0].Labels[naming.LabelStartupInstance] != "")
 					assert.Assert(t, restoreJobs.Items[0].Annotations[naming.PGBackRestConfigHash] != "")
+
+					// The restore reads from the repository of the source cluster.
+					command := restoreJobs.Items[0].Spec.Template.Spec.Containers[0].Command
+					repo := "--repo=" + strings.TrimPrefix(tc.dataSource.PostgresCluster.RepoName, "repo")
+					assert.Assert(t, strings.Contains(command[len(command)-1], repo),
+						"expected %q in %q", repo, command)
 				}
 
 				dataPVCs := &corev1.PersistentVolumeClaimList{}
@@ -2542,6 +2643,29 @@ volumes:
 		assert.Equal(t, job.Template.Spec.Containers[0].ImagePullPolicy, corev1.PullAlways)
 	})
 
+	t.Run("DNSConfig", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{
+			Spec: v1beta1.PostgresClusterSpec{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{
+					Nameservers: []string{"10.0.0.10"},
+				},
+				HostAliases: []corev1.HostAlias{{
+					IP: "10.1.2.3", Hostnames: []string{"kms.corp.example.com"},
+				}},
+			},
+		}
+		job, err := generateBackupJobSpecIntent(
+			cluster, v1beta1.PGBackRestRepo{},
+			"",
+			nil, nil,
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, job.Template.Spec.DNSPolicy, corev1.DNSNone)
+		assert.DeepEqual(t, job.Template.Spec.DNSConfig, cluster.Spec.DNSConfig)
+		assert.DeepEqual(t, job.Template.Spec.HostAliases, cluster.Spec.HostAliases)
+	})
+
 	t.Run("Resources", func(t *testing.T) {
 		cluster := &v1beta1.PostgresCluster{
 			Spec: v1beta1.PostgresClusterSpec{},
@@ -2682,7 +2806,7 @@ func TestGenerateRepoHostIntent(t *testing.T) {
 	assert.NilError(t, err)
 
 	t.Run("ServiceAccount", func(t *testing.T) {
-		assert.Equal(t, sts.Spec.Template.Spec.ServiceAccountName, "")
+		assert.Equal(t, sts.Spec.Template.Spec.ServiceAccountName, "-instance")
 		if assert.Check(t, sts.Spec.Template.Spec.AutomountServiceAccountToken != nil) {
 			assert.Equal(t, *sts.Spec.Template.Spec.AutomountServiceAccountToken, false)
 		}
@@ -2922,6 +3046,66 @@ func TestGenerateRestoreJobIntent(t *testing.T) {
 	}
 }
 
+func TestReconcileRestoreJobPointInTime(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{
+		Client:   tClient,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, tClient).Name
+	assert.NilError(t, tClient.Create(ctx, cluster))
+	t.Cleanup(func() { assert.Check(t, tClient.Delete(ctx, cluster)) })
+
+	pgdata := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pgdata"}}
+
+	t.Run("Target", func(t *testing.T) {
+		dataSource := &v1beta1.PostgresClusterDataSource{
+			RepoName: "repo2",
+			Options:  []string{"--type=time", `--target="2021-06-09 14:15:11-04"`},
+		}
+
+		assert.NilError(t, r.reconcileRestoreJob(ctx, cluster, nil, pgdata, nil,
+			dataSource, "instance-name", "instance1", "hash", "db"))
+
+		job := &batchv1.Job{ObjectMeta: naming.PGBackRestRestoreJob(cluster)}
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(job), job))
+
+		command := job.Spec.Template.Spec.Containers[0].Command
+		assert.Equal(t, command[len(command)-1], strings.Join([]string{
+			"--type=time", `--target="2021-06-09 14:15:11-04"`,
+			"--stanza=db", "--pg1-path=/pgdata/pg13", "--repo=2", "--delta",
+			"--target-action=promote",
+			"--link-map=pg_wal=/pgdata/pg13_wal",
+		}, " "))
+	})
+
+	t.Run("TargetAction", func(t *testing.T) {
+		dataSource := &v1beta1.PostgresClusterDataSource{
+			RepoName: "repo1",
+			Options:  []string{"--type=time", "--target-action=pause"},
+		}
+
+		// The operator sets the target action, so the Job is unchanged.
+		assert.NilError(t, r.reconcileRestoreJob(ctx, cluster, nil, pgdata, nil,
+			dataSource, "instance-name", "instance1", "hash", "db"))
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning InvalidDataSource"))
+
+		job := &batchv1.Job{ObjectMeta: naming.PGBackRestRestoreJob(cluster)}
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(job), job))
+
+		command := job.Spec.Template.Spec.Containers[0].Command
+		assert.Assert(t, strings.Contains(command[len(command)-1], "--repo=2"))
+	})
+}
+
 func TestObserveRestoreEnv(t *testing.T) {
 	ctx := context.Background()
 	_, tClient := setupKubernetes(t)
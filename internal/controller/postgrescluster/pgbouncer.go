@@ -24,9 +24,13 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -53,6 +57,11 @@ func (r *Reconciler) reconcilePGBouncer(
 	if err == nil {
 		configmap, err = r.reconcilePGBouncerConfigMap(ctx, cluster)
 	}
+	if err == nil && configmap == nil &&
+		cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		// PgBouncer has never had a valid configuration; wait for one.
+		return nil
+	}
 	if err == nil {
 		secret, err = r.reconcilePGBouncerSecret(ctx, cluster, root, service)
 	}
@@ -89,6 +98,25 @@ func (r *Reconciler) reconcilePGBouncerConfigMap(
 		return nil, client.IgnoreNotFound(err)
 	}
 
+	warnings, invalid := pgbouncer.ValidateConfig(cluster)
+	if len(warnings) > 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidPGBouncerConfig",
+			warnings.ToAggregate().Error())
+	}
+	if len(invalid) > 0 {
+		// Leave the existing ConfigMap, if any, in place until the spec is
+		// fixed. Return it as it is so nothing depends on the invalid spec.
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidPGBouncerConfig",
+			invalid.ToAggregate().Error())
+
+		key := client.ObjectKeyFromObject(configmap)
+		err := errors.WithStack(r.Client.Get(ctx, key, configmap))
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			return nil, nil
+		}
+		return configmap, err
+	}
+
 	err := errors.WithStack(r.setControllerReference(cluster, configmap))
 
 	configmap.Annotations = naming.Merge(
@@ -192,7 +220,7 @@ func (r *Reconciler) reconcilePGBouncerInPostgreSQL(
 	return err
 }
 
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;delete;patch
 
 // reconcilePGBouncerSecret writes the Secret for a PgBouncer Pod.
@@ -236,8 +264,31 @@ func (r *Reconciler) reconcilePGBouncerSecret(
 			naming.LabelRole:    naming.RolePGBouncer,
 		})
 
+	// Gather the Secrets of PostgreSQL users so their verifiers can be
+	// included in the PgBouncer auth file.
+	secrets := &corev1.SecretList{}
+	if err == nil {
+		var selector labels.Selector
+		selector, err = naming.AsSelector(naming.ClusterPostgresUsers(cluster.Name))
+		if err == nil {
+			err = errors.WithStack(
+				r.Client.List(ctx, secrets,
+					client.InNamespace(cluster.Namespace),
+					client.MatchingLabelsSelector{Selector: selector},
+				))
+		}
+	}
+
+	userSecrets := make(map[string]*corev1.Secret, len(secrets.Items))
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if name := secret.Labels[naming.LabelPostgresUser]; name != "" {
+			userSecrets[name] = secret
+		}
+	}
+
 	if err == nil {
-		err = pgbouncer.Secret(ctx, cluster, root, existing, service, intent)
+		err = pgbouncer.Secret(ctx, cluster, root, existing, service, userSecrets, intent)
 	}
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, intent))
@@ -315,7 +366,12 @@ func (r *Reconciler) generatePGBouncerService(
 			}
 			servicePort.NodePort = *spec.NodePort
 		}
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+		}
 	}
+	addServiceIPFamilies(cluster.Spec.Proxy.PGBouncer.Service, service)
+	addServiceSessionAffinity(cluster.Spec.Proxy.PGBouncer.Service, service)
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
@@ -389,6 +445,48 @@ func (r *Reconciler) generatePGBouncerDeployment(
 			naming.LabelRole:    naming.RolePGBouncer,
 		})
 
+	// Let Prometheus discover the exporter, when there is one.
+	if cluster.Spec.Proxy.PGBouncer.Exporter != nil {
+		deploy.Spec.Template.Annotations = naming.Merge(
+			deploy.Spec.Template.Annotations,
+			map[string]string{
+				"prometheus.io/scrape": "true",
+				"prometheus.io/port":   fmt.Sprint(pgbouncer.ExporterPort),
+				"prometheus.io/path":   "/metrics",
+			})
+	}
+
+	// Changes to the ConfigMap and Secret reach mounted files eventually, but
+	// not at any predictable time. When asked, restart PgBouncer whenever its
+	// configuration changes so that every Pod applies it.
+	if cluster.Spec.Proxy.PGBouncer.Config.RestartOnChange {
+		hash, err := safeHash32(func(w io.Writer) error {
+			_, err := io.WriteString(w, pgbouncer.ConfigFiles(cluster, secret))
+			return err
+		})
+		if err != nil {
+			return deploy, true, err
+		}
+		deploy.Spec.Template.Annotations = naming.Merge(
+			deploy.Spec.Template.Annotations,
+			map[string]string{naming.PGBouncerConfigHash: hash})
+	}
+
+	// PgBouncer reloads its configuration when it changes, but it reads some
+	// settings only when it starts. Restart PgBouncer when any of those change.
+	if startup := pgbouncer.StartupSettings(cluster); len(startup) > 0 {
+		hash, err := safeHash32(func(w io.Writer) error {
+			_, err := io.WriteString(w, startup)
+			return err
+		})
+		if err != nil {
+			return deploy, true, err
+		}
+		deploy.Spec.Template.Annotations = naming.Merge(
+			deploy.Spec.Template.Annotations,
+			map[string]string{naming.PGBouncerStartupHash: hash})
+	}
+
 	// if the shutdown flag is set, set pgBouncer replicas to 0
 	if cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown {
 		deploy.Spec.Replicas = initialize.Int32(0)
@@ -426,6 +524,12 @@ func (r *Reconciler) generatePGBouncerDeployment(
 		deploy.Spec.Template.Spec.TopologySpreadConstraints = append(
 			deploy.Spec.Template.Spec.TopologySpreadConstraints,
 			defaultTopologySpreadConstraints(*deploy.Spec.Selector)...)
+
+		// prefer to schedule PgBouncer pods on different nodes unless the
+		// spec already has its own scheduling constraints
+		if cluster.Spec.Proxy.PGBouncer.Affinity == nil {
+			deploy.Spec.Template.Spec.Affinity = defaultPodAntiAffinity(*deploy.Spec.Selector)
+		}
 	}
 
 	// Restart containers any time they stop, die, are killed, etc.
@@ -440,22 +544,36 @@ func (r *Reconciler) generatePGBouncerDeployment(
 	// There's no need for individual DNS names of PgBouncer pods.
 	deploy.Spec.Template.Spec.Subdomain = ""
 
-	// PgBouncer does not make any Kubernetes API calls. Use the default
-	// ServiceAccount and do not mount its credentials.
+	// PgBouncer does not make any Kubernetes API calls. Use the instance
+	// ServiceAccount for its possible cloud identity without mounting its
+	// Kubernetes API credentials.
 	deploy.Spec.Template.Spec.AutomountServiceAccountToken = initialize.Bool(false)
+	deploy.Spec.Template.Spec.ServiceAccountName = naming.ClusterInstanceRBAC(cluster).Name
 
 	// Do not add environment variables describing services in this namespace.
 	deploy.Spec.Template.Spec.EnableServiceLinks = initialize.Bool(false)
 
 	deploy.Spec.Template.Spec.SecurityContext = initialize.PodSecurityContext()
+	mergePodSecurityContext(deploy.Spec.Template.Spec.SecurityContext,
+		cluster.Spec.Proxy.PGBouncer.SecurityContext)
 
 	// set the image pull secrets, if any exist
 	deploy.Spec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
 
+	addDNSConfig(cluster, &deploy.Spec.Template.Spec)
+
 	err := errors.WithStack(r.setControllerReference(cluster, deploy))
 
 	if err == nil {
 		pgbouncer.Pod(cluster, configmap, primaryCertificate, secret, &deploy.Spec.Template.Spec)
+
+		// Leave the security of custom sidecars as they are.
+		custom := sets.NewString()
+		for i := range cluster.Spec.Proxy.PGBouncer.Containers {
+			custom.Insert(cluster.Spec.Proxy.PGBouncer.Containers[i].Name)
+		}
+		mergeContainerSecurityContext(&deploy.Spec.Template,
+			cluster.Spec.Proxy.PGBouncer.ContainerSecurityContext, custom)
 	}
 
 	return deploy, true, err
@@ -473,6 +591,17 @@ func (r *Reconciler) reconcilePGBouncerDeployment(
 	deploy, specified, err := r.generatePGBouncerDeployment(
 		cluster, primaryCertificate, configmap, secret)
 
+	if specified {
+		if warnings := validateSecurityContexts(
+			cluster.Spec.Proxy.PGBouncer.SecurityContext,
+			cluster.Spec.Proxy.PGBouncer.ContainerSecurityContext,
+			field.NewPath("spec", "proxy", "pgBouncer"),
+		); len(warnings) > 0 {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidSecurityContext",
+				warnings.ToAggregate().Error())
+		}
+	}
+
 	// Set observations whether the deployment exists or not.
 	defer func() {
 		cluster.Status.Proxy.PGBouncer.Replicas = deploy.Status.Replicas
@@ -20,19 +20,24 @@ package postgrescluster
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -267,6 +272,20 @@ ownerReferences:
 			assert.Assert(t, specified)
 		})
 	}
+
+	t.Run("LoadBalancerSourceRanges", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Service = &v1beta1.ServiceSpec{
+			Type:                     "LoadBalancer",
+			LoadBalancerSourceRanges: []string{"192.0.2.0/24"},
+		}
+
+		service, specified, err := reconciler.generatePGBouncerService(cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, specified)
+		alwaysExpect(t, service)
+		assert.DeepEqual(t, service.Spec.LoadBalancerSourceRanges, []string{"192.0.2.0/24"})
+	})
 }
 
 func TestReconcilePGBouncerService(t *testing.T) {
@@ -357,6 +376,80 @@ func TestReconcilePGBouncerService(t *testing.T) {
 	}
 }
 
+func TestReconcilePGBouncerConfigMap(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, cc.Create(ctx, cluster))
+	cluster.Default()
+
+	t.Run("ReservedDatabase", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Databases = map[string]string{
+			"pgbouncer": "host=elsewhere",
+		}
+
+		configmap, err := reconciler.reconcilePGBouncerConfigMap(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, configmap == nil, "expected no ConfigMap, got %v", configmap)
+
+		// The ConfigMap is not written.
+		configmap = &corev1.ConfigMap{ObjectMeta: naming.ClusterPGBouncer(cluster)}
+		err = cc.Get(ctx, client.ObjectKeyFromObject(configmap), configmap)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidPGBouncerConfig")
+		assert.Assert(t, strings.Contains(recorder.Events[0].Note, "databases[pgbouncer]"),
+			"got %q", recorder.Events[0].Note)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		configmap, err := reconciler.reconcilePGBouncerConfigMap(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, configmap != nil)
+		assert.Assert(t, len(configmap.Data["pgbouncer.ini"]) > 0)
+		assert.Equal(t, len(recorder.Events), 0)
+
+		// A reserved name does not change the existing ConfigMap.
+		before := configmap.DeepCopy()
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Databases = map[string]string{
+			"pgbouncer": "host=elsewhere",
+		}
+
+		returned, err := reconciler.reconcilePGBouncerConfigMap(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(recorder.Events), 1)
+
+		// The existing ConfigMap is returned.
+		assert.Assert(t, returned != nil)
+		assert.DeepEqual(t, before.Data, returned.Data)
+
+		after := new(corev1.ConfigMap)
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(before), after))
+		assert.DeepEqual(t, before.Data, after.Data)
+	})
+}
+
 func TestGeneratePGBouncerDeployment(t *testing.T) {
 	_, cc := setupKubernetes(t)
 	require.ParallelCapacity(t, 0)
@@ -445,6 +538,178 @@ namespace: ns3
 		})
 	})
 
+	t.Run("Exporter", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Metadata = &v1beta1.Metadata{
+			Annotations: map[string]string{"a": "v1"},
+		}
+
+		deploy, _, err := reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+
+		// No exporter by default.
+		assert.DeepEqual(t, deploy.Spec.Template.Annotations, map[string]string{"a": "v1"})
+		for _, container := range deploy.Spec.Template.Spec.Containers {
+			assert.Assert(t, container.Name != "pgbouncer-exporter")
+		}
+
+		cluster.Spec.Proxy.PGBouncer.Exporter = &v1beta1.PGBouncerExporterSpec{}
+
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+
+		// Prometheus can discover the exporter.
+		assert.DeepEqual(t, deploy.Spec.Template.Annotations, map[string]string{
+			"a":                    "v1",
+			"prometheus.io/path":   "/metrics",
+			"prometheus.io/port":   "9127",
+			"prometheus.io/scrape": "true",
+		})
+
+		var names []string
+		for _, container := range deploy.Spec.Template.Spec.Containers {
+			names = append(names, container.Name)
+		}
+		assert.DeepEqual(t, names, []string{"pgbouncer", "pgbouncer-config", "pgbouncer-exporter"})
+	})
+
+	t.Run("ReservedLabels", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Metadata = &v1beta1.Metadata{
+			Labels: map[string]string{
+				"postgres-operator.crunchydata.com/cluster": "other",
+				"postgres-operator.crunchydata.com/role":    "other",
+			},
+		}
+		cluster.Spec.Proxy.PGBouncer.Metadata = &v1beta1.Metadata{
+			Labels: map[string]string{
+				"postgres-operator.crunchydata.com/role": "another",
+			},
+		}
+
+		deploy, specified, err := reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, specified)
+
+		// Labels set by the operator win over those in the spec.
+		for _, labels := range []map[string]string{
+			deploy.Labels, deploy.Spec.Template.Labels,
+		} {
+			assert.DeepEqual(t, labels, map[string]string{
+				"postgres-operator.crunchydata.com/cluster": "test-cluster",
+				"postgres-operator.crunchydata.com/role":    "pgbouncer",
+			})
+		}
+	})
+
+	t.Run("StartupSettings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+			"pool_mode": "transaction",
+		}
+
+		deploy, _, err := reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+
+		// Settings that PgBouncer reloads do not change the pod template.
+		assert.Assert(t, deploy.Spec.Template.Annotations[naming.PGBouncerStartupHash] == "")
+
+		cluster.Spec.Proxy.PGBouncer.Config.Global["listen_addr"] = "0.0.0.0"
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+
+		first := deploy.Spec.Template.Annotations[naming.PGBouncerStartupHash]
+		assert.Assert(t, first != "")
+
+		// Identical settings have an identical hash.
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster.DeepCopy(), primary, configmap, secret.DeepCopy())
+		assert.NilError(t, err)
+		assert.Equal(t, deploy.Spec.Template.Annotations[naming.PGBouncerStartupHash], first)
+
+		// Settings that PgBouncer reads only at startup restart it.
+		cluster.Spec.Proxy.PGBouncer.Config.Global["listen_addr"] = "::"
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, deploy.Spec.Template.Annotations[naming.PGBouncerStartupHash] != first)
+	})
+
+	t.Run("RestartOnChange", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		// By default, reloadable configuration does not change the pod template.
+		deploy, _, err := reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, deploy.Spec.Template.Annotations[naming.PGBouncerConfigHash] == "")
+
+		cluster.Spec.Proxy.PGBouncer.Config.RestartOnChange = true
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+
+		first := deploy.Spec.Template.Annotations[naming.PGBouncerConfigHash]
+		assert.Assert(t, first != "")
+
+		// Identical configuration has an identical hash.
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster.DeepCopy(), primary, configmap, secret.DeepCopy())
+		assert.NilError(t, err)
+		assert.Equal(t, deploy.Spec.Template.Annotations[naming.PGBouncerConfigHash], first)
+
+		// Any change to the configuration restarts PgBouncer.
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+			"pool_mode": "transaction",
+		}
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+
+		second := deploy.Spec.Template.Annotations[naming.PGBouncerConfigHash]
+		assert.Assert(t, second != first)
+
+		// So does a change to the users file.
+		secret := secret.DeepCopy()
+		secret.Data = map[string][]byte{"pgbouncer-users.txt": []byte(`"app" "pass"`)}
+		deploy, _, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, deploy.Spec.Template.Annotations[naming.PGBouncerConfigHash] != second)
+	})
+
+	t.Run("Replicas", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		deploy, specified, err := reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, specified)
+
+		// Defaults to one.
+		assert.Equal(t, *deploy.Spec.Replicas, int32(1))
+
+		cluster.Spec.Proxy.PGBouncer.Replicas = initialize.Int32(3)
+		deploy, specified, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, specified)
+		assert.Equal(t, *deploy.Spec.Replicas, int32(3))
+
+		// Shutdown stops every pod.
+		cluster.Spec.Shutdown = initialize.Bool(true)
+		deploy, specified, err = reconciler.generatePGBouncerDeployment(
+			cluster, primary, configmap, secret)
+		assert.NilError(t, err)
+		assert.Assert(t, specified)
+		assert.Equal(t, *deploy.Spec.Replicas, int32(0))
+	})
+
 	t.Run("PodSpec", func(t *testing.T) {
 		deploy, specified, err := reconciler.generatePGBouncerDeployment(
 			cluster, primary, configmap, secret)
@@ -464,12 +729,23 @@ namespace: ns3
 		// set to true (as done in instance StatefulSet tests).
 
 		assert.Assert(t, marshalMatches(deploy.Spec.Template.Spec, `
+affinity:
+  podAntiAffinity:
+    preferredDuringSchedulingIgnoredDuringExecution:
+    - podAffinityTerm:
+        labelSelector:
+          matchLabels:
+            postgres-operator.crunchydata.com/cluster: test-cluster
+            postgres-operator.crunchydata.com/role: pgbouncer
+        topologyKey: kubernetes.io/hostname
+      weight: 1
 automountServiceAccountToken: false
 containers: null
 enableServiceLinks: false
 restartPolicy: Always
 securityContext:
   fsGroupChangePolicy: OnRootMismatch
+serviceAccountName: test-cluster-instance
 shareProcessNamespace: true
 topologySpreadConstraints:
 - labelSelector:
@@ -497,8 +773,154 @@ topologySpreadConstraints:
 			assert.NilError(t, err)
 			assert.Assert(t, specified)
 
+			assert.Assert(t, deploy.Spec.Template.Spec.Affinity == nil)
 			assert.Assert(t, deploy.Spec.Template.Spec.TopologySpreadConstraints == nil)
 		})
+
+		t.Run("Affinity", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Affinity = &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{},
+			}
+
+			deploy, specified, err := reconciler.generatePGBouncerDeployment(
+				cluster, primary, configmap, secret)
+			assert.NilError(t, err)
+			assert.Assert(t, specified)
+
+			// The default is not combined with what is in the spec.
+			assert.DeepEqual(t, deploy.Spec.Template.Spec.Affinity,
+				&corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}})
+		})
+
+		t.Run("PriorityClassName", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.PriorityClassName = initialize.String("some-priority-class")
+
+			deploy, specified, err := reconciler.generatePGBouncerDeployment(
+				cluster, primary, configmap, secret)
+			assert.NilError(t, err)
+			assert.Assert(t, specified)
+
+			assert.Equal(t, deploy.Spec.Template.Spec.PriorityClassName, "some-priority-class")
+		})
+
+		t.Run("Images", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+
+			// No pull secrets by default.
+			deploy, specified, err := reconciler.generatePGBouncerDeployment(
+				cluster, primary, configmap, secret)
+			assert.NilError(t, err)
+			assert.Assert(t, specified)
+			assert.Assert(t, deploy.Spec.Template.Spec.ImagePullSecrets == nil)
+
+			cluster.Spec.ImagePullPolicy = corev1.PullAlways
+			cluster.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+			cluster.Spec.Proxy.PGBouncer.Image = "example.com/pgbouncer:1.17"
+
+			deploy, specified, err = reconciler.generatePGBouncerDeployment(
+				cluster, primary, configmap, secret)
+			assert.NilError(t, err)
+			assert.Assert(t, specified)
+
+			assert.DeepEqual(t, deploy.Spec.Template.Spec.ImagePullSecrets,
+				[]corev1.LocalObjectReference{{Name: "registry"}})
+
+			var found bool
+			for _, container := range deploy.Spec.Template.Spec.Containers {
+				if container.Name == naming.ContainerPGBouncer {
+					found = true
+					assert.Equal(t, container.Image, "example.com/pgbouncer:1.17")
+					assert.Equal(t, container.ImagePullPolicy, corev1.PullAlways)
+				}
+			}
+			assert.Assert(t, found, "expected a %q container", naming.ContainerPGBouncer)
+		})
+	})
+}
+
+func TestReconcilePGBouncerDeploymentConditions(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	reconciler := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, cc.Create(ctx, cluster))
+	cluster.Default()
+
+	configmap := &corev1.ConfigMap{}
+	configmap.Name = "some-cm"
+
+	secret := &corev1.Secret{}
+	secret.Name = "some-secret"
+
+	primary := &corev1.SecretProjection{}
+	deploy := &appsv1.Deployment{ObjectMeta: naming.ClusterPGBouncer(cluster)}
+
+	t.Run("NoDeploymentStatus", func(t *testing.T) {
+		assert.NilError(t, reconciler.reconcilePGBouncerDeployment(
+			ctx, cluster, primary, configmap, secret))
+
+		// Nothing runs Deployments in this environment.
+		assert.Assert(t, meta.FindStatusCondition(
+			cluster.Status.Conditions, v1beta1.ProxyAvailable) == nil)
+	})
+
+	setAvailable := func(t *testing.T, status corev1.ConditionStatus, reason string) {
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(deploy), deploy))
+		deploy.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type:   appsv1.DeploymentAvailable,
+			Status: status,
+			Reason: reason,
+
+			LastTransitionTime: metav1.Now(),
+			LastUpdateTime:     metav1.Now(),
+		}}
+		assert.NilError(t, cc.Status().Update(ctx, deploy))
+	}
+
+	for _, tt := range []struct {
+		status corev1.ConditionStatus
+		reason string
+	}{
+		{corev1.ConditionFalse, "MinimumReplicasUnavailable"},
+		{corev1.ConditionTrue, "MinimumReplicasAvailable"},
+	} {
+		t.Run(string(tt.status), func(t *testing.T) {
+			setAvailable(t, tt.status, tt.reason)
+
+			cluster.Generation = 3
+			assert.NilError(t, reconciler.reconcilePGBouncerDeployment(
+				ctx, cluster, primary, configmap, secret))
+
+			condition := meta.FindStatusCondition(
+				cluster.Status.Conditions, v1beta1.ProxyAvailable)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionStatus(tt.status))
+			assert.Equal(t, condition.Reason, tt.reason)
+			assert.Equal(t, condition.ObservedGeneration, int64(3))
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := cluster.DeepCopy()
+		disabled.Spec.Proxy = nil
+
+		assert.NilError(t, reconciler.reconcilePGBouncerDeployment(
+			ctx, disabled, primary, configmap, secret))
+
+		err := cc.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+
+		// The condition is removed once the Deployment is gone.
+		assert.NilError(t, reconciler.reconcilePGBouncerDeployment(
+			ctx, disabled, primary, configmap, secret))
+		assert.Assert(t, meta.FindStatusCondition(
+			disabled.Status.Conditions, v1beta1.ProxyAvailable) == nil)
 	})
 }
 
@@ -344,6 +344,16 @@ func addPGMonitorExporterToInstancePodSpec(
 	initialize.Labels(template)
 	template.Labels[naming.LabelPGMonitorDiscovery] = "true"
 
+	// add the conventional annotations for Prometheus configurations that
+	// discover scrape targets by annotation rather than by label
+	initialize.Annotations(template)
+	template.Annotations["prometheus.io/scrape"] = "true"
+	template.Annotations["prometheus.io/port"] = fmt.Sprint(exporterPort)
+	template.Annotations["prometheus.io/path"] = "/metrics"
+	if cluster.Spec.Monitoring.PGMonitor.Exporter.CustomTLSSecret != nil {
+		template.Annotations["prometheus.io/scheme"] = "https"
+	}
+
 	return nil
 }
 
@@ -126,6 +126,14 @@ func TestAddPGMonitorExporterToInstancePodSpec(t *testing.T) {
 		assert.Assert(t, container.Ports[0].Protocol == "TCP")
 
 		assert.Assert(t, template.Spec.Volumes != nil)
+
+		// Prometheus can discover the exporter by label or by annotation.
+		assert.Equal(t, template.Labels["postgres-operator.crunchydata.com/crunchy-postgres-exporter"], "true")
+		assert.DeepEqual(t, template.Annotations, map[string]string{
+			"prometheus.io/scrape": "true",
+			"prometheus.io/port":   "9187",
+			"prometheus.io/path":   "/metrics",
+		})
 	})
 
 	t.Run("CustomConfig", func(t *testing.T) {
@@ -17,10 +17,17 @@ package postgrescluster
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
@@ -35,6 +42,96 @@ const (
 	rootCertFile    = "ca.crt"
 )
 
+// defaultLeafCertificateDuration is how long leaf certificates are valid when
+// the cluster does not specify. It matches the default of the pki package.
+const defaultLeafCertificateDuration = 365 * 24 * time.Hour
+
+// defaultCertificateExpiryWarning is how long before a certificate expires to
+// warn about it when the Reconciler does not specify.
+const defaultCertificateExpiryWarning = 30 * 24 * time.Hour
+
+// expiringCertificates remembers which certificates have been reported as
+// expiring soon so that each is reported once by this process.
+type expiringCertificates struct {
+	mutex    sync.Mutex
+	reported map[string]time.Time
+}
+
+// firstReport returns true the first time it is called with key and expires.
+// A different expiry means a different certificate for key.
+func (e *expiringCertificates) firstReport(key string, expires time.Time) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if previous, ok := e.reported[key]; ok && previous.Equal(expires) {
+		return false
+	}
+	if e.reported == nil {
+		e.reported = make(map[string]time.Time)
+	}
+	e.reported[key] = expires
+	return true
+}
+
+// forget removes key so that its next certificate is reported.
+func (e *expiringCertificates) forget(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.reported, key)
+}
+
+// forgetCluster removes every key of the cluster with uid.
+func (e *expiringCertificates) forgetCluster(uid types.UID) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for key := range e.reported {
+		if strings.HasPrefix(key, string(uid)+"/") {
+			delete(e.reported, key)
+		}
+	}
+}
+
+// recordCertificateEvents emits events about certificate when it was
+// generated or is about to expire. The description identifies certificate in
+// the event message. A certificate is renewed when it replaces a previous one.
+// Each certificate is reported as expiring once per cluster, or on every call
+// until SetupWithManager is called.
+func (r *Reconciler) recordCertificateEvents(
+	cluster *v1beta1.PostgresCluster, description string,
+	certificate pki.Certificate, generated, renewed bool,
+) {
+	expires := certificate.NotAfter()
+	key := string(cluster.UID) + "/" + description
+
+	if generated {
+		// The previous certificate is gone; so is any report about it.
+		if r.expiring != nil {
+			r.expiring.forget(key)
+		}
+
+		reason := "CertificateGenerated"
+		if renewed {
+			reason = "CertificateRenewed"
+		}
+		r.Recorder.Eventf(cluster, corev1.EventTypeNormal, reason,
+			"Generated %s certificate that expires at %s",
+			description, expires.UTC().Format(time.RFC3339))
+	}
+
+	window := r.CertificateExpiryWarning
+	if window <= 0 {
+		window = defaultCertificateExpiryWarning
+	}
+	if time.Until(expires) < window && (r.expiring == nil ||
+		r.expiring.firstReport(key, expires)) {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "CertificateExpiringSoon",
+			"The %s certificate expires at %s",
+			description, expires.UTC().Format(time.RFC3339))
+	}
+}
+
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;patch
 
@@ -42,12 +139,17 @@ const (
 // in the relevant secret, has been created and is not 'bad' due
 // to being expired, formatted incorrectly, etc.
 // If it is bad for some reason, a new root certificate is
-// generated for use.
+// generated for use. When cluster references its own certificate
+// authority, that is used instead.
 func (r *Reconciler) reconcileRootCertificate(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
 ) (
 	*pki.RootCertificateAuthority, error,
 ) {
+	if cluster.Spec.TLS != nil && cluster.Spec.TLS.RootCA != nil {
+		return r.customRootCertificate(ctx, cluster)
+	}
+
 	const keyCertificate, keyPrivateKey = "root.crt", "root.key"
 
 	existing := &corev1.Secret{}
@@ -56,6 +158,7 @@ func (r *Reconciler) reconcileRootCertificate(
 		r.Client.Get(ctx, client.ObjectKeyFromObject(existing), existing)))
 
 	root := &pki.RootCertificateAuthority{}
+	generated := false
 
 	if err == nil {
 		// Unmarshal and validate the stored root. These first errors can
@@ -67,6 +170,7 @@ func (r *Reconciler) reconcileRootCertificate(
 		if !pki.RootIsValid(root) {
 			root, err = pki.NewRootCertificateAuthority()
 			err = errors.WithStack(err)
+			generated = true
 		}
 	}
 
@@ -99,10 +203,116 @@ func (r *Reconciler) reconcileRootCertificate(
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, intent))
 	}
+	if err == nil {
+		r.recordCertificateEvents(cluster, "root", root.Certificate,
+			generated, len(existing.Data[keyCertificate]) > 0)
+		r.setLeafCertificatePolicy(cluster, root)
+	}
 
 	return root, err
 }
 
+// customRootCertificate returns the certificate authority stored in the
+// Secret referenced by cluster. When that Secret is missing or does not hold
+// a valid authority, it emits a warning event and returns an error so that
+// cluster is reconciled again later.
+func (r *Reconciler) customRootCertificate(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (
+	*pki.RootCertificateAuthority, error,
+) {
+	const keyCertificate, keyPrivateKey = "tls.crt", "tls.key"
+
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = cluster.Namespace, cluster.Spec.TLS.RootCA.Name
+	err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+
+	root := &pki.RootCertificateAuthority{}
+
+	if err == nil {
+		// These errors are reported below as an invalid root.
+		_ = root.Certificate.UnmarshalText(secret.Data[keyCertificate])
+		_ = root.PrivateKey.UnmarshalText(secret.Data[keyPrivateKey])
+
+		if !pki.RootIsValid(root) {
+			err = errors.Errorf(
+				"Secret %q must contain a valid certificate authority in %q and its ECDSA private key in %q",
+				secret.Name, keyCertificate, keyPrivateKey)
+		}
+	}
+
+	if err != nil {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidRootCertificate",
+			field.Invalid(field.NewPath("spec", "tls", "rootCA"),
+				secret.Name, errors.Cause(err).Error()).Error())
+		return nil, err
+	}
+
+	r.recordCertificateEvents(cluster, "root", root.Certificate, false, false)
+	r.setLeafCertificatePolicy(cluster, root)
+
+	return root, nil
+}
+
+// setLeafCertificatePolicy configures root to generate and renew leaf
+// certificates according to the TLS settings of cluster. Invalid settings are
+// ignored and reported in a warning event.
+func (r *Reconciler) setLeafCertificatePolicy(
+	cluster *v1beta1.PostgresCluster, root *pki.RootCertificateAuthority,
+) {
+	if cluster.Spec.TLS == nil {
+		return
+	}
+
+	var errs field.ErrorList
+	path := field.NewPath("spec", "tls")
+
+	if d := cluster.Spec.TLS.CertificateDuration; d != nil {
+		if d.Duration > 0 {
+			root.LeafDuration = d.Duration
+		} else {
+			errs = append(errs, field.Invalid(path.Child("certificateDuration"),
+				d.Duration.String(), "must be greater than zero"))
+		}
+	}
+
+	if d := cluster.Spec.TLS.RenewBefore; d != nil {
+		lifetime := root.LeafDuration
+		if lifetime <= 0 {
+			lifetime = defaultLeafCertificateDuration
+		}
+
+		if d.Duration > 0 && d.Duration < lifetime {
+			root.LeafRenewBefore = d.Duration
+		} else {
+			errs = append(errs, field.Invalid(path.Child("renewBefore"),
+				d.Duration.String(), "must be greater than zero and less than the certificate duration"))
+		}
+	}
+
+	if len(errs) > 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidTLSConfiguration",
+			errs.ToAggregate().Error())
+	}
+}
+
+// validateTLSProtocol returns an error when cluster asks for a minimum TLS
+// version that its version of PostgreSQL cannot enforce. That setting is
+// ignored by the Reconciler.
+func validateTLSProtocol(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	if cluster.Spec.TLS != nil &&
+		cluster.Spec.TLS.MinProtocol != "" &&
+		cluster.Spec.PostgresVersion < 12 {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "tls", "minProtocol"),
+			cluster.Spec.TLS.MinProtocol, "requires PostgreSQL 12 or newer"))
+	}
+
+	return errs
+}
+
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;patch
 
@@ -123,7 +333,8 @@ func (r *Reconciler) reconcileClusterCertificate(
 ) {
 	// if a custom postgrescluster secret is provided, just return it
 	if cluster.Spec.CustomTLSSecret != nil {
-		return cluster.Spec.CustomTLSSecret, nil
+		return cluster.Spec.CustomTLSSecret,
+			r.checkCustomTLSSecret(ctx, cluster, cluster.Spec.CustomTLSSecret)
 	}
 
 	const keyCertificate, keyPrivateKey, rootCA = "tls.crt", "tls.key", "ca.crt"
@@ -135,6 +346,7 @@ func (r *Reconciler) reconcileClusterCertificate(
 	leaf := &pki.LeafCertificate{}
 	dnsNames := naming.ServiceDNSNames(ctx, primaryService)
 	dnsFQDN := dnsNames[0]
+	generated := false
 
 	if err == nil {
 		// Unmarshal and validate the stored leaf. These first errors can
@@ -143,8 +355,10 @@ func (r *Reconciler) reconcileClusterCertificate(
 		_ = leaf.Certificate.UnmarshalText(existing.Data[keyCertificate])
 		_ = leaf.PrivateKey.UnmarshalText(existing.Data[keyPrivateKey])
 
+		previous := leaf
 		leaf, err = root.RegenerateLeafWhenNecessary(leaf, dnsFQDN, dnsNames)
 		err = errors.WithStack(err)
+		generated = leaf != previous
 	}
 
 	intent := &corev1.Secret{ObjectMeta: naming.PostgresTLSSecret(cluster)}
@@ -184,10 +398,71 @@ func (r *Reconciler) reconcileClusterCertificate(
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, intent))
 	}
+	if err == nil {
+		r.recordCertificateEvents(cluster, "cluster", leaf.Certificate,
+			generated, len(existing.Data[keyCertificate]) > 0)
+	}
 
 	return clusterCertSecretProjection(intent), err
 }
 
+// checkCustomTLSSecret emits a warning event when the Secret in projection is
+// missing or lacks any of the files that PostgreSQL needs.
+func (r *Reconciler) checkCustomTLSSecret(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	projection *corev1.SecretProjection,
+) error {
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = cluster.Namespace, projection.Name
+	err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+
+	path := field.NewPath("spec", "customTLSSecret")
+
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidCustomTLSSecret",
+			field.NotFound(path.Child("name"), projection.Name).Error())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	errs := validateCustomTLSSecret(secret, projection, path)
+	if len(errs) > 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidCustomTLSSecret",
+			errs.ToAggregate().Error())
+	}
+	return nil
+}
+
+// validateCustomTLSSecret returns an error for each file that PostgreSQL needs
+// but is missing from secret or from projection.
+func validateCustomTLSSecret(
+	secret *corev1.Secret, projection *corev1.SecretProjection, path *field.Path,
+) field.ErrorList {
+	var errs field.ErrorList
+	for _, file := range []string{clusterCertFile, clusterKeyFile, rootCertFile} {
+		// Without items, every key of the Secret is projected as a file.
+		key, found := file, len(projection.Items) == 0
+		for i := range projection.Items {
+			if projection.Items[i].Path == file {
+				key, found = projection.Items[i].Key, true
+			}
+		}
+
+		if !found {
+			errs = append(errs, field.Required(path.Child("items"),
+				fmt.Sprintf("an item with path %q is required", file)))
+		} else if len(secret.Data[key]) == 0 {
+			errs = append(errs, field.Required(path.Child("name"),
+				fmt.Sprintf("Secret %q must contain %q", secret.Name, key)))
+		}
+	}
+
+	return errs
+}
+
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;patch
 
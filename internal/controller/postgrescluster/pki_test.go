@@ -25,17 +25,22 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -57,8 +62,9 @@ func TestReconcileCerts(t *testing.T) {
 	namespace := setupNamespace(t, tClient).Name
 
 	r := &Reconciler{
-		Client: tClient,
-		Owner:  ControllerName,
+		Client:   tClient,
+		Owner:    ControllerName,
+		Recorder: new(record.FakeRecorder),
 	}
 
 	// set up cluster1
@@ -381,6 +387,428 @@ func TestReconcileCerts(t *testing.T) {
 	})
 }
 
+func TestReconcileCertificateEvents(t *testing.T) {
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	primaryService := new(corev1.Service)
+	primaryService.Namespace = cluster.Namespace
+	primaryService.Name = "the-primary"
+
+	reasons := func() []string {
+		var out []string
+		for _, event := range recorder.Events {
+			out = append(out, event.Type+"/"+event.Reason)
+		}
+		recorder.Events = recorder.Events[:0]
+		return out
+	}
+
+	root, err := r.reconcileRootCertificate(ctx, cluster)
+	assert.NilError(t, err)
+
+	t.Run("Generated", func(t *testing.T) {
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Assert(t, strings.Contains(recorder.Events[0].Note, "root certificate"))
+		assert.Assert(t, strings.Contains(recorder.Events[0].Note,
+			root.Certificate.NotAfter().UTC().Format(time.RFC3339)),
+			"expected expiry in %q", recorder.Events[0].Note)
+		assert.DeepEqual(t, reasons(), []string{"Normal/CertificateGenerated"})
+
+		_, err := r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, reasons(), []string{"Normal/CertificateGenerated"})
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		_, err := r.reconcileRootCertificate(ctx, cluster)
+		assert.NilError(t, err)
+
+		_, err = r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+		assert.NilError(t, err)
+		assert.Assert(t, len(reasons()) == 0)
+	})
+
+	t.Run("Renewed", func(t *testing.T) {
+		// A different Service name changes the DNS names of the certificate.
+		service := primaryService.DeepCopy()
+		service.Name = "another-primary"
+
+		_, err := r.reconcileClusterCertificate(ctx, root, cluster, service)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, reasons(), []string{"Normal/CertificateRenewed"})
+	})
+
+	t.Run("ExpiringSoon", func(t *testing.T) {
+		// Leaf certificates are valid for one year; the root for ten.
+		r := *r
+		r.CertificateExpiryWarning = 2 * 365 * 24 * time.Hour
+
+		_, err := r.reconcileRootCertificate(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, len(reasons()) == 0)
+
+		_, err = r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, reasons(), []string{
+			"Normal/CertificateRenewed", "Warning/CertificateExpiringSoon",
+		})
+
+		t.Run("Once", func(t *testing.T) {
+			r := r
+			r.expiring = new(expiringCertificates)
+
+			_, err := r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, reasons(), []string{"Warning/CertificateExpiringSoon"})
+
+			// The same certificate is not reported again.
+			_, err = r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+			assert.NilError(t, err)
+			assert.Assert(t, len(reasons()) == 0)
+		})
+	})
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	expiring := new(expiringCertificates)
+	expires := time.Now().Add(time.Hour)
+
+	assert.Assert(t, expiring.firstReport("uid/root", expires))
+	assert.Assert(t, !expiring.firstReport("uid/root", expires))
+	assert.Assert(t, !expiring.firstReport("uid/root", expires.UTC()))
+
+	// Another certificate or another cluster is reported.
+	assert.Assert(t, expiring.firstReport("uid/cluster", expires))
+	assert.Assert(t, expiring.firstReport("other/root", expires))
+	assert.Assert(t, expiring.firstReport("uid/root", expires.Add(time.Hour)))
+
+	// A forgotten certificate is reported again.
+	expiring.forget("uid/root")
+	assert.Assert(t, expiring.firstReport("uid/root", expires.Add(time.Hour)))
+
+	// Forgetting a cluster leaves the others.
+	expiring.forgetCluster("uid")
+	assert.Equal(t, len(expiring.reported), 1)
+	assert.Assert(t, !expiring.firstReport("other/root", expires))
+}
+
+func TestSetLeafCertificatePolicy(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{Recorder: recorder}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	const day = 24 * time.Hour
+
+	for _, tt := range []struct {
+		name            string
+		tls             *v1beta1.TLSSpec
+		duration, renew time.Duration
+		invalid         []string
+	}{
+		{name: "Unspecified"},
+		{name: "Empty", tls: &v1beta1.TLSSpec{}},
+		{
+			name: "Duration",
+			tls: &v1beta1.TLSSpec{
+				CertificateDuration: &metav1.Duration{Duration: 90 * day},
+			},
+			duration: 90 * day,
+		},
+		{
+			name: "DurationAndRenewBefore",
+			tls: &v1beta1.TLSSpec{
+				CertificateDuration: &metav1.Duration{Duration: 90 * day},
+				RenewBefore:         &metav1.Duration{Duration: 30 * day},
+			},
+			duration: 90 * day, renew: 30 * day,
+		},
+		{
+			name: "RenewBeforeDefaultDuration",
+			tls: &v1beta1.TLSSpec{
+				RenewBefore: &metav1.Duration{Duration: 30 * day},
+			},
+			renew: 30 * day,
+		},
+		{
+			name: "RenewBeforeTooLong",
+			tls: &v1beta1.TLSSpec{
+				CertificateDuration: &metav1.Duration{Duration: 90 * day},
+				RenewBefore:         &metav1.Duration{Duration: 90 * day},
+			},
+			duration: 90 * day,
+			invalid:  []string{"spec.tls.renewBefore"},
+		},
+		{
+			name: "Negative",
+			tls: &v1beta1.TLSSpec{
+				CertificateDuration: &metav1.Duration{Duration: -day},
+				RenewBefore:         &metav1.Duration{Duration: -day},
+			},
+			invalid: []string{"spec.tls.certificateDuration", "spec.tls.renewBefore"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+			cluster := cluster.DeepCopy()
+			cluster.Spec.TLS = tt.tls
+
+			root := new(pki.RootCertificateAuthority)
+			r.setLeafCertificatePolicy(cluster, root)
+
+			assert.Equal(t, root.LeafDuration, tt.duration)
+			assert.Equal(t, root.LeafRenewBefore, tt.renew)
+
+			if len(tt.invalid) == 0 {
+				assert.Equal(t, len(recorder.Events), 0)
+			} else {
+				assert.Equal(t, len(recorder.Events), 1)
+				assert.Equal(t, recorder.Events[0].Reason, "InvalidTLSConfiguration")
+				for _, field := range tt.invalid {
+					assert.Assert(t, strings.Contains(recorder.Events[0].Note, field),
+						"expected %q in %q", field, recorder.Events[0].Note)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileCustomRootCertificate(t *testing.T) {
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	cluster.Spec.TLS = &v1beta1.TLSSpec{
+		RootCA: &corev1.LocalObjectReference{Name: "corporate-ca"},
+	}
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	primaryService := new(corev1.Service)
+	primaryService.Namespace = cluster.Namespace
+	primaryService.Name = "the-primary"
+
+	t.Run("MissingSecret", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		root, err := r.reconcileRootCertificate(ctx, cluster)
+		assert.Assert(t, apierrors.IsNotFound(errors.Cause(err)), "got %v", err)
+		assert.Assert(t, root == nil)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidRootCertificate")
+	})
+
+	corporate, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	certificate, err := corporate.Certificate.MarshalText()
+	assert.NilError(t, err)
+	privateKey, err := corporate.PrivateKey.MarshalText()
+	assert.NilError(t, err)
+
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = cluster.Namespace, "corporate-ca"
+	secret.Data = map[string][]byte{"tls.crt": certificate}
+	assert.NilError(t, cc.Create(ctx, secret))
+
+	t.Run("MissingKey", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		root, err := r.reconcileRootCertificate(ctx, cluster)
+		assert.ErrorContains(t, err, `"tls.key"`)
+		assert.Assert(t, root == nil)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidRootCertificate")
+		assert.Assert(t, strings.Contains(recorder.Events[0].Note, "spec.tls.rootCA"),
+			"got %q", recorder.Events[0].Note)
+	})
+
+	secret.Data["tls.key"] = privateKey
+	assert.NilError(t, cc.Update(ctx, secret))
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		root, err := r.reconcileRootCertificate(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, root.Certificate.Equal(corporate.Certificate))
+		assert.Assert(t, root.PrivateKey.Equal(corporate.PrivateKey))
+
+		// The namespace root is not generated.
+		generated := &corev1.Secret{}
+		generated.Namespace, generated.Name = cluster.Namespace, naming.RootCertSecret
+		err = cc.Get(ctx, client.ObjectKeyFromObject(generated), generated)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+
+		// Leaf certificates are signed by the provided authority.
+		_, err = r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+		assert.NilError(t, err)
+
+		leafSecret := &corev1.Secret{ObjectMeta: naming.PostgresTLSSecret(cluster)}
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(leafSecret), leafSecret))
+		assert.DeepEqual(t, leafSecret.Data["ca.crt"], certificate)
+
+		leaf := &pki.LeafCertificate{}
+		assert.NilError(t, leaf.Certificate.UnmarshalText(leafSecret.Data["tls.crt"]))
+		assert.NilError(t, leaf.PrivateKey.UnmarshalText(leafSecret.Data["tls.key"]))
+
+		same, err := corporate.RegenerateLeafWhenNecessary(leaf,
+			leaf.Certificate.CommonName(), leaf.Certificate.DNSNames())
+		assert.NilError(t, err)
+		assert.Assert(t, same == leaf, "expected leaf to be signed by the provided authority")
+
+		for _, event := range recorder.Events {
+			assert.Assert(t, event.Type != corev1.EventTypeWarning, "got %#v", event)
+		}
+	})
+}
+
+func TestReconcileCustomTLSSecret(t *testing.T) {
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	r := &Reconciler{
+		Client:   cc,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	primaryService := new(corev1.Service)
+	primaryService.Namespace = cluster.Namespace
+	primaryService.Name = "the-primary"
+
+	root, err := r.reconcileRootCertificate(ctx, cluster)
+	assert.NilError(t, err)
+	recorder.Events = recorder.Events[:0]
+
+	t.Run("Managed", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		projection, err := r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+		assert.NilError(t, err)
+		assert.Equal(t, projection.Name, naming.PostgresTLSSecret(cluster).Name)
+
+		for _, event := range recorder.Events {
+			assert.Assert(t, event.Reason != "InvalidCustomTLSSecret", "got %#v", event)
+		}
+	})
+
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = cluster.Namespace, "from-cert-manager"
+	secret.Data = map[string][]byte{
+		"tls.crt": []byte("cert"),
+		"tls.key": []byte("key"),
+	}
+	assert.NilError(t, cc.Create(ctx, secret))
+
+	for _, tt := range []struct {
+		name     string
+		custom   corev1.SecretProjection
+		messages []string
+	}{
+		{
+			name:     "MissingSecret",
+			custom:   corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}},
+			messages: []string{`spec.customTLSSecret.name: Not found: "missing"`},
+		},
+		{
+			name:     "MissingKey",
+			custom:   corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name}},
+			messages: []string{`must contain "ca.crt"`},
+		},
+		{
+			name: "Items",
+			custom: corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+				Items: []corev1.KeyToPath{
+					{Key: "tls.crt", Path: "tls.crt"},
+					{Key: "tls.key", Path: "tls.key"},
+					{Key: "tls.crt", Path: "ca.crt"},
+				},
+			},
+		},
+		{
+			name: "MissingItem",
+			custom: corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+				Items: []corev1.KeyToPath{
+					{Key: "tls.crt", Path: "tls.crt"},
+					{Key: "other", Path: "tls.key"},
+				},
+			},
+			messages: []string{`must contain "other"`, `an item with path "ca.crt" is required`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+			cluster := cluster.DeepCopy()
+			cluster.Spec.CustomTLSSecret = tt.custom.DeepCopy()
+
+			projection, err := r.reconcileClusterCertificate(ctx, root, cluster, primaryService)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, projection, cluster.Spec.CustomTLSSecret)
+
+			if len(tt.messages) == 0 {
+				assert.Equal(t, len(recorder.Events), 0)
+			} else {
+				assert.Equal(t, len(recorder.Events), 1)
+				assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+				assert.Equal(t, recorder.Events[0].Reason, "InvalidCustomTLSSecret")
+				for _, message := range tt.messages {
+					assert.Assert(t, strings.Contains(recorder.Events[0].Note, message),
+						"expected %q in %q", message, recorder.Events[0].Note)
+				}
+			}
+		})
+	}
+}
+
 // getCertFromSecret returns a parsed certificate from the named secret
 func getCertFromSecret(
 	ctx context.Context, tClient client.Client, name, namespace, dataKey string,
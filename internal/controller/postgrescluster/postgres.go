@@ -34,9 +34,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pgaudit"
+	"github.com/crunchydata/postgres-operator/internal/pgbouncer"
+	"github.com/crunchydata/postgres-operator/internal/pgmonitor"
 	"github.com/crunchydata/postgres-operator/internal/postgis"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
@@ -44,9 +47,74 @@ import (
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// generatePostgresHBAs returns the HBA records for cluster. Rules from the
+// spec come after any mandatory records and before the defaults. Rules that
+// are malformed are skipped and reported in a warning event.
+func (r *Reconciler) generatePostgresHBAs(cluster *v1beta1.PostgresCluster) postgres.HBAs {
+	hbas := postgres.NewHBAs()
+	pgmonitor.PostgreSQLHBAs(cluster, &hbas)
+	pgbouncer.PostgreSQL(cluster, &hbas)
+
+	if cluster.Spec.Authentication != nil && len(cluster.Spec.Authentication.Rules) > 0 {
+		var errs field.ErrorList
+		path := field.NewPath("spec", "authentication", "rules")
+		rules := make([]postgres.HostBasedAuthentication, 0,
+			len(cluster.Spec.Authentication.Rules)+len(hbas.Default))
+
+		for i, rule := range cluster.Spec.Authentication.Rules {
+			if invalid := postgres.ValidateHBARule(rule, path.Index(i)); len(invalid) > 0 {
+				errs = append(errs, invalid...)
+			} else {
+				rules = append(rules, *postgres.NewHBAFromRule(rule))
+			}
+		}
+
+		if len(errs) > 0 {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidPostgresHBA",
+				errs.ToAggregate().Error())
+		}
+
+		hbas.Default = append(rules, hbas.Default...)
+	}
+
+	return hbas
+}
+
+// generatePostgresIdents returns the user name maps for cluster. Maps from the
+// spec come after any mandatory records and before the defaults. Maps that
+// are malformed are skipped and reported in a warning event.
+func (r *Reconciler) generatePostgresIdents(cluster *v1beta1.PostgresCluster) postgres.Idents {
+	idents := postgres.NewIdents()
+
+	if cluster.Spec.Authentication != nil && len(cluster.Spec.Authentication.Maps) > 0 {
+		var errs field.ErrorList
+		path := field.NewPath("spec", "authentication", "maps")
+		maps := make([]postgres.UserNameMap, 0,
+			len(cluster.Spec.Authentication.Maps)+len(idents.Default))
+
+		for i, spec := range cluster.Spec.Authentication.Maps {
+			if invalid := postgres.ValidateIdentMap(spec, path.Index(i)); len(invalid) > 0 {
+				errs = append(errs, invalid...)
+			} else {
+				maps = append(maps, *postgres.NewIdentFromMap(spec))
+			}
+		}
+
+		if len(errs) > 0 {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidPostgresIdent",
+				errs.ToAggregate().Error())
+		}
+
+		idents.Default = append(maps, idents.Default...)
+	}
+
+	return idents
+}
+
 // generatePostgresUserSecret returns a Secret containing a password and
-// connection details for the first database in spec. When existing is nil or
-// lacks a password or verifier, a new password and verifier are generated.
+// connection details for the first database in spec. When existing is nil,
+// lacks a password or verifier, or is annotated to rotate its password, a new
+// password and verifier are generated.
 func (r *Reconciler) generatePostgresUserSecret(
 	cluster *v1beta1.PostgresCluster, spec *v1beta1.PostgresUserSpec, existing *corev1.Secret,
 ) (*corev1.Secret, error) {
@@ -60,14 +128,14 @@ func (r *Reconciler) generatePostgresUserSecret(
 	// - https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-PARAMKEYWORDS
 	primary := naming.ClusterPrimaryService(cluster)
 	hostname := primary.Name + "." + primary.Namespace + ".svc"
-	port := fmt.Sprint(*cluster.Spec.Port)
+	port := fmt.Sprint(postgres.ServicePort(cluster))
 
 	intent.Data["host"] = []byte(hostname)
 	intent.Data["port"] = []byte(port)
 	intent.Data["user"] = []byte(username)
 
-	// Use the existing password and verifier.
-	if existing != nil {
+	// Use the existing password and verifier unless it should be rotated.
+	if existing != nil && existing.Annotations[naming.RotatePassword] != "true" {
 		intent.Data["password"] = existing.Data["password"]
 		intent.Data["verifier"] = existing.Data["verifier"]
 	}
@@ -229,9 +297,19 @@ func (r *Reconciler) reconcilePostgresDatabases(
 		}
 	}
 
+	// Owners of databases in spec.databases that are also users should be able
+	// to login when they are created here. See [Reconciler.reconcilePostgresUsers].
+	logins := make(map[string]bool)
+	if cluster.Spec.Users == nil {
+		logins[cluster.Name] = true
+	}
+	for _, user := range cluster.Spec.Users {
+		logins[string(user.Name)] = true
+	}
+
 	// Calculate a hash of the SQL that should be executed in PostgreSQL.
 
-	var pgAuditOK, postgisInstallOK bool
+	var pgAuditOK, postgisInstallOK, extensionsOK bool
 	create := func(ctx context.Context, exec postgres.Executor) error {
 		if pgAuditOK = pgaudit.EnableInPostgreSQL(ctx, exec) == nil; !pgAuditOK {
 			// pgAudit can only be enabled after its shared library is loaded,
@@ -254,7 +332,26 @@ func (r *Reconciler) reconcilePostgresDatabases(
 				"Unable to install PostGIS")
 		}
 
-		return postgres.CreateDatabasesInPostgreSQL(ctx, exec, databases.List())
+		// Extensions are created in every database, including templates, so
+		// databases created below have them too. Removing an extension from the
+		// spec does not drop it; it may be in use.
+		if extensions := postgres.Extensions(cluster); len(extensions) == 0 {
+			extensionsOK = true
+		} else {
+			err := postgres.CreateExtensionsInPostgreSQL(ctx, exec, extensions)
+			if extensionsOK = err == nil; !extensionsOK {
+				// Some extensions can only be created after their shared library
+				// is loaded, which may require a restart first.
+				r.Recorder.Event(cluster, corev1.EventTypeWarning, "ExtensionsNotCreated",
+					"Unable to create extensions: "+err.Error())
+			}
+		}
+
+		err := postgres.CreateDatabasesInPostgreSQL(ctx, exec, databases.List())
+		if err == nil && len(cluster.Spec.Databases) > 0 {
+			err = postgres.WriteDatabasesInPostgreSQL(ctx, exec, cluster.Spec.Databases, logins)
+		}
+		return err
 	}
 
 	revision, err := safeHash32(func(hasher io.Writer) error {
@@ -285,13 +382,40 @@ func (r *Reconciler) reconcilePostgresDatabases(
 		log := logging.FromContext(ctx).WithValues("revision", revision)
 		err = errors.WithStack(create(logging.NewContext(ctx, log), podExecutor))
 	}
-	if err == nil && pgAuditOK && postgisInstallOK {
+	if err == nil && pgAuditOK && postgisInstallOK && extensionsOK {
 		cluster.Status.DatabaseRevision = revision
 	}
+	if err == nil {
+		r.reconcileDatabasesStatus(cluster)
+	}
 
 	return err
 }
 
+// reconcileDatabasesStatus records the databases in spec.databases that now
+// exist in PostgreSQL. Databases removed from the spec are not dropped, so it
+// emits a warning event for each one instead.
+func (r *Reconciler) reconcileDatabasesStatus(cluster *v1beta1.PostgresCluster) {
+	specified := sets.NewString()
+	for _, database := range cluster.Spec.Databases {
+		specified.Insert(string(database.Name))
+	}
+
+	for _, database := range cluster.Status.Databases {
+		if !specified.Has(string(database)) {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "DatabaseNotDropped",
+				"Database %q was removed from spec.databases but still exists in PostgreSQL",
+				database)
+		}
+	}
+
+	cluster.Status.Databases = nil
+	for _, name := range specified.List() {
+		cluster.Status.Databases = append(cluster.Status.Databases,
+			v1beta1.PostgresIdentifier(name))
+	}
+}
+
 // reconcilePostgresUsers writes the objects necessary to manage users and their
 // passwords in PostgreSQL.
 func (r *Reconciler) reconcilePostgresUsers(
@@ -311,6 +435,62 @@ func (r *Reconciler) reconcilePostgresUsers(
 	return err
 }
 
+// validateDefaultUser returns the reasons the name of cluster cannot be used
+// for the PostgreSQL user that is created when the spec has no users.
+func validateDefaultUser(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var allErrors field.ErrorList
+
+	if cluster.Spec.Users != nil {
+		return allErrors
+	}
+
+	path := field.NewPath("spec", "users").Index(0).Child("name")
+	reUser := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+	// User names cannot be too long. PostgresCluster.Name is a DNS
+	// subdomain, so use len() to count characters.
+	if n := len(cluster.Name); n > 63 {
+		allErrors = append(allErrors,
+			field.Invalid(path, cluster.Name,
+				fmt.Sprintf("should be at most %d chars long", 63)))
+	}
+	// See v1beta1.PostgresRoleSpec validation markers.
+	if !reUser.MatchString(cluster.Name) {
+		allErrors = append(allErrors,
+			field.Invalid(path, cluster.Name,
+				fmt.Sprintf("should match '%s'", reUser)))
+	}
+	if reservedUserName(v1beta1.PostgresIdentifier(cluster.Name)) {
+		allErrors = append(allErrors,
+			field.Invalid(path, cluster.Name, `the "postgres" user is reserved`))
+	}
+
+	return allErrors
+}
+
+// reservedUserName returns whether or not name is a PostgreSQL user that the
+// operator does not manage through spec.users.
+func reservedUserName(name v1beta1.PostgresIdentifier) bool {
+	return name == "postgres"
+}
+
+// validatePostgresUsers returns the reasons the users in the spec of cluster,
+// or its default user, cannot be managed.
+func validatePostgresUsers(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	allErrors := validateDefaultUser(cluster)
+
+	path := field.NewPath("spec", "users")
+	for i := range cluster.Spec.Users {
+		if name := cluster.Spec.Users[i].Name; reservedUserName(name) {
+			allErrors = append(allErrors,
+				field.Invalid(path.Index(i).Child("name"), name,
+					`the "postgres" user is reserved`))
+		}
+	}
+
+	return allErrors
+}
+
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={list}
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={create,delete,patch}
 
@@ -323,39 +503,35 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 ) (
 	[]v1beta1.PostgresUserSpec, map[string]*corev1.Secret, error,
 ) {
+	allErrors := validatePostgresUsers(cluster)
+	if len(allErrors) > 0 {
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidUser",
+			allErrors.ToAggregate().Error())
+	}
+
 	// When users are unspecified, create one user matching the cluster name if
 	// it is also a valid user name.
-	// TODO(cbandy): Move this to a defaulting (mutating admission) webhook to
-	// leverage regular validation.
-	specUsers := cluster.Spec.Users
-	if specUsers == nil {
-		path := field.NewPath("spec", "users").Index(0).Child("name")
-		reUser := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
-		allErrors := field.ErrorList{}
-
-		// User names cannot be too long. PostgresCluster.Name is a DNS
-		// subdomain, so use len() to count characters.
-		if n := len(cluster.Name); n > 63 {
-			allErrors = append(allErrors,
-				field.Invalid(path, cluster.Name,
-					fmt.Sprintf("should be at most %d chars long", 63)))
-		}
-		// See v1beta1.PostgresRoleSpec validation markers.
-		if !reUser.MatchString(cluster.Name) {
-			allErrors = append(allErrors,
-				field.Invalid(path, cluster.Name,
-					fmt.Sprintf("should match '%s'", reUser)))
+	var specUsers []v1beta1.PostgresUserSpec
+	if cluster.Spec.Users == nil {
+		if len(allErrors) > 0 {
+			// Keep any existing Secrets; applications may be using them.
+			return nil, nil, nil
 		}
 
-		if len(allErrors) > 0 {
-			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidUser",
-				allErrors.ToAggregate().Error())
+		identifier := v1beta1.PostgresIdentifier(cluster.Name)
+		specUsers = []v1beta1.PostgresUserSpec{{
+			Name:      identifier,
+			Databases: []v1beta1.PostgresIdentifier{identifier},
+		}}
+	}
+
+	// Leave out reserved users, but keep any Secrets they already have.
+	reserved := sets.NewString()
+	for _, user := range cluster.Spec.Users {
+		if reservedUserName(user.Name) {
+			reserved.Insert(string(user.Name))
 		} else {
-			identifier := v1beta1.PostgresIdentifier(cluster.Name)
-			specUsers = []v1beta1.PostgresUserSpec{{
-				Name:      identifier,
-				Databases: []v1beta1.PostgresIdentifier{identifier},
-			}}
+			specUsers = append(specUsers, user)
 		}
 	}
 
@@ -396,7 +572,7 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 				} else {
 					userSecrets[secretUserName] = secret
 				}
-			} else if err == nil {
+			} else if err == nil && !reserved.Has(secretUserName) {
 				err = errors.WithStack(r.deleteControlled(ctx, cluster, secret))
 			}
 		}
@@ -418,6 +594,13 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 		if err == nil {
 			err = errors.WithStack(r.apply(ctx, userSecrets[userName]))
 		}
+
+		// The new password is stored, so remove the annotation that asked for
+		// it. The user is altered in PostgreSQL once its verifier changes.
+		if err == nil && secret != nil && secret.Annotations[naming.RotatePassword] == "true" {
+			err = errors.WithStack(r.patch(ctx, userSecrets[userName],
+				kubeapi.NewMergePatch().Remove("metadata", "annotations", naming.RotatePassword)))
+		}
 	}
 
 	return specUsers, userSecrets, err
@@ -539,6 +722,25 @@ func (r *Reconciler) reconcilePostgresDataVolume(
 
 	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
 
+	spec := instanceSpec.DataVolumeClaimSpec.DeepCopy()
+
+	// Kubernetes does not allow a PersistentVolumeClaim to shrink. Keep the
+	// size it already requests and explain with an event.
+	// - https://docs.k8s.io/concepts/storage/persistent-volumes/#expanding-persistent-volumes-claims
+	for i := range clusterVolumes {
+		if clusterVolumes[i].Name != existingPVCName {
+			continue
+		}
+		current := clusterVolumes[i].Spec.Resources.Requests[corev1.ResourceStorage]
+		requested, ok := spec.Resources.Requests[corev1.ResourceStorage]
+		if ok && requested.Cmp(current) < 0 {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "PersistentVolumeShrink",
+				"Cannot shrink PersistentVolumeClaim %q from %s to %s",
+				existingPVCName, current.String(), requested.String())
+			spec.Resources.Requests[corev1.ResourceStorage] = current
+		}
+	}
+
 	err = errors.WithStack(r.setControllerReference(cluster, pvc))
 
 	pvc.Annotations = naming.Merge(
@@ -551,7 +753,7 @@ func (r *Reconciler) reconcilePostgresDataVolume(
 		labelMap,
 	)
 
-	pvc.Spec = instanceSpec.DataVolumeClaimSpec
+	pvc.Spec = *spec
 
 	if err == nil {
 		err = r.handlePersistentVolumeClaimError(cluster,
@@ -20,7 +20,10 @@ package postgrescluster
 
 import (
 	"context"
+	"encoding/json"
 	"io"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -28,19 +31,173 @@ import (
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+func TestGeneratePostgresHBAs(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{Recorder: recorder}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Namespace = "ns1"
+	cluster.Name = "hippo"
+
+	printed := func(hbas []postgres.HostBasedAuthentication) []string {
+		out := make([]string, len(hbas))
+		for i := range hbas {
+			out[i] = hbas[i].String()
+		}
+		return out
+	}
+
+	t.Run("NoRules", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		hbas := reconciler.generatePostgresHBAs(cluster)
+		assert.DeepEqual(t, printed(hbas.Mandatory), printed(postgres.NewHBAs().Mandatory))
+		assert.DeepEqual(t, printed(hbas.Default), printed(postgres.NewHBAs().Default))
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Rules", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			Rules: []v1beta1.PostgresHBARule{
+				{
+					Connection: "host", Address: "10.0.0.0/8", Method: "md5",
+					Users: []v1beta1.PostgresIdentifier{"monitoring"},
+				},
+				{Connection: "hostssl", Address: "samenet", Method: "scram-sha-256"},
+			},
+		}
+
+		hbas := reconciler.generatePostgresHBAs(cluster)
+		assert.DeepEqual(t, printed(hbas.Mandatory), printed(postgres.NewHBAs().Mandatory))
+		assert.DeepEqual(t, printed(hbas.Default), []string{
+			`host all "monitoring" "10.0.0.0/8" md5`,
+			`hostssl all all samenet scram-sha-256`,
+			`hostssl all all all md5`,
+		})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			Rules: []v1beta1.PostgresHBARule{
+				{Connection: "host", Address: "10.0.0.0/99", Method: "md5"},
+				{Connection: "hostssl", Method: "cert"},
+				{Connection: "host", Method: "trust reject"},
+			},
+		}
+
+		hbas := reconciler.generatePostgresHBAs(cluster)
+		assert.DeepEqual(t, printed(hbas.Mandatory), printed(postgres.NewHBAs().Mandatory))
+		assert.DeepEqual(t, printed(hbas.Default), []string{
+			`hostssl all all all cert`,
+			`hostssl all all all md5`,
+		})
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidPostgresHBA")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, "spec.authentication.rules[0].address"))
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, "spec.authentication.rules[2].method"))
+	})
+}
+
+func TestGeneratePostgresIdents(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{Recorder: recorder}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Namespace = "ns1"
+	cluster.Name = "hippo"
+
+	printed := func(idents []postgres.UserNameMap) []string {
+		out := make([]string, len(idents))
+		for i := range idents {
+			out[i] = idents[i].String()
+		}
+		return out
+	}
+
+	t.Run("NoMaps", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		idents := reconciler.generatePostgresIdents(cluster)
+		assert.DeepEqual(t, printed(idents.Mandatory), printed(postgres.NewIdents().Mandatory))
+		assert.DeepEqual(t, printed(idents.Default), printed(postgres.NewIdents().Default))
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Maps", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			Maps: []v1beta1.PostgresUserNameMap{
+				{Name: "certs", SystemUser: "alice@example.com", DatabaseUser: "alice"},
+				{Name: "certs", SystemUser: `/^(.*)@example\.com$`, DatabaseUser: `\1`},
+			},
+		}
+
+		idents := reconciler.generatePostgresIdents(cluster)
+		assert.DeepEqual(t, printed(idents.Mandatory), printed(postgres.NewIdents().Mandatory))
+		assert.DeepEqual(t, printed(idents.Default), []string{
+			`"certs" "alice@example.com" "alice"`,
+			`"certs" "/^(.*)@example\.com$" "\1"`,
+		})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+			Maps: []v1beta1.PostgresUserNameMap{
+				{Name: "certs", SystemUser: "new\nline", DatabaseUser: "alice"},
+				{Name: "certs", SystemUser: "bob", DatabaseUser: "bob"},
+			},
+		}
+
+		idents := reconciler.generatePostgresIdents(cluster)
+		assert.DeepEqual(t, printed(idents.Default), []string{
+			`"certs" "bob" "bob"`,
+		})
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidPostgresIdent")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, "spec.authentication.maps[0].systemUser"))
+	})
+}
+
 func TestGeneratePostgresUserSecret(t *testing.T) {
 	_, tClient := setupKubernetes(t)
 	require.ParallelCapacity(t, 0)
@@ -153,6 +310,24 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 			assert.Equal(t, string(secret.Data["password"]), "asdf")
 			assert.Equal(t, string(secret.Data["verifier"]), "some$thing")
 		}
+
+		// Generated when existing Secret is annotated for rotation.
+		existing := &corev1.Secret{
+			Data: map[string][]byte{
+				"password": []byte(`asdf`),
+				"verifier": []byte(`some$thing`),
+			},
+		}
+		existing.Annotations = map[string]string{naming.RotatePassword: "true"}
+
+		secret, err = reconciler.generatePostgresUserSecret(cluster, spec, existing)
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			assert.Assert(t, string(secret.Data["password"]) != "asdf")
+			assert.Assert(t, len(secret.Data["password"]) > 16, "got %v", len(secret.Data["password"]))
+			assert.Assert(t, len(secret.Data["verifier"]) > 90, "got %v", len(secret.Data["verifier"]))
+		}
 	})
 
 	t.Run("Database", func(t *testing.T) {
@@ -237,6 +412,377 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 	})
 }
 
+func TestReconcilePostgresDatabases(t *testing.T) {
+	ctx := context.Background()
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	var calls []string
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{
+		Recorder: recorder,
+		PodExec: func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			calls = append(calls, string(b))
+			return nil
+		},
+	}
+
+	observed := &observedInstances{forCluster: []*Instance{{
+		Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "some-pod",
+				Annotations: map[string]string{"status": `{"role":"master"}`},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  naming.ContainerDatabase,
+					State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+				}},
+			},
+		}},
+		Runner: &appsv1.StatefulSet{},
+	}}}
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+		{Name: "app-user", Databases: []v1beta1.PostgresIdentifier{"app"}},
+	}
+	cluster.Spec.Databases = []v1beta1.PostgresDatabaseSpec{
+		{Name: "app", Owner: "app-user"},
+		{Name: "warehouse", Owner: "analysts"},
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		calls = nil
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+
+		sql := strings.Join(calls, "\n")
+		assert.Assert(t, cmp.Contains(sql, `
+{"database":"app","login":true,"owner":"app-user"}
+{"database":"warehouse","login":false,"owner":"analysts"}
+`))
+		assert.Assert(t, cmp.Contains(sql, `CREATE ROLE %I`))
+		assert.Assert(t, cmp.Contains(sql, `ALTER DATABASE %I OWNER TO %I`))
+
+		assert.Assert(t, cluster.Status.DatabaseRevision != "")
+		assert.DeepEqual(t, cluster.Status.Databases,
+			[]v1beta1.PostgresIdentifier{"app", "warehouse"})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		calls = nil
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Equal(t, len(calls), 0, "expected no SQL")
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+		calls = nil
+		cluster.Spec.Databases = cluster.Spec.Databases[:1]
+
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Assert(t, len(calls) > 0)
+		for _, sql := range calls {
+			assert.Assert(t, !strings.Contains(sql, "DROP"), "got %q", sql)
+		}
+
+		assert.DeepEqual(t, cluster.Status.Databases,
+			[]v1beta1.PostgresIdentifier{"app"})
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "DatabaseNotDropped")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, `"warehouse"`))
+	})
+
+	t.Run("Extensions", func(t *testing.T) {
+		calls = nil
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{{Name: "pg_stat_statements"}}
+
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Assert(t, cmp.Contains(strings.Join(calls, "\n"), `CREATE EXTENSION IF NOT EXISTS %I`))
+		assert.Equal(t, len(recorder.Events), 0)
+
+		// Removing an extension does not drop it.
+		calls = nil
+		cluster.Spec.Extensions = nil
+
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Assert(t, len(calls) > 0)
+		for _, sql := range calls {
+			assert.Assert(t, !strings.Contains(sql, "DROP"), "got %q", sql)
+			assert.Assert(t, !strings.Contains(sql, "CREATE EXTENSION IF NOT EXISTS %I"), "got %q", sql)
+		}
+	})
+
+	t.Run("PGStatStatements", func(t *testing.T) {
+		var commands []string
+		reconciler := &Reconciler{
+			Recorder: recorder,
+			PodExec: func(
+				_, _, _ string, _ io.Reader, _, _ io.Writer, command ...string,
+			) error {
+				commands = append(commands, strings.Join(command, " "))
+				return nil
+			},
+		}
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Monitoring = &v1beta1.MonitoringSpec{EnablePGStatStatements: true}
+
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Assert(t, cmp.Contains(strings.Join(commands, "\n"),
+			`--set=extensions=[{"name":"pg_stat_statements"}]`))
+
+		// Nothing is created when disabled.
+		commands = nil
+		cluster.Spec.Monitoring.EnablePGStatStatements = false
+
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Assert(t, !strings.Contains(strings.Join(commands, "\n"), `--set=extensions=`))
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("ExtensionsFail", func(t *testing.T) {
+		recorder := events.NewRecorder(t, scheme)
+		reconciler := &Reconciler{
+			Recorder: recorder,
+			PodExec: func(
+				_, _, _ string, stdin io.Reader, _, stderr io.Writer, _ ...string,
+			) error {
+				b, err := io.ReadAll(stdin)
+				assert.NilError(t, err)
+				if strings.Contains(string(b), "CREATE EXTENSION IF NOT EXISTS %I") {
+					_, _ = stderr.Write([]byte("ERROR:  unrecognized configuration parameter \"cron.database_name\"\n"))
+					return errors.New("exit status 3")
+				}
+				return nil
+			},
+		}
+
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{{Name: "pg_cron"}}
+		cluster.Status.DatabaseRevision = "before"
+
+		assert.NilError(t, reconciler.reconcilePostgresDatabases(ctx, cluster, observed))
+		assert.Equal(t, cluster.Status.DatabaseRevision, "before",
+			"expected another attempt later")
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "ExtensionsNotCreated")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, `"cron.database_name"`))
+	})
+}
+
+func TestReconcilePostgresUserSecrets(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	recorder := events.NewRecorder(t, scheme)
+	reconciler := &Reconciler{
+		Client:   tClient,
+		Owner:    client.FieldOwner(t.Name()),
+		Recorder: recorder,
+	}
+
+	ns := setupNamespace(t, tClient)
+
+	create := func(t testing.TB, name string) *v1beta1.PostgresCluster {
+		cluster := testCluster()
+		cluster.Namespace = ns.Name
+		cluster.Name = name
+
+		assert.NilError(t, tClient.Create(ctx, cluster))
+		t.Cleanup(func() { assert.Check(t, tClient.Delete(ctx, cluster)) })
+		return cluster
+	}
+
+	listUsers := func(t testing.TB, cluster *v1beta1.PostgresCluster) []string {
+		secrets := &corev1.SecretList{}
+		selector, err := naming.AsSelector(naming.ClusterPostgresUsers(cluster.Name))
+		assert.NilError(t, err)
+		assert.NilError(t, tClient.List(ctx, secrets,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabelsSelector{Selector: selector}))
+
+		var users []string
+		for i := range secrets.Items {
+			users = append(users, secrets.Items[i].Labels[naming.LabelPostgresUser])
+		}
+		sort.Strings(users)
+		return users
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+		cluster := create(t, "default-user")
+
+		specUsers, secrets, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, specUsers, []v1beta1.PostgresUserSpec{{
+			Name:      "default-user",
+			Databases: []v1beta1.PostgresIdentifier{"default-user"},
+		}})
+		assert.Equal(t, len(secrets), 1)
+		assert.DeepEqual(t, listUsers(t, cluster), []string{"default-user"})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Multiple", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+		cluster := create(t, "multiple-users")
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+			{Name: "app", Databases: []v1beta1.PostgresIdentifier{"app"}},
+			{Name: "reporting", Databases: []v1beta1.PostgresIdentifier{"app", "warehouse"}},
+		}
+
+		_, secrets, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 2)
+		assert.DeepEqual(t, listUsers(t, cluster), []string{"app", "reporting"})
+
+		// Removing a user deletes its Secret.
+		cluster.Spec.Users = cluster.Spec.Users[:1]
+
+		_, secrets, err = reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(secrets), 1)
+		assert.DeepEqual(t, listUsers(t, cluster), []string{"app"})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("RotatePassword", func(t *testing.T) {
+		cluster := create(t, "rotate-password")
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "app"}}
+
+		_, secrets, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		before := secrets["app"].DeepCopy()
+
+		// Annotate the Secret the way a person would.
+		assert.NilError(t, tClient.Patch(ctx, secrets["app"].DeepCopy(), client.RawPatch(
+			client.Merge.Type(), []byte(`{"metadata":{"annotations":{"`+
+				naming.RotatePassword+`":"true"}}}`))))
+
+		_, secrets, err = reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+
+		after := &corev1.Secret{}
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(before), after))
+		assert.Assert(t, string(after.Data["password"]) != string(before.Data["password"]))
+		assert.Assert(t, string(after.Data["verifier"]) != string(before.Data["verifier"]))
+		assert.Equal(t, after.Annotations[naming.RotatePassword], "", "expected trigger to be removed")
+		assert.Equal(t, string(secrets["app"].Data["verifier"]), string(after.Data["verifier"]))
+
+		// The new verifier is written to PostgreSQL.
+		var sql string
+		reconciler := *reconciler
+		reconciler.PodExec = func(
+			namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			sql = string(b)
+			return err
+		}
+
+		assert.NilError(t, reconciler.reconcilePostgresUsersInPostgreSQL(ctx, cluster,
+			&observedInstances{forCluster: []*Instance{{
+				Pods: []*corev1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "some-pod",
+						Annotations: map[string]string{"status": `{"role":"master"}`},
+					},
+					Status: corev1.PodStatus{
+						ContainerStatuses: []corev1.ContainerStatus{{
+							Name:  naming.ContainerDatabase,
+							State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+						}},
+					},
+				}},
+				Runner: &appsv1.StatefulSet{},
+			}}},
+			cluster.Spec.Users, secrets))
+
+		assert.Assert(t, cmp.Contains(sql, `ALTER ROLE %I WITH %s PASSWORD %L`))
+		verifier, err := json.Marshal(string(after.Data["verifier"]))
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(sql, `"verifier":`+string(verifier)))
+	})
+
+	t.Run("Reserved", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+		cluster := create(t, "postgres")
+
+		specUsers, secrets, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(specUsers), 0)
+		assert.Equal(t, len(secrets), 0)
+		assert.Assert(t, listUsers(t, cluster) == nil)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidUser")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, `spec.users[0].name`))
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, `the "postgres" user is reserved`))
+	})
+
+	t.Run("ReservedInSpec", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+		cluster := create(t, "reserved-in-spec")
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+			{Name: "app"},
+			{Name: "postgres"},
+		}
+
+		// Only the other users are managed.
+		specUsers, secrets, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, specUsers, []v1beta1.PostgresUserSpec{{Name: "app"}})
+		assert.Equal(t, len(secrets), 1)
+		assert.DeepEqual(t, listUsers(t, cluster), []string{"app"})
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidUser")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, `spec.users[1].name`))
+	})
+
+	t.Run("InvalidDefault", func(t *testing.T) {
+		t.Cleanup(func() { recorder.Events = recorder.Events[:0] })
+		cluster := create(t, "invalid.user")
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "app"}}
+
+		_, _, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, listUsers(t, cluster), []string{"app"})
+
+		// The cluster name is not a valid user name. Existing Secrets are kept.
+		cluster.Spec.Users = nil
+
+		specUsers, secrets, err := reconciler.reconcilePostgresUserSecrets(ctx, cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, len(specUsers), 0)
+		assert.Equal(t, len(secrets), 0)
+		assert.DeepEqual(t, listUsers(t, cluster), []string{"app"})
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, corev1.EventTypeWarning)
+		assert.Equal(t, recorder.Events[0].Reason, "InvalidUser")
+	})
+}
+
 func TestReconcilePostgresVolumes(t *testing.T) {
 	ctx := context.Background()
 	_, tClient := setupKubernetes(t)
@@ -287,6 +833,78 @@ volumeMode: Filesystem
 		`))
 	})
 
+	t.Run("DataVolumeShrink", func(t *testing.T) {
+		recorder := events.NewRecorder(t, tClient.Scheme())
+		reconciler := *reconciler
+		reconciler.Recorder = recorder
+
+		existing, err := reconciler.reconcilePostgresDataVolume(ctx, cluster, spec, instance, nil)
+		assert.NilError(t, err)
+
+		spec := spec.DeepCopy()
+		spec.DataVolumeClaimSpec.Resources.Requests[corev1.ResourceStorage] =
+			resource.MustParse("500Mi")
+
+		pvc, err := reconciler.reconcilePostgresDataVolume(ctx, cluster, spec, instance,
+			[]corev1.PersistentVolumeClaim{*existing})
+		assert.NilError(t, err)
+		assert.Equal(t, pvc.Name, existing.Name)
+
+		// The claim keeps its size, and the spec is unchanged.
+		assert.Assert(t, marshalMatches(pvc.Spec.Resources, `
+requests:
+  storage: 1Gi
+		`))
+		assert.Equal(t, spec.DataVolumeClaimSpec.Resources.Requests.Storage().String(), "500Mi")
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Type, "Warning")
+		assert.Equal(t, recorder.Events[0].Reason, "PersistentVolumeShrink")
+		assert.Assert(t, cmp.Contains(recorder.Events[0].Note, "from 1Gi to 500Mi"))
+	})
+
+	t.Run("DataVolumeGrow", func(t *testing.T) {
+		recorder := events.NewRecorder(t, tClient.Scheme())
+		reconciler := *reconciler
+		reconciler.Recorder = recorder
+
+		// Kubernetes allows a claim to grow when it is bound and its storage
+		// class allows expansion.
+		// - https://docs.k8s.io/concepts/storage/persistent-volumes/#expanding-persistent-volumes-claims
+		class := &storagev1.StorageClass{
+			ObjectMeta:           metav1.ObjectMeta{Name: cluster.Namespace + "-expand"},
+			Provisioner:          "kubernetes.io/no-provisioner",
+			AllowVolumeExpansion: initialize.Bool(true),
+		}
+		assert.NilError(t, tClient.Create(ctx, class))
+		t.Cleanup(func() { assert.Check(t, tClient.Delete(ctx, class)) })
+
+		spec := spec.DeepCopy()
+		spec.Name = "grow-instance"
+		spec.DataVolumeClaimSpec.StorageClassName = &class.Name
+		instance := &appsv1.StatefulSet{ObjectMeta: naming.GenerateInstance(cluster, spec)}
+
+		existing, err := reconciler.reconcilePostgresDataVolume(ctx, cluster, spec, instance, nil)
+		assert.NilError(t, err)
+
+		existing.Status.Phase = corev1.ClaimBound
+		assert.NilError(t, tClient.Status().Update(ctx, existing))
+
+		spec.DataVolumeClaimSpec.Resources.Requests[corev1.ResourceStorage] =
+			resource.MustParse("2Gi")
+
+		pvc, err := reconciler.reconcilePostgresDataVolume(ctx, cluster, spec, instance,
+			[]corev1.PersistentVolumeClaim{*existing})
+		assert.NilError(t, err)
+		assert.Equal(t, pvc.Name, existing.Name)
+		assert.Equal(t, len(recorder.Events), 0)
+
+		// The existing claim is patched to the new size.
+		fetched := new(corev1.PersistentVolumeClaim)
+		assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(pvc), fetched))
+		assert.Equal(t, fetched.Spec.Resources.Requests.Storage().String(), "2Gi")
+	})
+
 	t.Run("WALVolume", func(t *testing.T) {
 		observed := &Instance{}
 
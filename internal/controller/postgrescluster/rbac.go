@@ -65,7 +65,8 @@ func (r *Reconciler) reconcileInstanceRBAC(
 		err = errors.WithStack(r.setControllerReference(cluster, role))
 	}
 
-	account.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	account.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.ServiceAccountAnnotations)
 	account.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
 		map[string]string{
 			naming.LabelCluster: cluster.Name,
@@ -0,0 +1,62 @@
+//go:build envtest
+// +build envtest
+
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/testing/require"
+)
+
+func TestReconcileInstanceRBAC(t *testing.T) {
+	ctx := context.Background()
+	_, tClient := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	r := &Reconciler{Client: tClient, Owner: client.FieldOwner(t.Name())}
+	ns := setupNamespace(t, tClient)
+
+	cluster := fakePostgresCluster("hippo", ns.Name, "", false)
+	cluster.Spec.ServiceAccountAnnotations = map[string]string{
+		"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/hippo",
+	}
+	assert.NilError(t, tClient.Create(ctx, cluster))
+	t.Cleanup(func() { assert.Check(t, tClient.Delete(ctx, cluster)) })
+
+	account, err := r.reconcileInstanceRBAC(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Equal(t, account.Name, naming.ClusterInstanceRBAC(cluster).Name)
+
+	actual := &corev1.ServiceAccount{}
+	assert.NilError(t, tClient.Get(ctx, client.ObjectKeyFromObject(account), actual))
+
+	assert.Equal(t, actual.Annotations["eks.amazonaws.com/role-arn"],
+		"arn:aws:iam::111122223333:role/hippo")
+
+	// The cluster owns the ServiceAccount so it is garbage collected.
+	assert.Assert(t, len(actual.OwnerReferences) == 1)
+	assert.Equal(t, actual.OwnerReferences[0].UID, cluster.UID)
+	assert.Equal(t, *actual.OwnerReferences[0].Controller, true)
+}
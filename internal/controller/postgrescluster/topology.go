@@ -36,3 +36,19 @@ func defaultTopologySpreadConstraints(selector metav1.LabelSelector) []corev1.To
 		},
 	}
 }
+
+// defaultPodAntiAffinity returns scheduling constraints that prefer to
+// schedule pods matching selector on different nodes.
+func defaultPodAntiAffinity(selector metav1.LabelSelector) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 1,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &selector,
+					TopologyKey:   corev1.LabelHostname,
+				},
+			}},
+		},
+	}
+}
@@ -58,3 +58,21 @@ func TestDefaultTopologySpreadConstraints(t *testing.T) {
   whenUnsatisfiable: ScheduleAnyway
 	`))
 }
+
+func TestDefaultPodAntiAffinity(t *testing.T) {
+	affinity := defaultPodAntiAffinity(metav1.LabelSelector{
+		MatchLabels: map[string]string{"basic": "stuff"},
+	})
+
+	// Entire selector, hostname, and preferred rather than required.
+	assert.Assert(t, marshalMatches(affinity, `
+podAntiAffinity:
+  preferredDuringSchedulingIgnoredDuringExecution:
+  - podAffinityTerm:
+      labelSelector:
+        matchLabels:
+          basic: stuff
+      topologyKey: kubernetes.io/hostname
+    weight: 1
+	`))
+}
@@ -0,0 +1,397 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// upgradeScript runs pg_upgrade against the data directory of the previous
+// major version, $1, producing one for the next major version, $2, that
+// writes WAL to $3. It follows the layout expected by the instance Pods:
+// "/pgdata/pg<version>". The old directory is linked rather than copied and
+// is left in place afterward.
+const upgradeScript = `
+declare -r data_volume='/pgdata' old_version="$1" new_version="$2" wal_directory="$3"
+printf 'Performing PostgreSQL upgrade from version "%s" to "%s" ...\n\n' "$1" "$2"
+
+# pg_upgrade writes its logs and scripts to the current directory.
+cd "${data_volume}"
+
+echo -e "Step 1: Making new pgdata directory...\n"
+mkdir "${data_volume}/pg${new_version}"
+
+echo -e "Step 2: Initializing new pgdata directory...\n"
+/usr/pgsql-"${new_version}"/bin/initdb -k -E UTF8 \
+-D "${data_volume}/pg${new_version}" --waldir="${wal_directory}"
+
+echo -e "\nStep 3: Setting the expected permissions on the old pgdata directory...\n"
+chmod 700 "${data_volume}/pg${old_version}"
+
+echo -e "Step 4: Copying shared_preload_libraries setting to new postgresql.conf file...\n"
+echo "shared_preload_libraries = '$(/usr/pgsql-"""${old_version}"""/bin/postgres -D \
+"""${data_volume}/pg${old_version}""" -C shared_preload_libraries)'" >> "${data_volume}/pg${new_version}/postgresql.conf"
+
+echo -e "Step 5: Running pg_upgrade check...\n"
+time /usr/pgsql-"${new_version}"/bin/pg_upgrade --old-bindir /usr/pgsql-"${old_version}"/bin \
+--new-bindir /usr/pgsql-"${new_version}"/bin --old-datadir "${data_volume}/pg${old_version}" \
+--new-datadir "${data_volume}/pg${new_version}" --link --check
+
+echo -e "\nStep 6: Running pg_upgrade...\n"
+time /usr/pgsql-"${new_version}"/bin/pg_upgrade --old-bindir /usr/pgsql-"${old_version}"/bin \
+--new-bindir /usr/pgsql-"${new_version}"/bin --old-datadir "${data_volume}/pg${old_version}" \
+--new-datadir "${data_volume}/pg${new_version}" --link
+
+echo -e "\nStep 7: Copying patroni.dynamic.json...\n"
+cp "${data_volume}/pg${old_version}/patroni.dynamic.json" "${data_volume}/pg${new_version}"
+
+echo -e "\npg_upgrade Job Complete!"
+`
+
+// validatePostgresVersion returns an error when spec.postgresVersion is lower
+// than the version of the existing data directory, or when cluster asks for a
+// major upgrade it cannot perform.
+func validatePostgresVersion(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec", "postgresVersion")
+	current := cluster.Status.PostgresVersion
+
+	if current > 0 && cluster.Spec.PostgresVersion < current {
+		errs = append(errs, field.Invalid(path, cluster.Spec.PostgresVersion,
+			fmt.Sprintf("cannot be lower than the current version, %d", current)))
+	}
+
+	if current > 0 && cluster.Spec.PostgresVersion > current &&
+		cluster.Spec.Standby != nil && cluster.Spec.Standby.Enabled {
+		errs = append(errs, field.Forbidden(path,
+			"a standby cluster cannot perform a major version upgrade"))
+	}
+
+	return errs
+}
+
+// prepareMajorUpgrade compares spec.postgresVersion to the version recorded
+// in status and returns the version cluster should be upgraded to, if any.
+// When an upgrade is acknowledged through spec.upgrade.enabled, cluster is
+// changed in memory to keep its current version and to shut down so that
+// pg_upgrade can run. When it is not, the returned bool indicates the main
+// control loop should return early so that nothing in the cluster changes.
+func (r *Reconciler) prepareMajorUpgrade(cluster *v1beta1.PostgresCluster) (int, bool) {
+	current, target := cluster.Status.PostgresVersion, cluster.Spec.PostgresVersion
+
+	// Record the version of a new or existing cluster the first time it is seen.
+	if current == 0 {
+		cluster.Status.PostgresVersion = target
+		return 0, false
+	}
+
+	if target <= current {
+		// Clear any problem reported for an upgrade that is no longer wanted.
+		if condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.MajorVersionUpgrade); condition != nil &&
+			condition.Reason == "UpgradeNotEnabled" {
+			meta.RemoveStatusCondition(&cluster.Status.Conditions,
+				v1beta1.MajorVersionUpgrade)
+		}
+		return 0, false
+	}
+
+	if cluster.Spec.Upgrade == nil || !cluster.Spec.Upgrade.Enabled {
+		message := fmt.Sprintf(
+			"Set spec.upgrade.enabled to upgrade from PostgreSQL %d to %d.",
+			current, target)
+
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               v1beta1.MajorVersionUpgrade,
+			Status:             metav1.ConditionFalse,
+			Reason:             "UpgradeNotEnabled",
+			Message:            message,
+		})
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, "MajorUpgradeNotEnabled", message)
+		return 0, true
+	}
+
+	// Keep the current version until pg_upgrade has finished. Reconciling with
+	// the new version would restart instances on a data directory they cannot
+	// read.
+	cluster.Spec.PostgresVersion = current
+	cluster.Spec.Shutdown = initialize.Bool(true)
+
+	return target, false
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;patch;delete
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=deletecollection
+
+// reconcileMajorUpgrade runs pg_upgrade on the data volume of the startup
+// instance once every instance of cluster has stopped. When the Job completes,
+// cluster.Status.PostgresVersion becomes version. Once that is stored, a later
+// call with version zero deletes the Job and the Patroni DCS, and the cluster
+// starts on the new version. A boolean value is returned to indicate whether
+// the main control loop should return early.
+func (r *Reconciler) reconcileMajorUpgrade(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, observed *observedInstances,
+	clusterVolumes []corev1.PersistentVolumeClaim, version int,
+) (bool, error) {
+	setCondition := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			ObservedGeneration: cluster.GetGeneration(),
+			Type:               v1beta1.MajorVersionUpgrade,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	if version == 0 {
+		return false, r.cleanupMajorUpgrade(ctx, cluster)
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(job), job))
+
+	if err == nil {
+		switch {
+		case jobCompleted(job):
+			cluster.Status.PostgresVersion = version
+
+			// The upgraded data directory has a new system identifier. Let
+			// pgBackRest upgrade its stanzas to match. The Job and the DCS
+			// are deleted only after this status is stored; otherwise, a
+			// failure to store it would run pg_upgrade again.
+			cluster.Status.Patroni.SystemIdentifier = ""
+			if cluster.Status.PGBackRest != nil {
+				for i := range cluster.Status.PGBackRest.Repos {
+					cluster.Status.PGBackRest.Repos[i].StanzaCreated = false
+				}
+			}
+
+			// The exporter configuration depends on the PostgreSQL version.
+			cluster.Status.Monitoring.ExporterConfiguration = ""
+
+			message := fmt.Sprintf("Upgraded to PostgreSQL %d.", version)
+			setCondition(metav1.ConditionFalse, "UpgradeComplete", message)
+			r.Recorder.Event(cluster, corev1.EventTypeNormal, "MajorUpgradeComplete", message)
+
+		case jobFailed(job):
+			message := fmt.Sprintf(
+				"The %s Job failed. Inspect its logs, then delete it to try again.",
+				job.Name)
+			setCondition(metav1.ConditionFalse, "UpgradeFailed", message)
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "MajorUpgradeFailed", message)
+
+		default:
+			setCondition(metav1.ConditionTrue, "Upgrading", fmt.Sprintf(
+				"Running pg_upgrade to PostgreSQL %d.", version))
+		}
+		return true, err
+	}
+
+	if !apierrors.IsNotFound(errors.Cause(err)) {
+		return true, err
+	}
+
+	// Wait for every instance to stop before touching its data.
+	for _, instance := range observed.forCluster {
+		if len(instance.Pods) > 0 {
+			setCondition(metav1.ConditionTrue, "ShuttingDown", fmt.Sprintf(
+				"Stopping instances to upgrade to PostgreSQL %d.", version))
+			return false, nil
+		}
+	}
+
+	// The startup instance was the primary when the cluster shut down.
+	var dataVolume, walVolume *corev1.PersistentVolumeClaim
+	for i := range clusterVolumes {
+		labels := clusterVolumes[i].GetLabels()
+		if cluster.Status.StartupInstance == "" ||
+			labels[naming.LabelInstance] != cluster.Status.StartupInstance {
+			continue
+		}
+		switch labels[naming.LabelRole] {
+		case naming.RolePostgresData:
+			dataVolume = &clusterVolumes[i]
+		case naming.RolePostgresWAL:
+			walVolume = &clusterVolumes[i]
+		}
+	}
+	if dataVolume == nil {
+		return true, errors.Errorf(
+			"unable to find the data volume of instance %q to upgrade",
+			cluster.Status.StartupInstance)
+	}
+
+	job = generatePGUpgradeJob(cluster, dataVolume, walVolume, version)
+
+	err = errors.WithStack(controllerutil.SetControllerReference(cluster, job,
+		r.Client.Scheme()))
+	if err == nil {
+		err = r.apply(ctx, job)
+	}
+	if err == nil {
+		setCondition(metav1.ConditionTrue, "Upgrading", fmt.Sprintf(
+			"Running pg_upgrade to PostgreSQL %d.", version))
+	}
+
+	return true, err
+}
+
+// cleanupMajorUpgrade deletes the pg_upgrade Job of cluster after the status
+// of a completed upgrade is stored. The upgraded data directory has a new
+// system identifier, so the Patroni DCS is deleted first for Patroni to adopt
+// it. Both are deleted before any instance starts on the new version.
+func (r *Reconciler) cleanupMajorUpgrade(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	if condition := meta.FindStatusCondition(cluster.Status.Conditions,
+		v1beta1.MajorVersionUpgrade); condition == nil ||
+		condition.Reason != "UpgradeComplete" {
+		return nil
+	}
+
+	job := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+	err := errors.WithStack(r.Client.Get(ctx, client.ObjectKeyFromObject(job), job))
+
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		return nil
+	}
+	if err != nil || !jobCompleted(job) {
+		return err
+	}
+
+	err = r.deletePatroniArtifacts(ctx, cluster)
+	if err == nil {
+		err = errors.WithStack(client.IgnoreNotFound(r.Client.Delete(ctx, job,
+			client.PropagationPolicy(metav1.DeletePropagationBackground))))
+	}
+	return err
+}
+
+// generatePGUpgradeJob returns a Job that upgrades the PostgreSQL data
+// directory in dataVolume from the current version of cluster to version.
+// The WAL volume is mounted when there is one so that pg_upgrade can follow
+// the pg_wal symlink of the old data directory.
+func generatePGUpgradeJob(cluster *v1beta1.PostgresCluster,
+	dataVolume, walVolume *corev1.PersistentVolumeClaim, version int,
+) *batchv1.Job {
+	job := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+	job.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+
+	job.Annotations = naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil())
+	job.Labels = naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster:   cluster.Name,
+			naming.LabelPGUpgrade: "",
+		})
+
+	// Match the WAL directory that instances of version expect.
+	walStorage := postgres.DataVolumeMount().MountPath
+	if walVolume != nil {
+		walStorage = postgres.WALVolumeMount().MountPath
+	}
+
+	container := corev1.Container{
+		Command: []string{"bash", "-ceu", "--", upgradeScript, "upgrade",
+			fmt.Sprint(cluster.Status.PostgresVersion), fmt.Sprint(version),
+			fmt.Sprintf("%s/pg%d_wal", walStorage, version)},
+		Image:           config.PGUpgradeContainerImage(cluster),
+		ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+		Name:            naming.ContainerJobPGUpgrade,
+		SecurityContext: initialize.RestrictedSecurityContext(),
+		VolumeMounts:    []corev1.VolumeMount{postgres.DataVolumeMount()},
+	}
+	if cluster.Spec.Upgrade != nil {
+		container.Resources = cluster.Spec.Upgrade.Resources
+	}
+
+	volumes := []corev1.Volume{{
+		Name: postgres.DataVolumeMount().Name,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: dataVolume.Name,
+			},
+		},
+	}}
+	if walVolume != nil {
+		container.VolumeMounts = append(container.VolumeMounts, postgres.WALVolumeMount())
+		volumes = append(volumes, corev1.Volume{
+			Name: postgres.WALVolumeMount().Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: walVolume.Name,
+				},
+			},
+		})
+	}
+
+	job.Spec.Template.Labels = job.Labels
+	job.Spec.Template.Annotations = job.Annotations
+	job.Spec.Template.Spec = corev1.PodSpec{
+		// Set the image pull secrets, if any exist.
+		// This is set here rather than using the service account due to the lack
+		// of propagation to existing pods when the CRD is updated:
+		// https://github.com/kubernetes/kubernetes/issues/88456
+		ImagePullSecrets: cluster.Spec.ImagePullSecrets,
+		Containers:       []corev1.Container{container},
+		SecurityContext:  postgres.PodSecurityContext(cluster),
+		// Set RestartPolicy to "Never" since we want a new Pod to be
+		// created by the Job controller when there is a failure
+		// (instead of the container simply restarting).
+		RestartPolicy: corev1.RestartPolicyNever,
+		// These Jobs don't make Kubernetes API calls, so we can just
+		// use the default ServiceAccount and not mount its credentials.
+		AutomountServiceAccountToken: initialize.Bool(false),
+		EnableServiceLinks:           initialize.Bool(false),
+		Volumes:                      volumes,
+	}
+	addDNSConfig(cluster, &job.Spec.Template.Spec)
+
+	// Tolerate the same taints as the instance so the Pod can run wherever
+	// its volumes are available.
+	for i := range cluster.Spec.InstanceSets {
+		if set := &cluster.Spec.InstanceSets[i]; set.Name ==
+			dataVolume.GetLabels()[naming.LabelInstanceSet] {
+			job.Spec.Template.Spec.Tolerations = set.Tolerations
+			if set.PriorityClassName != nil {
+				job.Spec.Template.Spec.PriorityClassName = *set.PriorityClassName
+			}
+		}
+	}
+
+	// A failed upgrade may leave the new data directory behind; retrying
+	// requires a person to look at it first.
+	job.Spec.BackoffLimit = initialize.Int32(0)
+
+	return job
+}
@@ -0,0 +1,356 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestPrepareMajorUpgrade(t *testing.T) {
+	newCluster := func() *v1beta1.PostgresCluster {
+		cluster := testCluster()
+		cluster.Spec.PostgresVersion = 14
+		cluster.Status.PostgresVersion = 13
+		return cluster
+	}
+
+	t.Run("RecordsVersion", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(1)
+		r := &Reconciler{Recorder: recorder}
+
+		cluster := newCluster()
+		cluster.Status.PostgresVersion = 0
+
+		version, returnEarly := r.prepareMajorUpgrade(cluster)
+		assert.Equal(t, version, 0)
+		assert.Assert(t, !returnEarly)
+		assert.Equal(t, cluster.Status.PostgresVersion, 14)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("SameVersion", func(t *testing.T) {
+		r := &Reconciler{Recorder: record.NewFakeRecorder(1)}
+
+		cluster := newCluster()
+		cluster.Status.PostgresVersion = 14
+		before := cluster.DeepCopy()
+
+		version, returnEarly := r.prepareMajorUpgrade(cluster)
+		assert.Equal(t, version, 0)
+		assert.Assert(t, !returnEarly)
+		assert.DeepEqual(t, cluster, before)
+	})
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(1)
+		r := &Reconciler{Recorder: recorder}
+
+		for _, upgrade := range []*v1beta1.PostgresUpgradeSpec{
+			nil, {Enabled: false},
+		} {
+			cluster := newCluster()
+			cluster.Spec.Upgrade = upgrade
+
+			version, returnEarly := r.prepareMajorUpgrade(cluster)
+			assert.Equal(t, version, 0)
+			assert.Assert(t, returnEarly, "expected to hold the cluster as it is")
+
+			// Nothing in the spec changes.
+			assert.Equal(t, cluster.Spec.PostgresVersion, 14)
+			assert.Assert(t, cluster.Spec.Shutdown == nil)
+			assert.Equal(t, cluster.Status.PostgresVersion, 13)
+
+			condition := meta.FindStatusCondition(cluster.Status.Conditions,
+				v1beta1.MajorVersionUpgrade)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, metav1.ConditionFalse)
+			assert.Equal(t, condition.Reason, "UpgradeNotEnabled")
+			assert.Assert(t, strings.Contains(condition.Message, "spec.upgrade.enabled"))
+
+			assert.Equal(t, len(recorder.Events), 1)
+			assert.Assert(t, strings.Contains(<-recorder.Events, "MajorUpgradeNotEnabled"))
+		}
+	})
+
+	t.Run("NoLongerWanted", func(t *testing.T) {
+		r := &Reconciler{Recorder: record.NewFakeRecorder(1)}
+
+		cluster := newCluster()
+		_, _ = r.prepareMajorUpgrade(cluster)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.MajorVersionUpgrade) != nil)
+
+		cluster.Spec.PostgresVersion = 13
+		version, returnEarly := r.prepareMajorUpgrade(cluster)
+		assert.Equal(t, version, 0)
+		assert.Assert(t, !returnEarly)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.MajorVersionUpgrade) == nil)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(1)
+		r := &Reconciler{Recorder: recorder}
+
+		cluster := newCluster()
+		cluster.Spec.Upgrade = &v1beta1.PostgresUpgradeSpec{Enabled: true}
+
+		version, returnEarly := r.prepareMajorUpgrade(cluster)
+		assert.Equal(t, version, 14)
+		assert.Assert(t, !returnEarly)
+
+		// The cluster keeps its current version while it shuts down.
+		assert.Equal(t, cluster.Spec.PostgresVersion, 13)
+		assert.Assert(t, cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown)
+		assert.Equal(t, cluster.Status.PostgresVersion, 13)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+}
+
+func TestReconcileMajorUpgrade(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	newCluster := func() *v1beta1.PostgresCluster {
+		cluster := testCluster()
+		cluster.Namespace = "ns1"
+		cluster.Spec.PostgresVersion = 13
+		cluster.Status.PostgresVersion = 13
+		cluster.Status.StartupInstance = "hippo-instance1-abcd"
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Repos: []v1beta1.RepoStatus{{Name: "repo1", StanzaCreated: true}},
+		}
+		return cluster
+	}
+
+	newJob := func(cluster *v1beta1.PostgresCluster, condition batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{ObjectMeta: naming.PGUpgradeJob(cluster)}
+		if condition != "" {
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type: condition, Status: corev1.ConditionTrue,
+			}}
+		}
+		return job
+	}
+
+	reason := func(cluster *v1beta1.PostgresCluster) string {
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.MajorVersionUpgrade)
+		assert.Assert(t, condition != nil)
+		return condition.Reason
+	}
+
+	t.Run("InstancesRunning", func(t *testing.T) {
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+			Recorder: record.NewFakeRecorder(1),
+		}
+
+		cluster := newCluster()
+		observed := &observedInstances{forCluster: []*Instance{
+			{Name: "hippo-instance1-abcd", Pods: []*corev1.Pod{{}}},
+		}}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, observed, nil, 14)
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly, "expected instances to continue shutting down")
+		assert.Equal(t, reason(cluster), "ShuttingDown")
+	})
+
+	t.Run("MissingVolume", func(t *testing.T) {
+		r := &Reconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+			Recorder: record.NewFakeRecorder(1),
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, newCluster(),
+			&observedInstances{}, nil, 14)
+		assert.ErrorContains(t, err, `data volume of instance "hippo-instance1-abcd"`)
+		assert.Assert(t, returnEarly)
+	})
+
+	t.Run("Running", func(t *testing.T) {
+		cluster := newCluster()
+		r := &Reconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(newJob(cluster, "")).Build(),
+			Recorder: record.NewFakeRecorder(1),
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{}, nil, 14)
+		assert.NilError(t, err)
+		assert.Assert(t, returnEarly)
+		assert.Equal(t, reason(cluster), "Upgrading")
+		assert.Equal(t, cluster.Status.PostgresVersion, 13)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		cluster := newCluster()
+		recorder := record.NewFakeRecorder(1)
+		r := &Reconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(newJob(cluster, batchv1.JobFailed)).Build(),
+			Recorder: recorder,
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{}, nil, 14)
+		assert.NilError(t, err)
+		assert.Assert(t, returnEarly, "expected the cluster to stay down")
+		assert.Equal(t, reason(cluster), "UpgradeFailed")
+		assert.Equal(t, cluster.Status.PostgresVersion, 13)
+		assert.Assert(t, strings.Contains(<-recorder.Events, "MajorUpgradeFailed"))
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Status.Patroni.SystemIdentifier = "12345"
+		job := newJob(cluster, batchv1.JobComplete)
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(job).Build()
+		r := &Reconciler{
+			Client:   cc,
+			Recorder: record.NewFakeRecorder(1),
+		}
+
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{}, nil, 14)
+		assert.NilError(t, err)
+		assert.Assert(t, returnEarly)
+		assert.Equal(t, reason(cluster), "UpgradeComplete")
+
+		assert.Equal(t, cluster.Status.PostgresVersion, 14)
+		assert.Equal(t, cluster.Status.Patroni.SystemIdentifier, "")
+		assert.Assert(t, !cluster.Status.PGBackRest.Repos[0].StanzaCreated)
+
+		// The Job remains until the new version is stored in status.
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(job), job))
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		cluster := newCluster()
+		job := newJob(cluster, batchv1.JobComplete)
+		dcs := &corev1.Endpoints{}
+		dcs.Namespace, dcs.Name = cluster.Namespace, "hippo-ha-config"
+		dcs.Labels = naming.ClusterPatronis(cluster).MatchLabels
+		cc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(job, dcs).Build()
+		r := &Reconciler{Client: cc}
+
+		// Nothing is deleted until an upgrade has completed.
+		returnEarly, err := r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{}, nil, 0)
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly)
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(job), job))
+
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: v1beta1.MajorVersionUpgrade, Status: metav1.ConditionFalse,
+			Reason: "UpgradeComplete",
+		})
+
+		returnEarly, err = r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{}, nil, 0)
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly)
+
+		err = cc.Get(ctx, client.ObjectKeyFromObject(job), job)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected Job to be deleted, got %v", err)
+		err = cc.Get(ctx, client.ObjectKeyFromObject(dcs), dcs)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected DCS to be deleted, got %v", err)
+
+		// There is nothing to do once the Job is gone.
+		returnEarly, err = r.reconcileMajorUpgrade(ctx, cluster, &observedInstances{}, nil, 0)
+		assert.NilError(t, err)
+		assert.Assert(t, !returnEarly)
+	})
+}
+
+func TestGeneratePGUpgradeJob(t *testing.T) {
+	t.Setenv("RELATED_IMAGE_PGUPGRADE", "example.com/upgrade:latest")
+
+	cluster := testCluster()
+	cluster.Namespace = "ns1"
+	cluster.Spec.PostgresVersion = 13
+	cluster.Status.PostgresVersion = 13
+
+	data := &corev1.PersistentVolumeClaim{}
+	data.Name = "hippo-instance1-abcd-pgdata"
+	data.Labels = map[string]string{naming.LabelInstanceSet: "instance1"}
+
+	t.Run("DataVolume", func(t *testing.T) {
+		job := generatePGUpgradeJob(cluster, data, nil, 14)
+
+		assert.Equal(t, job.Name, "hippo-pgupgrade")
+		assert.Equal(t, job.Labels[naming.LabelCluster], "hippo")
+		assert.Equal(t, *job.Spec.BackoffLimit, int32(0))
+
+		spec := job.Spec.Template.Spec
+		assert.Equal(t, spec.RestartPolicy, corev1.RestartPolicyNever)
+		assert.DeepEqual(t, spec.ImagePullSecrets, cluster.Spec.ImagePullSecrets)
+		assert.Equal(t, len(spec.Volumes), 1)
+		assert.Equal(t, spec.Volumes[0].PersistentVolumeClaim.ClaimName, data.Name)
+
+		assert.Equal(t, len(spec.Containers), 1)
+		container := spec.Containers[0]
+		assert.Equal(t, container.Image, "example.com/upgrade:latest")
+		assert.DeepEqual(t, container.Command[:3], []string{"bash", "-ceu", "--"})
+		assert.DeepEqual(t, container.Command[4:],
+			[]string{"upgrade", "13", "14", "/pgdata/pg14_wal"})
+		assert.Equal(t, len(container.VolumeMounts), 1)
+	})
+
+	t.Run("WALVolume", func(t *testing.T) {
+		wal := &corev1.PersistentVolumeClaim{}
+		wal.Name = "hippo-instance1-abcd-pgwal"
+
+		job := generatePGUpgradeJob(cluster, data, wal, 14)
+
+		spec := job.Spec.Template.Spec
+		assert.Equal(t, len(spec.Volumes), 2)
+		assert.Equal(t, spec.Volumes[1].PersistentVolumeClaim.ClaimName, wal.Name)
+		assert.Equal(t, len(spec.Containers[0].VolumeMounts), 2)
+		assert.Equal(t, spec.Containers[0].Command[7], "/pgwal/pg14_wal")
+	})
+
+	t.Run("Resources", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Upgrade = &v1beta1.PostgresUpgradeSpec{
+			Enabled: true,
+			Image:   "example.com/spec:tag",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			},
+		}
+
+		job := generatePGUpgradeJob(cluster, data, nil, 14)
+		container := job.Spec.Template.Spec.Containers[0]
+		assert.Equal(t, container.Image, "example.com/spec:tag")
+		assert.DeepEqual(t, container.Resources, cluster.Spec.Upgrade.Resources)
+	})
+}
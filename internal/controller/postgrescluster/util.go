@@ -24,10 +24,13 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 var tmpDirSizeLimit = resource.MustParse("16Mi")
@@ -263,6 +266,203 @@ func addNSSWrapper(image string, imagePullPolicy corev1.PullPolicy, template *co
 	template.Spec.InitContainers = append(template.Spec.InitContainers, container)
 }
 
+// validateSecurityContexts returns warnings about the settings in pod and
+// container that would run as root or loosen the security of the containers
+// the operator manages. Those settings are ignored by mergePodSecurityContext
+// and mergeContainerSecurityContext.
+func validateSecurityContexts(
+	pod *corev1.PodSecurityContext, container *corev1.SecurityContext, path *field.Path,
+) field.ErrorList {
+	var warnings field.ErrorList
+	root := func(path *field.Path) {
+		warnings = append(warnings, field.Forbidden(path,
+			"the operator does not run containers as root"))
+	}
+	loosen := func(path *field.Path) {
+		warnings = append(warnings, field.Forbidden(path,
+			"the operator does not loosen the security of its containers"))
+	}
+
+	if pod != nil {
+		path := path.Child("securityContext")
+		if pod.RunAsUser != nil && *pod.RunAsUser == 0 {
+			root(path.Child("runAsUser"))
+		}
+		if pod.RunAsGroup != nil && *pod.RunAsGroup == 0 {
+			root(path.Child("runAsGroup"))
+		}
+		if pod.RunAsNonRoot != nil && !*pod.RunAsNonRoot {
+			root(path.Child("runAsNonRoot"))
+		}
+		if pod.FSGroup != nil && *pod.FSGroup == 0 {
+			root(path.Child("fsGroup"))
+		}
+		for i, gid := range pod.SupplementalGroups {
+			if gid == 0 {
+				root(path.Child("supplementalGroups").Index(i))
+			}
+		}
+	}
+
+	if container != nil {
+		path := path.Child("containerSecurityContext")
+		if container.RunAsUser != nil && *container.RunAsUser == 0 {
+			root(path.Child("runAsUser"))
+		}
+		if container.RunAsGroup != nil && *container.RunAsGroup == 0 {
+			root(path.Child("runAsGroup"))
+		}
+		if container.RunAsNonRoot != nil && !*container.RunAsNonRoot {
+			root(path.Child("runAsNonRoot"))
+		}
+		if container.AllowPrivilegeEscalation != nil && *container.AllowPrivilegeEscalation {
+			loosen(path.Child("allowPrivilegeEscalation"))
+		}
+		if container.Capabilities != nil && len(container.Capabilities.Add) > 0 {
+			loosen(path.Child("capabilities", "add"))
+		}
+		if container.Privileged != nil && *container.Privileged {
+			loosen(path.Child("privileged"))
+		}
+		if container.ProcMount != nil && *container.ProcMount != corev1.DefaultProcMount {
+			loosen(path.Child("procMount"))
+		}
+		if container.ReadOnlyRootFilesystem != nil && !*container.ReadOnlyRootFilesystem {
+			loosen(path.Child("readOnlyRootFilesystem"))
+		}
+	}
+
+	return warnings
+}
+
+// mergePodSecurityContext copies the settings of in onto out, except those
+// that would run as root. Supplemental groups are added to those in out.
+// See validateSecurityContexts.
+func mergePodSecurityContext(out, in *corev1.PodSecurityContext) {
+	if in == nil {
+		return
+	}
+	if in.RunAsUser != nil && *in.RunAsUser != 0 {
+		out.RunAsUser = initialize.Int64(*in.RunAsUser)
+	}
+	if in.RunAsGroup != nil && *in.RunAsGroup != 0 {
+		out.RunAsGroup = initialize.Int64(*in.RunAsGroup)
+	}
+	if in.RunAsNonRoot != nil && *in.RunAsNonRoot {
+		out.RunAsNonRoot = initialize.Bool(true)
+	}
+	if in.FSGroup != nil && *in.FSGroup != 0 {
+		out.FSGroup = initialize.Int64(*in.FSGroup)
+	}
+	if in.FSGroupChangePolicy != nil {
+		policy := *in.FSGroupChangePolicy
+		out.FSGroupChangePolicy = &policy
+	}
+	for _, gid := range in.SupplementalGroups {
+		if gid != 0 {
+			out.SupplementalGroups = append(out.SupplementalGroups, gid)
+		}
+	}
+	if in.SELinuxOptions != nil {
+		out.SELinuxOptions = in.SELinuxOptions.DeepCopy()
+	}
+	if in.SeccompProfile != nil {
+		out.SeccompProfile = in.SeccompProfile.DeepCopy()
+	}
+	if in.Sysctls != nil {
+		out.Sysctls = append([]corev1.Sysctl{}, in.Sysctls...)
+	}
+}
+
+// mergeContainerSecurityContext copies the settings of in onto the containers
+// and init containers of template, except those named in skip. Only settings
+// that do not run as root or loosen a restricted security context are copied.
+// See validateSecurityContexts.
+func mergeContainerSecurityContext(
+	template *corev1.PodTemplateSpec, in *corev1.SecurityContext, skip sets.String,
+) {
+	if in == nil {
+		return
+	}
+
+	merge := func(container *corev1.Container) {
+		if skip.Has(container.Name) {
+			return
+		}
+		if container.SecurityContext == nil {
+			container.SecurityContext = initialize.RestrictedSecurityContext()
+		}
+		out := container.SecurityContext
+
+		if in.RunAsUser != nil && *in.RunAsUser != 0 {
+			out.RunAsUser = initialize.Int64(*in.RunAsUser)
+		}
+		if in.RunAsGroup != nil && *in.RunAsGroup != 0 {
+			out.RunAsGroup = initialize.Int64(*in.RunAsGroup)
+		}
+		if in.SELinuxOptions != nil {
+			out.SELinuxOptions = in.SELinuxOptions.DeepCopy()
+		}
+		if in.SeccompProfile != nil {
+			out.SeccompProfile = in.SeccompProfile.DeepCopy()
+		}
+	}
+
+	for i := range template.Spec.InitContainers {
+		merge(&template.Spec.InitContainers[i])
+	}
+	for i := range template.Spec.Containers {
+		merge(&template.Spec.Containers[i])
+	}
+}
+
+// addDNSConfig copies the DNS settings and host aliases of cluster to pod.
+// When they are unset, pod uses the Kubernetes defaults.
+func addDNSConfig(cluster *v1beta1.PostgresCluster, pod *corev1.PodSpec) {
+	pod.DNSConfig = cluster.Spec.DNSConfig
+	pod.DNSPolicy = cluster.Spec.DNSPolicy
+	pod.HostAliases = cluster.Spec.HostAliases
+}
+
+// postgresServiceSpec returns the Service settings of cluster that apply to
+// every PostgreSQL Service, or nil when there are none.
+func postgresServiceSpec(cluster *v1beta1.PostgresCluster) *v1beta1.ServiceSpec {
+	if cluster.Spec.Service == nil {
+		return nil
+	}
+	return &cluster.Spec.Service.ServiceSpec
+}
+
+// addServiceIPFamilies copies the IP family settings of spec to service. When
+// they are unset, Kubernetes chooses according to the cluster configuration.
+// - https://docs.k8s.io/concepts/services-networking/dual-stack/#services
+func addServiceIPFamilies(spec *v1beta1.ServiceSpec, service *corev1.Service) {
+	if spec != nil {
+		service.Spec.IPFamilyPolicy = spec.IPFamilyPolicy
+		service.Spec.IPFamilies = spec.IPFamilies
+	}
+}
+
+// addServiceSessionAffinity copies the session affinity settings of spec to
+// service. When they are unset, connections from a client are not kept
+// together. The timeout applies only to ClientIP affinity.
+// - https://docs.k8s.io/concepts/services-networking/service/#session-affinity
+func addServiceSessionAffinity(spec *v1beta1.ServiceSpec, service *corev1.Service) {
+	if spec == nil || spec.SessionAffinity == "" {
+		return
+	}
+
+	service.Spec.SessionAffinity = corev1.ServiceAffinity(spec.SessionAffinity)
+	if service.Spec.SessionAffinity == corev1.ServiceAffinityClientIP &&
+		spec.SessionAffinityTimeoutSeconds != nil {
+		service.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+			ClientIP: &corev1.ClientIPConfig{
+				TimeoutSeconds: initialize.Int32(*spec.SessionAffinityTimeoutSeconds),
+			},
+		}
+	}
+}
+
 // jobFailed returns "true" if the Job provided has failed.  Otherwise it returns "false".
 func jobFailed(job *batchv1.Job) bool {
 	conditions := job.Status.Conditions
@@ -26,10 +26,14 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestSafeHash32(t *testing.T) {
@@ -422,6 +426,272 @@ func TestAddNSSWrapper(t *testing.T) {
 	}
 }
 
+func TestValidateSecurityContexts(t *testing.T) {
+	path := field.NewPath("spec", "instances").Index(0)
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Assert(t, validateSecurityContexts(nil, nil, path) == nil)
+		assert.Assert(t, validateSecurityContexts(
+			new(corev1.PodSecurityContext), new(corev1.SecurityContext), path) == nil)
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		pod := &corev1.PodSecurityContext{
+			RunAsUser:          initialize.Int64(1000),
+			RunAsNonRoot:       initialize.Bool(true),
+			FSGroup:            initialize.Int64(2000),
+			SupplementalGroups: []int64{3000},
+		}
+		container := &corev1.SecurityContext{
+			RunAsUser:              initialize.Int64(1000),
+			ReadOnlyRootFilesystem: initialize.Bool(true),
+			SeccompProfile: &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			},
+		}
+		assert.Assert(t, validateSecurityContexts(pod, container, path) == nil)
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		pod := &corev1.PodSecurityContext{
+			RunAsUser:          initialize.Int64(0),
+			RunAsNonRoot:       initialize.Bool(false),
+			FSGroup:            initialize.Int64(0),
+			SupplementalGroups: []int64{5, 0},
+		}
+		container := &corev1.SecurityContext{
+			RunAsUser:                initialize.Int64(0),
+			AllowPrivilegeEscalation: initialize.Bool(true),
+			Capabilities:             &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+			Privileged:               initialize.Bool(true),
+			ReadOnlyRootFilesystem:   initialize.Bool(false),
+		}
+
+		warnings := validateSecurityContexts(pod, container, path)
+		fields := make([]string, len(warnings))
+		for i := range warnings {
+			assert.Equal(t, warnings[i].Type, field.ErrorTypeForbidden)
+			fields[i] = warnings[i].Field
+		}
+		assert.DeepEqual(t, fields, []string{
+			"spec.instances[0].securityContext.runAsUser",
+			"spec.instances[0].securityContext.runAsNonRoot",
+			"spec.instances[0].securityContext.fsGroup",
+			"spec.instances[0].securityContext.supplementalGroups[1]",
+			"spec.instances[0].containerSecurityContext.runAsUser",
+			"spec.instances[0].containerSecurityContext.allowPrivilegeEscalation",
+			"spec.instances[0].containerSecurityContext.capabilities.add",
+			"spec.instances[0].containerSecurityContext.privileged",
+			"spec.instances[0].containerSecurityContext.readOnlyRootFilesystem",
+		})
+	})
+}
+
+func TestMergePodSecurityContext(t *testing.T) {
+	out := initialize.PodSecurityContext()
+	out.FSGroup = initialize.Int64(26)
+	out.SupplementalGroups = []int64{999}
+
+	mergePodSecurityContext(out, nil)
+	assert.Equal(t, *out.FSGroup, int64(26))
+
+	mergePodSecurityContext(out, &corev1.PodSecurityContext{
+		RunAsUser:          initialize.Int64(1000),
+		FSGroup:            initialize.Int64(2000),
+		SupplementalGroups: []int64{0, 3000},
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	})
+	assert.Assert(t, cmp.MarshalMatches(out, `
+fsGroup: 2000
+fsGroupChangePolicy: OnRootMismatch
+runAsUser: 1000
+seccompProfile:
+  type: RuntimeDefault
+supplementalGroups:
+- 999
+- 3000
+	`))
+
+	// Settings that run as root are ignored.
+	mergePodSecurityContext(out, &corev1.PodSecurityContext{
+		RunAsUser:    initialize.Int64(0),
+		RunAsNonRoot: initialize.Bool(false),
+		FSGroup:      initialize.Int64(0),
+	})
+	assert.Equal(t, *out.RunAsUser, int64(1000))
+	assert.Equal(t, *out.FSGroup, int64(2000))
+	assert.Assert(t, out.RunAsNonRoot == nil)
+}
+
+func TestMergeContainerSecurityContext(t *testing.T) {
+	template := new(corev1.PodTemplateSpec)
+	template.Spec.InitContainers = []corev1.Container{
+		{Name: "startup", SecurityContext: initialize.RestrictedSecurityContext()},
+		{Name: "custom-init"},
+	}
+	template.Spec.Containers = []corev1.Container{
+		{Name: "database", SecurityContext: initialize.RestrictedSecurityContext()},
+		{Name: "custom"},
+	}
+	skip := sets.NewString("custom-init", "custom")
+
+	mergeContainerSecurityContext(template, nil, skip)
+	assert.DeepEqual(t, template.Spec.Containers[0].SecurityContext,
+		initialize.RestrictedSecurityContext())
+
+	mergeContainerSecurityContext(template, &corev1.SecurityContext{
+		RunAsUser:                initialize.Int64(1000),
+		AllowPrivilegeEscalation: initialize.Bool(true),
+		Capabilities:             &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+		Privileged:               initialize.Bool(true),
+		ReadOnlyRootFilesystem:   initialize.Bool(false),
+		RunAsNonRoot:             initialize.Bool(false),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}, skip)
+
+	// Only settings that keep the restricted defaults are applied.
+	for _, container := range []corev1.Container{
+		template.Spec.InitContainers[0], template.Spec.Containers[0],
+	} {
+		assert.Assert(t, cmp.MarshalMatches(container.SecurityContext, `
+allowPrivilegeEscalation: false
+capabilities:
+  drop:
+  - ALL
+privileged: false
+readOnlyRootFilesystem: true
+runAsNonRoot: true
+runAsUser: 1000
+seccompProfile:
+  type: RuntimeDefault
+		`), "container %q", container.Name)
+	}
+
+	// Custom containers are left alone.
+	assert.Assert(t, template.Spec.InitContainers[1].SecurityContext == nil)
+	assert.Assert(t, template.Spec.Containers[1].SecurityContext == nil)
+}
+
+func TestAddDNSConfig(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		pod := corev1.PodSpec{}
+		addDNSConfig(&v1beta1.PostgresCluster{}, &pod)
+
+		assert.Assert(t, cmp.MarshalMatches(pod, `containers: null`))
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.DNSPolicy = corev1.DNSNone
+		cluster.Spec.DNSConfig = &corev1.PodDNSConfig{
+			Nameservers: []string{"10.0.0.10"},
+			Searches:    []string{"corp.example.com"},
+			Options: []corev1.PodDNSConfigOption{{
+				Name: "ndots", Value: initialize.String("2"),
+			}},
+		}
+		cluster.Spec.HostAliases = []corev1.HostAlias{{
+			IP: "10.1.2.3", Hostnames: []string{"kms.corp.example.com"},
+		}}
+
+		pod := corev1.PodSpec{}
+		addDNSConfig(cluster, &pod)
+
+		assert.Assert(t, cmp.MarshalMatches(pod, `
+containers: null
+dnsConfig:
+  nameservers:
+  - 10.0.0.10
+  options:
+  - name: ndots
+    value: "2"
+  searches:
+  - corp.example.com
+dnsPolicy: None
+hostAliases:
+- hostnames:
+  - kms.corp.example.com
+  ip: 10.1.2.3
+		`))
+	})
+}
+
+func TestAddServiceIPFamilies(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		service := corev1.Service{}
+		addServiceIPFamilies(nil, &service)
+		addServiceIPFamilies(&v1beta1.ServiceSpec{}, &service)
+
+		assert.Assert(t, cmp.MarshalMatches(service.Spec, `{}`))
+	})
+
+	t.Run("DualStack", func(t *testing.T) {
+		policy := corev1.IPFamilyPolicyRequireDualStack
+		spec := &v1beta1.ServiceSpec{
+			IPFamilyPolicy: &policy,
+			IPFamilies:     []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol},
+		}
+
+		service := corev1.Service{}
+		addServiceIPFamilies(spec, &service)
+
+		assert.Assert(t, cmp.MarshalMatches(service.Spec, `
+ipFamilies:
+- IPv6
+- IPv4
+ipFamilyPolicy: RequireDualStack
+		`))
+	})
+}
+
+func TestAddServiceSessionAffinity(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		service := corev1.Service{}
+		addServiceSessionAffinity(nil, &service)
+		addServiceSessionAffinity(&v1beta1.ServiceSpec{
+			SessionAffinityTimeoutSeconds: initialize.Int32(60),
+		}, &service)
+
+		assert.Assert(t, cmp.MarshalMatches(service.Spec, `{}`))
+	})
+
+	t.Run("None", func(t *testing.T) {
+		service := corev1.Service{}
+		addServiceSessionAffinity(&v1beta1.ServiceSpec{
+			SessionAffinity:               "None",
+			SessionAffinityTimeoutSeconds: initialize.Int32(60),
+		}, &service)
+
+		// The timeout applies only to ClientIP.
+		assert.Assert(t, cmp.MarshalMatches(service.Spec, `sessionAffinity: None`))
+	})
+
+	t.Run("ClientIP", func(t *testing.T) {
+		service := corev1.Service{}
+		addServiceSessionAffinity(&v1beta1.ServiceSpec{
+			SessionAffinity: "ClientIP",
+		}, &service)
+
+		assert.Assert(t, cmp.MarshalMatches(service.Spec, `sessionAffinity: ClientIP`))
+
+		addServiceSessionAffinity(&v1beta1.ServiceSpec{
+			SessionAffinity:               "ClientIP",
+			SessionAffinityTimeoutSeconds: initialize.Int32(600),
+		}, &service)
+
+		assert.Assert(t, cmp.MarshalMatches(service.Spec, `
+sessionAffinity: ClientIP
+sessionAffinityConfig:
+  clientIP:
+    timeoutSeconds: 600
+		`))
+	})
+}
+
 func TestJobCompleted(t *testing.T) {
 
 	testCases := []struct {
@@ -505,6 +505,7 @@ func (r *Reconciler) reconcileMovePGDataDir(ctx context.Context,
 		jobSpec.Template.Spec.PriorityClassName =
 			*cluster.Spec.InstanceSets[0].PriorityClassName
 	}
+	addDNSConfig(cluster, &jobSpec.Template.Spec)
 	moveDirJob.Spec = *jobSpec
 
 	// set gvk and ownership refs
@@ -623,6 +624,7 @@ func (r *Reconciler) reconcileMoveWALDir(ctx context.Context,
 		jobSpec.Template.Spec.PriorityClassName =
 			*cluster.Spec.InstanceSets[0].PriorityClassName
 	}
+	addDNSConfig(cluster, &jobSpec.Template.Spec)
 	moveDirJob.Spec = *jobSpec
 
 	// set gvk and ownership refs
@@ -745,6 +747,7 @@ func (r *Reconciler) reconcileMoveRepoDir(ctx context.Context,
 			jobSpec.Template.Spec.PriorityClassName = *repoHost.PriorityClassName
 		}
 	}
+	addDNSConfig(cluster, &jobSpec.Template.Spec)
 	moveDirJob.Spec = *jobSpec
 
 	// set gvk and ownership refs
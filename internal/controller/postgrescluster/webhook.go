@@ -0,0 +1,221 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/pgbouncer"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/util"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// Validator is an admission webhook that rejects PostgresClusters with
+// problems the Reconciler would otherwise report in warning events. It is
+// served at "/validate-postgres-operator-crunchydata-com-v1beta1-postgrescluster".
+type Validator struct {
+	// Client reads the Secrets referenced by a PostgresCluster. When nil,
+	// those Secrets are not checked.
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = (*Validator)(nil)
+
+// +kubebuilder:webhook:path=/validate-postgres-operator-crunchydata-com-v1beta1-postgrescluster,mutating=false,failurePolicy=ignore,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=create;update,versions=v1beta1,name=vpostgrescluster.postgres-operator.crunchydata.com,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/mutate-postgres-operator-crunchydata-com-v1beta1-postgrescluster,mutating=true,failurePolicy=ignore,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=create;update,versions=v1beta1,name=mpostgrescluster.postgres-operator.crunchydata.com,admissionReviewVersions=v1
+
+// SetupWebhookWithManager adds v to the webhook server of mgr. It also adds a
+// mutating webhook at "/mutate-postgres-operator-crunchydata-com-v1beta1-postgrescluster"
+// that calls PostgresCluster.Default so that stored PostgresClusters show the
+// same defaults the Reconciler applies.
+func (v *Validator) SetupWebhookWithManager(mgr manager.Manager) error {
+	return builder.WebhookManagedBy(mgr).
+		For(&v1beta1.PostgresCluster{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *Validator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return errors.Errorf("expected a PostgresCluster, got %T", obj)
+	}
+
+	errs, err := v.validate(ctx, cluster)
+	if err == nil && len(errs) > 0 {
+		err = invalid(cluster, errs)
+	}
+	return err
+}
+
+// ValidateUpdate implements admission.CustomValidator. It allows changes that
+// do not add problems so that PostgresClusters stored before a validation
+// existed can still be patched and deleted.
+func (v *Validator) ValidateUpdate(ctx context.Context, oldObj, obj runtime.Object) error {
+	cluster, ok := obj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return errors.Errorf("expected a PostgresCluster, got %T", obj)
+	}
+	previous, ok := oldObj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return errors.Errorf("expected a PostgresCluster, got %T", oldObj)
+	}
+
+	// The names of existing objects are derived from the recorded prefix.
+	if value, ok := previous.GetAnnotations()[naming.ResourceNamePrefix]; ok &&
+		cluster.DeletionTimestamp == nil {
+		if next, ok := cluster.GetAnnotations()[naming.ResourceNamePrefix]; !ok || next != value {
+			return invalid(cluster, field.ErrorList{field.Forbidden(
+				field.NewPath("metadata", "annotations").Key(naming.ResourceNamePrefix),
+				"cannot be changed or removed")})
+		}
+	}
+
+	// Allow changes to metadata alone, such as the finalizers and annotations
+	// the Reconciler removes, and any change to a cluster being deleted.
+	if cluster.DeletionTimestamp != nil ||
+		equality.Semantic.DeepEqual(previous.Spec, cluster.Spec) {
+		return nil
+	}
+
+	errs, err := v.validate(ctx, cluster)
+	if err != nil || len(errs) == 0 {
+		return err
+	}
+	existing, err := v.validate(ctx, previous)
+	if err != nil {
+		return err
+	}
+
+	// Report only the problems that the old cluster did not have.
+	reported := sets.NewString()
+	for _, e := range existing {
+		reported.Insert(e.Error())
+	}
+	var added field.ErrorList
+	for _, e := range errs {
+		if !reported.Has(e.Error()) {
+			added = append(added, e)
+		}
+	}
+
+	if len(added) > 0 {
+		return invalid(cluster, added)
+	}
+	return nil
+}
+
+// ValidateDelete implements admission.CustomValidator. Deletes are always allowed.
+func (v *Validator) ValidateDelete(context.Context, runtime.Object) error { return nil }
+
+// invalid returns an Invalid API error for cluster with errs as its causes.
+func invalid(cluster *v1beta1.PostgresCluster, errs field.ErrorList) error {
+	return apierrors.NewInvalid(
+		v1beta1.GroupVersion.WithKind("PostgresCluster").GroupKind(),
+		cluster.Name, errs)
+}
+
+// validate returns the problems with cluster. It returns an error when the
+// Secrets it references cannot be read.
+func (v *Validator) validate(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (field.ErrorList, error) {
+	// Validate what the Reconciler sees.
+	cluster = cluster.DeepCopy()
+	cluster.Default()
+
+	errs := validatePostgresCluster(cluster)
+
+	// A custom Secret may be created after the cluster, so only check the
+	// contents of one that exists.
+	if projection := cluster.Spec.CustomTLSSecret; projection != nil && v.Client != nil {
+		secret := &corev1.Secret{}
+		secret.Namespace, secret.Name = cluster.Namespace, projection.Name
+		err := v.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+
+		if err == nil {
+			errs = append(errs, validateCustomTLSSecret(secret, projection,
+				field.NewPath("spec", "customTLSSecret"))...)
+		} else if !apierrors.IsNotFound(err) {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return errs, nil
+}
+
+// validatePostgresCluster returns the problems with the spec of cluster that
+// the Reconciler reports in warning events. It uses the same validation as the
+// Reconciler so that the two agree.
+func validatePostgresCluster(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	errs = append(errs, naming.ValidateClusterNames(cluster)...)
+	errs = append(errs, validateStandby(cluster)...)
+	errs = append(errs, validateDataSource(cluster)...)
+	errs = append(errs, validatePostgresVersion(cluster)...)
+	errs = append(errs, validateImages(cluster)...)
+	errs = append(errs, validatePostgresUsers(cluster)...)
+	errs = append(errs, validatePatroniSwitchover(cluster)...)
+	errs = append(errs, validateTLSProtocol(cluster)...)
+	errs = append(errs, pgbackrest.ValidateRepos(cluster)...)
+
+	if cluster.Spec.Authentication != nil {
+		path := field.NewPath("spec", "authentication", "rules")
+		for i, rule := range cluster.Spec.Authentication.Rules {
+			errs = append(errs, postgres.ValidateHBARule(rule, path.Index(i))...)
+		}
+
+		path = field.NewPath("spec", "authentication", "maps")
+		for i, spec := range cluster.Spec.Authentication.Maps {
+			errs = append(errs, postgres.ValidateIdentMap(spec, path.Index(i))...)
+		}
+	}
+
+	path := field.NewPath("spec", "instances")
+	for i := range cluster.Spec.InstanceSets {
+		errs = append(errs,
+			validateInstanceSetEnvironment(&cluster.Spec.InstanceSets[i], path.Index(i))...)
+
+		// Custom containers are ignored unless the feature gate is enabled.
+		if util.DefaultMutableFeatureGate.Enabled(util.InstanceSidecars) {
+			errs = append(errs,
+				validateInstanceSetContainers(&cluster.Spec.InstanceSets[i], path.Index(i))...)
+		}
+	}
+
+	// Warnings are about settings the operator overrides, so they are allowed.
+	_, invalid := pgbouncer.ValidateConfig(cluster)
+	errs = append(errs, invalid...)
+
+	return errs
+}
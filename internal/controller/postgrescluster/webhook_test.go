@@ -0,0 +1,472 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"gotest.tools/v3/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/util"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestValidator(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+	assert.NilError(t, util.AddAndSetFeatureGates(""))
+
+	secret := &corev1.Secret{Data: map[string][]byte{
+		"tls.crt": []byte("cert"), "tls.key": []byte("key"),
+	}}
+	secret.Namespace, secret.Name = "ns1", "custom-tls"
+
+	v := &Validator{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+	}
+
+	newCluster := func() *v1beta1.PostgresCluster {
+		cluster := testCluster()
+		cluster.Namespace = "ns1"
+		return cluster
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		cluster := newCluster()
+
+		assert.NilError(t, v.ValidateCreate(ctx, cluster))
+		assert.NilError(t, v.ValidateUpdate(ctx, cluster, cluster))
+		assert.NilError(t, v.ValidateDelete(ctx, cluster))
+	})
+
+	t.Run("WrongKind", func(t *testing.T) {
+		assert.ErrorContains(t, v.ValidateCreate(ctx, &corev1.ConfigMap{}), "expected a PostgresCluster")
+	})
+
+	t.Run("UpdateExistingProblems", func(t *testing.T) {
+		t.Setenv("RELATED_IMAGE_PGBOUNCER", "")
+
+		// This cluster was stored before its problem was validated.
+		before := newCluster()
+		before.Spec.Proxy.PGBouncer.Image = ""
+		assert.Assert(t, v.ValidateCreate(ctx, before) != nil)
+
+		// Metadata can change, e.g. when the Reconciler removes a finalizer.
+		after := before.DeepCopy()
+		after.Annotations = map[string]string{"some": "thing"}
+		assert.NilError(t, v.ValidateUpdate(ctx, before, after))
+
+		// Anything can change while the cluster is being deleted.
+		after = before.DeepCopy()
+		after.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		after.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+		assert.NilError(t, v.ValidateUpdate(ctx, before, after))
+
+		// The spec can change without adding problems.
+		after = before.DeepCopy()
+		after.Spec.InstanceSets[0].Replicas = initialize.Int32(3)
+		assert.NilError(t, v.ValidateUpdate(ctx, before, after))
+
+		// Only new problems are reported.
+		after.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+		err := v.ValidateUpdate(ctx, before, after)
+		assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+		assert.ErrorContains(t, err, `spec.standby`)
+		assert.Assert(t, !strings.Contains(err.Error(), "pgBouncer"), "got %v", err)
+	})
+
+	t.Run("ResourceNamePrefix", func(t *testing.T) {
+		before := newCluster()
+		before.Annotations = map[string]string{naming.ResourceNamePrefix: "team-a-"}
+
+		after := before.DeepCopy()
+		after.Annotations[naming.ResourceNamePrefix] = "team-b-"
+		err := v.ValidateUpdate(ctx, before, after)
+		assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+		assert.ErrorContains(t, err, "resource-name-prefix")
+		assert.ErrorContains(t, err, "cannot be changed")
+
+		after = before.DeepCopy()
+		after.Annotations = nil
+		assert.Assert(t, apierrors.IsInvalid(v.ValidateUpdate(ctx, before, after)))
+
+		// The Reconciler records the prefix on clusters that have none.
+		assert.NilError(t, v.ValidateUpdate(ctx, newCluster(), before))
+
+		// An invalid prefix is rejected on create.
+		cluster := newCluster()
+		cluster.Annotations = map[string]string{naming.ResourceNamePrefix: "Team-A-"}
+		assert.ErrorContains(t, v.ValidateCreate(ctx, cluster), "invalid resource name prefix")
+	})
+
+	t.Run("DeleteInvalid", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Name = "invalid.user"
+
+		assert.NilError(t, v.ValidateDelete(ctx, cluster))
+	})
+
+	for _, tt := range []struct {
+		name     string
+		mutate   func(*v1beta1.PostgresCluster)
+		expected []string
+	}{
+		{
+			name: "InvalidDefaultUserName",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Name = "invalid.user"
+			},
+			expected: []string{`spec.users[0].name`, `should match`},
+		},
+		{
+			name: "ReservedUserName",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Name = "postgres"
+			},
+			expected: []string{`spec.users[0].name`, `the "postgres" user is reserved`},
+		},
+		{
+			name: "ReservedUserNameInSpec",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "app"}, {Name: "postgres"}}
+			},
+			expected: []string{`spec.users[1].name`, `the "postgres" user is reserved`},
+		},
+		{
+			name: "MalformedUserNameMap",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+					Maps: []v1beta1.PostgresUserNameMap{
+						{Name: "certs", SystemUser: "new\nline", DatabaseUser: "alice"},
+					},
+				}
+			},
+			expected: []string{`spec.authentication.maps[0].systemUser`},
+		},
+		{
+			name: "StandbyWithoutSource",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+			},
+			expected: []string{`spec.standby`, `requires a host or repoName`},
+		},
+		{
+			name: "PostgresVersionDecreased",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Status.PostgresVersion = cluster.Spec.PostgresVersion
+				cluster.Spec.PostgresVersion--
+			},
+			expected: []string{`spec.postgresVersion`, `cannot be lower than the current version`},
+		},
+		{
+			name: "StandbyMajorUpgrade",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Status.PostgresVersion = cluster.Spec.PostgresVersion - 1
+				cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true, RepoName: "repo1"}
+			},
+			expected: []string{`spec.postgresVersion`, `standby cluster cannot perform a major version upgrade`},
+		},
+		{
+			name: "TwoDataSources",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.DataSource = &v1beta1.DataSource{
+					PGBackRest:      &v1beta1.PGBackRestDataSource{},
+					PostgresCluster: &v1beta1.PostgresClusterDataSource{},
+				}
+			},
+			expected: []string{`spec.dataSource`, `only one of pgbackrest or postgresCluster`},
+		},
+		{
+			name: "RepoWithoutBackend",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Backups.PGBackRest.Repos[0].Volume = nil
+			},
+			expected: []string{`spec.backups.pgbackrest.repos[0]`, `one of azure, gcs, s3, or volume is required`},
+		},
+		{
+			name: "RepoWithTwoBackends",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Backups.PGBackRest.Repos[0].S3 = &v1beta1.RepoS3{
+					Bucket: "b", Endpoint: "e", Region: "r",
+				}
+			},
+			expected: []string{`spec.backups.pgbackrest.repos[0]`, `only one of azure, gcs, s3, or volume`},
+		},
+		{
+			name: "HBARule",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+					Rules: []v1beta1.PostgresHBARule{{Connection: "host"}},
+				}
+			},
+			expected: []string{`spec.authentication.rules[0].method`},
+		},
+		{
+			name: "FailoverWithoutTarget",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+					Switchover: &v1beta1.PatroniSwitchover{
+						Enabled: true, Type: v1beta1.PatroniSwitchoverTypeFailover,
+					},
+				}
+			},
+			expected: []string{`spec.patroni.switchover.targetInstance`},
+		},
+		{
+			name: "TLSMinProtocol",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.PostgresVersion = 11
+				cluster.Spec.TLS = &v1beta1.TLSSpec{MinProtocol: "TLSv1.2"}
+			},
+			expected: []string{`spec.tls.minProtocol`, `requires PostgreSQL 12 or newer`},
+		},
+		{
+			name: "PgBouncerPoolMode",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{"pool_mode": "always"}
+			},
+			expected: []string{`spec.proxy.pgBouncer.config.global[pool_mode]`, `"always"`},
+		},
+		{
+			name: "PgBouncerReservedDatabase",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Proxy.PGBouncer.Config.Databases = map[string]string{"pgbouncer": "host=x"}
+			},
+			expected: []string{`spec.proxy.pgBouncer.config.databases[pgbouncer]`},
+		},
+		{
+			name: "CustomTLSSecretMissingKey",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.CustomTLSSecret = &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "custom-tls"},
+				}
+			},
+			expected: []string{`spec.customTLSSecret.name`, `must contain "ca.crt"`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newCluster()
+			tt.mutate(cluster)
+
+			err := v.ValidateCreate(ctx, cluster)
+			assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+			for _, expected := range tt.expected {
+				assert.ErrorContains(t, err, expected)
+			}
+
+			// Updates that add these problems to the spec are validated the same way.
+			if before := newCluster(); !equality.Semantic.DeepEqual(before.Spec, cluster.Spec) {
+				assert.Error(t, v.ValidateUpdate(ctx, before, cluster), err.Error())
+			}
+		})
+	}
+
+	t.Run("CustomTLSSecretNotFound", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Spec.CustomTLSSecret = &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "later"},
+		}
+
+		// The Secret may be created after the cluster.
+		assert.NilError(t, v.ValidateCreate(ctx, cluster))
+	})
+
+	t.Run("ReservedContainerNames", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Spec.InstanceSets[0].Containers = []corev1.Container{{Name: "database"}}
+		cluster.Spec.InstanceSets[0].InitContainers = []corev1.Container{{Name: "ok"}}
+
+		// Custom containers are ignored without the feature gate.
+		assert.NilError(t, v.ValidateCreate(ctx, cluster))
+
+		assert.NilError(t, util.AddAndSetFeatureGates(string(util.InstanceSidecars+"=true")))
+		t.Cleanup(func() {
+			assert.NilError(t, util.AddAndSetFeatureGates(string(util.InstanceSidecars+"=false")))
+		})
+
+		err := v.ValidateCreate(ctx, cluster)
+		assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+		assert.ErrorContains(t, err, `spec.instances[0].containers[0].name`)
+		assert.Assert(t, !strings.Contains(err.Error(), "initContainers"), "got %v", err)
+	})
+
+	t.Run("LongName", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Name = strings.Repeat("x", 60)
+
+		err := v.ValidateCreate(ctx, cluster)
+		assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+		assert.ErrorContains(t, err, `metadata.name`)
+		assert.ErrorContains(t, err, `spec.instances[0].name`)
+	})
+
+	t.Run("ReservedEnvironment", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Spec.InstanceSets[0].Env = []corev1.EnvVar{
+			{Name: "TZ", Value: "UTC"},
+			{Name: "PGDATA", Value: "/tmp"},
+			{Name: "PATRONI_NAME", Value: "other"},
+		}
+		cluster.Spec.InstanceSets[0].EnvFrom = []corev1.EnvFromSource{{
+			Prefix: "PATRONI_",
+			ConfigMapRef: &corev1.ConfigMapEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "bundle"},
+			},
+		}}
+
+		err := v.ValidateCreate(ctx, cluster)
+		assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+		assert.ErrorContains(t, err, `spec.instances[0].env[1].name`)
+		assert.ErrorContains(t, err, `spec.instances[0].env[2].name`)
+		assert.ErrorContains(t, err, `spec.instances[0].envFrom[0].prefix`)
+		assert.Assert(t, !strings.Contains(err.Error(), "env[0]"), "got %v", err)
+	})
+
+	t.Run("MissingImages", func(t *testing.T) {
+		t.Setenv("RELATED_IMAGE_PGBOUNCER", "")
+		t.Setenv("RELATED_IMAGE_PGADMIN", "")
+
+		cluster := newCluster()
+		cluster.Spec.Proxy.PGBouncer.Image = ""
+		cluster.Spec.UserInterface = &v1beta1.UserInterfaceSpec{
+			PGAdmin: &v1beta1.PGAdminPodSpec{},
+		}
+
+		err := v.ValidateCreate(ctx, cluster)
+		assert.Assert(t, apierrors.IsInvalid(err), "expected Invalid, got %v", err)
+		assert.ErrorContains(t, err, `spec.proxy.pgBouncer.image`)
+		assert.ErrorContains(t, err, `spec.userInterface.pgAdmin.image`)
+		assert.ErrorContains(t, err, `RELATED_IMAGE_PGBOUNCER environment variable`)
+
+		// An image from the environment is enough.
+		t.Setenv("RELATED_IMAGE_PGBOUNCER", "example.com/pgbouncer:latest")
+		t.Setenv("RELATED_IMAGE_PGADMIN", "example.com/pgadmin:latest")
+		assert.NilError(t, v.ValidateCreate(ctx, cluster))
+	})
+
+	t.Run("ManyProblems", func(t *testing.T) {
+		cluster := newCluster()
+		cluster.Name = "invalid.user"
+		cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+		cluster.Spec.InstanceSets[0].Replicas = initialize.Int32(2)
+
+		status := v.ValidateCreate(ctx, cluster).(*apierrors.StatusError).Status()
+		assert.Equal(t, len(status.Details.Causes), 2)
+	})
+}
+
+func TestDefaultingWebhook(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	// This is the mutating webhook that SetupWebhookWithManager registers.
+	webhook := admission.DefaultingWebhookFor(&v1beta1.PostgresCluster{})
+	assert.NilError(t, webhook.InjectScheme(scheme))
+
+	admit := func(t *testing.T, cluster *v1beta1.PostgresCluster) *v1beta1.PostgresCluster {
+		raw, err := json.Marshal(cluster)
+		assert.NilError(t, err)
+
+		response := webhook.Handle(ctx, admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    k8sruntime.RawExtension{Raw: raw},
+			},
+		})
+		assert.Assert(t, response.Allowed, "got %#v", response.Result)
+
+		ops, err := json.Marshal(response.Patches)
+		assert.NilError(t, err)
+		patch, err := jsonpatch.DecodePatch(ops)
+		assert.NilError(t, err)
+		patched, err := patch.Apply(raw)
+		assert.NilError(t, err)
+
+		result := new(v1beta1.PostgresCluster)
+		assert.NilError(t, json.Unmarshal(patched, result))
+		return result
+	}
+
+	t.Run("Minimal", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.APIVersion, cluster.Kind = v1beta1.GroupVersion.String(), "PostgresCluster"
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{}}
+		cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{PGBouncer: &v1beta1.PGBouncerPodSpec{}}
+
+		result := admit(t, cluster)
+
+		// The result matches what the Reconciler sees.
+		expected := cluster.DeepCopy()
+		expected.Default()
+		assert.DeepEqual(t, result.Spec, expected.Spec)
+
+		assert.Equal(t, *result.Spec.Port, int32(5432))
+		assert.Equal(t, result.Spec.InstanceSets[0].Name, "00")
+		assert.Equal(t, *result.Spec.InstanceSets[0].Replicas, int32(1))
+		assert.Equal(t, *result.Spec.Patroni.Port, int32(8008))
+		assert.Equal(t, *result.Spec.Proxy.PGBouncer.Port, int32(5432))
+		assert.Equal(t, *result.Spec.Proxy.PGBouncer.Replicas, int32(1))
+		assert.Equal(t, result.Spec.Proxy.PGBouncer.PoolMode, "session")
+	})
+
+	t.Run("AlreadySet", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.APIVersion, cluster.Kind = v1beta1.GroupVersion.String(), "PostgresCluster"
+		cluster.Namespace, cluster.Name = "ns1", "hippo"
+		cluster.Spec.Port = initialize.Int32(6543)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{
+			Name: "one", Replicas: initialize.Int32(3),
+		}}
+		cluster.Spec.Patroni = &v1beta1.PatroniSpec{Port: initialize.Int32(9009)}
+		cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{PGBouncer: &v1beta1.PGBouncerPodSpec{
+			PoolMode: "transaction",
+			Port:     initialize.Int32(6432),
+			Replicas: initialize.Int32(2),
+		}}
+
+		result := admit(t, cluster)
+
+		assert.Equal(t, *result.Spec.Port, int32(6543))
+		assert.Equal(t, result.Spec.InstanceSets[0].Name, "one")
+		assert.Equal(t, *result.Spec.InstanceSets[0].Replicas, int32(3))
+		assert.Equal(t, *result.Spec.Patroni.Port, int32(9009))
+		assert.Equal(t, *result.Spec.Proxy.PGBouncer.Port, int32(6432))
+		assert.Equal(t, *result.Spec.Proxy.PGBouncer.Replicas, int32(2))
+		assert.Equal(t, result.Spec.Proxy.PGBouncer.PoolMode, "transaction")
+
+		// Unset fields are still defaulted.
+		assert.Equal(t, *result.Spec.Patroni.LeaderLeaseDurationSeconds, int32(30))
+	})
+}
@@ -25,6 +25,35 @@ const (
 	// Patroni Switchover (or Failover).
 	PatroniSwitchover = annotationPrefix + "trigger-switchover"
 
+	// PatroniFailover is the annotation added to a PostgresCluster to force a
+	// Patroni Failover while its primary is unhealthy. The value is the name of
+	// an instance to promote, or empty to promote any running replica. It is
+	// removed once the failover succeeds or is rejected; a failover that fails
+	// is tried again while the annotation remains.
+	PatroniFailover = annotationPrefix + "failover"
+
+	// PatroniPendingRestart is the annotation added to a Pod when PostgreSQL
+	// inside it must restart. The value is either a comma-separated list of
+	// parameters of the dynamic configuration or the value of [PatroniRestart]
+	// that requested the restart. It is removed once the Patroni member restarts.
+	PatroniPendingRestart = annotationPrefix + "pending-restart"
+
+	// PatroniRestart is the annotation added to a PostgresCluster to initiate a
+	// rolling restart of its instances. The value is any unique identifier,
+	// e.g. a timestamp. Changing it requests another restart.
+	PatroniRestart = annotationPrefix + "restart"
+
+	// ResourceNamePrefix is the annotation added to a PostgresCluster with the
+	// prefix of the names of objects generated for it. PGO records the value of
+	// PGO_RESOURCE_NAME_PREFIX when it first reconciles the cluster and keeps
+	// using it, so changing the environment does not rename existing objects.
+	ResourceNamePrefix = annotationPrefix + "resource-name-prefix"
+
+	// DryRun is the annotation added to a PostgresCluster to log what the
+	// operator would change rather than change it. The operator still sets its
+	// finalizer and cleans up when the cluster is deleted.
+	DryRun = annotationPrefix + "dry-run"
+
 	// PGBackRestBackup is the annotation that is added to a PostgresCluster to initiate a manual
 	// backup.  The value of the annotation will be a unique identifier for a backup Job (e.g. a
 	// timestamp), which will be stored in the PostgresCluster status to properly track completion
@@ -32,6 +61,20 @@ const (
 	// ID associated with a specific manual backup Job.
 	PGBackRestBackup = annotationPrefix + "pgbackrest-backup"
 
+	// PGBouncerConfigHash is an annotation on PgBouncer Pods with the hash of
+	// their configuration files when spec.proxy.pgBouncer.config.restartOnChange
+	// is true. Changing it restarts PgBouncer.
+	PGBouncerConfigHash = annotationPrefix + "pgbouncer-config-hash"
+
+	// PGBouncerStartupHash is an annotation on PgBouncer Pods with the hash of
+	// settings PgBouncer does not reload. Changing it restarts PgBouncer.
+	PGBouncerStartupHash = annotationPrefix + "pgbouncer-startup-hash"
+
+	// PostgresConfigHash is an annotation on the cluster ConfigMap and instance
+	// Pods with the hash of PostgreSQL parameters that take effect only when
+	// PostgreSQL starts. Changing it recreates the instance Pods.
+	PostgresConfigHash = annotationPrefix + "postgres-config-hash"
+
 	// PGBackRestConfigHash is an annotation used to specify the hash value associated with a
 	// repo configuration as needed to detect configuration changes that invalidate running Jobs
 	// (and therefore must be recreated)
@@ -58,4 +101,9 @@ const (
 	// for this annotation is due to an issue in pgBackRest (#1841) where using a wildcard address to
 	// bind all addresses does not work in certain IPv6 environments.
 	PGBackRestIPVersion = annotationPrefix + "pgbackrest-ip-version"
+
+	// RotatePassword is the annotation added to a PostgreSQL user Secret to generate a new
+	// password for that user. When the value is "true", the new password is stored in the Secret
+	// and the annotation is removed. The user is then altered in PostgreSQL to match.
+	RotatePassword = annotationPrefix + "rotate-password"
 )
@@ -25,6 +25,8 @@ import (
 func TestAnnotationsValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsQualifiedName(Finalizer))
 	assert.Assert(t, nil == validation.IsQualifiedName(PatroniSwitchover))
+	assert.Assert(t, nil == validation.IsQualifiedName(PatroniPendingRestart))
+	assert.Assert(t, nil == validation.IsQualifiedName(PatroniRestart))
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestBackup))
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestConfigHash))
 	assert.Assert(t, nil == validation.IsQualifiedName(PGBackRestCurrentConfig))
@@ -22,15 +22,51 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// InstancePodName returns the name of the one Pod of the instance StatefulSet
+// named instance. It is also the hostname of that Pod.
+func InstancePodName(instance string) string {
+	return instance + "-0"
+}
+
+// InstanceHostname returns the DNS name, relative to the namespace of cluster,
+// of the Pod of the instance named instance, e.g. "{instance}-0.{cluster}-pods".
+// The name stays the same when the Pod is recreated and resolves whether or
+// not the Pod is ready. See [ClusterPodService].
+func InstanceHostname(cluster *v1beta1.PostgresCluster, instance string) string {
+	return InstancePodName(instance) + "." + ClusterPodService(cluster).Name
+}
+
+// InstanceHostnames returns the possible DNS names for the Pod of the
+// instance named instance in cluster. The first name is the fully qualified
+// domain name (FQDN).
+func InstanceHostnames(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instance string,
+) []string {
+	var (
+		domain    = KubernetesClusterDomain(ctx)
+		namespace = cluster.Namespace
+		name      = InstanceHostname(cluster, instance)
+	)
+
+	return []string{
+		name + "." + namespace + ".svc." + domain,
+		name + "." + namespace + ".svc",
+		name + "." + namespace,
+		name,
+	}
+}
+
 // InstancePodDNSNames returns the possible DNS names for instance. The first
 // name is the fully qualified domain name (FQDN).
 func InstancePodDNSNames(ctx context.Context, instance *appsv1.StatefulSet) []string {
 	var (
 		domain    = KubernetesClusterDomain(ctx)
 		namespace = instance.Namespace
-		name      = instance.Name + "-0." + instance.Spec.ServiceName
+		name      = InstancePodName(instance.Name) + "." + instance.Spec.ServiceName
 	)
 
 	// We configure our instances with a subdomain so that Pods get stable DNS
@@ -24,6 +24,8 @@ import (
 	"gotest.tools/v3/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestInstancePodDNSNames(t *testing.T) {
@@ -49,6 +51,42 @@ func TestInstancePodDNSNames(t *testing.T) {
 	assert.Assert(t, strings.HasSuffix(names[0], "."), "expected root, got %q", names[0])
 }
 
+func TestInstancePodName(t *testing.T) {
+	assert.Equal(t, InstancePodName("hippo-00-abcd"), "hippo-00-abcd-0")
+}
+
+func TestInstanceHostnames(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "some-place"
+	cluster.Name = "hippo"
+
+	assert.Equal(t, InstanceHostname(cluster, "hippo-00-abcd"), "hippo-00-abcd-0.hippo-pods")
+
+	names := InstanceHostnames(ctx, cluster, "hippo-00-abcd")
+	assert.Assert(t, len(names) > 0)
+
+	assert.DeepEqual(t, names[1:], []string{
+		"hippo-00-abcd-0.hippo-pods.some-place.svc",
+		"hippo-00-abcd-0.hippo-pods.some-place",
+		"hippo-00-abcd-0.hippo-pods",
+	})
+
+	assert.Assert(t, len(names[0]) > len(names[1]), "expected FQDN first, got %q", names[0])
+	assert.Assert(t, strings.HasPrefix(names[0], names[1]+"."), "wrong FQDN: %q", names[0])
+	assert.Assert(t, strings.HasSuffix(names[0], "."), "expected root, got %q", names[0])
+
+	// The names match those of the instance StatefulSet.
+	instance := &appsv1.StatefulSet{}
+	instance.Namespace = cluster.Namespace
+	instance.Name = "hippo-00-abcd"
+	instance.Spec.ServiceName = ClusterPodService(cluster).Name
+
+	assert.DeepEqual(t, names, InstancePodDNSNames(ctx, instance))
+}
+
 func TestServiceDNSNames(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -85,9 +85,17 @@ const (
 	// support discovery by Prometheus according to pgMonitor configuration
 	LabelPGMonitorDiscovery = labelPrefix + "crunchy-postgres-exporter"
 
+	// LabelPGUpgrade is used to identify the Job that upgrades PostgreSQL to a
+	// new major version.
+	LabelPGUpgrade = labelPrefix + "pgupgrade"
+
 	// LabelPostgresUser identifies the PostgreSQL user an object is for or about.
 	LabelPostgresUser = labelPrefix + "pguser"
 
+	// LabelReplicationLag indicates whether the replication lag of an instance
+	// Pod is within the limit of the replica Service.
+	LabelReplicationLag = labelPrefix + "replication-lag"
+
 	// LabelStartupInstance is used to indicate the startup instance associated with a resource
 	LabelStartupInstance = labelPrefix + "startup-instance"
 
@@ -121,6 +129,17 @@ const (
 	RoleMonitoring = "monitoring"
 )
 
+const (
+	// ReplicationLagWithinLimit is the LabelReplicationLag value of instance
+	// Pods that may receive connections through the replica Service.
+	ReplicationLagWithinLimit = "within-limit"
+
+	// ReplicationLagExceeded is the LabelReplicationLag value of instance Pods
+	// that are too far behind the primary to receive connections through the
+	// replica Service.
+	ReplicationLagExceeded = "exceeded"
+)
+
 const (
 	// DataPGAdmin is a LabelData value that indicates the object has pgAdmin data.
 	DataPGAdmin = "pgadmin"
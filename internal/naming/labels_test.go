@@ -43,7 +43,9 @@ func TestLabelsValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestore))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGBackRestRestoreConfig))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGMonitorDiscovery))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelPGUpgrade))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelPostgresUser))
+	assert.Assert(t, nil == validation.IsQualifiedName(LabelReplicationLag))
 	assert.Assert(t, nil == validation.IsQualifiedName(LabelStartupInstance))
 }
 
@@ -62,6 +64,8 @@ func TestLabelValuesValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsValidLabelValue(RoleReplica))
 	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupReplicaCreate)))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RoleMonitoring))
+	assert.Assert(t, nil == validation.IsValidLabelValue(ReplicationLagExceeded))
+	assert.Assert(t, nil == validation.IsValidLabelValue(ReplicationLagWithinLimit))
 }
 
 func TestMerge(t *testing.T) {
@@ -0,0 +1,188 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package naming
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// Length limits of generated names. See "limitations.md" for the sources.
+const (
+	// MaxCronJobNameLength is the longest a CronJob name can be.
+	MaxCronJobNameLength = 52
+
+	// MaxJobNameLength is the longest a Job name can be so that its Pods can
+	// have a "job-name" label.
+	MaxJobNameLength = validation.LabelValueMaxLength
+
+	// MaxServiceNameLength is the longest a Service name can be.
+	MaxServiceNameLength = validation.DNS1035LabelMaxLength
+
+	// MaxStatefulSetNameLength is the longest a StatefulSet name can be so that
+	// its Pods can have a "controller-revision-hash" label.
+	MaxStatefulSetNameLength = 52
+)
+
+// NameLengthLimit returns the length limit of generated names of kind when it
+// is shorter than a DNS subdomain.
+func NameLengthLimit(kind string) (int, bool) {
+	switch kind {
+	case "CronJob":
+		return MaxCronJobNameLength, true
+	case "Job":
+		return MaxJobNameLength, true
+	case "Service":
+		return MaxServiceNameLength, true
+	case "StatefulSet":
+		return MaxStatefulSetNameLength, true
+	}
+	return 0, false
+}
+
+// generatedName is the name of an object generated for a PostgresCluster.
+type generatedName struct {
+	kind  string
+	name  string
+	limit int
+}
+
+// clusterGeneratedNames returns the names of objects generated for cluster
+// that have length limits shorter than a DNS subdomain. Names that depend on
+// an instance set are not included.
+func clusterGeneratedNames(cluster *v1beta1.PostgresCluster) []generatedName {
+	names := []generatedName{
+		{"Service", ClusterPodService(cluster).Name, MaxServiceNameLength},
+		{"Service", ClusterPrimaryService(cluster).Name, MaxServiceNameLength},
+		{"Service", ClusterReplicaService(cluster).Name, MaxServiceNameLength},
+		{"Service", PatroniLeaderEndpoints(cluster).Name, MaxServiceNameLength},
+
+		// The Job that creates the first backup always exists.
+		{"Job", PGBackRestBackupJob(cluster).Name, MaxJobNameLength},
+	}
+
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		names = append(names,
+			generatedName{"Service", ClusterPGBouncer(cluster).Name, MaxServiceNameLength})
+	}
+	if cluster.Spec.UserInterface != nil && cluster.Spec.UserInterface.PGAdmin != nil {
+		names = append(names,
+			generatedName{"StatefulSet", ClusterPGAdmin(cluster).Name, MaxStatefulSetNameLength})
+	}
+	if cluster.Spec.Upgrade != nil && cluster.Spec.Upgrade.Enabled {
+		names = append(names,
+			generatedName{"Job", PGUpgradeJob(cluster).Name, MaxJobNameLength})
+	}
+
+	if cluster.Spec.Backups.PGBackRest.Restore != nil ||
+		(cluster.Spec.DataSource != nil && cluster.Spec.DataSource.PostgresCluster != nil) {
+		names = append(names,
+			generatedName{"Job", PGBackRestRestoreJob(cluster).Name, MaxJobNameLength})
+	}
+	if cluster.Spec.DataSource != nil && cluster.Spec.DataSource.Volumes != nil {
+		volumes := cluster.Spec.DataSource.Volumes
+		if volumes.PGDataVolume != nil {
+			names = append(names,
+				generatedName{"Job", MovePGDataDirJob(cluster).Name, MaxJobNameLength})
+		}
+		if volumes.PGWALVolume != nil {
+			names = append(names,
+				generatedName{"Job", MovePGWALDirJob(cluster).Name, MaxJobNameLength})
+		}
+		if volumes.PGBackRestVolume != nil {
+			names = append(names,
+				generatedName{"Job", MovePGBackRestRepoDirJob(cluster).Name, MaxJobNameLength})
+		}
+	}
+
+	var repoHost bool
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		repoHost = repoHost || repo.Volume != nil
+
+		if schedules := repo.BackupSchedules; schedules != nil {
+			for _, scheduled := range []struct {
+				backupType string
+				schedule   *string
+			}{
+				{"full", schedules.Full},
+				{"diff", schedules.Differential},
+				{"incr", schedules.Incremental},
+			} {
+				if scheduled.schedule != nil {
+					names = append(names, generatedName{"CronJob",
+						PGBackRestCronJob(cluster, scheduled.backupType, repo.Name).Name,
+						MaxCronJobNameLength})
+				}
+			}
+		}
+	}
+	if repoHost {
+		names = append(names,
+			generatedName{"StatefulSet", PGBackRestRepoHost(cluster).Name, MaxStatefulSetNameLength})
+	}
+
+	return names
+}
+
+// ValidateClusterNames returns an error for each name of cluster that would
+// make a generated object name longer than Kubernetes allows. It reports the
+// most restrictive limit so that the message says how long the name can be.
+func ValidateClusterNames(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	if prefix, ok := cluster.GetAnnotations()[ResourceNamePrefix]; ok {
+		if err := ValidateResourceNamePrefix(prefix); err != nil {
+			errs = append(errs, field.Invalid(
+				field.NewPath("metadata", "annotations").Key(ResourceNamePrefix),
+				prefix, err.Error()))
+		}
+	}
+
+	// Find the generated name that leaves the least room for the cluster name.
+	// PostgresCluster names are DNS subdomains, so use len() to count characters.
+	var shortest generatedName
+	maximum := validation.DNS1123SubdomainMaxLength
+	for _, generated := range clusterGeneratedNames(cluster) {
+		if room := generated.limit - (len(generated.name) - len(cluster.Name)); room < maximum {
+			maximum, shortest = room, generated
+		}
+	}
+
+	if len(cluster.Name) > maximum {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), cluster.Name,
+			fmt.Sprintf("should be at most %d chars long; the %s name %q would be longer than %d",
+				maximum, shortest.kind, shortest.name, shortest.limit)))
+	}
+
+	// Each instance set contributes to the names of its StatefulSets.
+	path := field.NewPath("spec", "instances")
+	for i := range cluster.Spec.InstanceSets {
+		set := &cluster.Spec.InstanceSets[i]
+		name := GenerateStartupInstance(cluster, set).Name
+
+		if len(name) > MaxStatefulSetNameLength {
+			errs = append(errs, field.Invalid(path.Index(i).Child("name"), set.Name,
+				fmt.Sprintf("the StatefulSet name %q would be longer than %d; "+
+					"shorten this or the cluster name", name, MaxStatefulSetNameLength)))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,161 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestValidateClusterNames(t *testing.T) {
+	t.Setenv("PGO_RESOURCE_NAME_PREFIX", "")
+
+	named := func(n int) *v1beta1.PostgresCluster {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.ObjectMeta = metav1.ObjectMeta{Namespace: "ns1", Name: strings.Repeat("x", n)}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+		return cluster
+	}
+
+	t.Run("Minimal", func(t *testing.T) {
+		// The backup Job is "{cluster}-backup-xxxx" and its Pods have a label.
+		assert.Assert(t, len(ValidateClusterNames(named(51))) == 0)
+
+		errs := ValidateClusterNames(named(52))
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field, "metadata.name")
+		assert.ErrorContains(t, errs[0], "at most 51 chars")
+		assert.ErrorContains(t, errs[0], "Job name")
+		assert.ErrorContains(t, errs[0], "longer than 63")
+	})
+
+	t.Run("PGAdmin", func(t *testing.T) {
+		cluster := named(44)
+		cluster.Spec.UserInterface = &v1beta1.UserInterfaceSpec{
+			PGAdmin: &v1beta1.PGAdminPodSpec{},
+		}
+		assert.Assert(t, len(ValidateClusterNames(cluster)) == 0)
+
+		cluster.Name += "x"
+		errs := ValidateClusterNames(cluster)
+		assert.Equal(t, len(errs), 1)
+		assert.ErrorContains(t, errs[0], "at most 44 chars")
+		assert.ErrorContains(t, errs[0], `StatefulSet name "`+cluster.Name+`-pgadmin"`)
+		assert.ErrorContains(t, errs[0], "longer than 52")
+	})
+
+	t.Run("PGBouncer", func(t *testing.T) {
+		cluster := named(51)
+		cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{
+			PGBouncer: &v1beta1.PGBouncerPodSpec{},
+		}
+
+		// "{cluster}-pgbouncer" is shorter than the backup Job.
+		assert.Assert(t, len(ValidateClusterNames(cluster)) == 0)
+	})
+
+	t.Run("BackupSchedules", func(t *testing.T) {
+		cluster := named(41)
+		cluster.Spec.Backups.PGBackRest.Repos[0].BackupSchedules =
+			&v1beta1.PGBackRestBackupSchedules{Full: initialize.String("@daily")}
+		assert.Assert(t, len(ValidateClusterNames(cluster)) == 0)
+
+		cluster.Name += "x"
+		errs := ValidateClusterNames(cluster)
+		assert.Equal(t, len(errs), 1)
+		assert.ErrorContains(t, errs[0], "at most 41 chars")
+		assert.ErrorContains(t, errs[0], `CronJob name "`+cluster.Name+`-repo1-full"`)
+	})
+
+	t.Run("RepoHost", func(t *testing.T) {
+		cluster := named(42)
+		cluster.Spec.Backups.PGBackRest.Repos[0].Volume = &v1beta1.RepoPVC{}
+		assert.Assert(t, len(ValidateClusterNames(cluster)) == 0)
+
+		cluster.Name += "x"
+		errs := ValidateClusterNames(cluster)
+		assert.Equal(t, len(errs), 1)
+		assert.ErrorContains(t, errs[0], "at most 42 chars")
+		assert.ErrorContains(t, errs[0], `StatefulSet name "`+cluster.Name+`-repo-host"`)
+	})
+
+	t.Run("InstanceSets", func(t *testing.T) {
+		// The combined length of the cluster and set names can be 46.
+		cluster := named(40)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "abcdef"}, {Name: "abcdefg"},
+		}
+
+		errs := ValidateClusterNames(cluster)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field, "spec.instances[1].name")
+		assert.ErrorContains(t, errs[0], "longer than 52")
+		assert.ErrorContains(t, errs[0], "shorten this or the cluster name")
+
+		// The default instance set name is "00".
+		cluster = named(44)
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{}}
+		cluster.Default()
+		assert.Assert(t, len(ValidateClusterNames(cluster)) == 0)
+
+		cluster.Name += "x"
+		errs = ValidateClusterNames(cluster)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field, "spec.instances[0].name")
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "team-a-")
+
+		assert.Assert(t, len(ValidateClusterNames(named(44))) == 0)
+
+		errs := ValidateClusterNames(named(45))
+		assert.Equal(t, len(errs), 1)
+		assert.ErrorContains(t, errs[0], "at most 44 chars")
+		assert.ErrorContains(t, errs[0], `"team-a-`)
+	})
+
+	t.Run("RecordedPrefix", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "team-a-")
+
+		// The prefix recorded on the cluster takes precedence.
+		cluster := named(49)
+		cluster.Annotations = map[string]string{ResourceNamePrefix: "b-"}
+		assert.Assert(t, len(ValidateClusterNames(cluster)) == 0)
+
+		cluster.Annotations[ResourceNamePrefix] = "B-"
+		errs := ValidateClusterNames(cluster)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field,
+			"metadata.annotations[postgres-operator.crunchydata.com/resource-name-prefix]")
+		assert.ErrorContains(t, errs[0], "invalid resource name prefix")
+	})
+}
+
+func TestNameLengthLimit(t *testing.T) {
+	limit, ok := NameLengthLimit("CronJob")
+	assert.Assert(t, ok)
+	assert.Equal(t, limit, 52)
+
+	_, ok = NameLengthLimit("ConfigMap")
+	assert.Assert(t, !ok)
+}
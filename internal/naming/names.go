@@ -18,10 +18,12 @@ package naming
 import (
 	"fmt"
 	"hash/fnv"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/config"
@@ -47,11 +49,17 @@ const (
 	ContainerPGBouncer = "pgbouncer"
 	// ContainerPGBouncerConfig is the name of a container supporting PgBouncer.
 	ContainerPGBouncerConfig = "pgbouncer-config"
+	// ContainerPGBouncerExporter is the name of a container running pgbouncer_exporter.
+	ContainerPGBouncerExporter = "pgbouncer-exporter"
 
 	// ContainerPostgresStartup is the name of the initialization container
 	// that prepares the filesystem for PostgreSQL.
 	ContainerPostgresStartup = "postgres-startup"
 
+	// ContainerPostgresPermissions is the name of the initialization container
+	// that gives ownership of the PostgreSQL volumes to the PostgreSQL user.
+	ContainerPostgresPermissions = "postgres-permissions"
+
 	// ContainerClientCertCopy is the name of the container that is responsible for copying and
 	// setting proper permissions on the client certificate and key after initialization whenever
 	// there is a change in the certificates or key
@@ -76,6 +84,9 @@ const (
 	// ContainerJobMovePGBackRestRepoDir is the name of the job container utilized to copy v4
 	// Operator pgBackRest repo directories to the v5 default location
 	ContainerJobMovePGBackRestRepoDir = "repo-move-job"
+	// ContainerJobPGUpgrade is the name of the job container utilized to upgrade
+	// a pgData directory to a new PostgreSQL major version
+	ContainerJobPGUpgrade = "pgupgrade"
 )
 
 const (
@@ -192,12 +203,39 @@ func AsObjectKey(m metav1.ObjectMeta) client.ObjectKey {
 	return client.ObjectKey{Namespace: m.Namespace, Name: m.Name}
 }
 
+// resourceName returns the name from which the names of objects generated for
+// cluster are derived: cluster's name with the prefix recorded on cluster or,
+// when none is recorded yet, the operator-wide prefix.
+func resourceName(cluster *v1beta1.PostgresCluster) string {
+	if prefix, ok := cluster.GetAnnotations()[ResourceNamePrefix]; ok {
+		return prefix + cluster.Name
+	}
+	return config.ResourceNamePrefix() + cluster.Name
+}
+
+// ValidateResourceNamePrefix returns an error when prefix cannot begin the
+// name of a generated object. Object names are used as DNS labels, so prefix
+// must begin with a lowercase letter or digit, consist of only lowercase
+// letters, digits, and hyphens, and leave room for the cluster name.
+func ValidateResourceNamePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	// Append one character to stand in for the shortest possible cluster name.
+	if errs := validation.IsDNS1123Label(prefix + "a"); len(errs) > 0 {
+		return fmt.Errorf("invalid resource name prefix %q: %s",
+			prefix, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // ClusterConfigMap returns the ObjectMeta necessary to lookup
 // cluster's shared ConfigMap.
 func ClusterConfigMap(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-config",
+		Name:      resourceName(cluster) + "-config",
 	}
 }
 
@@ -206,7 +244,7 @@ func ClusterConfigMap(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ClusterInstanceRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-instance",
+		Name:      resourceName(cluster) + "-instance",
 	}
 }
 
@@ -215,7 +253,7 @@ func ClusterInstanceRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ClusterPGAdmin(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-pgadmin",
+		Name:      resourceName(cluster) + "-pgadmin",
 	}
 }
 
@@ -225,7 +263,7 @@ func ClusterPGAdmin(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ClusterPGBouncer(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-pgbouncer",
+		Name:      resourceName(cluster) + "-pgbouncer",
 	}
 }
 
@@ -238,7 +276,7 @@ func ClusterPodService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	// likely to resolve.
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-pods",
+		Name:      resourceName(cluster) + "-pods",
 	}
 }
 
@@ -247,7 +285,7 @@ func ClusterPodService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ClusterPrimaryService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-primary",
+		Name:      resourceName(cluster) + "-primary",
 	}
 }
 
@@ -256,7 +294,7 @@ func ClusterPrimaryService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ClusterReplicaService(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-replicas",
+		Name:      resourceName(cluster) + "-replicas",
 	}
 }
 
@@ -266,7 +304,7 @@ func GenerateInstance(
 ) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-" + set.Name + "-" + rand.String(4),
+		Name:      resourceName(cluster) + "-" + set.Name + "-" + rand.String(4),
 	}
 }
 
@@ -284,7 +322,7 @@ func GenerateStartupInstance(
 
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-" + set.Name + "-" + suffix,
+		Name:      resourceName(cluster) + "-" + set.Name + "-" + suffix,
 	}
 }
 
@@ -311,7 +349,7 @@ func InstanceCertificates(instance metav1.Object) metav1.ObjectMeta {
 func InstanceSet(cluster *v1beta1.PostgresCluster,
 	set *v1beta1.PostgresInstanceSetSpec) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		Name:      cluster.Name + "-set-" + set.Name,
+		Name:      resourceName(cluster) + "-set-" + set.Name,
 		Namespace: cluster.Namespace,
 	}
 }
@@ -339,7 +377,7 @@ func InstancePostgresWALVolume(instance *appsv1.StatefulSet) metav1.ObjectMeta {
 func MonitoringUserSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-monitoring",
+		Name:      resourceName(cluster) + "-monitoring",
 	}
 }
 
@@ -349,7 +387,7 @@ func MonitoringUserSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ExporterWebConfigMap(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-exporter-web-config",
+		Name:      resourceName(cluster) + "-exporter-web-config",
 	}
 }
 
@@ -358,7 +396,7 @@ func ExporterWebConfigMap(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func ReplicationClientCertSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-replication-cert",
+		Name:      resourceName(cluster) + "-replication-cert",
 	}
 }
 
@@ -394,7 +432,7 @@ func PatroniLeaderEndpoints(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta
 
 // PatroniScope returns the "scope" Patroni uses for cluster.
 func PatroniScope(cluster *v1beta1.PostgresCluster) string {
-	return cluster.Name + "-ha"
+	return resourceName(cluster) + "-ha"
 }
 
 // PatroniTrigger returns the ObjectMeta necessary to lookup the ConfigMap or
@@ -411,7 +449,7 @@ func PatroniTrigger(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func PGBackRestConfig(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.GetNamespace(),
-		Name:      fmt.Sprintf(cmNameSuffix, cluster.GetName()),
+		Name:      fmt.Sprintf(cmNameSuffix, resourceName(cluster)),
 	}
 }
 
@@ -419,7 +457,7 @@ func PGBackRestConfig(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 // to create replicas using pgBackRest
 func PGBackRestBackupJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		Name:      cluster.GetName() + "-backup-" + rand.String(4),
+		Name:      resourceName(cluster) + "-backup-" + rand.String(4),
 		Namespace: cluster.GetNamespace(),
 	}
 }
@@ -428,7 +466,7 @@ func PGBackRestBackupJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func PGBackRestCronJob(cluster *v1beta1.PostgresCluster, backuptype, repoName string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.GetNamespace(),
-		Name:      cluster.Name + "-" + repoName + "-" + backuptype,
+		Name:      resourceName(cluster) + "-" + repoName + "-" + backuptype,
 	}
 }
 
@@ -436,7 +474,7 @@ func PGBackRestCronJob(cluster *v1beta1.PostgresCluster, backuptype, repoName st
 func PGBackRestRestoreJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.GetNamespace(),
-		Name:      cluster.Name + "-pgbackrest-restore",
+		Name:      resourceName(cluster) + "-pgbackrest-restore",
 	}
 }
 
@@ -445,7 +483,16 @@ func PGBackRestRestoreJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func PGBackRestRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-pgbackrest",
+		Name:      resourceName(cluster) + "-pgbackrest",
+	}
+}
+
+// PGBackRestRepoHost returns the ObjectMeta for the StatefulSet of a pgBackRest
+// dedicated repository host
+func PGBackRestRepoHost(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      resourceName(cluster) + "-repo-host",
+		Namespace: cluster.GetNamespace(),
 	}
 }
 
@@ -453,7 +500,7 @@ func PGBackRestRBAC(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func PGBackRestRepoVolume(cluster *v1beta1.PostgresCluster,
 	repoName string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		Name:      fmt.Sprintf("%s-%s", cluster.GetName(), repoName),
+		Name:      fmt.Sprintf("%s-%s", resourceName(cluster), repoName),
 		Namespace: cluster.GetNamespace(),
 	}
 }
@@ -463,7 +510,7 @@ func PGBackRestRepoVolume(cluster *v1beta1.PostgresCluster,
 // TODO(tjmoore4): Once we no longer need this for cleanup purposes, this should be removed.
 func PGBackRestSSHConfig(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		Name:      fmt.Sprintf(sshCMNameSuffix, cluster.GetName()),
+		Name:      fmt.Sprintf(sshCMNameSuffix, resourceName(cluster)),
 		Namespace: cluster.GetNamespace(),
 	}
 }
@@ -473,7 +520,7 @@ func PGBackRestSSHConfig(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 // TODO(tjmoore4): Once we no longer need this for cleanup purposes, this should be removed.
 func PGBackRestSSHSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		Name:      fmt.Sprintf(sshSecretNameSuffix, cluster.GetName()),
+		Name:      fmt.Sprintf(sshSecretNameSuffix, resourceName(cluster)),
 		Namespace: cluster.GetNamespace(),
 	}
 }
@@ -481,7 +528,7 @@ func PGBackRestSSHSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 // PGBackRestSecret returns the ObjectMeta for a pgBackRest Secret
 func PGBackRestSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		Name:      cluster.GetName() + "-pgbackrest",
+		Name:      resourceName(cluster) + "-pgbackrest",
 		Namespace: cluster.GetNamespace(),
 	}
 }
@@ -492,7 +539,7 @@ func PGBackRestSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func DeprecatedPostgresUserSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-pguser",
+		Name:      resourceName(cluster) + "-pguser",
 	}
 }
 
@@ -501,7 +548,7 @@ func DeprecatedPostgresUserSecret(cluster *v1beta1.PostgresCluster) metav1.Objec
 func PostgresUserSecret(cluster *v1beta1.PostgresCluster, username string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-pguser-" + username,
+		Name:      resourceName(cluster) + "-pguser-" + username,
 	}
 }
 
@@ -510,7 +557,7 @@ func PostgresUserSecret(cluster *v1beta1.PostgresCluster, username string) metav
 func PostgresTLSSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      cluster.Name + "-cluster-cert",
+		Name:      resourceName(cluster) + "-cluster-cert",
 	}
 }
 
@@ -518,7 +565,7 @@ func PostgresTLSSecret(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func MovePGDataDirJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.GetNamespace(),
-		Name:      cluster.Name + "-move-pgdata-dir",
+		Name:      resourceName(cluster) + "-move-pgdata-dir",
 	}
 }
 
@@ -526,7 +573,7 @@ func MovePGDataDirJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func MovePGWALDirJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.GetNamespace(),
-		Name:      cluster.Name + "-move-pgwal-dir",
+		Name:      resourceName(cluster) + "-move-pgwal-dir",
 	}
 }
 
@@ -534,7 +581,15 @@ func MovePGWALDirJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 func MovePGBackRestRepoDirJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Namespace: cluster.GetNamespace(),
-		Name:      cluster.Name + "-move-pgbackrest-repo-dir",
+		Name:      resourceName(cluster) + "-move-pgbackrest-repo-dir",
+	}
+}
+
+// PGUpgradeJob returns the ObjectMeta for a PostgreSQL major version upgrade Job
+func PGUpgradeJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      resourceName(cluster) + "-pgupgrade",
 	}
 }
 
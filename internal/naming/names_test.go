@@ -122,6 +122,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"PGBackRestBackupJob", PGBackRestBackupJob(cluster)},
 			{"PGBackRestRestoreJob", PGBackRestRestoreJob(cluster)},
+			{"PGUpgradeJob", PGUpgradeJob(cluster)},
 		})
 	})
 
@@ -193,6 +194,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 	t.Run("StatefulSets", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"ClusterPGAdmin", ClusterPGAdmin(cluster)},
+			{"PGBackRestRepoHost", PGBackRestRepoHost(cluster)},
 		})
 	})
 
@@ -298,6 +300,86 @@ func TestGenerateStartupInstance(t *testing.T) {
 
 }
 
+func TestResourceNamePrefix(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1", Name: "pg0",
+		},
+	}
+	set := &v1beta1.PostgresInstanceSetSpec{Name: "hippos"}
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "")
+
+		assert.Equal(t, ClusterPrimaryService(cluster).Name, "pg0-primary")
+		assert.Equal(t, PatroniScope(cluster), "pg0-ha")
+		assert.Equal(t, PGBackRestRepoHost(cluster).Name, "pg0-repo-host")
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "team-a-")
+
+		assert.Equal(t, ClusterConfigMap(cluster).Name, "team-a-pg0-config")
+		assert.Equal(t, ClusterPodService(cluster).Name, "team-a-pg0-pods")
+		assert.Equal(t, ClusterPrimaryService(cluster).Name, "team-a-pg0-primary")
+		assert.Equal(t, ClusterReplicaService(cluster).Name, "team-a-pg0-replicas")
+		assert.Equal(t, ClusterPGBouncer(cluster).Name, "team-a-pg0-pgbouncer")
+		assert.Equal(t, PatroniScope(cluster), "team-a-pg0-ha")
+		assert.Equal(t, PGBackRestConfig(cluster).Name, "team-a-pg0-pgbackrest-config")
+		assert.Equal(t, PGBackRestRepoHost(cluster).Name, "team-a-pg0-repo-host")
+		assert.Equal(t, PGBackRestRepoVolume(cluster, "repo1").Name, "team-a-pg0-repo1")
+		assert.Equal(t, PostgresUserSecret(cluster, "hippo").Name, "team-a-pg0-pguser-hippo")
+
+		assert.Assert(t, strings.HasPrefix(GenerateInstance(cluster, set).Name, "team-a-pg0-hippos-"))
+		assert.Assert(t, strings.HasPrefix(GenerateStartupInstance(cluster, set).Name, "team-a-pg0-hippos-"))
+
+		// The namespace and the name of the cluster itself are unchanged.
+		assert.Equal(t, ClusterPrimaryService(cluster).Namespace, "ns1")
+		assert.Equal(t, cluster.Name, "pg0")
+	})
+
+	t.Run("Recorded", func(t *testing.T) {
+		t.Setenv("PGO_RESOURCE_NAME_PREFIX", "team-b-")
+
+		recorded := cluster.DeepCopy()
+		recorded.Annotations = map[string]string{ResourceNamePrefix: "team-a-"}
+
+		assert.Equal(t, ClusterPrimaryService(recorded).Name, "team-a-pg0-primary")
+		assert.Equal(t, PatroniScope(recorded), "team-a-pg0-ha")
+		assert.Equal(t, PGBackRestRepoVolume(recorded, "repo1").Name, "team-a-pg0-repo1")
+		assert.Equal(t, PostgresUserSecret(recorded, "hippo").Name, "team-a-pg0-pguser-hippo")
+
+		// An empty prefix is recorded for clusters created without one.
+		recorded.Annotations[ResourceNamePrefix] = ""
+		assert.Equal(t, ClusterPrimaryService(recorded).Name, "pg0-primary")
+		assert.Equal(t, PatroniScope(recorded), "pg0-ha")
+	})
+}
+
+func TestValidateResourceNamePrefix(t *testing.T) {
+	for _, prefix := range []string{
+		"",
+		"a",
+		"team-a-",
+		"0-",
+		strings.Repeat("x", 62),
+	} {
+		assert.NilError(t, ValidateResourceNamePrefix(prefix), "%q", prefix)
+	}
+
+	for _, tt := range []struct{ prefix, message string }{
+		{prefix: "-", message: "alphanumeric"},
+		{prefix: "Team-", message: "lower case"},
+		{prefix: "team.a-", message: "alphanumeric"},
+		{prefix: "team_a-", message: "alphanumeric"},
+		{prefix: strings.Repeat("x", 63), message: "no more than 63"},
+	} {
+		err := ValidateResourceNamePrefix(tt.prefix)
+		assert.ErrorContains(t, err, tt.message, "%q", tt.prefix)
+		assert.ErrorContains(t, err, "invalid resource name prefix")
+	}
+}
+
 func TestPortNamesUniqueAndValid(t *testing.T) {
 	// Port names have to be unique within a Pod. The number of ports we employ
 	// should be few enough that we can name them uniquely across all pods.
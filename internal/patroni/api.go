@@ -179,6 +179,47 @@ func (exec Executor) RestartPendingMembers(ctx context.Context, role, scope stri
 	return err
 }
 
+// RestartMember restarts the Patroni member named member in scope, whether or
+// not it has a pending restart.
+func (exec Executor) RestartMember(ctx context.Context, scope, member string) error {
+	var stdout, stderr bytes.Buffer
+
+	// The following exits zero when it is able to read the DCS and communicate
+	// with the Patroni HTTP API. It prints the result of calling "POST /restart"
+	// on the member.
+	// - https://github.com/zalando/patroni/blob/v2.1.1/patroni/ctl.py#L580-L596
+	err := exec(ctx, nil, &stdout, &stderr,
+		"patronictl", "restart", "--force", scope, member)
+
+	log := logging.FromContext(ctx)
+	log.V(1).Info("restarted member",
+		"stdout", stdout.String(),
+		"stderr", stderr.String(),
+	)
+
+	return err
+}
+
+// ReloadMembers reloads the configuration of every Patroni member in scope.
+func (exec Executor) ReloadMembers(ctx context.Context, scope string) error {
+	var stdout, stderr bytes.Buffer
+
+	// The following exits zero when it is able to read the DCS and communicate
+	// with the Patroni HTTP API. It prints the result of calling "POST /reload"
+	// on each member.
+	// - https://github.com/zalando/patroni/blob/v2.1.1/patroni/ctl.py#L529-L555
+	err := exec(ctx, nil, &stdout, &stderr,
+		"patronictl", "reload", "--force", scope)
+
+	log := logging.FromContext(ctx)
+	log.V(1).Info("reloaded members",
+		"stdout", stdout.String(),
+		"stderr", stderr.String(),
+	)
+
+	return err
+}
+
 // GetTimeline gets the patronictl status and returns the timeline,
 // currently the only information required by PGO.
 // Returns zero if it runs into errors or cannot find a running Leader pod
@@ -237,6 +237,45 @@ func TestExecutorRestartPendingMembers(t *testing.T) {
 	assert.Equal(t, expected, actual, "should call exec")
 }
 
+func TestExecutorRestartMember(t *testing.T) {
+	expected := errors.New("oop")
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command, strings.Fields(
+			`patronictl restart --force shoe-scope shoe-member`,
+		))
+		assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+		assert.Assert(t, stderr != nil, "should capture stderr")
+		assert.Assert(t, stdout != nil, "should capture stdout")
+		return expected
+	}
+
+	actual := Executor(exec).RestartMember(
+		context.Background(), "shoe-scope", "shoe-member")
+
+	assert.Equal(t, expected, actual, "should call exec")
+}
+
+func TestExecutorReloadMembers(t *testing.T) {
+	expected := errors.New("oop")
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command, strings.Fields(
+			`patronictl reload --force shoe-scope`,
+		))
+		assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+		assert.Assert(t, stderr != nil, "should capture stderr")
+		assert.Assert(t, stdout != nil, "should capture stdout")
+		return expected
+	}
+
+	actual := Executor(exec).ReloadMembers(context.Background(), "shoe-scope")
+
+	assert.Equal(t, expected, actual, "should call exec")
+}
+
 func TestExecutorGetTimeline(t *testing.T) {
 	t.Run("Error", func(t *testing.T) {
 		expected := errors.New("bang")
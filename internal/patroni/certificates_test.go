@@ -17,12 +17,16 @@ package patroni
 
 import (
 	"errors"
+	"path"
 	"testing"
 
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 type funcMarshaler func() ([]byte, error)
@@ -58,4 +62,28 @@ func TestInstanceCertificates(t *testing.T) {
       path: ~postgres-operator/patroni.crt+key
     name: some-name
 	`))
+
+	// The REST API and patronictl read the projected files.
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	data, err := clusterYAML(cluster, postgres.HBAs{}, postgres.Parameters{})
+	assert.NilError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NilError(t, yaml.Unmarshal([]byte(data), &config))
+
+	restapi := config["restapi"].(map[string]interface{})
+	ctl := config["ctl"].(map[string]interface{})
+	for _, item := range projections[0].Secret.Items {
+		file := path.Join(configDirectory, item.Path)
+		switch item.Key {
+		case certAuthorityFileKey:
+			assert.Equal(t, restapi["cafile"], file)
+			assert.Equal(t, ctl["cacert"], file)
+		case certServerFileKey:
+			assert.Equal(t, restapi["certfile"], file)
+			assert.Equal(t, ctl["certfile"], file)
+		}
+	}
+	assert.Equal(t, ctl["insecure"], false)
 }
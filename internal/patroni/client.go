@@ -0,0 +1,271 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package patroni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Client calls the Patroni REST API.
+// - https://patroni.readthedocs.io/en/latest/rest_api.html
+type Client struct {
+	// HTTP sends requests to Patroni. Its Transport may be an ExecTransport to
+	// send them from inside a database container, or it may connect to Patroni
+	// directly, e.g. through a port-forward.
+	HTTP *http.Client
+
+	// URL is the root of the Patroni REST API, e.g. "https://localhost:8008".
+	URL string
+}
+
+// NewExecClient returns a Client that calls the Patroni REST API listening on
+// port from inside the database container that exec runs in.
+func NewExecClient(exec Executor, port int32) Client {
+	return Client{
+		HTTP: &http.Client{Transport: ExecTransport(exec)},
+		URL:  "https://localhost:" + strconv.Itoa(int(port)),
+	}
+}
+
+// ClusterStatus is the response of "GET /cluster".
+type ClusterStatus struct {
+	Members []ClusterMember `json:"members"`
+
+	// Paused is true when Patroni is in maintenance mode.
+	Paused bool `json:"pause,omitempty"`
+}
+
+// ClusterMember is one member of a Patroni cluster.
+type ClusterMember struct {
+	// Name is the name of the member. It matches the name of its Pod.
+	Name string `json:"name"`
+
+	// Role is the role of the member, such as "leader", "replica", or
+	// "sync_standby".
+	Role string `json:"role"`
+
+	// State is the state of PostgreSQL, such as "running" or "streaming".
+	State string `json:"state"`
+
+	// Timeline is the PostgreSQL timeline of the member.
+	Timeline int64 `json:"timeline,omitempty"`
+
+	// PendingRestart is true when PostgreSQL must restart to apply changes to
+	// its parameters.
+	PendingRestart bool `json:"pending_restart,omitempty"`
+
+	// Lag is how far the member is behind the leader, in bytes. It is nil for
+	// the leader and when Patroni does not know.
+	Lag *int64 `json:"-"`
+}
+
+// UnmarshalJSON reads a ClusterMember from data. Patroni reports the lag of a
+// replica as a number of bytes or as "unknown".
+// - https://github.com/zalando/patroni/blob/v2.1.1/patroni/utils.py#L487-L492
+func (m *ClusterMember) UnmarshalJSON(data []byte) error {
+	type member ClusterMember
+	var raw struct {
+		member
+		Lag interface{} `json:"lag"`
+	}
+
+	err := json.Unmarshal(data, &raw)
+	if err == nil {
+		*m = ClusterMember(raw.member)
+		if lag, ok := raw.Lag.(float64); ok {
+			m.Lag = new(int64)
+			*m.Lag = int64(lag)
+		}
+	}
+	return err
+}
+
+// Cluster calls "GET /cluster" to get the members of the Patroni cluster.
+func (c Client) Cluster(ctx context.Context) (*ClusterStatus, error) {
+	var status ClusterStatus
+	err := c.do(ctx, http.MethodGet, "/cluster", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Switchover calls "POST /switchover" to demote leader and promote candidate.
+// When candidate is blank, Patroni chooses a healthy replica.
+func (c Client) Switchover(ctx context.Context, leader, candidate string) error {
+	body := map[string]string{"leader": leader}
+	if candidate != "" {
+		body["candidate"] = candidate
+	}
+	return c.do(ctx, http.MethodPost, "/switchover", body, nil)
+}
+
+// Failover calls "POST /failover" to promote candidate regardless of the
+// health of the current leader.
+func (c Client) Failover(ctx context.Context, candidate string) error {
+	body := map[string]string{"candidate": candidate}
+	return c.do(ctx, http.MethodPost, "/failover", body, nil)
+}
+
+// Configuration calls "GET /config" to get Patroni's dynamic configuration.
+func (c Client) Configuration(ctx context.Context) (map[string]interface{}, error) {
+	var configuration map[string]interface{}
+	err := c.do(ctx, http.MethodGet, "/config", nil, &configuration)
+	if err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
+
+// PatchConfiguration calls "PATCH /config" to merge patch into Patroni's
+// dynamic configuration. It returns the resulting configuration.
+func (c Client) PatchConfiguration(
+	ctx context.Context, patch map[string]interface{},
+) (map[string]interface{}, error) {
+	var configuration map[string]interface{}
+	err := c.do(ctx, http.MethodPatch, "/config", patch, &configuration)
+	if err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
+
+// do sends a request with the JSON encoding of in, if any, and decodes the
+// JSON response into out, if any. It returns an error when the response
+// status is not successful.
+func (c Client) do(
+	ctx context.Context, method, endpoint string, in, out interface{},
+) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.URL+endpoint, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	// Patroni explains an unsuccessful request in plain text.
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s: %s", method, endpoint, response.Status,
+			strings.TrimSpace(string(data)))
+	}
+
+	if out != nil {
+		err = json.Unmarshal(data, out)
+	}
+	return err
+}
+
+// execTransportScript sends one request to the Patroni REST API from inside
+// the database container. It verifies the server and presents a certificate
+// using the same files as patronictl. The server is reached through localhost,
+// which is not a name in its certificate, so only its issuer is verified.
+//
+// The status code of the response is printed on the first line followed by
+// the response body.
+const execTransportScript = `
+import ssl, sys, urllib.error, urllib.request
+method, url, cafile, certfile = sys.argv[1:]
+context = ssl.create_default_context(cafile=cafile)
+context.check_hostname = False
+context.load_cert_chain(certfile)
+request = urllib.request.Request(url, data=sys.stdin.buffer.read() or None, method=method)
+request.add_header('Content-Type', 'application/json')
+try:
+    response = urllib.request.urlopen(request, context=context)
+except urllib.error.HTTPError as error:
+    response = error
+sys.stdout.write('%d\n' % response.getcode())
+sys.stdout.flush()
+sys.stdout.buffer.write(response.read())
+`
+
+// ExecTransport is an http.RoundTripper that sends requests to Patroni by
+// running Python inside a database container.
+type ExecTransport Executor
+
+// ExecTransport implements http.RoundTripper.
+var _ http.RoundTripper = ExecTransport(nil)
+
+// RoundTrip sends request from inside the database container and returns
+// the response of Patroni.
+func (exec ExecTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var stdin io.Reader = bytes.NewReader(nil)
+	if request.Body != nil {
+		defer request.Body.Close()
+		stdin = request.Body
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := exec(request.Context(), stdin, &stdout, &stderr,
+		"python3", "-c", execTransportScript,
+		request.Method, request.URL.String(),
+		path.Join(configDirectory, certAuthorityConfigPath),
+		path.Join(configDirectory, certServerConfigPath))
+	if err == nil && stderr.Len() > 0 {
+		err = errors.New(stderr.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, data, _ := bytes.Cut(stdout.Bytes(), []byte("\n"))
+	code, err := strconv.Atoi(string(line))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected response from Patroni: %q", stdout.String())
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       request,
+	}, nil
+}
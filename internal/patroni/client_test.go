@@ -0,0 +1,283 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package patroni
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// serve returns a Client for a test server that checks each request and
+// responds with status and body.
+func serve(t *testing.T, method, endpoint string, status int, body string,
+	check func(t *testing.T, r *http.Request),
+) Client {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, method)
+		assert.Equal(t, r.URL.Path, endpoint)
+		if check != nil {
+			check(t, r)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return Client{HTTP: server.Client(), URL: server.URL}
+}
+
+func TestClientCluster(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		// - https://github.com/zalando/patroni/blob/v2.1.1/docs/rest_api.rst#cluster-status-endpoints
+		client := serve(t, "GET", "/cluster", 200, `{
+"members": [
+  {"name": "hippo-instance1-67mc-0", "role": "leader", "state": "running", "api_url": "https://hippo-instance1-67mc-0.hippo-pods:8008/patroni", "host": "hippo-instance1-67mc-0.hippo-pods", "port": 5432, "timeline": 4},
+  {"name": "hippo-instance1-ltcf-0", "role": "replica", "state": "streaming", "api_url": "https://hippo-instance1-ltcf-0.hippo-pods:8008/patroni", "host": "hippo-instance1-ltcf-0.hippo-pods", "port": 5432, "timeline": 4, "lag": 12582912, "pending_restart": true},
+  {"name": "hippo-instance1-x8rl-0", "role": "sync_standby", "state": "starting", "api_url": "https://hippo-instance1-x8rl-0.hippo-pods:8008/patroni", "host": "hippo-instance1-x8rl-0.hippo-pods", "port": 5432, "timeline": 3, "lag": "unknown"}
+],
+"pause": true
+}`, nil)
+
+		status, err := client.Cluster(ctx)
+		assert.NilError(t, err)
+		assert.Assert(t, status.Paused)
+		assert.Equal(t, len(status.Members), 3)
+
+		assert.DeepEqual(t, status.Members[0], ClusterMember{
+			Name: "hippo-instance1-67mc-0", Role: "leader", State: "running", Timeline: 4,
+		})
+
+		assert.Equal(t, status.Members[1].Role, "replica")
+		assert.Equal(t, status.Members[1].State, "streaming")
+		assert.Assert(t, status.Members[1].Lag != nil)
+		assert.Equal(t, *status.Members[1].Lag, int64(12582912))
+		assert.Assert(t, status.Members[1].PendingRestart)
+
+		assert.Equal(t, status.Members[2].Role, "sync_standby")
+		assert.Equal(t, status.Members[2].Timeline, int64(3))
+		assert.Assert(t, status.Members[2].Lag == nil, "expected unknown lag to be nil")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		client := serve(t, "GET", "/cluster", 503, "no luck\n", nil)
+
+		status, err := client.Cluster(ctx)
+		assert.Error(t, err, "GET /cluster: 503 Service Unavailable: no luck")
+		assert.Assert(t, status == nil)
+	})
+
+	t.Run("BadJSON", func(t *testing.T) {
+		client := serve(t, "GET", "/cluster", 200, "nope", nil)
+
+		status, err := client.Cluster(ctx)
+		assert.ErrorContains(t, err, "invalid character")
+		assert.Assert(t, status == nil)
+	})
+}
+
+func TestClientSwitchover(t *testing.T) {
+	ctx := context.Background()
+
+	body := func(expected string) func(*testing.T, *http.Request) {
+		return func(t *testing.T, r *http.Request) {
+			assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), expected)
+		}
+	}
+
+	t.Run("Candidate", func(t *testing.T) {
+		client := serve(t, "POST", "/switchover", 200,
+			"Successfully switched over to \"two\"",
+			body(`{"candidate":"two","leader":"one"}`))
+
+		assert.NilError(t, client.Switchover(ctx, "one", "two"))
+	})
+
+	t.Run("NoCandidate", func(t *testing.T) {
+		client := serve(t, "POST", "/switchover", 200,
+			"Successfully switched over to \"two\"",
+			body(`{"leader":"one"}`))
+
+		assert.NilError(t, client.Switchover(ctx, "one", ""))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		client := serve(t, "POST", "/switchover", 412,
+			"Switchover failed", nil)
+
+		assert.Error(t, client.Switchover(ctx, "one", "two"),
+			"POST /switchover: 412 Precondition Failed: Switchover failed")
+	})
+}
+
+func TestClientFailover(t *testing.T) {
+	ctx := context.Background()
+
+	client := serve(t, "POST", "/failover", 200,
+		"Successfully failed over to \"two\"",
+		func(t *testing.T, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), `{"candidate":"two"}`)
+		})
+
+	assert.NilError(t, client.Failover(ctx, "two"))
+}
+
+func TestClientConfiguration(t *testing.T) {
+	ctx := context.Background()
+
+	client := serve(t, "GET", "/config", 200,
+		`{"loop_wait": 10, "postgresql": {"parameters": {"work_mem": "4MB"}}}`, nil)
+
+	configuration, err := client.Configuration(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, configuration, map[string]interface{}{
+		"loop_wait": float64(10),
+		"postgresql": map[string]interface{}{
+			"parameters": map[string]interface{}{"work_mem": "4MB"},
+		},
+	})
+}
+
+func TestClientPatchConfiguration(t *testing.T) {
+	ctx := context.Background()
+
+	client := serve(t, "PATCH", "/config", 200,
+		`{"loop_wait": 10, "ttl": 30, "retry_timeout": 5}`,
+		func(t *testing.T, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), `{"retry_timeout":5}`)
+		})
+
+	configuration, err := client.PatchConfiguration(ctx,
+		map[string]interface{}{"retry_timeout": 5})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, configuration, map[string]interface{}{
+		"loop_wait": float64(10), "retry_timeout": float64(5), "ttl": float64(30),
+	})
+}
+
+func TestExecTransport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		client := NewExecClient(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Equal(t, len(command), 7)
+			assert.DeepEqual(t, command[:2], []string{"python3", "-c"})
+			assert.Assert(t, strings.Contains(command[2], "urllib.request"))
+			assert.DeepEqual(t, command[3:], []string{
+				"PATCH", "https://localhost:8008/config",
+				"/etc/patroni/~postgres-operator/patroni.ca-roots",
+				"/etc/patroni/~postgres-operator/patroni.crt+key",
+			})
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), `{"ttl":15}`)
+
+			_, err = stdout.Write([]byte("200\n{\"ttl\": 15}"))
+			return err
+		}, 8008)
+
+		configuration, err := client.PatchConfiguration(ctx,
+			map[string]interface{}{"ttl": 15})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, configuration, map[string]interface{}{"ttl": float64(15)})
+	})
+
+	t.Run("NoBody", func(t *testing.T) {
+		client := NewExecClient(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Equal(t, command[3], "GET")
+			assert.Equal(t, command[4], "https://localhost:9999/cluster")
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, len(b), 0)
+
+			_, err = stdout.Write([]byte("200\n{\"members\": []}"))
+			return err
+		}, 9999)
+
+		status, err := client.Cluster(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(status.Members), 0)
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		client := NewExecClient(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			_, err := stdout.Write([]byte("503\nno luck"))
+			return err
+		}, 8008)
+
+		assert.Error(t, client.Failover(ctx, "two"),
+			"POST /failover: 503 Service Unavailable: no luck")
+	})
+
+	t.Run("ExecError", func(t *testing.T) {
+		expected := errors.New("bang")
+		client := NewExecClient(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			return expected
+		}, 8008)
+
+		_, err := client.Cluster(ctx)
+		assert.Assert(t, errors.Is(err, expected), "got %v", err)
+	})
+
+	t.Run("Stderr", func(t *testing.T) {
+		client := NewExecClient(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			_, err := stderr.Write([]byte("Traceback"))
+			return err
+		}, 8008)
+
+		_, err := client.Cluster(ctx)
+		assert.ErrorContains(t, err, "Traceback")
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		client := NewExecClient(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			_, err := stdout.Write([]byte("what"))
+			return err
+		}, 8008)
+
+		_, err := client.Cluster(ctx)
+		assert.ErrorContains(t, err, "unexpected response from Patroni")
+	})
+}
@@ -16,11 +16,16 @@
 package patroni
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
+	"reflect"
+	"sort"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/yaml"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
@@ -31,6 +36,12 @@ import (
 const (
 	configDirectory  = "/etc/patroni"
 	configMapFileKey = "patroni.yaml"
+
+	postgresConfigMapFileKey = "postgresql.conf"
+	postgresConfigPath       = "~postgres-operator/postgresql.conf"
+
+	identConfigMapFileKey = "pg_ident.conf"
+	identConfigPath       = "~postgres-operator/pg_ident.conf"
 )
 
 const (
@@ -82,13 +93,6 @@ func clusterYAML(
 		"postgresql": map[string]interface{}{
 			// TODO(cbandy): "callbacks"
 
-			// Custom configuration "must exist on all cluster nodes".
-			//
-			// TODO(cbandy): I imagine we will always set this to a file we own. At
-			// the very least, it will start with an "include_dir" directive.
-			// - https://www.postgresql.org/docs/current/config-setting.html#CONFIG-INCLUDES
-			//"custom_conf": nil,
-
 			// TODO(cbandy): Should "parameters", "pg_hba", and "pg_ident" be set in
 			// DCS? If so, are they are automatically regenerated and reloaded?
 
@@ -162,6 +166,15 @@ func clusterYAML(
 		},
 	}
 
+	if len(cluster.Spec.Config.Files) != 0 {
+		// Custom configuration "must exist on all cluster nodes". Patroni
+		// includes it at the top of "postgresql.conf" and writes its own
+		// parameters after it, so mandatory values cannot be overridden.
+		// - https://www.postgresql.org/docs/current/config-setting.html#CONFIG-INCLUDES
+		root["postgresql"].(map[string]interface{})["custom_conf"] =
+			path.Join(configDirectory, postgresConfigPath)
+	}
+
 	if !ClusterBootstrapped(cluster) {
 		// Patroni has not yet bootstrapped. Populate the "bootstrap.dcs" field to
 		// facilitate it. When Patroni is already bootstrapped, this field is ignored.
@@ -184,6 +197,192 @@ func clusterYAML(
 	return string(append([]byte(yamlGeneratedWarning), b...)), err
 }
 
+// quoteConfigValue ensures that s is interpreted by PostgreSQL as a single
+// string in a configuration file.
+// - https://www.postgresql.org/docs/current/config-setting.html#CONFIG-SETTING-CONFIGURATION-FILE
+func quoteConfigValue(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// postgresConfig returns the contents of the file Patroni uses as
+// "postgresql.custom_conf" when cluster has additional configuration files.
+// It keeps the settings of initdb and then includes the files that end with
+// ".conf" in the additional configuration directory.
+func postgresConfig(cluster *v1beta1.PostgresCluster) string {
+	// Patroni renames the "postgresql.conf" of initdb to "postgresql.base.conf"
+	// when it does not have custom configuration.
+	base := path.Join(postgres.ConfigDirectory(cluster), "postgresql.base.conf")
+
+	return "" +
+		"# Generated by postgres-operator. DO NOT EDIT.\n" +
+		"# Your changes will not be saved.\n" +
+		"include_if_exists " + quoteConfigValue(base) + "\n" +
+		"include_dir " + quoteConfigValue(postgres.AdditionalConfigVolumeMount().MountPath) + "\n"
+}
+
+// identFile returns the contents of pg_ident.conf for cluster. Mandatory
+// records come first, followed by those in "postgresql.pg_ident" of the
+// Patroni dynamic configuration. Patroni ignores that section when the
+// "ident_file" parameter is set, so it is copied here. When the section is
+// missing or empty, the defaults are included instead. PostgreSQL reloads
+// when the file changes; see [InstancePod].
+// - https://www.postgresql.org/docs/current/auth-username-maps.html
+func identFile(cluster *v1beta1.PostgresCluster, pgIdents postgres.Idents) string {
+	lines := make([]string, 0, len(pgIdents.Mandatory))
+	for i := range pgIdents.Mandatory {
+		lines = append(lines, pgIdents.Mandatory[i].String())
+	}
+
+	var section []interface{}
+	if cluster.Spec.Patroni != nil {
+		postgresql, _ := cluster.Spec.Patroni.DynamicConfiguration["postgresql"].(map[string]interface{})
+		section, _ = postgresql["pg_ident"].([]interface{})
+	}
+	for i := range section {
+		// any pg_ident values that are not strings will be skipped
+		if value, ok := section[i].(string); ok {
+			lines = append(lines, value)
+		}
+	}
+	if len(lines) == len(pgIdents.Mandatory) {
+		for i := range pgIdents.Default {
+			lines = append(lines, pgIdents.Default[i].String())
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by postgres-operator. DO NOT EDIT.\n")
+	b.WriteString("# Your changes will not be saved.\n")
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// PostgreSQL populates outParameters with any settings required by Patroni.
+func PostgreSQL(cluster *v1beta1.PostgresCluster, outParameters *postgres.Parameters) {
+	// Read user name maps from the file in the cluster ConfigMap. Patroni does
+	// not manage pg_ident.conf when this is set.
+	// PostgreSQL must be restarted when changing this value.
+	outParameters.Mandatory.Add("ident_file", path.Join(configDirectory, identConfigPath))
+
+	// Patroni fills in synchronous_standby_names during synchronous replication.
+	// Transactions wait for those replicas unless synchronous_commit is "local"
+	// or "off", so start with the PostgreSQL default but allow users to choose
+	// a stronger or weaker guarantee.
+	// PostgreSQL must be reloaded when changing this value.
+	// - https://www.postgresql.org/docs/current/runtime-config-wal.html#GUC-SYNCHRONOUS-COMMIT
+	if SynchronousReplication(cluster) {
+		outParameters.Default.Add("synchronous_commit", "on")
+	}
+}
+
+// SynchronousReplication returns true when cluster asks Patroni for
+// synchronous replication.
+func SynchronousReplication(cluster *v1beta1.PostgresCluster) bool {
+	return cluster.Spec.Patroni != nil &&
+		cluster.Spec.Patroni.SyncReplication != nil &&
+		cluster.Spec.Patroni.SyncReplication.Enabled
+}
+
+// ValidateDynamicConfiguration returns an error for each setting in the Patroni
+// dynamic configuration of cluster that conflicts with a value managed by the
+// operator or that has the wrong type. DynamicConfiguration replaces conflicting
+// settings with the managed values and ignores those of the wrong type.
+func ValidateDynamicConfiguration(
+	cluster *v1beta1.PostgresCluster, pgParameters postgres.Parameters,
+) field.ErrorList {
+	var errs field.ErrorList
+
+	if cluster.Spec.Patroni == nil {
+		return errs
+	}
+
+	root := cluster.Spec.Patroni.DynamicConfiguration
+	path := field.NewPath("spec", "patroni", "dynamicConfiguration")
+
+	// These are managed by other fields of the spec.
+	type managedSetting struct {
+		key, field string
+		value      interface{}
+	}
+	value := func(p *int32) interface{} {
+		if p == nil {
+			return nil
+		}
+		return *p
+	}
+	managed := []managedSetting{
+		{"loop_wait", "syncPeriodSeconds", value(cluster.Spec.Patroni.SyncPeriodSeconds)},
+		{"ttl", "leaderLeaseDurationSeconds", value(cluster.Spec.Patroni.LeaderLeaseDurationSeconds)},
+	}
+	if sync := cluster.Spec.Patroni.SyncReplication; sync != nil {
+		managed = append(managed,
+			managedSetting{"synchronous_mode", "syncReplication.enabled", sync.Enabled},
+			managedSetting{"synchronous_mode_strict", "syncReplication.strict", sync.Enabled && sync.Strict})
+		if sync.Enabled {
+			managed = append(managed,
+				managedSetting{"synchronous_node_count", "syncReplication.nodeCount", value(sync.NodeCount)})
+		}
+	}
+	for _, setting := range managed {
+		// Patroni reads booleans and numbers much like PostgreSQL does.
+		if v, ok := root[setting.key]; ok && (setting.value == nil ||
+			!postgres.SameParameterValue(fmt.Sprint(v), fmt.Sprint(setting.value))) {
+			errs = append(errs, field.Forbidden(path.Child(setting.key),
+				"this setting is managed by spec.patroni."+setting.field))
+		}
+	}
+
+	postgresql, ok := root["postgresql"].(map[string]interface{})
+	if _, exists := root["postgresql"]; exists && !ok {
+		errs = append(errs, field.Invalid(path.Child("postgresql"),
+			root["postgresql"], "must be an object"))
+	}
+
+	parameters, ok := postgresql["parameters"].(map[string]interface{})
+	if _, exists := postgresql["parameters"]; exists && !ok {
+		errs = append(errs, field.Invalid(path.Child("postgresql", "parameters"),
+			postgresql["parameters"], "must be an object"))
+	}
+
+	if section, exists := postgresql["pg_hba"]; exists {
+		list, ok := section.([]interface{})
+		for i := 0; ok && i < len(list); i++ {
+			_, ok = list[i].(string)
+		}
+		if !ok {
+			errs = append(errs, field.Invalid(path.Child("postgresql", "pg_hba"),
+				section, "must be a list of strings"))
+		}
+	}
+
+	names := make([]string, 0, len(parameters))
+	given := postgres.NewParameterSet()
+	for k, v := range parameters {
+		// Values of shared_preload_libraries are appended to the mandatory ones.
+		if !strings.EqualFold(k, "shared_preload_libraries") {
+			names = append(names, k)
+			given.Add(k, fmt.Sprint(v))
+		}
+	}
+	sort.Strings(names)
+
+	conflicts := sets.NewString(pgParameters.Conflicts(given)...)
+	if SynchronousReplication(cluster) {
+		conflicts.Insert("synchronous_standby_names")
+	}
+	path = path.Child("postgresql", "parameters")
+	for _, k := range names {
+		if conflicts.Has(strings.ToLower(k)) {
+			errs = append(errs, field.Forbidden(path.Key(k),
+				"this parameter is managed by the operator"))
+		}
+	}
+
+	return errs
+}
+
 // DynamicConfiguration combines configuration with some PostgreSQL settings
 // and returns a value that can be marshaled to JSON.
 func DynamicConfiguration(
@@ -200,6 +399,18 @@ func DynamicConfiguration(
 	root["ttl"] = *cluster.Spec.Patroni.LeaderLeaseDurationSeconds
 	root["loop_wait"] = *cluster.Spec.Patroni.SyncPeriodSeconds
 
+	// Synchronous replication in the spec takes precedence over the settings
+	// of the same name in configuration.
+	// - https://patroni.readthedocs.io/en/latest/replication_modes.html
+	if sync := cluster.Spec.Patroni.SyncReplication; sync != nil {
+		root["synchronous_mode"] = sync.Enabled
+		root["synchronous_mode_strict"] = sync.Enabled && sync.Strict
+
+		if sync.Enabled && sync.NodeCount != nil {
+			root["synchronous_node_count"] = *sync.NodeCount
+		}
+	}
+
 	// Copy the "postgresql" section before making any changes.
 	postgresql := map[string]interface{}{
 		// TODO(cbandy): explain this. requires an archive, perhaps.
@@ -240,6 +451,10 @@ func DynamicConfiguration(
 			}
 		}
 	}
+	// Patroni decides which replicas are synchronous.
+	if SynchronousReplication(cluster) {
+		delete(parameters, "synchronous_standby_names")
+	}
 	postgresql["parameters"] = parameters
 
 	// Copy the "postgresql.pg_hba" section after any mandatory values.
@@ -312,6 +527,68 @@ func DynamicConfiguration(
 	return root
 }
 
+// ChangedParameters returns the names of PostgreSQL parameters that differ
+// between two dynamic configurations, including those set in only one of them.
+// The names are sorted.
+func ChangedParameters(before, after map[string]interface{}) []string {
+	// Numbers decoded from JSON are float64 while those in the spec are int64,
+	// and their text differs when large, e.g. "2e+08" and "200000000". Pass
+	// both sections through JSON so their values have the same types.
+	parameters := func(configuration map[string]interface{}) map[string]interface{} {
+		postgresql, _ := configuration["postgresql"].(map[string]interface{})
+		section, _ := postgresql["parameters"].(map[string]interface{})
+
+		var normalized map[string]interface{}
+		if b, err := json.Marshal(section); err == nil {
+			_ = json.Unmarshal(b, &normalized)
+		}
+		return normalized
+	}
+
+	var names []string
+	previous, current := parameters(before), parameters(after)
+	for name, value := range current {
+		if old, ok := previous[name]; !ok || !reflect.DeepEqual(old, value) {
+			names = append(names, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// RestartParameters returns the PostgreSQL parameters of the dynamic
+// configuration of cluster that take effect only when PostgreSQL starts. Each
+// is on its own line as "name = value", sorted by name. Changes to the other
+// parameters are reloaded by Patroni.
+func RestartParameters(
+	cluster *v1beta1.PostgresCluster, pgParameters postgres.Parameters,
+) string {
+	var configuration map[string]interface{}
+	if cluster.Spec.Patroni != nil {
+		configuration = cluster.Spec.Patroni.DynamicConfiguration
+	}
+	configuration = DynamicConfiguration(cluster, configuration, postgres.HBAs{}, pgParameters)
+
+	postgresql, _ := configuration["postgresql"].(map[string]interface{})
+	parameters, _ := postgresql["parameters"].(map[string]interface{})
+
+	lines := make([]string, 0, len(parameters))
+	for name, value := range parameters {
+		if postgres.ParameterRequiresRestart(name) {
+			lines = append(lines, fmt.Sprintf("%s = %v\n", name, value))
+		}
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
 // instanceEnvironment returns the environment variables needed by Patroni's
 // instance container.
 func instanceEnvironment(
@@ -446,10 +723,20 @@ func instanceConfigFiles(cluster, instance *corev1.ConfigMap) []corev1.VolumePro
 				LocalObjectReference: corev1.LocalObjectReference{
 					Name: cluster.Name,
 				},
-				Items: []corev1.KeyToPath{{
-					Key:  configMapFileKey,
-					Path: "~postgres-operator_cluster.yaml",
-				}},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  configMapFileKey,
+						Path: "~postgres-operator_cluster.yaml",
+					},
+					{
+						Key:  postgresConfigMapFileKey,
+						Path: postgresConfigPath,
+					},
+					{
+						Key:  identConfigMapFileKey,
+						Path: identConfigPath,
+					},
+				},
 			},
 		},
 		{
@@ -649,3 +936,56 @@ func probeTiming(spec *v1beta1.PatroniSpec) *corev1.Probe {
 
 	return &probe
 }
+
+// overrideProbeTiming copies the fields that are set in timing onto probe.
+func overrideProbeTiming(probe *corev1.Probe, timing *v1beta1.ProbeTiming) {
+	if timing == nil {
+		return
+	}
+	if timing.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = *timing.InitialDelaySeconds
+	}
+	if timing.TimeoutSeconds != nil {
+		probe.TimeoutSeconds = *timing.TimeoutSeconds
+	}
+	if timing.PeriodSeconds != nil {
+		probe.PeriodSeconds = *timing.PeriodSeconds
+	}
+	if timing.FailureThreshold != nil {
+		probe.FailureThreshold = *timing.FailureThreshold
+	}
+}
+
+// ValidateProbeTiming returns an error for each instance set of cluster with
+// a liveness probe that cannot fail before the Patroni leader lock expires.
+// Until the probe fails, an unresponsive Patroni leaves its PostgreSQL
+// running and accepting writes, even after a replica is promoted.
+func ValidateProbeTiming(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	if cluster.Spec.Patroni == nil ||
+		cluster.Spec.Patroni.LeaderLeaseDurationSeconds == nil ||
+		cluster.Spec.Patroni.SyncPeriodSeconds == nil {
+		return errs
+	}
+
+	lease := *cluster.Spec.Patroni.LeaderLeaseDurationSeconds
+	path := field.NewPath("spec", "instances")
+	for i := range cluster.Spec.InstanceSets {
+		timing := cluster.Spec.InstanceSets[i].LivenessProbe
+		if timing == nil {
+			continue
+		}
+
+		probe := probeTiming(cluster.Spec.Patroni)
+		overrideProbeTiming(probe, timing)
+
+		if window := probe.PeriodSeconds * probe.FailureThreshold; window > lease {
+			errs = append(errs, field.Invalid(path.Index(i).Child("livenessProbe"), window,
+				fmt.Sprintf("periodSeconds times failureThreshold should be at most "+
+					"spec.patroni.leaderLeaseDurationSeconds, %d", lease)))
+		}
+	}
+
+	return errs
+}
@@ -151,6 +151,127 @@ watchdog:
   mode: "off"
 	`)+"\n")
 	})
+
+	t.Run("AdditionalConfigFiles", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Default()
+
+		data, err := clusterYAML(cluster, postgres.HBAs{}, postgres.Parameters{})
+		assert.NilError(t, err)
+		assert.Assert(t, !strings.Contains(data, "custom_conf"))
+
+		cluster.Spec.Config.Files = []corev1.VolumeProjection{{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "my-settings"},
+			},
+		}}
+
+		data, err = clusterYAML(cluster, postgres.HBAs{}, postgres.Parameters{})
+		assert.NilError(t, err)
+
+		var parsed struct {
+			PostgreSQL struct {
+				CustomConf string `json:"custom_conf"`
+			} `json:"postgresql"`
+		}
+		assert.NilError(t, yaml.Unmarshal([]byte(data), &parsed))
+		assert.Equal(t, parsed.PostgreSQL.CustomConf,
+			"/etc/patroni/~postgres-operator/postgresql.conf")
+	})
+}
+
+func TestPostgresConfig(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 14
+
+	assert.Equal(t, postgresConfig(cluster), strings.TrimSpace(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+include_if_exists '/pgdata/pg14/postgresql.base.conf'
+include_dir '/etc/postgres'
+	`)+"\n")
+
+	assert.Equal(t, quoteConfigValue(`it's`), `'it''s'`)
+}
+
+func TestIdentFile(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	idents := postgres.Idents{
+		Mandatory: []postgres.UserNameMap{
+			*postgres.NewIdent("operator").SystemUser("postgres").DatabaseUser("postgres"),
+		},
+		Default: []postgres.UserNameMap{
+			*postgres.NewIdent("certs").SystemUser("alice").DatabaseUser("app"),
+		},
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, identFile(cluster, postgres.Idents{}), strings.TrimSpace(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+		`)+"\n")
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		assert.Equal(t, identFile(cluster, idents), strings.TrimSpace(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+"operator" "postgres" "postgres"
+"certs" "alice" "app"
+		`)+"\n")
+	})
+
+	t.Run("DynamicConfiguration", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+			DynamicConfiguration: map[string]interface{}{
+				"postgresql": map[string]interface{}{
+					"pg_ident": []interface{}{"custom root postgres", 5},
+				},
+			},
+		}
+
+		// The section replaces the defaults; values that are not strings are skipped.
+		assert.Equal(t, identFile(cluster, idents), strings.TrimSpace(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+"operator" "postgres" "postgres"
+custom root postgres
+		`)+"\n")
+	})
+}
+
+func TestPostgreSQL(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	parameters := postgres.NewParameters()
+	PostgreSQL(cluster, &parameters)
+
+	value, ok := parameters.Mandatory.Get("ident_file")
+	assert.Assert(t, ok)
+	assert.Equal(t, value, "/etc/patroni/~postgres-operator/pg_ident.conf")
+
+	_, ok = parameters.Default.Get("synchronous_commit")
+	assert.Assert(t, !ok)
+
+	t.Run("SyncReplication", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+			SyncReplication: &v1beta1.PatroniSyncReplication{Enabled: true},
+		}
+
+		parameters := postgres.NewParameters()
+		PostgreSQL(cluster, &parameters)
+
+		value, ok := parameters.Default.Get("synchronous_commit")
+		assert.Assert(t, ok)
+		assert.Equal(t, value, "on")
+	})
 }
 
 func TestDynamicConfiguration(t *testing.T) {
@@ -707,6 +828,99 @@ func TestDynamicConfiguration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "synchronous: input passes through",
+			input: map[string]interface{}{
+				"synchronous_mode": true,
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"synchronous_standby_names": "input",
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait":        int32(10),
+				"ttl":              int32(30),
+				"synchronous_mode": true,
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"synchronous_standby_names": "input",
+					},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "synchronous: spec overrides input",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						SyncReplication: &v1beta1.PatroniSyncReplication{
+							Enabled:   true,
+							NodeCount: newInt32(2),
+							Strict:    true,
+						},
+					},
+				},
+			},
+			input: map[string]interface{}{
+				"synchronous_mode":        false,
+				"synchronous_node_count":  5,
+				"synchronous_mode_strict": false,
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"synchronous_commit":        "remote_apply",
+						"synchronous_standby_names": "input",
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        true,
+				"synchronous_mode_strict": true,
+				"synchronous_node_count":  int32(2),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"synchronous_commit": "remote_apply",
+					},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "synchronous: spec disables",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						SyncReplication: &v1beta1.PatroniSyncReplication{
+							Enabled: false,
+							Strict:  true,
+						},
+					},
+				},
+			},
+			input: map[string]interface{}{
+				"synchronous_mode":        true,
+				"synchronous_mode_strict": true,
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        false,
+				"synchronous_mode_strict": false,
+				"postgresql": map[string]interface{}{
+					"parameters":    map[string]interface{}{},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			cluster := tt.cluster
@@ -723,6 +937,263 @@ func TestDynamicConfiguration(t *testing.T) {
 	}
 }
 
+func TestChangedParameters(t *testing.T) {
+	parameters := func(values map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"postgresql": map[string]interface{}{"parameters": values},
+		}
+	}
+
+	assert.Assert(t, ChangedParameters(nil, nil) == nil)
+	assert.Assert(t, ChangedParameters(
+		map[string]interface{}{"ttl": 30}, map[string]interface{}{"ttl": 15}) == nil,
+		"expected only PostgreSQL parameters")
+
+	// Values from JSON compare equal to those from the spec.
+	assert.Assert(t, ChangedParameters(
+		parameters(map[string]interface{}{"max_connections": float64(100), "jit": "off"}),
+		parameters(map[string]interface{}{"max_connections": int64(100), "jit": "off"}),
+	) == nil)
+
+	// Large numbers from JSON compare equal to those from the spec.
+	assert.Assert(t, ChangedParameters(
+		parameters(map[string]interface{}{
+			"autovacuum_freeze_max_age": float64(200000000), "max_wal_size": float64(1e6),
+		}),
+		parameters(map[string]interface{}{
+			"autovacuum_freeze_max_age": int64(200000000), "max_wal_size": int64(1000000),
+		}),
+	) == nil)
+
+	assert.DeepEqual(t, ChangedParameters(
+		parameters(map[string]interface{}{"work_mem": "4MB", "jit": "off", "port": 5432}),
+		parameters(map[string]interface{}{"work_mem": "8MB", "jit": "off", "wal_level": "logical"}),
+	), []string{"port", "wal_level", "work_mem"})
+}
+
+func TestRestartParameters(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+
+	parameters := postgres.Parameters{
+		Mandatory: postgres.NewParameterSet(),
+		Default:   postgres.NewParameterSet(),
+	}
+	parameters.Mandatory.Add("wal_level", "logical")
+	parameters.Default.Add("jit", "off")
+
+	// Only parameters that require a restart are included.
+	before := RestartParameters(cluster, parameters)
+	assert.Equal(t, before, "wal_level = logical\n")
+
+	// Identical configuration has identical parameters.
+	assert.Equal(t, RestartParameters(cluster.DeepCopy(), parameters), before)
+
+	t.Run("Reloadable", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{"work_mem": "8MB"},
+				"pg_hba":     []interface{}{"host all all all md5"},
+			},
+		}
+
+		assert.Equal(t, RestartParameters(cluster, parameters), before)
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"max_connections": 200,
+					"work_mem":        "8MB",
+				},
+			},
+		}
+
+		assert.Equal(t, RestartParameters(cluster, parameters),
+			"max_connections = 200\nwal_level = logical\n")
+	})
+}
+
+func TestValidateDynamicConfiguration(t *testing.T) {
+	parameters := postgres.Parameters{
+		Mandatory: postgres.NewParameterSet(),
+		Default:   postgres.NewParameterSet(),
+	}
+	parameters.Mandatory.Add("wal_level", "logical")
+	parameters.Mandatory.Add("shared_preload_libraries", "mandatory")
+	parameters.Default.Add("jit", "off")
+
+	cluster := new(v1beta1.PostgresCluster)
+	assert.Assert(t, len(ValidateDynamicConfiguration(cluster, parameters)) == 0)
+
+	cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+		DynamicConfiguration: map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"jit":                      "on",
+					"shared_buffers":           "1GB",
+					"shared_preload_libraries": "given",
+				},
+			},
+		},
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		assert.Assert(t, len(ValidateDynamicConfiguration(cluster, parameters)) == 0)
+	})
+
+	t.Run("SameValue", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{"wal_level": "logical"},
+			},
+		}
+		assert.Assert(t, len(ValidateDynamicConfiguration(cluster, parameters)) == 0)
+
+		// Booleans and numbers are compared by value rather than spelling.
+		parameters := postgres.Parameters{Mandatory: postgres.NewParameterSet()}
+		parameters.Mandatory.Add("ssl", "on")
+		parameters.Mandatory.Add("max_wal_senders", "10")
+
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"loop_wait": "10",
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"ssl": true, "max_wal_senders": float64(10),
+				},
+			},
+			"synchronous_mode": "off",
+		}
+		cluster.Spec.Patroni.SyncReplication = &v1beta1.PatroniSyncReplication{}
+		cluster.Default()
+		assert.Assert(t, len(ValidateDynamicConfiguration(cluster, parameters)) == 0)
+
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"ssl": "false", "max_wal_senders": "1e1",
+				},
+			},
+		}
+		errs := ValidateDynamicConfiguration(cluster, parameters)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field,
+			"spec.patroni.dynamicConfiguration.postgresql.parameters[ssl]")
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"jit":                      "on",
+					"shared_buffers":           "1GB",
+					"shared_preload_libraries": "given",
+					"wal_level":                "minimal",
+				},
+			},
+		}
+
+		errs := ValidateDynamicConfiguration(cluster, parameters)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field,
+			"spec.patroni.dynamicConfiguration.postgresql.parameters[wal_level]")
+
+		// The mandatory value is still used.
+		cluster.Default()
+		actual := DynamicConfiguration(cluster,
+			cluster.Spec.Patroni.DynamicConfiguration, postgres.HBAs{}, parameters)
+		assert.DeepEqual(t,
+			actual["postgresql"].(map[string]interface{})["parameters"],
+			map[string]interface{}{
+				"jit":                      "on",
+				"shared_buffers":           "1GB",
+				"shared_preload_libraries": "mandatory,given",
+				"wal_level":                "logical",
+			})
+	})
+
+	t.Run("ManagedSettings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"loop_wait":               float64(10),
+			"maximum_lag_on_failover": float64(1048576),
+			"retry_timeout":           float64(5),
+			"ttl":                     float64(60),
+		}
+		cluster.Default()
+
+		// Only the setting that differs from the spec is rejected.
+		errs := ValidateDynamicConfiguration(cluster, parameters)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field, "spec.patroni.dynamicConfiguration.ttl")
+		assert.Assert(t, cmp.Contains(errs[0].Detail, "leaderLeaseDurationSeconds"))
+
+		// The value from the spec is still used, and the others pass through.
+		actual := DynamicConfiguration(cluster,
+			cluster.Spec.Patroni.DynamicConfiguration, postgres.HBAs{}, parameters)
+		assert.Equal(t, actual["ttl"], int32(30))
+		assert.Equal(t, actual["maximum_lag_on_failover"], float64(1048576))
+		assert.Equal(t, actual["retry_timeout"], float64(5))
+	})
+
+	t.Run("WrongTypes", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": "nope",
+		}
+		errs := ValidateDynamicConfiguration(cluster, parameters)
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, errs[0].Field, "spec.patroni.dynamicConfiguration.postgresql")
+
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"parameters": []interface{}{"jit"},
+				"pg_hba":     []interface{}{"local all all trust", true},
+			},
+		}
+		errs = ValidateDynamicConfiguration(cluster, parameters)
+		assert.Equal(t, len(errs), 2)
+		assert.Equal(t, errs[0].Field, "spec.patroni.dynamicConfiguration.postgresql.parameters")
+		assert.Equal(t, errs[1].Field, "spec.patroni.dynamicConfiguration.postgresql.pg_hba")
+
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"pg_hba": []interface{}{"local all all trust"},
+			},
+		}
+		assert.Assert(t, len(ValidateDynamicConfiguration(cluster, parameters)) == 0)
+	})
+
+	t.Run("SyncReplication", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni.SyncReplication = &v1beta1.PatroniSyncReplication{Enabled: true}
+		cluster.Spec.Patroni.DynamicConfiguration = map[string]interface{}{
+			"synchronous_mode":        true,
+			"synchronous_mode_strict": true,
+			"synchronous_node_count":  float64(1),
+			"postgresql": map[string]interface{}{
+				"parameters": map[string]interface{}{
+					"synchronous_commit":        "remote_apply",
+					"synchronous_standby_names": "*",
+				},
+			},
+		}
+		cluster.Default()
+
+		errs := ValidateDynamicConfiguration(cluster, parameters)
+		assert.Equal(t, len(errs), 2)
+		assert.Equal(t, errs[0].Field, "spec.patroni.dynamicConfiguration.synchronous_mode_strict")
+		assert.Equal(t, errs[1].Field,
+			"spec.patroni.dynamicConfiguration.postgresql.parameters[synchronous_standby_names]")
+	})
+}
+
 func TestInstanceConfigFiles(t *testing.T) {
 	t.Parallel()
 
@@ -736,6 +1207,10 @@ func TestInstanceConfigFiles(t *testing.T) {
     items:
     - key: patroni.yaml
       path: ~postgres-operator_cluster.yaml
+    - key: postgresql.conf
+      path: ~postgres-operator/postgresql.conf
+    - key: pg_ident.conf
+      path: ~postgres-operator/pg_ident.conf
     name: cm1
 - configMap:
     items:
@@ -887,6 +1362,30 @@ postgresql:
   pgpass: /tmp/.pgpass
   use_unix_socket: true
 restapi: {}
+tags: {}
+	`, "\t\n")+"\n")
+
+	instance.WALVolumeClaimSpec = new(corev1.PersistentVolumeClaimSpec)
+	dataWithWALVolume, err := instanceYAML(cluster, instance, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, dataWithWALVolume, strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+bootstrap:
+  initdb:
+  - data-checksums
+  - encoding=UTF8
+  - waldir=/pgwal/pg12_wal
+  method: initdb
+kubernetes: {}
+postgresql:
+  basebackup:
+  - waldir=/pgwal/pg12_wal
+  create_replica_methods:
+  - basebackup
+  pgpass: /tmp/.pgpass
+  use_unix_socket: true
+restapi: {}
 tags: {}
 	`, "\t\n")+"\n")
 }
@@ -1032,3 +1531,27 @@ func TestProbeTiming(t *testing.T) {
 		assert.Assert(t, actual.FailureThreshold >= 1) // Minimum value is 1.
 	}
 }
+
+func TestValidateProbeTiming(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.Patroni = new(v1beta1.PatroniSpec)
+	cluster.Spec.Patroni.Default()
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "00"}, {Name: "01"}}
+	assert.Assert(t, len(ValidateProbeTiming(cluster)) == 0)
+
+	// The default lease is 30 seconds; the default period is 10 seconds.
+	cluster.Spec.InstanceSets[0].LivenessProbe = &v1beta1.ProbeTiming{
+		FailureThreshold: initialize.Int32(3),
+	}
+	cluster.Spec.InstanceSets[1].LivenessProbe = &v1beta1.ProbeTiming{
+		InitialDelaySeconds: initialize.Int32(60),
+	}
+	assert.Assert(t, len(ValidateProbeTiming(cluster)) == 0)
+
+	cluster.Spec.InstanceSets[1].LivenessProbe.FailureThreshold = initialize.Int32(12)
+	errs := ValidateProbeTiming(cluster)
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, errs[0].Field, "spec.instances[1].livenessProbe")
+	assert.ErrorContains(t, errs[0], ": 120: ")
+	assert.ErrorContains(t, errs[0], "leaderLeaseDurationSeconds, 30")
+}
@@ -41,6 +41,7 @@ func ClusterBootstrapped(postgresCluster *v1beta1.PostgresCluster) bool {
 func ClusterConfigMap(ctx context.Context,
 	inCluster *v1beta1.PostgresCluster,
 	inHBAs postgres.HBAs,
+	inIdents postgres.Idents,
 	inParameters postgres.Parameters,
 	outClusterConfigMap *corev1.ConfigMap,
 ) error {
@@ -50,6 +51,8 @@ func ClusterConfigMap(ctx context.Context,
 
 	outClusterConfigMap.Data[configMapFileKey], err = clusterYAML(inCluster, inHBAs,
 		inParameters)
+	outClusterConfigMap.Data[postgresConfigMapFileKey] = postgresConfig(inCluster)
+	outClusterConfigMap.Data[identConfigMapFileKey] = identFile(inCluster, inIdents)
 
 	return err
 }
@@ -133,19 +136,30 @@ func InstancePod(ctx context.Context,
 
 	outInstancePod.Spec.Volumes = append(outInstancePod.Spec.Volumes, volume)
 
-	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+	mount := corev1.VolumeMount{
 		Name:      volume.Name,
 		MountPath: configDirectory,
 		ReadOnly:  true,
-	})
+	}
+	container.VolumeMounts = append(container.VolumeMounts, mount)
+
+	// PostgreSQL reads the "ident_file" during reload, but Patroni does not
+	// reload when that file changes. Kubernetes updates the file some time
+	// after the ConfigMap changes, so reload when the file itself changes.
+	postgres.AddReloadDirectory(&outInstancePod.Spec, mount)
 
-	instanceProbes(inCluster, container)
+	instanceProbes(inCluster, inInstanceSpec, container)
 
 	return nil
 }
 
 // instanceProbes adds Patroni liveness and readiness probes to container.
-func instanceProbes(cluster *v1beta1.PostgresCluster, container *corev1.Container) {
+// The timing of each probe comes from the Patroni spec, then any timing in
+// the instance set spec.
+func instanceProbes(
+	cluster *v1beta1.PostgresCluster, instance *v1beta1.PostgresInstanceSetSpec,
+	container *corev1.Container,
+) {
 
 	// Patroni uses a watchdog to ensure that PostgreSQL does not accept commits
 	// after the leader lock expires, even if Patroni becomes unresponsive.
@@ -166,6 +180,7 @@ func instanceProbes(cluster *v1beta1.PostgresCluster, container *corev1.Containe
 		Port:   intstr.FromInt(int(*cluster.Spec.Patroni.Port)),
 		Scheme: corev1.URISchemeHTTPS,
 	}
+	overrideProbeTiming(container.LivenessProbe, instance.LivenessProbe)
 
 	// Readiness is reflected in the controlling object's status (e.g. ReadyReplicas)
 	// and allows our controller to react when Patroni bootstrap completes.
@@ -179,6 +194,7 @@ func instanceProbes(cluster *v1beta1.PostgresCluster, container *corev1.Containe
 		Port:   intstr.FromInt(int(*cluster.Spec.Patroni.Port)),
 		Scheme: corev1.URISchemeHTTPS,
 	}
+	overrideProbeTiming(container.ReadinessProbe, instance.ReadinessProbe)
 }
 
 // PodIsStandbyLeader returns whether or not pod is currently acting as a "standby_leader".
@@ -197,11 +213,15 @@ func PodIsStandbyLeader(pod metav1.Object) bool {
 }
 
 // PodRequiresRestart returns whether or not PostgreSQL inside pod has (pending)
-// parameter changes that require a PostgreSQL restart.
+// parameter changes that require a PostgreSQL restart. This is true when
+// Patroni reports it or when pod has the [naming.PatroniPendingRestart] annotation.
 func PodRequiresRestart(pod metav1.Object) bool {
 	if pod == nil {
 		return false
 	}
+	if _, ok := pod.GetAnnotations()[naming.PatroniPendingRestart]; ok {
+		return true
+	}
 
 	// TODO(cbandy): This works only when using Kubernetes for DCS.
 
@@ -17,12 +17,16 @@ package patroni
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"testing"
 
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
@@ -36,19 +40,26 @@ func TestClusterConfigMap(t *testing.T) {
 
 	cluster := new(v1beta1.PostgresCluster)
 	pgHBAs := postgres.HBAs{}
+	pgIdents := postgres.Idents{}
 	pgParameters := postgres.Parameters{}
 
 	cluster.Default()
 	config := new(corev1.ConfigMap)
-	assert.NilError(t, ClusterConfigMap(ctx, cluster, pgHBAs, pgParameters, config))
+	assert.NilError(t, ClusterConfigMap(ctx, cluster, pgHBAs, pgIdents, pgParameters, config))
 
 	// The output of clusterYAML should go into config.
 	data, _ := clusterYAML(cluster, pgHBAs, pgParameters)
 	assert.DeepEqual(t, config.Data["patroni.yaml"], data)
 
+	// The output of postgresConfig should go into config.
+	assert.Equal(t, config.Data["postgresql.conf"], postgresConfig(cluster))
+
+	// The output of identFile should go into config.
+	assert.Equal(t, config.Data["pg_ident.conf"], identFile(cluster, pgIdents))
+
 	// No change when called again.
 	before := config.DeepCopy()
-	assert.NilError(t, ClusterConfigMap(ctx, cluster, pgHBAs, pgParameters, config))
+	assert.NilError(t, ClusterConfigMap(ctx, cluster, pgHBAs, pgIdents, pgParameters, config))
 	assert.DeepEqual(t, config, before)
 }
 
@@ -100,6 +111,26 @@ func TestReconcileInstanceCertificates(t *testing.T) {
 	assert.NilError(t, InstanceCertificates(ctx,
 		root.Certificate, leaf.Certificate, leaf.PrivateKey, secret))
 	assert.DeepEqual(t, secret, before)
+
+	t.Run("ClientVerifiesServer", func(t *testing.T) {
+		// Patroni serves its REST API with "restapi.certfile", and patronictl
+		// verifies it with "ctl.cacert". Both come from this Secret.
+		pair, err := tls.X509KeyPair(
+			secret.Data["patroni.crt-combined"], secret.Data["patroni.crt-combined"])
+		assert.NilError(t, err)
+
+		server, err := x509.ParseCertificate(pair.Certificate[0])
+		assert.NilError(t, err)
+
+		roots := x509.NewCertPool()
+		assert.Assert(t, roots.AppendCertsFromPEM(secret.Data["patroni.ca-roots"]))
+
+		_, err = server.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		assert.NilError(t, err)
+	})
 }
 
 func TestInstanceConfigMap(t *testing.T) {
@@ -218,6 +249,10 @@ volumes:
         items:
         - key: patroni.yaml
           path: ~postgres-operator_cluster.yaml
+        - key: postgresql.conf
+          path: ~postgres-operator/postgresql.conf
+        - key: pg_ident.conf
+          path: ~postgres-operator/pg_ident.conf
     - configMap:
         items:
         - key: patroni.yaml
@@ -229,6 +264,97 @@ volumes:
         - key: patroni.crt-combined
           path: ~postgres-operator/patroni.crt+key
 	`))
+
+	t.Run("ReloadIdentFile", func(t *testing.T) {
+		template := new(corev1.PodTemplateSpec)
+		template.Spec.Containers = []corev1.Container{
+			{Name: "database"},
+			{Name: naming.ContainerClientCertCopy, Command: []string{"reload"}},
+		}
+
+		assert.NilError(t, InstancePod(context.Background(),
+			cluster, clusterConfigMap, clusterPodService, patroniLeaderService,
+			instanceSpec, instanceCertficates, instanceConfigMap, template))
+
+		// PostgreSQL reloads when pg_ident.conf changes.
+		reloader := template.Spec.Containers[1]
+		assert.DeepEqual(t, reloader.Command, []string{"reload", "/etc/patroni"})
+		assert.DeepEqual(t, reloader.VolumeMounts, []corev1.VolumeMount{{
+			Name: "patroni-config", MountPath: "/etc/patroni", ReadOnly: true,
+		}})
+	})
+}
+
+func TestInstanceProbes(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	instance := new(v1beta1.PostgresInstanceSetSpec)
+
+	t.Run("Defaults", func(t *testing.T) {
+		container := new(corev1.Container)
+		instanceProbes(cluster, instance, container)
+
+		assert.DeepEqual(t, container.LivenessProbe, &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/liveness", Port: intstr.FromInt(8008), Scheme: corev1.URISchemeHTTPS,
+			}},
+			InitialDelaySeconds: 3,
+			TimeoutSeconds:      5,
+			PeriodSeconds:       10,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		})
+		assert.DeepEqual(t, container.ReadinessProbe, &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/readiness", Port: intstr.FromInt(8008), Scheme: corev1.URISchemeHTTPS,
+			}},
+			InitialDelaySeconds: 3,
+			TimeoutSeconds:      5,
+			PeriodSeconds:       10,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		})
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		instance := instance.DeepCopy()
+		instance.LivenessProbe = &v1beta1.ProbeTiming{
+			InitialDelaySeconds: initialize.Int32(60),
+			TimeoutSeconds:      initialize.Int32(20),
+			FailureThreshold:    initialize.Int32(12),
+		}
+		instance.ReadinessProbe = &v1beta1.ProbeTiming{
+			PeriodSeconds: initialize.Int32(5),
+		}
+
+		container := new(corev1.Container)
+		instanceProbes(cluster, instance, container)
+
+		// The handlers stay the same while the timing changes. Fields that
+		// are unset keep their defaults.
+		assert.DeepEqual(t, container.LivenessProbe, &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/liveness", Port: intstr.FromInt(8008), Scheme: corev1.URISchemeHTTPS,
+			}},
+			InitialDelaySeconds: 60,
+			TimeoutSeconds:      20,
+			PeriodSeconds:       10,
+			SuccessThreshold:    1,
+			FailureThreshold:    12,
+		})
+		assert.DeepEqual(t, container.ReadinessProbe, &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/readiness", Port: intstr.FromInt(8008), Scheme: corev1.URISchemeHTTPS,
+			}},
+			InitialDelaySeconds: 3,
+			TimeoutSeconds:      5,
+			PeriodSeconds:       5,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		})
+	})
 }
 
 func TestPodIsStandbyLeader(t *testing.T) {
@@ -275,4 +401,11 @@ func TestPodRequiresRestart(t *testing.T) {
 	// Expected value
 	pod.Annotations["status"] = `{"pending_restart":true}`
 	assert.Assert(t, PodRequiresRestart(pod))
+
+	// Requested by the operator
+	pod.Annotations = map[string]string{
+		"status":                     `{}`,
+		naming.PatroniPendingRestart: "shared_buffers",
+	}
+	assert.Assert(t, PodRequiresRestart(pod))
 }
@@ -25,6 +25,7 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -45,7 +46,7 @@ func WriteUsersInPGAdmin(
 	args := []string{
 		cluster.Name,
 		primary.Name + "." + primary.Namespace + ".svc",
-		fmt.Sprint(*cluster.Spec.Port),
+		fmt.Sprint(postgres.ServicePort(cluster)),
 	}
 	script := strings.Join([]string{
 		// Unpack arguments into an object.
@@ -281,6 +281,9 @@ func populatePGInstanceConfigurationMap(
 				global.Set(option, val)
 			}
 		}
+		for option, val := range getRepoRetentionConfigs(repo) {
+			global.Set(option, val)
+		}
 
 		// Only "volume" (i.e. PVC-based) repos should ever have a repo host configured.  This
 		// means cloud-based repos (S3, GCS or Azure) should not have a repo host configured.
@@ -333,6 +336,9 @@ func populateRepoHostConfigurationMap(
 				global.Set(option, val)
 			}
 		}
+		for option, val := range getRepoRetentionConfigs(repo) {
+			global.Set(option, val)
+		}
 
 		if !pgBackRestLogPathSet && repo.Volume != nil {
 			// pgBackRest will log to the first configured repo volume when commands
@@ -394,6 +400,24 @@ func getExternalRepoConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
 	return repoConfigs
 }
 
+// getRepoRetentionConfigs returns a map containing the retention settings for a pgBackRest
+// repository as defined in the PostgresCluster spec
+func getRepoRetentionConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
+
+	repoConfigs := make(map[string]string)
+
+	if repo.Retention != nil {
+		if repo.Retention.Full != nil {
+			repoConfigs[repo.Name+"-retention-full"] = fmt.Sprint(*repo.Retention.Full)
+		}
+		if repo.Retention.Differential != nil {
+			repoConfigs[repo.Name+"-retention-diff"] = fmt.Sprint(*repo.Retention.Differential)
+		}
+	}
+
+	return repoConfigs
+}
+
 // reloadCommand returns an entrypoint that convinces the pgBackRest TLS server
 // to reload its options and certificate files when they change. The process
 // will appear as name in `ps` and `top`.
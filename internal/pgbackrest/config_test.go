@@ -159,6 +159,48 @@ pg1-socket-path = /tmp/postgres
 		`, "\t\n")+"\n")
 	})
 
+	t.Run("Retention", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+			"repo2-retention-diff": "3",
+		}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name:   "repo1",
+				Volume: &v1beta1.RepoPVC{},
+				Retention: &v1beta1.PGBackRestRetention{
+					Full:         initialize.Int32(2),
+					Differential: initialize.Int32(4),
+				},
+			},
+			{
+				Name: "repo2",
+				S3: &v1beta1.RepoS3{
+					Bucket: "s-bucket", Endpoint: "endpoint-s", Region: "earth",
+				},
+				Retention: &v1beta1.PGBackRestRetention{
+					Full:         initialize.Int32(7),
+					Differential: initialize.Int32(14),
+				},
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(cluster,
+			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		for _, key := range []string{"pgbackrest_instance.conf", "pgbackrest_repo.conf"} {
+			config := configmap.Data[key]
+
+			assert.Assert(t, cmp.Contains(config, "\nrepo1-retention-diff = 4\n"), "%s", key)
+			assert.Assert(t, cmp.Contains(config, "\nrepo1-retention-full = 2\n"), "%s", key)
+			assert.Assert(t, cmp.Contains(config, "\nrepo2-retention-full = 7\n"), "%s", key)
+
+			// Global settings take precedence.
+			assert.Assert(t, cmp.Contains(config, "\nrepo2-retention-diff = 3\n"), "%s", key)
+		}
+	})
+
 	t.Run("CustomMetadata", func(t *testing.T) {
 		cluster := cluster.DeepCopy()
 		cluster.Spec.Metadata = &v1beta1.Metadata{
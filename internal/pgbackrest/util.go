@@ -22,6 +22,7 @@ import (
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -41,6 +42,57 @@ func DedicatedRepoHostEnabled(postgresCluster *v1beta1.PostgresCluster) bool {
 	return false
 }
 
+// ValidateRepos returns any problems with the pgBackRest repositories in the PostgresCluster spec
+// that would prevent pgBackRest from using them, such as a repository with more than one type or
+// an S3 repository without a bucket.
+// Credentials for external repositories belong in Secrets projected through the pgBackRest
+// "configuration" field, so they are not checked here.
+func ValidateRepos(postgresCluster *v1beta1.PostgresCluster) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec", "backups", "pgbackrest", "repos")
+
+	for i, repo := range postgresCluster.Spec.Backups.PGBackRest.Repos {
+		types := 0
+		for _, specified := range []bool{
+			repo.Azure != nil, repo.GCS != nil, repo.S3 != nil, repo.Volume != nil,
+		} {
+			if specified {
+				types++
+			}
+		}
+		switch {
+		case types == 0:
+			errs = append(errs, field.Required(path.Index(i),
+				"one of azure, gcs, s3, or volume is required"))
+		case types > 1:
+			errs = append(errs, field.Forbidden(path.Index(i),
+				"only one of azure, gcs, s3, or volume may be specified"))
+		}
+
+		if repo.Azure != nil && repo.Azure.Container == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("azure", "container"), ""))
+		}
+		if repo.GCS != nil && repo.GCS.Bucket == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("gcs", "bucket"), ""))
+		}
+		if repo.S3 != nil {
+			s3 := path.Index(i).Child("s3")
+
+			if repo.S3.Bucket == "" {
+				errs = append(errs, field.Required(s3.Child("bucket"), ""))
+			}
+			if repo.S3.Endpoint == "" {
+				errs = append(errs, field.Required(s3.Child("endpoint"), ""))
+			}
+			if repo.S3.Region == "" {
+				errs = append(errs, field.Required(s3.Child("region"), ""))
+			}
+		}
+	}
+
+	return errs
+}
+
 // CalculateConfigHashes calculates hashes for any external pgBackRest repository configuration
 // present in the PostgresCluster spec (e.g. configuration for Azure, GCR and/or S3 repositories).
 // Additionally it returns a hash of the hashes for each external repository.
@@ -132,3 +132,53 @@ func TestCalculateConfigHashes(t *testing.T) {
 		assert.Assert(t, hashMap[repo] != configHashMap[repo])
 	}
 }
+
+func TestValidateRepos(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	assert.Assert(t, len(ValidateRepos(cluster)) == 0)
+
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1", Volume: &v1beta1.RepoPVC{}},
+		{Name: "repo2", S3: &v1beta1.RepoS3{
+			Bucket: "bucket", Endpoint: "s3.example.com", Region: "earth",
+		}},
+	}
+	assert.Assert(t, len(ValidateRepos(cluster)) == 0)
+
+	cluster.Spec.Backups.PGBackRest.Repos[1].S3 = &v1beta1.RepoS3{Bucket: "bucket"}
+
+	errs := ValidateRepos(cluster)
+	assert.Equal(t, errs.ToAggregate().Error(), ""+
+		"[spec.backups.pgbackrest.repos[1].s3.endpoint: Required value, "+
+		"spec.backups.pgbackrest.repos[1].s3.region: Required value]")
+
+	t.Run("Types", func(t *testing.T) {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{Name: "repo1", Azure: &v1beta1.RepoAzure{Container: "container"}},
+			{Name: "repo2", GCS: &v1beta1.RepoGCS{Bucket: "bucket"}},
+			{Name: "repo3", S3: &v1beta1.RepoS3{
+				Bucket: "bucket", Endpoint: "s3.example.com", Region: "earth",
+			}},
+			{Name: "repo4", Volume: &v1beta1.RepoPVC{}},
+		}
+		assert.Assert(t, len(ValidateRepos(cluster)) == 0)
+
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{Name: "repo1"},
+			{Name: "repo2",
+				GCS:    &v1beta1.RepoGCS{Bucket: "bucket"},
+				Volume: &v1beta1.RepoPVC{},
+			},
+			{Name: "repo3", Azure: &v1beta1.RepoAzure{}},
+			{Name: "repo4", GCS: &v1beta1.RepoGCS{}},
+		}
+
+		errs := ValidateRepos(cluster)
+		assert.Equal(t, errs.ToAggregate().Error(), ""+
+			"[spec.backups.pgbackrest.repos[0]: Required value: one of azure, gcs, s3, or volume is required, "+
+			"spec.backups.pgbackrest.repos[1]: Forbidden: only one of azure, gcs, s3, or volume may be specified, "+
+			"spec.backups.pgbackrest.repos[2].azure.container: Required value, "+
+			"spec.backups.pgbackrest.repos[3].gcs.bucket: Required value]")
+	})
+}
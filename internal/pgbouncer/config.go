@@ -23,28 +23,39 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 const (
 	configDirectory = "/etc/pgbouncer"
 
+	// peersDirectory contains the configuration and Unix socket of each
+	// PgBouncer process when there are peers.
+	peersDirectory = "/tmp/pgbouncer-peers"
+
 	authFileAbsolutePath  = configDirectory + "/" + authFileProjectionPath
 	emptyFileAbsolutePath = configDirectory + "/" + emptyFileProjectionPath
+	hbaFileAbsolutePath   = configDirectory + "/" + hbaFileProjectionPath
 	iniFileAbsolutePath   = configDirectory + "/" + iniFileProjectionPath
 
 	authFileProjectionPath  = "~postgres-operator/users.txt"
 	emptyFileProjectionPath = "pgbouncer.ini"
+	hbaFileProjectionPath   = "~postgres-operator/hba.conf"
 	iniFileProjectionPath   = "~postgres-operator.ini"
 
 	authFileSecretKey   = "pgbouncer-users.txt" // #nosec G101 this is a name, not a credential
 	passwordSecretKey   = "pgbouncer-password"  // #nosec G101 this is a name, not a credential
 	verifierSecretKey   = "pgbouncer-verifier"  // #nosec G101 this is a name, not a credential
 	emptyConfigMapKey   = "pgbouncer-empty"
+	hbaFileConfigMapKey = "pgbouncer-hba.conf"
 	iniFileConfigMapKey = "pgbouncer.ini"
 )
 
 const (
+	// adminDatabase is the name of the PgBouncer admin console.
+	adminDatabase = "pgbouncer"
+
 	iniGeneratedWarning = "" +
 		"# Generated by postgres-operator. DO NOT EDIT.\n" +
 		"# Your changes will not be saved.\n"
@@ -71,37 +82,66 @@ func (vs iniValueSet) String() string {
 	return b.String()
 }
 
-// authFileContents returns a PgBouncer user database.
-func authFileContents(password string) []byte {
+// iniBool returns the PgBouncer representation of b.
+func iniBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// appendListValue returns the comma-separated list in value with item appended
+// when it is not already present.
+func appendListValue(value, item string) string {
+	for _, v := range strings.Split(value, ",") {
+		if strings.TrimSpace(v) == item {
+			return value
+		}
+	}
+	if len(strings.TrimSpace(value)) == 0 {
+		return item
+	}
+	return value + "," + item
+}
+
+// quote surrounds s with double quotes and escapes any double quotes within it.
+func quote(s string) string {
 	// > There should be at least 2 fields, surrounded by double quotes.
 	// > Double quotes in a field value can be escaped by writing two double quotes.
 	// - https://www.pgbouncer.org/config.html#authentication-file-format
-	quote := func(s string) string {
-		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
-	}
-
-	user1 := quote(postgresqlUser) + " " + quote(password) + "\n"
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
 
-	return []byte(user1)
+// quoteList returns a comma-separated list of quoted values.
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i := range values {
+		quoted[i] = quote(values[i])
+	}
+	return strings.Join(quoted, ",")
 }
 
-func clusterINI(cluster *v1beta1.PostgresCluster) string {
-	var (
-		pgBouncerPort = *cluster.Spec.Proxy.PGBouncer.Port
-		postgresPort  = *cluster.Spec.Port
-	)
+// authFileContents returns a PgBouncer user database containing the
+// usernames and passwords in users. The password may also be a SCRAM verifier.
+func authFileContents(users map[string]string) []byte {
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
 
-	global := iniValueSet{
-		// Prior to PostgreSQL v12, the default setting for "extra_float_digits"
-		// does not return precise float values. Applications that want
-		// consistent results from different PostgreSQL versions may connect
-		// with this startup parameter. The JDBC driver uses it regardless.
-		// Trust that applications that know or care about this setting are
-		// using it consistently within each connection pool.
-		// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-EXTRA-FLOAT-DIGITS
-		// - https://github.com/pgjdbc/pgjdbc/blob/REL42.2.19/pgjdbc/src/main/java/org/postgresql/core/v3/ConnectionFactoryImpl.java#L334
-		"ignore_startup_parameters": "extra_float_digits",
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		_, _ = fmt.Fprintf(&b, "%s %s\n", quote(name), quote(users[name]))
+	}
+	return []byte(b.String())
+}
 
+// mandatorySettings returns the PgBouncer settings that the operator depends
+// on. These cannot be changed through the PostgresCluster spec.
+func mandatorySettings(cluster *v1beta1.PostgresCluster) iniValueSet {
+	settings := iniValueSet{
 		// Authenticate frontend connections using passwords stored in PostgreSQL.
 		// PgBouncer will connect to the backend database that is requested by
 		// the frontend as the "auth_user" and execute "auth_query". When
@@ -110,38 +150,190 @@ func clusterINI(cluster *v1beta1.PostgresCluster) string {
 		"auth_query": "SELECT username, password from pgbouncer.get_auth($1)",
 		"auth_user":  postgresqlUser,
 
-		// TODO(cbandy): Use an HBA file to control authentication of PgBouncer
-		// accounts; e.g. "admin_users" below.
-		// - https://www.pgbouncer.org/config.html#hba-file-format
-		//"auth_hba_file": "",
-		//"auth_type":     "hba",
-		//"admin_users": "pgbouncer",
-
-		// Require TLS encryption on client connections.
-		"client_tls_sslmode":   "require",
+		// Use the certificates and keys that are mounted into the pod.
 		"client_tls_cert_file": certFrontendAbsolutePath,
 		"client_tls_key_file":  certFrontendPrivateKeyAbsolutePath,
 		"client_tls_ca_file":   certFrontendAuthorityAbsolutePath,
 
-		// Listen on the PgBouncer port on all addresses.
+		// Prevent the user from bypassing the main configuration file.
+		"conffile": iniFileAbsolutePath,
+
+		// Listen on the port exposed by the container and Service.
+		"listen_port": fmt.Sprint(*cluster.Spec.Proxy.PGBouncer.Port),
+	}
+
+	// Verify PostgreSQL using the certificate authority that is mounted into
+	// the pod, unless TLS is disabled.
+	if serverTLSMode(cluster) != "disable" {
+		settings["server_tls_ca_file"] = certBackendAuthorityAbsolutePath
+	}
+
+	// Peers share the listening port.
+	// - https://www.pgbouncer.org/config.html#so_reuseport
+	if cluster.Spec.Proxy.PGBouncer.Peering != nil {
+		settings["so_reuseport"] = "1"
+	}
+
+	// Authenticate clients using the rules of an HBA file, when specified.
+	// - https://www.pgbouncer.org/config.html#hba-file-format
+	if len(cluster.Spec.Proxy.PGBouncer.Config.HBA) > 0 {
+		settings["auth_hba_file"] = hbaFileAbsolutePath
+		settings["auth_type"] = "hba"
+	}
+
+	// Allow specific users to connect to the admin console.
+	// - https://www.pgbouncer.org/usage.html#admin-console
+	if users := cluster.Spec.Proxy.PGBouncer.AdminUsers; len(users) > 0 {
+		settings["admin_users"] = quoteList(users)
+	}
+	// The exporter reads the admin console as one of the stats users.
+	users := cluster.Spec.Proxy.PGBouncer.StatsUsers
+	if exporterEnabled(cluster) {
+		users = append(users[:len(users):len(users)], exporterUser)
+	}
+	if len(users) > 0 {
+		settings["stats_users"] = quoteList(users)
+	}
+
+	return settings
+}
+
+// startupOnlySettings are the global settings that PgBouncer reads when it
+// starts but not when it reloads.
+// - https://www.pgbouncer.org/config.html
+// - https://www.pgbouncer.org/usage.html#sighup
+var startupOnlySettings = []string{
+	"listen_addr",
+	"peer_id",
+	"pidfile",
+	"so_reuseport",
+	"unix_socket_dir",
+	"unix_socket_group",
+	"unix_socket_mode",
+	"user",
+}
+
+// startupINI returns the global settings of cluster that require PgBouncer to
+// restart when they change. It is empty when none are specified.
+func startupINI(cluster *v1beta1.PostgresCluster) string {
+	settings := iniValueSet{}
+	for _, k := range startupOnlySettings {
+		if v, ok := cluster.Spec.Proxy.PGBouncer.Config.Global[k]; ok {
+			settings[k] = v
+		}
+	}
+	return settings.String()
+}
+
+// serverTLSMode returns the "server_tls_sslmode" of cluster.
+func serverTLSMode(cluster *v1beta1.PostgresCluster) string {
+	if server := cluster.Spec.Proxy.PGBouncer.Server; server != nil && server.TLSMode != "" {
+		return server.TLSMode
+	}
+	return "verify-full"
+}
+
+func clusterINI(cluster *v1beta1.PostgresCluster) string {
+	postgresPort := postgres.ServicePort(cluster)
+
+	global := iniValueSet{
+		// Require TLS encryption on client connections.
+		"client_tls_sslmode": "require",
+
+		// Listen on all addresses.
 		"listen_addr": "*",
-		"listen_port": fmt.Sprint(pgBouncerPort),
 
-		// Require TLS encryption on connections to PostgreSQL.
-		"server_tls_sslmode": "verify-full",
-		"server_tls_ca_file": certBackendAuthorityAbsolutePath,
+		// Encrypt connections to PostgreSQL; require TLS by default.
+		"server_tls_sslmode": serverTLSMode(cluster),
 
 		// Disable Unix sockets to keep the filesystem read-only.
 		"unix_socket_dir": "",
 	}
 
+	if mode := cluster.Spec.Proxy.PGBouncer.PoolMode; len(mode) > 0 {
+		global["pool_mode"] = mode
+	}
+
+	// Restrict TLS on client connections the same as PostgreSQL.
+	// - https://www.pgbouncer.org/config.html#client_tls_protocols
+	if tls := cluster.Spec.TLS; tls != nil {
+		switch tls.MinProtocol {
+		case "TLSv1.2":
+			global["client_tls_protocols"] = "tlsv1.2,tlsv1.3"
+		case "TLSv1.3":
+			global["client_tls_protocols"] = "tlsv1.3"
+		}
+		if len(tls.Ciphers) > 0 {
+			global["client_tls_ciphers"] = tls.Ciphers
+		}
+	}
+
+	if logging := cluster.Spec.Proxy.PGBouncer.Logging; logging != nil {
+		// Connections and disconnections are noisy, so log them only when
+		// they are explicitly enabled.
+		global["log_connections"] = iniBool(logging.Connections != nil && *logging.Connections)
+		global["log_disconnections"] = iniBool(logging.Disconnections != nil && *logging.Disconnections)
+
+		if logging.PoolerErrors != nil {
+			global["log_pooler_errors"] = iniBool(*logging.PoolerErrors)
+		}
+		if logging.Verbose != nil {
+			global["verbose"] = fmt.Sprint(*logging.Verbose)
+		}
+	}
+
+	if timeouts := cluster.Spec.Proxy.PGBouncer.Timeouts; timeouts != nil {
+		if timeouts.ClientIdle != nil {
+			global["client_idle_timeout"] = fmt.Sprint(*timeouts.ClientIdle)
+		}
+		if timeouts.Query != nil {
+			global["query_timeout"] = fmt.Sprint(*timeouts.Query)
+		}
+		if timeouts.ServerIdle != nil {
+			global["server_idle_timeout"] = fmt.Sprint(*timeouts.ServerIdle)
+		}
+	}
+
 	// Override the above with any specified settings.
 	for k, v := range cluster.Spec.Proxy.PGBouncer.Config.Global {
 		global[k] = v
 	}
 
-	// Prevent the user from bypassing the main configuration file.
-	global["conffile"] = iniFileAbsolutePath
+	// Append any specified startup parameters in the order they appear.
+	//
+	// Prior to PostgreSQL v12, the default setting for "extra_float_digits"
+	// does not return precise float values. Applications that want
+	// consistent results from different PostgreSQL versions may connect
+	// with this startup parameter. The JDBC driver uses it regardless.
+	// Trust that applications that know or care about this setting are
+	// using it consistently within each connection pool.
+	// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-EXTRA-FLOAT-DIGITS
+	// - https://github.com/pgjdbc/pgjdbc/blob/REL42.2.19/pgjdbc/src/main/java/org/postgresql/core/v3/ConnectionFactoryImpl.java#L334
+	ignored := global["ignore_startup_parameters"]
+	for _, v := range cluster.Spec.Proxy.PGBouncer.IgnoreStartupParameters {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			ignored = appendListValue(ignored, v)
+		}
+	}
+	global["ignore_startup_parameters"] = appendListValue(ignored, "extra_float_digits")
+
+	// Choose a "server_reset_query" that suits the pool mode unless one is
+	// specified. There is nothing to reset in "transaction" and "statement"
+	// modes, so PgBouncer recommends leaving it empty there.
+	// - https://www.pgbouncer.org/config.html#server_reset_query
+	if _, specified := cluster.Spec.Proxy.PGBouncer.Config.Global["server_reset_query"]; !specified {
+		switch global["pool_mode"] {
+		case "session":
+			global["server_reset_query"] = "DISCARD ALL"
+		case "transaction", "statement":
+			global["server_reset_query"] = ""
+		}
+	}
+
+	// Override everything with mandatory settings.
+	for k, v := range mandatorySettings(cluster) {
+		global[k] = v
+	}
 
 	// Use a wildcard to automatically create connection pools based on database
 	// names. These pools connect to cluster's primary service. The service name
@@ -178,9 +370,65 @@ func clusterINI(cluster *v1beta1.PostgresCluster) string {
 		result += "\n[users]\n" + users.String()
 	}
 
+	if peers := clusterPeers(cluster); len(peers) > 0 {
+		result += "\n[peers]\n" + peers.String()
+	}
+
 	return result
 }
 
+// clusterPeers returns the PgBouncer processes that forward cancel requests to
+// one another. Each listens on a Unix socket in its own directory.
+// - https://www.pgbouncer.org/config.html#section-peers
+func clusterPeers(cluster *v1beta1.PostgresCluster) iniValueSet {
+	peering := cluster.Spec.Proxy.PGBouncer.Peering
+	if peering == nil {
+		return nil
+	}
+
+	peers := iniValueSet{}
+	for id := int32(1); id <= peering.Processes; id++ {
+		peers[fmt.Sprint(id)] = fmt.Sprintf("host=%s/%d port=%d",
+			peersDirectory, id, *cluster.Spec.Proxy.PGBouncer.Port)
+	}
+	return peers
+}
+
+// clusterHBAs returns the HostBasedAuthentication records for clients of
+// PgBouncer.
+func clusterHBAs(cluster *v1beta1.PostgresCluster) postgres.HBAs {
+	var hbas postgres.HBAs
+
+	if exporterEnabled(cluster) {
+		hbas.Mandatory = append(hbas.Mandatory, exporterHBAs()...)
+	}
+
+	for _, rule := range cluster.Spec.Proxy.PGBouncer.Config.HBA {
+		hbas.Default = append(hbas.Default, *postgres.NewHBAFromRule(rule))
+	}
+
+	return hbas
+}
+
+// hbaFileContents returns a PgBouncer HBA file containing records in hbas.
+// PgBouncer uses the first record that matches, so mandatory records come
+// first and the order of each slice is preserved.
+func hbaFileContents(hbas postgres.HBAs) string {
+	var b strings.Builder
+	b.WriteString(iniGeneratedWarning)
+
+	for _, records := range [][]postgres.HostBasedAuthentication{
+		hbas.Mandatory, hbas.Default,
+	} {
+		for i := range records {
+			b.WriteString(records[i].String())
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
 // podConfigFiles returns projections of PgBouncer's configuration files to
 // include in the configuration volume.
 func podConfigFiles(
@@ -208,6 +456,19 @@ func podConfigFiles(
 	// - https://docs.k8s.io/concepts/storage/volumes/#projected
 	projections = append(projections, config.Files...)
 
+	// Include the HBA file only when there are rules so that PgBouncer does
+	// not restart otherwise.
+	items := []corev1.KeyToPath{{
+		Key:  iniFileConfigMapKey,
+		Path: iniFileProjectionPath,
+	}}
+	if len(config.HBA) > 0 {
+		items = append(items, corev1.KeyToPath{
+			Key:  hbaFileConfigMapKey,
+			Path: hbaFileProjectionPath,
+		})
+	}
+
 	// Add our non-empty configurations last so that they take precedence.
 	projections = append(projections, []corev1.VolumeProjection{
 		{
@@ -215,10 +476,7 @@ func podConfigFiles(
 				LocalObjectReference: corev1.LocalObjectReference{
 					Name: configmap.Name,
 				},
-				Items: []corev1.KeyToPath{{
-					Key:  iniFileConfigMapKey,
-					Path: iniFileProjectionPath,
-				}},
+				Items: items,
 			},
 		},
 		{
@@ -266,3 +524,25 @@ done
 
 	return []string{"bash", "-ceu", "--", wrapper, name, configDirectory}
 }
+
+// peersCommand returns an entrypoint that starts processes PgBouncer processes
+// that share a listening port. Each reads the main configuration file and then
+// its own peer_id and Unix socket directory.
+func peersCommand(processes int32) []string {
+	const script = `
+declare -r config="$1" directory="$2" count="$3"
+declare -a pids=()
+for (( id = 1; id <= count; id++ )); do
+  mkdir -p "${directory}/${id}"
+  printf '%s\n' "%include ${config}" '[pgbouncer]' \
+    "conffile = ${directory}/${id}.ini" \
+    "peer_id = ${id}" \
+    "unix_socket_dir = ${directory}/${id}" > "${directory}/${id}.ini"
+  pgbouncer "${directory}/${id}.ini" & pids+=("$!")
+done
+trap 'kill -TERM "${pids[@]}"' INT TERM
+wait -n
+`
+	return []string{"bash", "-ceu", "--", script, "pgbouncer",
+		iniFileAbsolutePath, peersDirectory, fmt.Sprint(processes)}
+}
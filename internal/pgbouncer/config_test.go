@@ -21,12 +21,14 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -44,9 +46,39 @@ func TestPrettyYAML(t *testing.T) {
 func TestAuthFileContents(t *testing.T) {
 	t.Parallel()
 
-	password := `very"random`
-	data := authFileContents(password)
-	assert.Equal(t, string(data), `"_crunchypgbouncer" "very""random"`+"\n")
+	for _, tt := range []struct {
+		name     string
+		users    map[string]string
+		expected string
+	}{
+		{
+			name:     "Empty",
+			users:    map[string]string{},
+			expected: ``,
+		},
+		{
+			name:     "Single",
+			users:    map[string]string{postgresqlUser: `very"random`},
+			expected: `"_crunchypgbouncer" "very""random"` + "\n",
+		},
+		{
+			name: "Multiple",
+			users: map[string]string{
+				postgresqlUser: `very"random`,
+				"zeta":         "SCRAM-SHA-256$4096:salt$stored:server",
+				`app"user`:     `""`,
+			},
+			expected: strings.Join([]string{
+				`"_crunchypgbouncer" "very""random"`,
+				`"app""user" """"""`,
+				`"zeta" "SCRAM-SHA-256$4096:salt$stored:server"`,
+			}, "\n") + "\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(authFileContents(tt.users)), tt.expected)
+		})
+	}
 }
 
 func TestClusterINI(t *testing.T) {
@@ -120,7 +152,7 @@ client_tls_cert_file = /etc/pgbouncer/~postgres-operator/frontend-tls.crt
 client_tls_key_file = /etc/pgbouncer/~postgres-operator/frontend-tls.key
 client_tls_sslmode = require
 conffile = /etc/pgbouncer/~postgres-operator.ini
-ignore_startup_parameters = custom
+ignore_startup_parameters = custom,extra_float_digits
 listen_addr = *
 listen_port = 8888
 server_tls_ca_file = /etc/pgbouncer/~postgres-operator/backend-ca.crt
@@ -139,6 +171,501 @@ app = mode=rad
 		cluster.Spec.Proxy.PGBouncer.Config.Global["conffile"] = "too-far"
 		assert.Assert(t, !strings.Contains(clusterINI(cluster), "too-far"))
 	})
+
+	t.Run("MandatorySettings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{
+			Global: map[string]string{
+				"auth_file":            "/tmp/users.txt",
+				"auth_query":           "SELECT 'whomp'",
+				"client_tls_cert_file": "/tmp/tls.crt",
+				"listen_port":          "1234",
+				"max_client_conn":      "1000",
+				"default_pool_size":    "50",
+			},
+		}
+
+		assert.Equal(t, clusterINI(cluster), strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+
+[pgbouncer]
+%include /etc/pgbouncer/pgbouncer.ini
+
+[pgbouncer]
+auth_file = /etc/pgbouncer/~postgres-operator/users.txt
+auth_query = SELECT username, password from pgbouncer.get_auth($1)
+auth_user = _crunchypgbouncer
+client_tls_ca_file = /etc/pgbouncer/~postgres-operator/frontend-ca.crt
+client_tls_cert_file = /etc/pgbouncer/~postgres-operator/frontend-tls.crt
+client_tls_key_file = /etc/pgbouncer/~postgres-operator/frontend-tls.key
+client_tls_sslmode = require
+conffile = /etc/pgbouncer/~postgres-operator.ini
+default_pool_size = 50
+ignore_startup_parameters = extra_float_digits
+listen_addr = *
+listen_port = 8888
+max_client_conn = 1000
+server_tls_ca_file = /etc/pgbouncer/~postgres-operator/backend-ca.crt
+server_tls_sslmode = verify-full
+unix_socket_dir =
+
+[databases]
+* = host=foo-baz-primary port=9999
+		`, "\t\n")+"\n")
+	})
+
+	t.Run("HBA", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{
+			Global: map[string]string{"auth_type": "md5"},
+			HBA:    []v1beta1.PostgresHBARule{{Connection: "hostssl", Method: "md5"}},
+		}
+
+		ini := clusterINI(cluster)
+		assert.Assert(t, strings.Contains(ini,
+			"\nauth_hba_file = /etc/pgbouncer/~postgres-operator/hba.conf\n"), "got:\n%s", ini)
+		assert.Assert(t, strings.Contains(ini, "\nauth_type = hba\n"), "got:\n%s", ini)
+	})
+
+	t.Run("ListenAddresses", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{
+			Global: map[string]string{
+				"listen_addr": "127.0.0.1",
+				"listen_port": "1234",
+			},
+		}
+
+		// The addresses can be changed, but the port cannot.
+		ini := clusterINI(cluster)
+		assert.Assert(t, strings.Contains(ini, "\nlisten_addr = 127.0.0.1\n"), "got:\n%s", ini)
+		assert.Assert(t, strings.Contains(ini, "\nlisten_port = 8888\n"), "got:\n%s", ini)
+		assert.Assert(t, !strings.Contains(ini, "listen_addr = *"), "got:\n%s", ini)
+	})
+
+	t.Run("ExplicitDatabases", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{
+			Databases: map[string]string{
+				"zebra":  "host=elsewhere dbname=z",
+				"appdb":  "host=foo-baz-primary port=9999",
+				"report": "host=foo-baz-replicas port=9999 dbname=appdb",
+			},
+		}
+
+		ini := clusterINI(cluster)
+
+		// Databases are listed in order, and the wildcard is not present.
+		assert.Assert(t, strings.HasSuffix(ini, strings.Trim(`
+[databases]
+appdb = host=foo-baz-primary port=9999
+report = host=foo-baz-replicas port=9999 dbname=appdb
+zebra = host=elsewhere dbname=z
+		`, "\t\n")+"\n"), "got:\n%s", ini)
+		assert.Assert(t, !strings.Contains(ini, "\n* ="), "got:\n%s", ini)
+
+		// The output does not vary between calls.
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, clusterINI(cluster), ini)
+		}
+	})
+
+	t.Run("AdminAndStatsUsers", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		t.Run("Empty", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.AdminUsers = []string{}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, !strings.Contains(ini, "admin_users"), "got:\n%s", ini)
+			assert.Assert(t, !strings.Contains(ini, "stats_users"), "got:\n%s", ini)
+		})
+
+		t.Run("Single", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.AdminUsers = []string{"monitor"}
+			cluster.Spec.Proxy.PGBouncer.StatsUsers = []string{"stats"}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\n"+`admin_users = "monitor"`+"\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini, "\n"+`stats_users = "stats"`+"\n"), "got:\n%s", ini)
+		})
+
+		t.Run("Multiple", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.AdminUsers = []string{"one", "two,three"}
+			cluster.Spec.Proxy.PGBouncer.StatsUsers = []string{`has"quote`, "x"}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\n"+`admin_users = "one","two,three"`+"\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini, "\n"+`stats_users = "has""quote","x"`+"\n"), "got:\n%s", ini)
+
+			// These settings cannot be changed through global settings.
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+				"admin_users": "someone-else",
+			}
+			assert.Assert(t, !strings.Contains(clusterINI(cluster), "someone-else"))
+		})
+
+		t.Run("Exporter", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Exporter = &v1beta1.PGBouncerExporterSpec{}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\n"+`stats_users = "_crunchypgbouncer_exporter"`+"\n"), "got:\n%s", ini)
+
+			// The exporter is added after other stats users.
+			statsUsers := []string{"stats"}
+			cluster.Spec.Proxy.PGBouncer.StatsUsers = statsUsers
+
+			ini = clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\n"+`stats_users = "stats","_crunchypgbouncer_exporter"`+"\n"), "got:\n%s", ini)
+			assert.DeepEqual(t, cluster.Spec.Proxy.PGBouncer.StatsUsers, []string{"stats"})
+		})
+	})
+
+	t.Run("Logging", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		t.Run("Unset", func(t *testing.T) {
+			ini := clusterINI(cluster)
+			assert.Assert(t, !strings.Contains(ini, "log_"), "got:\n%s", ini)
+			assert.Assert(t, !strings.Contains(ini, "verbose"), "got:\n%s", ini)
+		})
+
+		t.Run("Empty", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Logging = new(v1beta1.PGBouncerLogging)
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini,
+				"\nlog_connections = 0\nlog_disconnections = 0\n"), "got:\n%s", ini)
+			assert.Assert(t, !strings.Contains(ini, "log_pooler_errors"), "got:\n%s", ini)
+			assert.Assert(t, !strings.Contains(ini, "verbose"), "got:\n%s", ini)
+		})
+
+		t.Run("Requested", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Logging = &v1beta1.PGBouncerLogging{
+				Connections:    initialize.Bool(true),
+				Disconnections: initialize.Bool(false),
+				PoolerErrors:   initialize.Bool(false),
+				Verbose:        initialize.Int32(2),
+			}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini,
+				"\nlog_connections = 1\nlog_disconnections = 0\nlog_pooler_errors = 0\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini, "\nverbose = 2\n"), "got:\n%s", ini)
+
+			// Global settings take precedence.
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+				"log_connections": "0",
+				"verbose":         "1",
+			}
+
+			ini = clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\nlog_connections = 0\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini, "\nverbose = 1\n"), "got:\n%s", ini)
+		})
+	})
+
+	t.Run("ServerTLSMode", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		// The default verifies the certificate and name of PostgreSQL.
+		ini := clusterINI(cluster)
+		assert.Assert(t, strings.Contains(ini,
+			"\nserver_tls_ca_file = /etc/pgbouncer/~postgres-operator/backend-ca.crt\n"+
+				"server_tls_sslmode = verify-full\n"), "got:\n%s", ini)
+
+		for _, mode := range []string{"prefer", "require", "verify-ca", "verify-full"} {
+			cluster.Spec.Proxy.PGBouncer.Server = &v1beta1.PGBouncerServerSpec{TLSMode: mode}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini,
+				"\nserver_tls_ca_file = /etc/pgbouncer/~postgres-operator/backend-ca.crt\n"+
+					"server_tls_sslmode = "+mode+"\n"), "got:\n%s", ini)
+		}
+
+		t.Run("Disabled", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Server = &v1beta1.PGBouncerServerSpec{TLSMode: "disable"}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\nserver_tls_sslmode = disable\n"), "got:\n%s", ini)
+			assert.Assert(t, !strings.Contains(ini, "server_tls_ca_file"), "got:\n%s", ini)
+		})
+	})
+
+	t.Run("Peering", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		t.Run("Unset", func(t *testing.T) {
+			ini := clusterINI(cluster)
+			assert.Assert(t, !strings.Contains(ini, "so_reuseport"), "got:\n%s", ini)
+			assert.Assert(t, !strings.Contains(ini, "[peers]"), "got:\n%s", ini)
+		})
+
+		t.Run("Requested", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Peering = &v1beta1.PGBouncerPeering{Processes: 3}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\nso_reuseport = 1\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.HasSuffix(ini, strings.Trim(`
+[peers]
+1 = host=/tmp/pgbouncer-peers/1 port=8888
+2 = host=/tmp/pgbouncer-peers/2 port=8888
+3 = host=/tmp/pgbouncer-peers/3 port=8888
+			`, "\t\n")+"\n"), "got:\n%s", ini)
+
+			// Peers cannot be bound without this.
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{"so_reuseport": "0"}
+			ini = clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\nso_reuseport = 1\n"), "got:\n%s", ini)
+		})
+	})
+
+	t.Run("Timeouts", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		t.Run("Unset", func(t *testing.T) {
+			ini := clusterINI(cluster)
+			assert.Assert(t, !strings.Contains(ini, "_timeout"), "got:\n%s", ini)
+
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Timeouts = new(v1beta1.PGBouncerTimeouts)
+
+			ini = clusterINI(cluster)
+			assert.Assert(t, !strings.Contains(ini, "_timeout"), "got:\n%s", ini)
+		})
+
+		t.Run("Requested", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Timeouts = &v1beta1.PGBouncerTimeouts{
+				ClientIdle: initialize.Int32(3600),
+				Query:      initialize.Int32(0),
+				ServerIdle: initialize.Int32(60),
+			}
+
+			ini := clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\nclient_idle_timeout = 3600\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini, "\nquery_timeout = 0\n"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini, "\nserver_idle_timeout = 60\n"), "got:\n%s", ini)
+
+			// Global settings take precedence.
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+				"server_idle_timeout": "300",
+			}
+
+			ini = clusterINI(cluster)
+			assert.Assert(t, strings.Contains(ini, "\nserver_idle_timeout = 300\n"), "got:\n%s", ini)
+		})
+
+		t.Run("NotMandatory", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Timeouts = &v1beta1.PGBouncerTimeouts{
+				ClientIdle: initialize.Int32(1), Query: initialize.Int32(1), ServerIdle: initialize.Int32(1),
+			}
+
+			for _, key := range []string{
+				"client_idle_timeout", "query_timeout", "server_idle_timeout",
+			} {
+				_, found := mandatorySettings(cluster)[key]
+				assert.Assert(t, !found, "%q should not be mandatory", key)
+			}
+		})
+	})
+
+	t.Run("PoolMode", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		t.Run("Unset", func(t *testing.T) {
+			ini := clusterINI(cluster)
+			assert.Assert(t, !strings.Contains(ini, "pool_mode"), "got:\n%s", ini)
+			assert.Assert(t, strings.Contains(ini,
+				"\nignore_startup_parameters = extra_float_digits\n"), "got:\n%s", ini)
+		})
+
+		for _, mode := range []string{"session", "transaction", "statement"} {
+			t.Run(mode, func(t *testing.T) {
+				cluster := cluster.DeepCopy()
+				cluster.Spec.Proxy.PGBouncer.PoolMode = mode
+
+				ini := clusterINI(cluster)
+				assert.Assert(t, strings.Contains(ini,
+					"\npool_mode = "+mode+"\n"), "got:\n%s", ini)
+				assert.Assert(t, strings.Contains(ini,
+					"\nignore_startup_parameters = extra_float_digits\n"), "got:\n%s", ini)
+			})
+		}
+
+		t.Run("ServerResetQuery", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+
+			// PgBouncer's own default applies when the mode is unset.
+			assert.Assert(t, !strings.Contains(clusterINI(cluster), "server_reset_query"))
+
+			for mode, expected := range map[string]string{
+				"session":     "\nserver_reset_query = DISCARD ALL\n",
+				"transaction": "\nserver_reset_query =\n",
+				"statement":   "\nserver_reset_query =\n",
+			} {
+				cluster.Spec.Proxy.PGBouncer.PoolMode = mode
+				ini := clusterINI(cluster)
+				assert.Assert(t, strings.Contains(ini, expected), "%s:\n%s", mode, ini)
+			}
+
+			// Any specified value wins, even an empty one.
+			cluster.Spec.Proxy.PGBouncer.PoolMode = "transaction"
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+				"server_reset_query": "RESET ALL",
+			}
+			assert.Assert(t, strings.Contains(clusterINI(cluster),
+				"\nserver_reset_query = RESET ALL\n"))
+
+			cluster.Spec.Proxy.PGBouncer.PoolMode = "session"
+			cluster.Spec.Proxy.PGBouncer.Config.Global["server_reset_query"] = ""
+			assert.Assert(t, strings.Contains(clusterINI(cluster),
+				"\nserver_reset_query =\n"))
+		})
+
+		t.Run("CustomIgnoredParameters", func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+				"ignore_startup_parameters": "search_path",
+			}
+
+			// Every mode ignores "extra_float_digits".
+			for _, mode := range []string{"session", "transaction", "statement"} {
+				cluster.Spec.Proxy.PGBouncer.PoolMode = mode
+				assert.Assert(t, strings.Contains(clusterINI(cluster),
+					"\nignore_startup_parameters = search_path,extra_float_digits\n"))
+			}
+
+			// It is not added twice.
+			cluster.Spec.Proxy.PGBouncer.Config.Global["ignore_startup_parameters"] =
+				"extra_float_digits, search_path"
+			assert.Assert(t, strings.Contains(clusterINI(cluster),
+				"\nignore_startup_parameters = extra_float_digits, search_path\n"))
+
+			// The mode can also come from global settings.
+			cluster.Spec.Proxy.PGBouncer.PoolMode = ""
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+				"ignore_startup_parameters": "",
+				"pool_mode":                 "statement",
+			}
+			assert.Assert(t, strings.Contains(clusterINI(cluster),
+				"\nignore_startup_parameters = extra_float_digits\n"))
+		})
+	})
+
+	t.Run("IgnoreStartupParameters", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+		cluster.Spec.Proxy.PGBouncer.IgnoreStartupParameters = []string{
+			"options", "search_path",
+		}
+
+		assert.Assert(t, strings.Contains(clusterINI(cluster),
+			"\nignore_startup_parameters = options,search_path,extra_float_digits\n"))
+
+		// They are appended to global settings without repeating.
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+			"ignore_startup_parameters": "search_path,application_name",
+		}
+		assert.Assert(t, strings.Contains(clusterINI(cluster),
+			"\nignore_startup_parameters = search_path,application_name,options,extra_float_digits\n"))
+
+		// "extra_float_digits" is kept where it is specified.
+		cluster.Spec.Proxy.PGBouncer.IgnoreStartupParameters = []string{
+			"extra_float_digits", "options",
+		}
+		cluster.Spec.Proxy.PGBouncer.Config.Global = nil
+		assert.Assert(t, strings.Contains(clusterINI(cluster),
+			"\nignore_startup_parameters = extra_float_digits,options\n"))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config = v1beta1.PGBouncerConfiguration{}
+
+		ini := clusterINI(cluster)
+		assert.Assert(t, !strings.Contains(ini, "client_tls_protocols"), "got:\n%s", ini)
+		assert.Assert(t, !strings.Contains(ini, "client_tls_ciphers"), "got:\n%s", ini)
+
+		cluster.Spec.TLS = &v1beta1.TLSSpec{MinProtocol: "TLSv1.2", Ciphers: "HIGH:!aNULL"}
+		ini = clusterINI(cluster)
+		assert.Assert(t, strings.Contains(ini,
+			"\nclient_tls_protocols = tlsv1.2,tlsv1.3\n"), "got:\n%s", ini)
+		assert.Assert(t, strings.Contains(ini,
+			"\nclient_tls_ciphers = HIGH:!aNULL\n"), "got:\n%s", ini)
+
+		cluster.Spec.TLS = &v1beta1.TLSSpec{MinProtocol: "TLSv1.3"}
+		ini = clusterINI(cluster)
+		assert.Assert(t, strings.Contains(ini,
+			"\nclient_tls_protocols = tlsv1.3\n"), "got:\n%s", ini)
+		assert.Assert(t, !strings.Contains(ini, "client_tls_ciphers"), "got:\n%s", ini)
+	})
+}
+
+func TestHBAFileContents(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.Proxy = new(v1beta1.PostgresProxySpec)
+	cluster.Spec.Proxy.PGBouncer = new(v1beta1.PGBouncerPodSpec)
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, hbaFileContents(clusterHBAs(cluster)), iniGeneratedWarning)
+	})
+
+	t.Run("Rules", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.HBA = []v1beta1.PostgresHBARule{
+			{Connection: "hostssl", Address: "10.0.0.0/8", Method: "scram-sha-256"},
+			{
+				Connection: "hostssl",
+				Databases:  []v1beta1.PostgresIdentifier{"pgbouncer"},
+				Users:      []v1beta1.PostgresIdentifier{"monitor"},
+				Method:     "md5",
+			},
+			{Connection: "host", Method: "reject"},
+		}
+
+		// Rules appear in the order they are specified.
+		assert.Equal(t, hbaFileContents(clusterHBAs(cluster)), strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+hostssl all all "10.0.0.0/8" scram-sha-256
+hostssl "pgbouncer" "monitor" all md5
+host all all all reject
+		`, "\t\n")+"\n")
+	})
+	t.Run("Exporter", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Exporter = &v1beta1.PGBouncerExporterSpec{}
+		cluster.Spec.Proxy.PGBouncer.Config.HBA = []v1beta1.PostgresHBARule{
+			{Connection: "host", Method: "reject"},
+		}
+
+		// The exporter is allowed to connect before any other rules.
+		assert.Equal(t, hbaFileContents(clusterHBAs(cluster)), strings.Trim(`
+# Generated by postgres-operator. DO NOT EDIT.
+# Your changes will not be saved.
+hostssl "pgbouncer" "_crunchypgbouncer_exporter" "127.0.0.1/32" scram-sha-256
+host all all all reject
+		`, "\t\n")+"\n")
+	})
 }
 
 func TestPodConfigFiles(t *testing.T) {
@@ -210,21 +737,187 @@ func TestPodConfigFiles(t *testing.T) {
 	})
 }
 
+func TestPodConfigFilesHBA(t *testing.T) {
+	t.Parallel()
+
+	config := v1beta1.PGBouncerConfiguration{
+		HBA: []v1beta1.PostgresHBARule{{Connection: "hostssl", Method: "md5"}},
+	}
+	configmap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "some-cm"}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "some-shh"}}
+
+	projections := podConfigFiles(config, configmap, secret)
+	assert.Assert(t, marshalMatches(projections, `
+- configMap:
+    items:
+    - key: pgbouncer-empty
+      path: pgbouncer.ini
+    name: some-cm
+- configMap:
+    items:
+    - key: pgbouncer.ini
+      path: ~postgres-operator.ini
+    - key: pgbouncer-hba.conf
+      path: ~postgres-operator/hba.conf
+    name: some-cm
+- secret:
+    items:
+    - key: pgbouncer-users.txt
+      path: ~postgres-operator/users.txt
+    name: some-shh
+	`))
+}
+
 func TestReloadCommand(t *testing.T) {
-	shellcheck := require.ShellCheck(t)
 	command := reloadCommand("some-name")
 
 	// Expect a bash command with an inline script.
 	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
 	assert.Assert(t, len(command) > 3)
 
-	// Write out that inline script.
-	dir := t.TempDir()
-	file := filepath.Join(dir, "script.bash")
-	assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+
+		// Write out that inline script.
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		// Expect shellcheck to be happy.
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+
+	t.Run("Execute", func(t *testing.T) {
+		if _, err := exec.LookPath("bash"); err != nil {
+			t.Skip(`requires "bash" executable`)
+		}
+
+		// Replace pkill with a script that records how it was called.
+		bin, config := t.TempDir(), t.TempDir()
+		signals := filepath.Join(t.TempDir(), "signals")
+		assert.NilError(t, os.WriteFile(filepath.Join(bin, "pkill"), []byte(
+			"#!/bin/sh\necho \"$@\" >> '"+signals+"'\n"), 0o700))
+
+		// Watch the temporary directory rather than the one in the container.
+		args := append(command[1:len(command)-1:len(command)-1], config)
+		cmd := exec.Command("bash", args...)
+		cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		assert.NilError(t, cmd.Start())
+		t.Cleanup(func() { _ = cmd.Process.Kill(); _ = cmd.Wait() })
+
+		// Nothing is signaled until the configuration changes.
+		time.Sleep(time.Second)
+		_, err := os.Stat(signals)
+		assert.Assert(t, os.IsNotExist(err), "got %v", err)
+
+		// Kubernetes updates mounted ConfigMaps by replacing a symlink, which
+		// changes the mtime of the directory.
+		future := time.Now().Add(time.Minute)
+		assert.NilError(t, os.Chtimes(config, future, future))
+
+		var recorded []byte
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+			if recorded, _ = os.ReadFile(signals); len(recorded) > 0 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		// PgBouncer reloads its configuration on SIGHUP, the same as RELOAD.
+		// - https://www.pgbouncer.org/usage.html#sighup
+		assert.Equal(t, string(recorded), "-HUP --exact pgbouncer\n")
+	})
+}
+
+func TestPeersCommand(t *testing.T) {
+	command := peersCommand(2)
+
+	// Expect a bash command with an inline script.
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[4:], []string{
+		"pgbouncer", "/etc/pgbouncer/~postgres-operator.ini", "/tmp/pgbouncer-peers", "2",
+	})
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+
+		// Write out that inline script.
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		// Expect shellcheck to be happy.
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+
+	t.Run("Execute", func(t *testing.T) {
+		if _, err := exec.LookPath("bash"); err != nil {
+			t.Skip(`requires "bash" executable`)
+		}
 
-	// Expect shellcheck to be happy.
-	cmd := exec.Command(shellcheck, "--enable=all", file)
-	output, err := cmd.CombinedOutput()
-	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+		// Replace pgbouncer with a script that records its configuration.
+		bin, peers := t.TempDir(), t.TempDir()
+		assert.NilError(t, os.WriteFile(filepath.Join(bin, "pgbouncer"), []byte(
+			"#!/bin/sh\ncat \"$1\" > \"$1.started\"\n"), 0o700))
+
+		// Use the temporary directory rather than the one in the container.
+		cmd := exec.Command("bash", command[1:4]...)
+		cmd.Args = append(cmd.Args, "pgbouncer", "/etc/pgbouncer/main.ini", peers, "2")
+		cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+
+		// Each process has its own peer_id and Unix socket directory.
+		for _, id := range []string{"1", "2"} {
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+				if _, err := os.Stat(filepath.Join(peers, id+".ini.started")); err == nil {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			started, err := os.ReadFile(filepath.Join(peers, id+".ini.started"))
+			assert.NilError(t, err)
+			assert.Equal(t, string(started), strings.Join([]string{
+				"%include /etc/pgbouncer/main.ini",
+				"[pgbouncer]",
+				"conffile = " + filepath.Join(peers, id+".ini"),
+				"peer_id = " + id,
+				"unix_socket_dir = " + filepath.Join(peers, id),
+			}, "\n")+"\n")
+
+			info, err := os.Stat(filepath.Join(peers, id))
+			assert.NilError(t, err)
+			assert.Assert(t, info.IsDir())
+		}
+	})
+}
+
+func TestStartupINI(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{
+		PGBouncer: &v1beta1.PGBouncerPodSpec{},
+	}
+
+	// Nothing by default.
+	assert.Equal(t, startupINI(cluster), "")
+
+	// Settings that PgBouncer reloads are not included.
+	cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+		"max_client_conn": "500",
+		"pool_mode":       "transaction",
+	}
+	assert.Equal(t, startupINI(cluster), "")
+
+	cluster.Spec.Proxy.PGBouncer.Config.Global["listen_addr"] = "0.0.0.0"
+	cluster.Spec.Proxy.PGBouncer.Config.Global["unix_socket_dir"] = "/tmp"
+	assert.Equal(t, startupINI(cluster), strings.Trim(`
+listen_addr = 0.0.0.0
+unix_socket_dir = /tmp
+	`, "\t\n")+"\n")
 }
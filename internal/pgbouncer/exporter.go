@@ -0,0 +1,96 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgbouncer
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ExporterPort is the port on which pgbouncer_exporter serves metrics.
+	// - https://github.com/prometheus/prometheus/wiki/Default-port-allocations
+	ExporterPort = int32(9127)
+
+	// exporterUser is the PgBouncer user that pgbouncer_exporter uses to
+	// read the admin console. It exists only in the PgBouncer auth file.
+	exporterUser = "_crunchypgbouncer_exporter"
+
+	exporterPasswordSecretKey = "pgbouncer-exporter-password" // #nosec G101 this is a name, not a credential
+	exporterVerifierSecretKey = "pgbouncer-exporter-verifier" // #nosec G101 this is a name, not a credential
+)
+
+// exporterEnabled returns true when cluster asks for PgBouncer metrics.
+func exporterEnabled(cluster *v1beta1.PostgresCluster) bool {
+	return cluster.Spec.Proxy != nil &&
+		cluster.Spec.Proxy.PGBouncer != nil &&
+		cluster.Spec.Proxy.PGBouncer.Exporter != nil
+}
+
+// exporterContainer returns a container that runs pgbouncer_exporter against
+// the admin console of the PgBouncer in the same pod.
+func exporterContainer(cluster *v1beta1.PostgresCluster, secret *corev1.Secret) corev1.Container {
+	// Connect over the loopback interface using TLS, which PgBouncer requires
+	// of every client. The password is read from the environment by lib/pq.
+	// - https://pkg.go.dev/github.com/lib/pq#hdr-Connection_String_Parameters
+	connection := fmt.Sprintf("postgres://%s@127.0.0.1:%d/%s?sslmode=require",
+		exporterUser, *cluster.Spec.Proxy.PGBouncer.Port, adminDatabase)
+
+	return corev1.Container{
+		Name: naming.ContainerPGBouncerExporter,
+
+		Command: []string{
+			"pgbouncer_exporter",
+			"--pgBouncer.connectionString=" + connection,
+			fmt.Sprintf("--web.listen-address=:%d", ExporterPort),
+		},
+		Env: []corev1.EnvVar{{
+			Name: "PGPASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  exporterPasswordSecretKey,
+				},
+			},
+		}},
+		Image:           config.PGBouncerExporterContainerImage(cluster),
+		ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+		Resources:       cluster.Spec.Proxy.PGBouncer.Exporter.Resources,
+		SecurityContext: initialize.RestrictedSecurityContext(),
+
+		Ports: []corev1.ContainerPort{{
+			Name:          naming.PortExporter,
+			ContainerPort: ExporterPort,
+			Protocol:      corev1.ProtocolTCP,
+		}},
+	}
+}
+
+// exporterHBAs returns the HBA records that allow pgbouncer_exporter to
+// connect when PgBouncer is using an HBA file.
+func exporterHBAs() []postgres.HostBasedAuthentication {
+	return []postgres.HostBasedAuthentication{
+		*postgres.NewHBA().TLS().Database(adminDatabase).User(exporterUser).
+			Network("127.0.0.1/32").Method("scram-sha-256"),
+	}
+}
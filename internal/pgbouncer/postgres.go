@@ -25,6 +25,7 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/internal/postgres/password"
 	"github.com/crunchydata/postgres-operator/internal/util"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 const (
@@ -221,12 +222,20 @@ func generatePassword() (plaintext, verifier string, err error) {
 	return
 }
 
-func postgresqlHBAs() []postgres.HostBasedAuthentication {
+func postgresqlHBAs(cluster *v1beta1.PostgresCluster) []postgres.HostBasedAuthentication {
 	// PgBouncer must connect over TLS using a SCRAM password. Other network
 	// connections are forbidden.
 	// - https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
 	// - https://www.postgresql.org/docs/current/auth-password.html
 
+	// When TLS is disabled, PgBouncer connects without it. The network is
+	// expected to protect these connections instead.
+	if serverTLSMode(cluster) == "disable" {
+		return []postgres.HostBasedAuthentication{
+			*postgres.NewHBA().User(postgresqlUser).TCP().Method("scram-sha-256"),
+		}
+	}
+
 	return []postgres.HostBasedAuthentication{
 		*postgres.NewHBA().User(postgresqlUser).TLS().Method("scram-sha-256"),
 		*postgres.NewHBA().User(postgresqlUser).TCP().Method("reject"),
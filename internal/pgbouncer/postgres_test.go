@@ -25,6 +25,9 @@ import (
 	"github.com/onsi/gomega"
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestSQLAuthenticationQuery(t *testing.T) {
@@ -193,8 +196,43 @@ COMMIT;`))
 }
 
 func TestPostgreSQLHBAs(t *testing.T) {
-	rules := postgresqlHBAs()
-	assert.Equal(t, len(rules), 2)
-	assert.Equal(t, rules[0].String(), `hostssl all "_crunchypgbouncer" all scram-sha-256`)
-	assert.Equal(t, rules[1].String(), `host all "_crunchypgbouncer" all reject`)
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.Proxy = &v1beta1.PostgresProxySpec{
+		PGBouncer: &v1beta1.PGBouncerPodSpec{},
+	}
+
+	// TLS is required unless it is disabled.
+	tls := []string{
+		`hostssl all "_crunchypgbouncer" all scram-sha-256`,
+		`host all "_crunchypgbouncer" all reject`,
+	}
+
+	for _, tt := range []struct {
+		mode  string
+		rules []string
+	}{
+		{mode: "", rules: tls},
+		{mode: "disable", rules: []string{`host all "_crunchypgbouncer" all scram-sha-256`}},
+		{mode: "prefer", rules: tls},
+		{mode: "require", rules: tls},
+		{mode: "verify-ca", rules: tls},
+		{mode: "verify-full", rules: tls},
+	} {
+		cluster.Spec.Proxy.PGBouncer.Server = &v1beta1.PGBouncerServerSpec{TLSMode: tt.mode}
+
+		var rules []string
+		for _, rule := range postgresqlHBAs(cluster) {
+			rules = append(rules, rule.String())
+		}
+		assert.DeepEqual(t, rules, tt.rules)
+
+		// The same rules go to PostgreSQL through the cluster HBA.
+		hbas := new(postgres.HBAs)
+		PostgreSQL(cluster, hbas)
+		rules = rules[:0]
+		for _, rule := range hbas.Mandatory {
+			rules = append(rules, rule.String())
+		}
+		assert.DeepEqual(t, rules, tt.rules)
+	}
 }
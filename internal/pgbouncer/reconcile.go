@@ -17,10 +17,13 @@ package pgbouncer
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/crunchydata/postgres-operator/internal/config"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -31,6 +34,36 @@ import (
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// ConfigFiles returns the contents of the PgBouncer configuration files that
+// ConfigMap and Secret generate for inCluster. The result is the same for the
+// same configuration, so Pods can be restarted whenever it changes.
+func ConfigFiles(inCluster *v1beta1.PostgresCluster, inSecret *corev1.Secret) string {
+	if inCluster.Spec.Proxy == nil || inCluster.Spec.Proxy.PGBouncer == nil {
+		// PgBouncer is disabled; there is nothing to do.
+		return ""
+	}
+
+	var files strings.Builder
+	files.WriteString(clusterINI(inCluster))
+
+	if len(inCluster.Spec.Proxy.PGBouncer.Config.HBA) > 0 {
+		files.WriteString(hbaFileContents(clusterHBAs(inCluster)))
+	}
+	if inSecret != nil {
+		files.Write(inSecret.Data[authFileSecretKey])
+	}
+
+	return files.String()
+}
+
+// StartupSettings returns the configuration of inCluster that PgBouncer reads
+// only when it starts. Other configuration is reloaded as it changes, so Pods
+// need to restart only when this does. It is empty when there is nothing
+// PgBouncer must be restarted to apply.
+func StartupSettings(inCluster *v1beta1.PostgresCluster) string {
+	return startupINI(inCluster)
+}
+
 // ConfigMap populates the PgBouncer ConfigMap.
 func ConfigMap(
 	inCluster *v1beta1.PostgresCluster,
@@ -45,14 +78,77 @@ func ConfigMap(
 
 	outConfigMap.Data[emptyConfigMapKey] = ""
 	outConfigMap.Data[iniFileConfigMapKey] = clusterINI(inCluster)
+
+	if len(inCluster.Spec.Proxy.PGBouncer.Config.HBA) > 0 {
+		outConfigMap.Data[hbaFileConfigMapKey] = hbaFileContents(clusterHBAs(inCluster))
+	}
+}
+
+// ValidateConfig returns any problems with the PgBouncer settings in inCluster.
+// Warnings are about settings that the operator depends on; ConfigMap ignores
+// them. Errors are about settings that must be corrected before PgBouncer
+// can use them; callers should not call ConfigMap while there are any.
+func ValidateConfig(inCluster *v1beta1.PostgresCluster) (warnings, errs field.ErrorList) {
+	if inCluster.Spec.Proxy == nil || inCluster.Spec.Proxy.PGBouncer == nil {
+		// PgBouncer is disabled; there is nothing to do.
+		return
+	}
+
+	config := inCluster.Spec.Proxy.PGBouncer.Config
+	path := field.NewPath("spec", "proxy", "pgBouncer", "config")
+
+	mandatory := mandatorySettings(inCluster)
+	keys := make([]string, 0, len(config.Global))
+	for k := range config.Global {
+		if _, ok := mandatory[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		warnings = append(warnings, field.Forbidden(path.Child("global").Key(k),
+			"this setting is managed by the operator"))
+	}
+
+	// PgBouncer refuses to start with any other pool_mode.
+	// - https://www.pgbouncer.org/config.html#pool_mode
+	if mode, ok := config.Global["pool_mode"]; ok {
+		switch mode {
+		case "session", "statement", "transaction":
+		default:
+			errs = append(errs, field.NotSupported(path.Child("global").Key("pool_mode"),
+				mode, []string{"session", "statement", "transaction"}))
+		}
+	}
+
+	// Rules are written to the HBA file as they are, so they must not contain
+	// anything that would add or change its lines.
+	for i, rule := range config.HBA {
+		errs = append(errs, postgres.ValidateHBARule(rule, path.Child("hba").Index(i))...)
+	}
+
+	// The "pgbouncer" database is the PgBouncer admin console. A definition
+	// by the same name makes the console unreachable.
+	// - https://www.pgbouncer.org/usage.html#admin-console
+	if _, ok := config.Databases[adminDatabase]; ok {
+		errs = append(errs, field.Invalid(path.Child("databases").Key(adminDatabase),
+			config.Databases[adminDatabase],
+			"this name is reserved for the PgBouncer admin console"))
+	}
+
+	return
 }
 
-// Secret populates the PgBouncer Secret.
+// Secret populates the PgBouncer Secret. The SCRAM verifiers of any
+// inUserSecrets, keyed by PostgreSQL user name, are included in the auth file.
+// Those named for the users of PgBouncer itself are ignored.
 func Secret(ctx context.Context,
 	inCluster *v1beta1.PostgresCluster,
 	inRoot *pki.RootCertificateAuthority,
 	inSecret *corev1.Secret,
 	inService *corev1.Service,
+	inUserSecrets map[string]*corev1.Secret,
 	outSecret *corev1.Secret,
 ) error {
 	if inCluster.Spec.Proxy == nil || inCluster.Spec.Proxy.PGBouncer == nil {
@@ -77,7 +173,34 @@ func Secret(ctx context.Context,
 	if err == nil {
 		// Store the SCRAM verifier alongside the plaintext password so that
 		// later reconciles don't generate it repeatedly.
-		outSecret.Data[authFileSecretKey] = authFileContents(password)
+		users := map[string]string{postgresqlUser: password}
+		for name, secret := range inUserSecrets {
+			// PostgreSQL users cannot replace the users PgBouncer depends on.
+			if name == postgresqlUser || name == exporterUser {
+				continue
+			}
+			if verifier := secret.Data["verifier"]; len(verifier) > 0 {
+				users[name] = string(verifier)
+			}
+		}
+
+		// The exporter authenticates with SCRAM using a password of its own.
+		// Keep that password and verifier while the exporter is enabled.
+		if exporterEnabled(inCluster) {
+			exporterPassword := string(inSecret.Data[exporterPasswordSecretKey])
+			exporterVerifier := string(inSecret.Data[exporterVerifierSecretKey])
+
+			if len(exporterPassword) == 0 || len(exporterVerifier) == 0 {
+				exporterPassword, exporterVerifier, err = generatePassword()
+				err = errors.WithStack(err)
+			}
+
+			users[exporterUser] = exporterVerifier
+			outSecret.Data[exporterPasswordSecretKey] = []byte(exporterPassword)
+			outSecret.Data[exporterVerifierSecretKey] = []byte(exporterVerifier)
+		}
+
+		outSecret.Data[authFileSecretKey] = authFileContents(users)
 		outSecret.Data[passwordSecretKey] = []byte(password)
 		outSecret.Data[verifierSecretKey] = []byte(verifier)
 	}
@@ -155,6 +278,21 @@ func Pod(
 		VolumeMounts: []corev1.VolumeMount{configVolumeMount},
 	}
 
+	// Start peers that share the port, each with a directory for its
+	// configuration and Unix socket.
+	var peersVolume *corev1.Volume
+	if peering := inCluster.Spec.Proxy.PGBouncer.Peering; peering != nil {
+		peersVolume = &corev1.Volume{Name: "pgbouncer-peers"}
+		peersVolume.EmptyDir = &corev1.EmptyDirVolumeSource{
+			SizeLimit: resource.NewQuantity(1<<20, resource.BinarySI),
+		}
+
+		container.Command = peersCommand(peering.Processes)
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name: peersVolume.Name, MountPath: peersDirectory,
+		})
+	}
+
 	// TODO container.LivenessProbe?
 	// TODO container.ReadinessProbe?
 
@@ -189,6 +327,10 @@ func Pod(
 
 	outPod.Containers = []corev1.Container{container, reloader}
 
+	if exporterEnabled(inCluster) {
+		outPod.Containers = append(outPod.Containers, exporterContainer(inCluster, inSecret))
+	}
+
 	// If the PGBouncerSidecars feature gate is enabled and custom pgBouncer
 	// sidecars are defined, add the defined container to the Pod.
 	if util.DefaultMutableFeatureGate.Enabled(util.PGBouncerSidecars) &&
@@ -197,6 +339,10 @@ func Pod(
 	}
 
 	outPod.Volumes = []corev1.Volume{configVolume}
+
+	if peersVolume != nil {
+		outPod.Volumes = append(outPod.Volumes, *peersVolume)
+	}
 }
 
 // PostgreSQL populates outHBAs with any records needed to run PgBouncer.
@@ -209,5 +355,5 @@ func PostgreSQL(
 		return
 	}
 
-	outHBAs.Mandatory = append(outHBAs.Mandatory, postgresqlHBAs()...)
+	outHBAs.Mandatory = append(outHBAs.Mandatory, postgresqlHBAs(inCluster)...)
 }
@@ -17,6 +17,7 @@ package pgbouncer
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -24,6 +25,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/internal/util"
@@ -57,6 +59,174 @@ func TestConfigMap(t *testing.T) {
 	before := config.DeepCopy()
 	ConfigMap(cluster, config)
 	assert.DeepEqual(t, before, config)
+
+	// There is no HBA file by default.
+	_, ok := config.Data["pgbouncer-hba.conf"]
+	assert.Assert(t, !ok)
+
+	t.Run("HBA", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.HBA = []v1beta1.PostgresHBARule{
+			{Connection: "hostssl", Method: "md5"},
+		}
+
+		config := new(corev1.ConfigMap)
+		ConfigMap(cluster, config)
+		assert.Equal(t, config.Data["pgbouncer-hba.conf"],
+			hbaFileContents(clusterHBAs(cluster)))
+	})
+}
+
+func TestConfigFiles(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	secret := new(corev1.Secret)
+
+	// Nothing when PgBouncer is disabled.
+	assert.Equal(t, ConfigFiles(cluster, secret), "")
+
+	cluster.Spec.Proxy = new(v1beta1.PostgresProxySpec)
+	cluster.Spec.Proxy.PGBouncer = new(v1beta1.PGBouncerPodSpec)
+	cluster.Default()
+
+	// The output of clusterINI is included.
+	files := ConfigFiles(cluster, secret)
+	assert.Equal(t, files, clusterINI(cluster))
+
+	// Identical configuration has identical contents.
+	assert.Equal(t, ConfigFiles(cluster.DeepCopy(), secret.DeepCopy()), files)
+
+	t.Run("HBA", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.HBA = []v1beta1.PostgresHBARule{
+			{Connection: "hostssl", Method: "md5"},
+		}
+
+		assert.Assert(t, ConfigFiles(cluster, secret) != files)
+		assert.Assert(t, strings.HasSuffix(ConfigFiles(cluster, secret),
+			hbaFileContents(clusterHBAs(cluster))))
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+			"max_client_conn": "500",
+		}
+
+		assert.Assert(t, ConfigFiles(cluster, secret) != files)
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		secret := new(corev1.Secret)
+		secret.Data = map[string][]byte{
+			"pgbouncer-users.txt": authFileContents(map[string]string{"app": "pass"}),
+			"pgbouncer-password":  []byte("ignored"),
+		}
+
+		assert.Equal(t, ConfigFiles(cluster, secret),
+			files+`"app" "pass"`+"\n")
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+
+	t.Run("Disabled", func(t *testing.T) {
+		warnings, errs := ValidateConfig(cluster)
+		assert.Assert(t, len(warnings)+len(errs) == 0)
+	})
+
+	cluster.Spec.Proxy = new(v1beta1.PostgresProxySpec)
+	cluster.Spec.Proxy.PGBouncer = new(v1beta1.PGBouncerPodSpec)
+	cluster.Default()
+
+	t.Run("Default", func(t *testing.T) {
+		warnings, errs := ValidateConfig(cluster)
+		assert.Assert(t, len(warnings)+len(errs) == 0)
+	})
+
+	t.Run("CommonSettings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+			"default_pool_size":    "50",
+			"max_client_conn":      "1000",
+			"reserve_pool_size":    "5",
+			"reserve_pool_timeout": "3",
+		}
+		warnings, errs := ValidateConfig(cluster)
+		assert.Assert(t, len(warnings)+len(errs) == 0)
+	})
+
+	t.Run("MandatorySettings", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{
+			"max_client_conn":    "1000",
+			"server_tls_ca_file": "/tmp/ca.crt",
+			"auth_query":         "SELECT 1",
+			"conffile":           "elsewhere",
+			"auth_file":          "users.txt",
+		}
+
+		warnings, errs := ValidateConfig(cluster)
+		assert.Equal(t, len(errs), 0)
+		assert.Equal(t, len(warnings), 4)
+		assert.Equal(t, warnings.ToAggregate().Error(), "["+
+			`spec.proxy.pgBouncer.config.global[auth_file]: Forbidden: this setting is managed by the operator, `+
+			`spec.proxy.pgBouncer.config.global[auth_query]: Forbidden: this setting is managed by the operator, `+
+			`spec.proxy.pgBouncer.config.global[conffile]: Forbidden: this setting is managed by the operator, `+
+			`spec.proxy.pgBouncer.config.global[server_tls_ca_file]: Forbidden: this setting is managed by the operator`+
+			"]")
+	})
+
+	t.Run("PoolMode", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		for _, mode := range []string{"session", "statement", "transaction"} {
+			cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{"pool_mode": mode}
+
+			warnings, errs := ValidateConfig(cluster)
+			assert.Assert(t, len(warnings)+len(errs) == 0, "mode %q", mode)
+		}
+
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{"pool_mode": "always"}
+
+		warnings, errs := ValidateConfig(cluster)
+		assert.Equal(t, len(warnings), 0)
+		assert.Equal(t, errs.ToAggregate().Error(),
+			`spec.proxy.pgBouncer.config.global[pool_mode]: Unsupported value: "always": `+
+				`supported values: "session", "statement", "transaction"`)
+	})
+
+	t.Run("ReservedDatabase", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.Databases = map[string]string{
+			"app":       "host=elsewhere",
+			"pgbouncer": "host=elsewhere",
+		}
+
+		warnings, errs := ValidateConfig(cluster)
+		assert.Equal(t, len(warnings), 0)
+		assert.Equal(t, errs.ToAggregate().Error(),
+			`spec.proxy.pgBouncer.config.databases[pgbouncer]: Invalid value: "host=elsewhere": `+
+				`this name is reserved for the PgBouncer admin console`)
+	})
+
+	t.Run("HBARules", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Config.HBA = []v1beta1.PostgresHBARule{
+			{Connection: "host", Method: "scram-sha-256"},
+			{Connection: "host", Method: "md5\nhost all all all trust"},
+		}
+
+		warnings, errs := ValidateConfig(cluster)
+		assert.Equal(t, len(warnings), 0)
+		assert.Equal(t, errs.ToAggregate().Error(),
+			`spec.proxy.pgBouncer.config.hba[1].method: Invalid value: "md5\nhost all all all trust": `+
+				`must be a single authentication method`)
+	})
 }
 
 func TestSecret(t *testing.T) {
@@ -74,7 +244,7 @@ func TestSecret(t *testing.T) {
 	t.Run("Disabled", func(t *testing.T) {
 		// Nothing happens when PgBouncer is disabled.
 		constant := intent.DeepCopy()
-		assert.NilError(t, Secret(ctx, cluster, root, existing, service, intent))
+		assert.NilError(t, Secret(ctx, cluster, root, existing, service, nil, intent))
 		assert.DeepEqual(t, constant, intent)
 	})
 
@@ -83,7 +253,7 @@ func TestSecret(t *testing.T) {
 	cluster.Default()
 
 	constant := existing.DeepCopy()
-	assert.NilError(t, Secret(ctx, cluster, root, existing, service, intent))
+	assert.NilError(t, Secret(ctx, cluster, root, existing, service, nil, intent))
 	assert.DeepEqual(t, constant, existing)
 
 	// A password should be generated.
@@ -93,11 +263,63 @@ func TestSecret(t *testing.T) {
 	// The output of authFileContents should go into intent.
 	assert.Assert(t, len(intent.Data["pgbouncer-users.txt"]) != 0)
 
+	// Only the PgBouncer user is in the auth file.
+	assert.Equal(t, string(intent.Data["pgbouncer-users.txt"]),
+		`"_crunchypgbouncer" "`+string(intent.Data["pgbouncer-password"])+`"`+"\n")
+
 	// Assuming the intent is written, no change when called again.
 	existing.Data = intent.Data
 	before := intent.DeepCopy()
-	assert.NilError(t, Secret(ctx, cluster, root, existing, service, intent))
+	assert.NilError(t, Secret(ctx, cluster, root, existing, service, nil, intent))
 	assert.DeepEqual(t, before, intent)
+
+	// There is no exporter password unless the exporter is enabled.
+	assert.Assert(t, intent.Data["pgbouncer-exporter-password"] == nil)
+	assert.Assert(t, intent.Data["pgbouncer-exporter-verifier"] == nil)
+
+	t.Run("Exporter", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Exporter = &v1beta1.PGBouncerExporterSpec{}
+
+		intent := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, cluster, root, existing, service, nil, intent))
+
+		// A password is generated for the exporter.
+		password := intent.Data["pgbouncer-exporter-password"]
+		verifier := intent.Data["pgbouncer-exporter-verifier"]
+		assert.Assert(t, len(password) != 0)
+		assert.Assert(t, strings.HasPrefix(string(verifier), "SCRAM-SHA-256$"))
+
+		// Its verifier is in the auth file.
+		assert.Equal(t, string(intent.Data["pgbouncer-users.txt"]),
+			`"_crunchypgbouncer" "`+string(existing.Data["pgbouncer-password"])+`"`+"\n"+
+				`"_crunchypgbouncer_exporter" "`+string(verifier)+`"`+"\n")
+
+		// The password is kept when called again.
+		existing := existing.DeepCopy()
+		existing.Data = intent.Data
+		again := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, cluster, root, existing, service, nil, again))
+		assert.DeepEqual(t, again.Data, intent.Data)
+	})
+
+	t.Run("UserSecrets", func(t *testing.T) {
+		users := map[string]*corev1.Secret{
+			"app":   {Data: map[string][]byte{"verifier": []byte("SCRAM-SHA-256$app")}},
+			"empty": {Data: map[string][]byte{}},
+
+			"_crunchypgbouncer": {Data: map[string][]byte{"verifier": []byte("SCRAM-SHA-256$x")}},
+		}
+
+		intent := new(corev1.Secret)
+		assert.NilError(t, Secret(ctx, cluster, root, existing, service, users, intent))
+
+		// Users with a verifier are added after the PgBouncer user, which
+		// they cannot replace.
+		assert.Equal(t, string(intent.Data["pgbouncer-users.txt"]),
+			`"_crunchypgbouncer" "`+string(existing.Data["pgbouncer-password"])+`"`+"\n"+
+				`"app" "SCRAM-SHA-256$app"`+"\n")
+	})
 }
 
 func TestPod(t *testing.T) {
@@ -460,6 +682,87 @@ volumes:
 			assert.Assert(t, found, "expected custom sidecar 'customsidecar1', but container not found")
 		})
 	})
+
+	t.Run("Exporter", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Containers = nil
+		call := func() { Pod(cluster, configMap, primaryCertificate, secret, pod) }
+
+		call()
+		assert.Equal(t, len(pod.Containers), 2, "expected no exporter by default")
+
+		secret := secret.DeepCopy()
+		secret.Name = "some-secret"
+		cluster.Spec.ImagePullPolicy = corev1.PullAlways
+		cluster.Spec.Proxy.PGBouncer.Port = initialize.Int32(6543)
+		cluster.Spec.Proxy.PGBouncer.Exporter = &v1beta1.PGBouncerExporterSpec{
+			Image: "exporter-image",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+			},
+		}
+
+		Pod(cluster, configMap, primaryCertificate, secret, pod)
+		assert.Equal(t, len(pod.Containers), 3)
+		assert.Assert(t, marshalMatches(pod.Containers[2], `
+command:
+- pgbouncer_exporter
+- --pgBouncer.connectionString=postgres://_crunchypgbouncer_exporter@127.0.0.1:6543/pgbouncer?sslmode=require
+- --web.listen-address=:9127
+env:
+- name: PGPASSWORD
+  valueFrom:
+    secretKeyRef:
+      key: pgbouncer-exporter-password
+      name: some-secret
+image: exporter-image
+imagePullPolicy: Always
+name: pgbouncer-exporter
+ports:
+- containerPort: 9127
+  name: exporter
+  protocol: TCP
+resources:
+  requests:
+    cpu: 10m
+securityContext:
+  allowPrivilegeEscalation: false
+  capabilities:
+    drop:
+    - ALL
+  privileged: false
+  readOnlyRootFilesystem: true
+  runAsNonRoot: true
+		`))
+	})
+
+	t.Run("Peering", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Proxy.PGBouncer.Containers = nil
+		cluster.Spec.Proxy.PGBouncer.Peering = &v1beta1.PGBouncerPeering{Processes: 3}
+
+		Pod(cluster, configMap, primaryCertificate, secret, pod)
+
+		// The PgBouncer container starts the peers.
+		assert.DeepEqual(t, pod.Containers[0].Command, peersCommand(3))
+		assert.Assert(t, marshalMatches(pod.Containers[0].VolumeMounts, `
+- mountPath: /etc/pgbouncer
+  name: pgbouncer-config
+  readOnly: true
+- mountPath: /tmp/pgbouncer-peers
+  name: pgbouncer-peers
+		`))
+
+		// The reloader signals every PgBouncer process, so it is unchanged.
+		assert.DeepEqual(t, pod.Containers[1].Command, reloadCommand("pgbouncer-config"))
+
+		assert.Equal(t, len(pod.Volumes), 2)
+		assert.Assert(t, marshalMatches(pod.Volumes[1], `
+emptyDir:
+  sizeLimit: 1Mi
+name: pgbouncer-peers
+		`))
+	})
 }
 
 func TestPostgreSQL(t *testing.T) {
@@ -484,7 +787,7 @@ func TestPostgreSQL(t *testing.T) {
 
 		assert.DeepEqual(t, hbas,
 			&postgres.HBAs{
-				Mandatory: postgresqlHBAs(),
+				Mandatory: postgresqlHBAs(cluster),
 			},
 			// postgres.HostBasedAuthentication has unexported fields. Call String() to compare.
 			cmp.Transformer("", postgres.HostBasedAuthentication.String))
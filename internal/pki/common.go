@@ -46,14 +46,20 @@ func generateSerialNumber() (*big.Int, error) {
 	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 }
 
+const (
+	// defaultLeafExpiration is how long leaf certificates are valid by default.
+	defaultLeafExpiration = time.Hour * 24 * 365
+
+	// leafStartValid is when leaf certificates become valid relative to the
+	// time they are generated.
+	leafStartValid = time.Hour * -1
+)
+
 func generateLeafCertificate(
 	signer *x509.Certificate, signerPrivate *ecdsa.PrivateKey,
 	signeePublic *ecdsa.PublicKey, serialNumber *big.Int,
-	commonName string, dnsNames []string,
+	commonName string, dnsNames []string, leafExpiration time.Duration,
 ) (*x509.Certificate, error) {
-	const leafExpiration = time.Hour * 24 * 365
-	const leafStartValid = time.Hour * -1
-
 	now := currentTime()
 	template := &x509.Certificate{
 		BasicConstraintsValid: true,
@@ -53,6 +53,15 @@ func (c Certificate) DNSNames() []string {
 	return append([]string{}, c.x509.DNSNames...)
 }
 
+// NotAfter returns the time after which c is no longer valid. It is the zero
+// Time when c is empty.
+func (c Certificate) NotAfter() time.Time {
+	if c.x509 == nil {
+		return time.Time{}
+	}
+	return c.x509.NotAfter
+}
+
 // hasSubject checks that c has these values in its subject.
 func (c Certificate) hasSubject(commonName string, dnsNames []string) bool {
 	ok := c.x509 != nil &&
@@ -86,6 +95,15 @@ type LeafCertificate struct {
 type RootCertificateAuthority struct {
 	Certificate Certificate
 	PrivateKey  PrivateKey
+
+	// LeafDuration is how long the leaf certificates it generates are valid.
+	// When zero, they are valid for one year.
+	LeafDuration time.Duration
+
+	// LeafRenewBefore is how long before they expire that leaf certificates
+	// are regenerated. When zero, they are regenerated after two thirds of
+	// their lifetime.
+	LeafRenewBefore time.Duration
 }
 
 // NewRootCertificateAuthority generates a new key and self-signed certificate
@@ -157,12 +175,20 @@ func (root *RootCertificateAuthority) GenerateLeafCertificate(
 		leaf.PrivateKey.ecdsa = key
 		leaf.Certificate.x509, err = generateLeafCertificate(
 			root.Certificate.x509, root.PrivateKey.ecdsa, &key.PublicKey, serial,
-			commonName, dnsNames)
+			commonName, dnsNames, root.leafDuration())
 	}
 
 	return &leaf, err
 }
 
+// leafDuration returns how long new leaf certificates are valid.
+func (root *RootCertificateAuthority) leafDuration() time.Duration {
+	if root.LeafDuration > 0 {
+		return root.LeafDuration
+	}
+	return defaultLeafExpiration
+}
+
 // leafIsValid checks if leaf is valid according to this package's policies and
 // is signed by root.
 func (root *RootCertificateAuthority) leafIsValid(leaf *LeafCertificate) bool {
@@ -197,11 +223,20 @@ func (root *RootCertificateAuthority) leafIsValid(leaf *LeafCertificate) bool {
 		leaf.PrivateKey.ecdsa != nil &&
 		leaf.PrivateKey.ecdsa.PublicKey.Equal(leaf.Certificate.x509.PublicKey)
 
-	// It is not yet past the "renewal by" time,
-	// as defined by the before and after times of the certificate's expiration
-	// and the default ratio
-	ok = ok && isBeforeRenewalTime(leaf.Certificate.x509.NotBefore,
-		leaf.Certificate.x509.NotAfter)
+	// It is not yet past the "renewal by" time, as defined by LeafRenewBefore
+	// or by the before and after times of the certificate's expiration and the
+	// default ratio.
+	if root.LeafRenewBefore > 0 {
+		ok = ok && currentTime().Before(
+			leaf.Certificate.x509.NotAfter.Add(-1*root.LeafRenewBefore))
+	} else {
+		ok = ok && isBeforeRenewalTime(leaf.Certificate.x509.NotBefore,
+			leaf.Certificate.x509.NotAfter)
+	}
+
+	// It is not valid for longer than LeafDuration.
+	ok = ok && leaf.Certificate.x509.NotAfter.Sub(leaf.Certificate.x509.NotBefore) <=
+		root.leafDuration()-leafStartValid
 
 	return ok
 }
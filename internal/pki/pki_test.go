@@ -45,6 +45,15 @@ func TestCertificateDNSNames(t *testing.T) {
 	assert.Assert(t, zero.DNSNames() == nil)
 }
 
+func TestCertificateNotAfter(t *testing.T) {
+	zero := Certificate{}
+	assert.Assert(t, zero.NotAfter().IsZero())
+
+	root, err := NewRootCertificateAuthority()
+	assert.NilError(t, err)
+	assert.Equal(t, root.Certificate.NotAfter(), root.Certificate.x509.NotAfter)
+}
+
 func TestCertificateHasSubject(t *testing.T) {
 	zero := Certificate{}
 
@@ -183,7 +192,9 @@ func TestRootIsInvalid(t *testing.T) {
 		leaf, err := root.GenerateLeafCertificate("", nil)
 		assert.NilError(t, err)
 
-		assert.Assert(t, !RootIsValid((*RootCertificateAuthority)(leaf)))
+		assert.Assert(t, !RootIsValid(&RootCertificateAuthority{
+			Certificate: leaf.Certificate, PrivateKey: leaf.PrivateKey,
+		}))
 	})
 
 	t.Run("TooEarly", func(t *testing.T) {
@@ -367,7 +378,9 @@ func TestLeafIsInvalid(t *testing.T) {
 	})
 
 	t.Run("IsAuthority", func(t *testing.T) {
-		assert.Assert(t, !root.leafIsValid((*LeafCertificate)(root)))
+		assert.Assert(t, !root.leafIsValid(&LeafCertificate{
+			Certificate: root.Certificate, PrivateKey: root.PrivateKey,
+		}))
 	})
 
 	t.Run("TooEarly", func(t *testing.T) {
@@ -446,6 +459,65 @@ func TestRegenerateLeaf(t *testing.T) {
 	assert.Assert(t, !after.Certificate.Equal(before.Certificate))
 }
 
+func TestLeafDurationAndRenewal(t *testing.T) {
+	const day = 24 * time.Hour
+
+	root, err := NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	short := *root
+	short.LeafDuration = 90 * day
+
+	t.Run("Duration", func(t *testing.T) {
+		leaf, err := short.GenerateLeafCertificate("some-cn", nil)
+		assert.NilError(t, err)
+
+		lifetime := leaf.Certificate.NotAfter().Sub(time.Now())
+		assert.Assert(t, lifetime > 89*day && lifetime <= 90*day, "got %v", lifetime)
+
+		// Shorter certificates are acceptable to either policy.
+		assert.Assert(t, short.leafIsValid(leaf))
+		assert.Assert(t, root.leafIsValid(leaf))
+
+		// Longer certificates are replaced.
+		long, err := root.GenerateLeafCertificate("some-cn", nil)
+		assert.NilError(t, err)
+		assert.Assert(t, !short.leafIsValid(long))
+
+		regenerated, err := short.RegenerateLeafWhenNecessary(long, "some-cn", nil)
+		assert.NilError(t, err)
+		assert.Assert(t, !regenerated.Certificate.Equal(long.Certificate))
+		assert.Assert(t, regenerated.Certificate.NotAfter().Before(long.Certificate.NotAfter()))
+	})
+
+	t.Run("RenewBefore", func(t *testing.T) {
+		original := currentTime
+		t.Cleanup(func() { currentTime = original })
+
+		renewing := short
+		renewing.LeafRenewBefore = 10 * day
+
+		leaf, err := renewing.GenerateLeafCertificate("some-cn", nil)
+		assert.NilError(t, err)
+
+		// Outside the renewal window, the certificate is left alone.
+		currentTime = func() time.Time { return original().Add(70 * day) }
+		same, err := renewing.RegenerateLeafWhenNecessary(leaf, "some-cn", nil)
+		assert.NilError(t, err)
+		assert.Assert(t, same == leaf)
+
+		// The default ratio would renew before now.
+		assert.Assert(t, !short.leafIsValid(leaf))
+
+		// Inside the renewal window, the certificate is regenerated.
+		currentTime = func() time.Time { return original().Add(81 * day) }
+		renewed, err := renewing.RegenerateLeafWhenNecessary(leaf, "some-cn", nil)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed != leaf)
+		assert.Assert(t, renewed.Certificate.NotAfter().After(leaf.Certificate.NotAfter()))
+	})
+}
+
 func basicOpenSSLVerify(t *testing.T, openssl string, root, leaf Certificate) {
 	verify := func(t testing.TB, args ...string) {
 		t.Helper()
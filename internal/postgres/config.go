@@ -56,6 +56,11 @@ safelink() (
 
 	// configMountPath is where to mount additional config files
 	configMountPath = "/etc/postgres"
+
+	// postgresUserID and postgresGroupID are the UID and GID of the "postgres"
+	// user in Crunchy PostgreSQL images.
+	postgresUserID  = 26
+	postgresGroupID = 26
 )
 
 // ConfigDirectory returns the absolute path to $PGDATA for cluster.
@@ -84,6 +89,16 @@ func WALDirectory(
 	return fmt.Sprintf("%s/pg%d_wal", walStorage, cluster.Spec.PostgresVersion)
 }
 
+// ServicePort returns the port on which the primary Service of cluster accepts
+// connections. This is the port PostgreSQL listens on unless spec.service.port
+// is set.
+func ServicePort(cluster *v1beta1.PostgresCluster) int32 {
+	if spec := cluster.Spec.Service; spec != nil && spec.Port != nil {
+		return *spec.Port
+	}
+	return *cluster.Spec.Port
+}
+
 // Environment returns the environment variables required to invoke PostgreSQL
 // utilities.
 func Environment(cluster *v1beta1.PostgresCluster) []corev1.EnvVar {
@@ -121,7 +136,8 @@ func Environment(cluster *v1beta1.PostgresCluster) []corev1.EnvVar {
 
 // reloadCommand returns an entrypoint that convinces PostgreSQL to reload
 // certificate files when they change. The process will appear as name in `ps`
-// and `top`.
+// and `top`. Directories appended to the command are watched, too; PostgreSQL
+// reloads when any of them change. See [AddReloadDirectory].
 func reloadCommand(name string) []string {
 	// Use a Bash loop to periodically check the mtime of the mounted
 	// certificate volume. When it changes, copy the replication certificate,
@@ -156,6 +172,14 @@ while read -r -t 5 -u "${fd}" || true; do
     exec {fd}>&- && exec {fd}<> <(:)
     stat --format='Loaded certificates dated %%y' "${directory}"
   fi
+  for config; do
+    if [ "${config}" -nt "/proc/self/fd/${fd}" ] &&
+      pkill -HUP --exact --parent=1 postgres
+    then
+      exec {fd}>&- && exec {fd}<> <(:)
+      stat --format='Loaded configuration dated %%y' "${config}"
+    fi
+  done
 done
 `,
 		naming.CertMountPath,
@@ -166,12 +190,39 @@ done
 	)
 
 	// Elide the above script from `ps` and `top` by wrapping it in a function
-	// and calling that.
-	wrapper := `monitor() {` + script + `}; export -f monitor; exec -a "$0" bash -ceu monitor`
+	// and calling that with any directories.
+	wrapper := `monitor() {` + script + `}; export -f monitor; exec -a "$0" bash -ceu 'monitor "$@"' "$0" "$@"`
 
 	return []string{"bash", "-ceu", "--", wrapper, name}
 }
 
+// permissionsCommand returns the command of an init container that gives
+// ownership of the PostgreSQL volumes and directories to uid and gid.
+// Like the "OnRootMismatch" policy of "securityContext.fsGroup", a directory
+// is changed recursively only when its owner or group is wrong.
+func permissionsCommand(uid, gid int64, directories ...string) []string {
+	args := append([]string{fmt.Sprint(uid), fmt.Sprint(gid)}, directories...)
+
+	script := strings.Join([]string{
+		`declare -r uid="$1" gid="$2"; shift 2`,
+
+		// Function to log values in a basic structured format.
+		`results() { printf '::postgres-operator: %s::%s\n' "$@"; }`,
+
+		// Directories that do not exist yet are created by the startup
+		// container with the correct owner.
+		`for directory; do`,
+		`  [ -d "${directory}" ] || continue`,
+		`  [ "$(stat --format='%u:%g' "${directory}")" = "${uid}:${gid}" ] ||`,
+		`    chown --recursive --no-dereference "${uid}:${gid}" "${directory}"`,
+		`  chmod u+rwx "${directory}"`,
+		`  results 'directory' "${directory}" 'owner' "$(stat --format='%u:%g %A' "${directory}")"`,
+		`done`,
+	}, "\n")
+
+	return append([]string{"bash", "-ceu", "--", script, "permissions"}, args...)
+}
+
 // startupCommand returns an entrypoint that prepares the filesystem for
 // PostgreSQL.
 func startupCommand(
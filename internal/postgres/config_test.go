@@ -16,6 +16,7 @@
 package postgres
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,6 +27,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -57,6 +59,22 @@ func TestWALDirectory(t *testing.T) {
 	assert.Equal(t, WALDirectory(cluster, instance), "/pgwal/pg13_wal")
 }
 
+func TestServicePort(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	cluster.Spec.Port = initialize.Int32(5440)
+
+	// The PostgreSQL port by default.
+	assert.Equal(t, ServicePort(cluster), int32(5440))
+
+	cluster.Spec.Service = new(v1beta1.PostgresServiceSpec)
+	assert.Equal(t, ServicePort(cluster), int32(5440))
+
+	cluster.Spec.Service.Port = initialize.Int32(15432)
+	assert.Equal(t, ServicePort(cluster), int32(15432))
+	assert.Equal(t, *cluster.Spec.Port, int32(5440))
+}
+
 func TestBashSafeLink(t *testing.T) {
 	// macOS lacks `realpath` which is part of GNU coreutils.
 	if _, err := exec.LookPath("realpath"); err != nil {
@@ -317,6 +335,45 @@ func TestBashSafeLinkPrettyYAML(t *testing.T) {
 		"expected literal block scalar, got:\n%s", b)
 }
 
+func TestPermissionsCommand(t *testing.T) {
+	command := permissionsCommand(26, 26, "/pgdata", "/pgdata/pg13")
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[4:],
+		[]string{"permissions", "26", "26", "/pgdata", "/pgdata/pg13"})
+
+	t.Run("Execute", func(t *testing.T) {
+		// Run as the current user which already owns the directories.
+		dir := t.TempDir()
+		uid, gid := fmt.Sprint(os.Getuid()), fmt.Sprint(os.Getgid())
+		data := filepath.Join(dir, "data")
+		assert.NilError(t, os.Mkdir(data, 0o500))
+
+		cmd := exec.Command("bash", "-ceu", "--", command[3], "-",
+			uid, gid, data, filepath.Join(dir, "missing"))
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+
+		// Missing directories are skipped; existing ones become writable.
+		assert.Equal(t, string(output), fmt.Sprintf(
+			"::postgres-operator: directory::%s\n::postgres-operator: owner::%s:%s drwx------\n",
+			data, uid, gid))
+	})
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+
+		// Write out that inline script.
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		// Expect shellcheck to be happy.
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+}
+
 func TestStartupCommand(t *testing.T) {
 	shellcheck := require.ShellCheck(t)
 
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 
 	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // CreateDatabasesInPostgreSQL calls exec to create databases that do not exist
@@ -81,3 +82,103 @@ SELECT pg_catalog.format('CREATE DATABASE %I',
 
 	return err
 }
+
+// WriteDatabasesInPostgreSQL calls exec to create databases and their owners
+// that do not exist in PostgreSQL. Owners that do not exist are created with
+// the LOGIN option only when logins contains their name. Once they exist, it
+// transfers each database to its specified owner. It never drops anything.
+func WriteDatabasesInPostgreSQL(
+	ctx context.Context, exec Executor,
+	databases []v1beta1.PostgresDatabaseSpec, logins map[string]bool,
+) error {
+	log := logging.FromContext(ctx)
+
+	var err error
+	var sql bytes.Buffer
+
+	// Prevent unexpected dereferences by emptying "search_path". The "pg_catalog"
+	// schema is still searched, and only temporary objects can be created.
+	// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-SEARCH-PATH
+	_, _ = sql.WriteString(`SET search_path TO '';`)
+
+	// Fill a temporary table with the JSON of the database specifications.
+	// "\copy" reads from subsequent lines until the special line "\.".
+	// - https://www.postgresql.org/docs/current/app-psql.html#APP-PSQL-META-COMMANDS-COPY
+	_, _ = sql.WriteString(`
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+`)
+
+	encoder := json.NewEncoder(&sql)
+	encoder.SetEscapeHTML(false)
+
+	for i := range databases {
+		var owner interface{}
+		if databases[i].Owner != "" {
+			owner = databases[i].Owner
+		}
+
+		if err == nil {
+			err = encoder.Encode(map[string]interface{}{
+				"database": databases[i].Name,
+				"login":    logins[string(databases[i].Owner)],
+				"owner":    owner,
+			})
+		}
+	}
+	_, _ = sql.WriteString(`\.` + "\n")
+
+	// Create owners that do not already exist.
+	// - https://www.postgresql.org/docs/current/sql-createrole.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('CREATE ROLE %I WITH %s', owners.owner,
+       CASE WHEN owners.login THEN 'LOGIN' ELSE 'NOLOGIN' END)
+  FROM (SELECT DISTINCT
+        pg_catalog.json_extract_path_text(input.data, 'owner') AS owner,
+        pg_catalog.json_extract_path_text(input.data, 'login')::boolean AS login
+        FROM input) AS owners
+ WHERE owners.owner IS NOT NULL
+   AND NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = owners.owner)
+ ORDER BY owners.owner
+\gexec
+`)
+
+	// Create databases that do not already exist.
+	// - https://www.postgresql.org/docs/current/sql-createdatabase.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('CREATE DATABASE %I',
+       pg_catalog.json_extract_path_text(input.data, 'database'))
+  FROM input
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_database
+       WHERE datname = pg_catalog.json_extract_path_text(input.data, 'database'))
+ ORDER BY input.id
+\gexec
+`)
+
+	// Transfer databases to their specified owners.
+	// - https://www.postgresql.org/docs/current/sql-alterdatabase.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('ALTER DATABASE %I OWNER TO %I',
+       pg_catalog.json_extract_path_text(input.data, 'database'),
+       pg_catalog.json_extract_path_text(input.data, 'owner'))
+  FROM input
+  JOIN pg_catalog.pg_database
+    ON datname = pg_catalog.json_extract_path_text(input.data, 'database')
+ WHERE pg_catalog.json_extract_path_text(input.data, 'owner') IS NOT NULL
+   AND pg_catalog.pg_get_userbyid(datdba) <> pg_catalog.json_extract_path_text(input.data, 'owner')
+ ORDER BY input.id
+\gexec
+`)
+
+	stdout, stderr, err := exec.Exec(ctx, &sql,
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("wrote PostgreSQL databases", "stdout", stdout, "stderr", stderr)
+
+	return err
+}
@@ -25,6 +25,7 @@ import (
 	"gotest.tools/v3/assert"
 
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestCreateDatabasesInPostgreSQL(t *testing.T) {
@@ -101,3 +102,108 @@ SELECT pg_catalog.format('CREATE DATABASE %I',
 		assert.Equal(t, calls, 1)
 	})
 }
+
+func TestWriteDatabasesInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteDatabasesInPostgreSQL(ctx, exec, nil, nil))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimLeft(`
+SET search_path TO '';
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+\.
+
+SELECT pg_catalog.format('CREATE ROLE %I WITH %s', owners.owner,
+       CASE WHEN owners.login THEN 'LOGIN' ELSE 'NOLOGIN' END)
+  FROM (SELECT DISTINCT
+        pg_catalog.json_extract_path_text(input.data, 'owner') AS owner,
+        pg_catalog.json_extract_path_text(input.data, 'login')::boolean AS login
+        FROM input) AS owners
+ WHERE owners.owner IS NOT NULL
+   AND NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = owners.owner)
+ ORDER BY owners.owner
+\gexec
+
+SELECT pg_catalog.format('CREATE DATABASE %I',
+       pg_catalog.json_extract_path_text(input.data, 'database'))
+  FROM input
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_database
+       WHERE datname = pg_catalog.json_extract_path_text(input.data, 'database'))
+ ORDER BY input.id
+\gexec
+
+SELECT pg_catalog.format('ALTER DATABASE %I OWNER TO %I',
+       pg_catalog.json_extract_path_text(input.data, 'database'),
+       pg_catalog.json_extract_path_text(input.data, 'owner'))
+  FROM input
+  JOIN pg_catalog.pg_database
+    ON datname = pg_catalog.json_extract_path_text(input.data, 'database')
+ WHERE pg_catalog.json_extract_path_text(input.data, 'owner') IS NOT NULL
+   AND pg_catalog.pg_get_userbyid(datdba) <> pg_catalog.json_extract_path_text(input.data, 'owner')
+ ORDER BY input.id
+\gexec
+`, "\n"))
+			return nil
+		}
+
+		assert.NilError(t, WriteDatabasesInPostgreSQL(ctx, exec, nil, nil))
+		assert.Equal(t, calls, 1)
+
+		assert.NilError(t, WriteDatabasesInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresDatabaseSpec{}, map[string]bool{}))
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `
+\copy input (data) from stdin with (format text)
+{"database":"app","login":true,"owner":"app-user"}
+{"database":"warehouse","login":false,"owner":"analysts"}
+{"database":"no owner","login":false,"owner":null}
+\.
+`))
+			return nil
+		}
+
+		assert.NilError(t, WriteDatabasesInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresDatabaseSpec{
+				{Name: "app", Owner: "app-user"},
+				{Name: "warehouse", Owner: "analysts"},
+				{Name: "no owner"},
+			},
+			map[string]bool{"app-user": true},
+		))
+		assert.Equal(t, calls, 1)
+	})
+}
@@ -0,0 +1,172 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// preloadLibraries are the shared libraries that extensions require to be
+// loaded when PostgreSQL starts, indexed by extension name.
+var preloadLibraries = map[string]string{
+	"pg_cron":            "pg_cron",            // https://github.com/citusdata/pg_cron#setting-up-pg_cron
+	"pg_stat_statements": "pg_stat_statements", // https://www.postgresql.org/docs/current/pgstatstatements.html
+	"pgaudit":            "pgaudit",            // https://github.com/pgaudit/pgaudit#settings
+	"timescaledb":        "timescaledb",        // https://docs.timescale.com/self-hosted/latest/configuration/
+}
+
+// Extensions returns the extensions to create in cluster. These are those in
+// spec.extensions followed by pg_stat_statements when
+// spec.monitoring.enablePGStatStatements is true.
+func Extensions(cluster *v1beta1.PostgresCluster) []v1beta1.PostgresExtensionSpec {
+	extensions := cluster.Spec.Extensions
+
+	if cluster.Spec.Monitoring != nil && cluster.Spec.Monitoring.EnablePGStatStatements {
+		const name = "pg_stat_statements"
+		for i := range extensions {
+			if extensions[i].Name == name {
+				return extensions
+			}
+		}
+
+		extensions = append(extensions[:len(extensions):len(extensions)],
+			v1beta1.PostgresExtensionSpec{Name: name})
+	}
+
+	return extensions
+}
+
+// ExtensionParameters sets the parameters required by the extensions of
+// cluster. See [Extensions].
+func ExtensionParameters(cluster *v1beta1.PostgresCluster, outParameters *Parameters) {
+	shared := outParameters.Mandatory.Value("shared_preload_libraries")
+
+	loaded := make(map[string]bool)
+	for _, library := range strings.Split(shared, ",") {
+		loaded[strings.TrimSpace(library)] = true
+	}
+
+	// Append any libraries that are not already loaded.
+	// PostgreSQL must be restarted when changing this value.
+	// - https://www.postgresql.org/docs/current/runtime-config-client.html
+	libraries := []string{}
+	if shared != "" {
+		libraries = append(libraries, shared)
+	}
+	for _, extension := range Extensions(cluster) {
+		if library, ok := preloadLibraries[string(extension.Name)]; ok && !loaded[library] {
+			loaded[library] = true
+			libraries = append(libraries, library)
+		}
+	}
+
+	if len(libraries) > 0 {
+		outParameters.Mandatory.Add("shared_preload_libraries", strings.Join(libraries, ","))
+	}
+}
+
+// CreateExtensionsInPostgreSQL calls exec to create extensions that do not
+// exist in every database of PostgreSQL. The pg_cron extension can exist in
+// only one database, so it is created only in "cron.database_name". It never
+// drops anything. The error includes any messages from psql.
+func CreateExtensionsInPostgreSQL(
+	ctx context.Context, exec Executor, extensions []v1beta1.PostgresExtensionSpec,
+) error {
+	log := logging.FromContext(ctx)
+
+	// Pass the extension specifications as JSON in a psql variable. The
+	// variable is quoted as a literal where it is used below.
+	// - https://www.postgresql.org/docs/current/app-psql.html#APP-PSQL-INTERPOLATION
+	type extension struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema,omitempty"`
+	}
+	var everywhere, cron []extension
+	for i := range extensions {
+		e := extension{
+			Name:   string(extensions[i].Name),
+			Schema: string(extensions[i].Schema),
+		}
+		if e.Name == "pg_cron" {
+			cron = append(cron, e)
+		} else {
+			everywhere = append(everywhere, e)
+		}
+	}
+
+	sql := strings.Join([]string{
+		// Quiet NOTICE messages from IF NOT EXISTS statements.
+		// - https://www.postgresql.org/docs/current/runtime-config-client.html
+		`SET client_min_messages = WARNING;`,
+
+		// Create schemas that do not already exist.
+		// - https://www.postgresql.org/docs/current/sql-createschema.html
+		`SELECT pg_catalog.format('CREATE SCHEMA IF NOT EXISTS %I', input.schema)`,
+		`  FROM pg_catalog.json_to_recordset(:'extensions') AS input (name text, schema text)`,
+		` WHERE input.schema IS NOT NULL`,
+		`\gexec`,
+
+		// Create extensions that do not already exist.
+		// - https://www.postgresql.org/docs/current/sql-createextension.html
+		`SELECT pg_catalog.format('CREATE EXTENSION IF NOT EXISTS %I', input.name)`,
+		`    || CASE WHEN input.schema IS NULL THEN ''`,
+		`       ELSE pg_catalog.format(' SCHEMA %I', input.schema) END`,
+		`  FROM ROWS FROM (pg_catalog.json_to_recordset(:'extensions') AS (name text, schema text))`,
+		`       WITH ORDINALITY AS input (name, schema, id)`,
+		` ORDER BY input.id`,
+		`\gexec`,
+	}, "\n")
+
+	variables := func(input []extension) map[string]string {
+		data, _ := json.Marshal(input) // strings always marshal
+		return map[string]string{
+			"extensions": string(data),
+
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		}
+	}
+
+	var stdout, stderr string
+	var err error
+	if len(everywhere) > 0 {
+		stdout, stderr, err = exec.ExecInAllDatabases(ctx, sql, variables(everywhere))
+		log.V(1).Info("created PostgreSQL extensions", "stdout", stdout, "stderr", stderr)
+	}
+
+	// pg_cron refuses to be created outside of its database, which is
+	// "postgres" unless configured otherwise. The setting does not exist until
+	// pg_cron is loaded.
+	// - https://github.com/citusdata/pg_cron#setting-up-pg_cron
+	if err == nil && len(cron) > 0 {
+		stdout, stderr, err = exec.ExecInDatabasesFromQuery(ctx,
+			`SELECT COALESCE(pg_catalog.current_setting('cron.database_name', true), 'postgres')`,
+			sql, variables(cron))
+		log.V(1).Info("created pg_cron extension", "stdout", stdout, "stderr", stderr)
+	}
+
+	if err != nil && strings.TrimSpace(stderr) != "" {
+		err = errors.WithMessage(err, strings.TrimSpace(stderr))
+	}
+	return err
+}
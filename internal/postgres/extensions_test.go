@@ -0,0 +1,231 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestExtensions(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	assert.Assert(t, Extensions(cluster) == nil)
+
+	cluster.Spec.Monitoring = &v1beta1.MonitoringSpec{}
+	assert.Assert(t, Extensions(cluster) == nil)
+
+	t.Run("PGStatStatements", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Monitoring.EnablePGStatStatements = true
+
+		assert.DeepEqual(t, Extensions(cluster),
+			[]v1beta1.PostgresExtensionSpec{{Name: "pg_stat_statements"}})
+
+		// Extensions in the spec come first and are not changed.
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{{Name: "hstore"}}
+		assert.DeepEqual(t, Extensions(cluster), []v1beta1.PostgresExtensionSpec{
+			{Name: "hstore"}, {Name: "pg_stat_statements"},
+		})
+		assert.Equal(t, len(cluster.Spec.Extensions), 1)
+
+		// The spec can choose a schema for it.
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{
+			{Name: "pg_stat_statements", Schema: "monitor"},
+		}
+		assert.DeepEqual(t, Extensions(cluster), cluster.Spec.Extensions)
+	})
+}
+
+func TestExtensionParameters(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+
+	t.Run("NoExtensions", func(t *testing.T) {
+		parameters := NewParameters()
+		ExtensionParameters(cluster, &parameters)
+
+		_, found := parameters.Mandatory.Get("shared_preload_libraries")
+		assert.Assert(t, !found)
+	})
+
+	t.Run("NoLibraries", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{{Name: "hstore"}}
+
+		parameters := NewParameters()
+		ExtensionParameters(cluster, &parameters)
+
+		_, found := parameters.Mandatory.Get("shared_preload_libraries")
+		assert.Assert(t, !found)
+	})
+
+	t.Run("Libraries", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{
+			{Name: "timescaledb"}, {Name: "hstore"}, {Name: "pg_stat_statements"},
+		}
+
+		parameters := NewParameters()
+		ExtensionParameters(cluster, &parameters)
+
+		assert.Equal(t, parameters.Mandatory.Value("shared_preload_libraries"),
+			"timescaledb,pg_stat_statements")
+	})
+
+	t.Run("PGStatStatements", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Monitoring = &v1beta1.MonitoringSpec{EnablePGStatStatements: true}
+
+		parameters := NewParameters()
+		ExtensionParameters(cluster, &parameters)
+
+		assert.Equal(t, parameters.Mandatory.Value("shared_preload_libraries"),
+			"pg_stat_statements")
+
+		cluster.Spec.Monitoring.EnablePGStatStatements = false
+
+		parameters = NewParameters()
+		ExtensionParameters(cluster, &parameters)
+
+		_, found := parameters.Mandatory.Get("shared_preload_libraries")
+		assert.Assert(t, !found)
+	})
+
+	t.Run("AlreadyLoaded", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Extensions = []v1beta1.PostgresExtensionSpec{
+			{Name: "pgaudit"}, {Name: "pg_cron"}, {Name: "pg_stat_statements"},
+		}
+
+		parameters := NewParameters()
+		parameters.Mandatory.Add("shared_preload_libraries", "pg_stat_statements, pgnodemx,pgaudit")
+		ExtensionParameters(cluster, &parameters)
+
+		assert.Equal(t, parameters.Mandatory.Value("shared_preload_libraries"),
+			"pg_stat_statements, pgnodemx,pgaudit,pg_cron")
+	})
+}
+
+func TestCreateExtensionsInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+
+			assert.Assert(t, strings.Contains(strings.Join(command, "\n"),
+				`SELECT datname FROM pg_catalog.pg_database`,
+			), "expected all databases and templates")
+			return expected
+		}
+
+		assert.Equal(t, expected, CreateExtensionsInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresExtensionSpec{{Name: "pgaudit"}}))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		exec := func(context.Context, io.Reader, io.Writer, io.Writer, ...string) error {
+			panic("unexpected call to exec")
+		}
+
+		assert.NilError(t, CreateExtensionsInPostgreSQL(ctx, exec, nil))
+	})
+
+	t.Run("Stderr", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, _, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stderr.Write([]byte("ERROR:  could not open extension control file\n"))
+			return errors.New("exit status 3")
+		}
+
+		err := CreateExtensionsInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresExtensionSpec{{Name: "missing"}})
+		assert.ErrorContains(t, err, "could not open extension control file")
+		assert.ErrorContains(t, err, "exit status 3")
+	})
+
+	t.Run("Cron", func(t *testing.T) {
+		var calls [][]string
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls = append(calls, command)
+			return nil
+		}
+
+		assert.NilError(t, CreateExtensionsInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresExtensionSpec{{Name: "pg_cron"}, {Name: "pgaudit"}}))
+		assert.Equal(t, len(calls), 2)
+
+		// Other extensions are created in every database.
+		assert.Assert(t, strings.Contains(strings.Join(calls[0], "\n"),
+			`SELECT datname FROM pg_catalog.pg_database`))
+		assert.Assert(t, cmp.Contains(calls[0], `--set=extensions=[{"name":"pgaudit"}]`))
+
+		// pg_cron is created only in its database.
+		assert.Assert(t, cmp.Contains(calls[1],
+			`SELECT COALESCE(pg_catalog.current_setting('cron.database_name', true), 'postgres')`))
+		assert.Assert(t, cmp.Contains(calls[1], `--set=extensions=[{"name":"pg_cron"}]`))
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			assert.Assert(t, cmp.Contains(command,
+				`--set=extensions=[{"name":"pg_stat_statements"},{"name":"Mixed Case","schema":"some-schema"}]`))
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET client_min_messages = WARNING;
+SELECT pg_catalog.format('CREATE SCHEMA IF NOT EXISTS %I', input.schema)
+  FROM pg_catalog.json_to_recordset(:'extensions') AS input (name text, schema text)
+ WHERE input.schema IS NOT NULL
+\gexec
+SELECT pg_catalog.format('CREATE EXTENSION IF NOT EXISTS %I', input.name)
+    || CASE WHEN input.schema IS NULL THEN ''
+       ELSE pg_catalog.format(' SCHEMA %I', input.schema) END
+  FROM ROWS FROM (pg_catalog.json_to_recordset(:'extensions') AS (name text, schema text))
+       WITH ORDINALITY AS input (name, schema, id)
+ ORDER BY input.id
+\gexec`))
+			return nil
+		}
+
+		assert.NilError(t, CreateExtensionsInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresExtensionSpec{
+				{Name: "pg_stat_statements"},
+				{Name: "Mixed Case", Schema: "some-schema"},
+			}))
+		assert.Equal(t, calls, 1)
+	})
+}
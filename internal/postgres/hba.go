@@ -17,7 +17,14 @@ package postgres
 
 import (
 	"fmt"
+	"net"
+	"sort"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // NewHBAs returns HostBasedAuthentication records required by this package.
@@ -60,6 +67,94 @@ func NewHBA() *HostBasedAuthentication {
 	return new(HostBasedAuthentication).AllDatabases().AllNetworks().AllUsers()
 }
 
+// NewHBAFromRule returns an HBA record that matches the connections described
+// by rule.
+func NewHBAFromRule(rule v1beta1.PostgresHBARule) *HostBasedAuthentication {
+	hba := NewHBA().Method(rule.Method).Options(rule.Options)
+	hba.origin = rule.Connection
+
+	if len(rule.Databases) > 0 {
+		names := make([]string, len(rule.Databases))
+		for i := range rule.Databases {
+			names[i] = hba.quote(string(rule.Databases[i]))
+		}
+		hba.database = strings.Join(names, ",")
+	}
+	if len(rule.Users) > 0 {
+		names := make([]string, len(rule.Users))
+		for i := range rule.Users {
+			names[i] = hba.quote(string(rule.Users[i]))
+		}
+		hba.user = strings.Join(names, ",")
+	}
+	switch rule.Address {
+	case "", "all":
+	case "samehost", "samenet":
+		hba.address = rule.Address
+	default:
+		hba.Network(rule.Address)
+	}
+
+	return hba
+}
+
+// ValidateHBARule returns any problems that would prevent rule from being
+// written as a valid record in pg_hba.conf.
+func ValidateHBARule(rule v1beta1.PostgresHBARule, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if rule.Connection != "local" {
+		switch address := rule.Address; {
+		case address == "", address == "all", address == "samehost", address == "samenet":
+		case strings.Contains(address, "/"):
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				errs = append(errs, field.Invalid(path.Child("address"), address,
+					"must be a valid CIDR block"))
+			}
+		case len(validation.IsDNS1123Subdomain(strings.TrimPrefix(address, "."))) > 0:
+			errs = append(errs, field.Invalid(path.Child("address"), address,
+				"must be a CIDR block, a host name, or a keyword"))
+		}
+	}
+
+	for i := range rule.Databases {
+		if strings.Contains(string(rule.Databases[i]), "\n") {
+			errs = append(errs, field.Invalid(path.Child("databases").Index(i),
+				rule.Databases[i], "must not contain a newline"))
+		}
+	}
+	for i := range rule.Users {
+		if strings.Contains(string(rule.Users[i]), "\n") {
+			errs = append(errs, field.Invalid(path.Child("users").Index(i),
+				rule.Users[i], "must not contain a newline"))
+		}
+	}
+
+	if rule.Method == "" || strings.ContainsAny(rule.Method, " \t\n\"#") {
+		errs = append(errs, field.Invalid(path.Child("method"), rule.Method,
+			"must be a single authentication method"))
+	}
+
+	keys := make([]string, 0, len(rule.Options))
+	for k := range rule.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, " \t\n\"#=") {
+			errs = append(errs, field.Invalid(path.Child("options").Key(k), k,
+				"must be a single option name"))
+		}
+		if strings.Contains(rule.Options[k], "\n") {
+			errs = append(errs, field.Invalid(path.Child("options").Key(k), rule.Options[k],
+				"must not contain a newline"))
+		}
+	}
+
+	return errs
+}
+
 func (HostBasedAuthentication) quote(value string) string {
 	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
 }
@@ -82,12 +177,29 @@ func (hba *HostBasedAuthentication) AllUsers() *HostBasedAuthentication {
 	return hba
 }
 
+// ClientCert requires the client to present a certificate when a connection
+// matches hba. The mode is either "verify-ca" or "verify-full". This appends
+// to any Options, so call it after Options.
+// - https://www.postgresql.org/docs/current/auth-cert.html
+func (hba *HostBasedAuthentication) ClientCert(mode string) *HostBasedAuthentication {
+	hba.options = fmt.Sprintf("%s %s=%s", hba.options, "clientcert", hba.quote(mode))
+	return hba
+}
+
 // Database makes hba match connections made to a specific database.
 func (hba *HostBasedAuthentication) Database(name string) *HostBasedAuthentication {
 	hba.database = hba.quote(name)
 	return hba
 }
 
+// LDAP makes hba authenticate connections using an LDAP server. The opts are
+// the "ldap" method options, such as "ldapserver" and either "ldapprefix" and
+// "ldapsuffix" for simple bind or "ldapbasedn" for search+bind.
+// - https://www.postgresql.org/docs/current/auth-ldap.html
+func (hba *HostBasedAuthentication) LDAP(opts map[string]string) *HostBasedAuthentication {
+	return hba.Method("ldap").Options(opts)
+}
+
 // Local makes hba match connection attempts using Unix-domain sockets.
 func (hba *HostBasedAuthentication) Local() *HostBasedAuthentication {
 	hba.origin = "local"
@@ -112,11 +224,19 @@ func (hba *HostBasedAuthentication) NoSSL() *HostBasedAuthentication {
 	return hba
 }
 
-// Options specifies any options for the authentication method.
+// Options specifies any options for the authentication method. They are
+// sorted by name so the record is the same every time.
 func (hba *HostBasedAuthentication) Options(opts map[string]string) *HostBasedAuthentication {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
 	hba.options = ""
-	for k, v := range opts {
-		hba.options = fmt.Sprintf("%s %s=%s", hba.options, k, hba.quote(v))
+	for _, k := range keys {
+		hba.options = fmt.Sprintf("%s %s=%s", hba.options, k, hba.quote(opts[k]))
 	}
 	return hba
 }
@@ -20,8 +20,10 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestNewHBAs(t *testing.T) {
@@ -68,6 +70,153 @@ func TestHostBasedAuthentication(t *testing.T) {
 			Method("md5").Options(map[string]string{"clientcert": "verify-ca"}).
 			String())
 
+	assert.Equal(t, `hostssl all "certuser" all cert  clientcert="verify-full"`,
+		NewHBA().TLS().User("certuser").Method("cert").ClientCert("verify-full").String())
+
+	assert.Equal(t, `hostssl all all all md5  map="x" clientcert="verify-ca"`,
+		NewHBA().TLS().Method("md5").
+			Options(map[string]string{"map": "x"}).ClientCert("verify-ca").
+			String())
+
 	assert.Equal(t, `hostnossl all all all reject`,
 		NewHBA().NoSSL().Method("reject").String())
 }
+
+func TestHostBasedAuthenticationLDAP(t *testing.T) {
+	t.Run("SimpleBind", func(t *testing.T) {
+		assert.Equal(t, ``+
+			`hostssl all +"humans" all ldap `+
+			` ldapport="636" ldapprefix="cn=" ldapscheme="ldaps"`+
+			` ldapserver="ldap.example.com" ldapsuffix=", dc=example, dc=com"`,
+			NewHBA().TLS().Role("humans").LDAP(map[string]string{
+				"ldapserver": "ldap.example.com",
+				"ldapport":   "636",
+				"ldapscheme": "ldaps",
+				"ldapprefix": "cn=",
+				"ldapsuffix": ", dc=example, dc=com",
+			}).String())
+	})
+
+	t.Run("SearchBind", func(t *testing.T) {
+		assert.Equal(t, ``+
+			`hostssl all all all ldap `+
+			` ldapbasedn="dc=example,dc=com" ldapbinddn="cn=pg,dc=example,dc=com"`+
+			` ldapbindpasswd="has""quote" ldapsearchattribute="uid"`+
+			` ldapserver="ldap.example.com" ldaptls="1"`,
+			NewHBA().TLS().LDAP(map[string]string{
+				"ldapserver":          "ldap.example.com",
+				"ldaptls":             "1",
+				"ldapbasedn":          "dc=example,dc=com",
+				"ldapbinddn":          "cn=pg,dc=example,dc=com",
+				"ldapbindpasswd":      `has"quote`,
+				"ldapsearchattribute": "uid",
+			}).String())
+	})
+}
+
+func TestNewHBAFromRule(t *testing.T) {
+	assert.Equal(t, `host all all all md5`,
+		NewHBAFromRule(v1beta1.PostgresHBARule{
+			Connection: "host", Method: "md5",
+		}).String())
+
+	assert.Equal(t, `hostssl "app","other" "alice","bob" "10.0.0.0/8" scram-sha-256`,
+		NewHBAFromRule(v1beta1.PostgresHBARule{
+			Connection: "hostssl",
+			Databases:  []v1beta1.PostgresIdentifier{"app", "other"},
+			Users:      []v1beta1.PostgresIdentifier{"alice", "bob"},
+			Address:    "10.0.0.0/8",
+			Method:     "scram-sha-256",
+		}).String())
+
+	assert.Equal(t, `local "has""quote" all peer  map="pgo"`,
+		NewHBAFromRule(v1beta1.PostgresHBARule{
+			Connection: "local",
+			Databases:  []v1beta1.PostgresIdentifier{`has"quote`},
+			Address:    "ignored",
+			Method:     "peer",
+			Options:    map[string]string{"map": "pgo"},
+		}).String())
+
+	// Options are sorted.
+	assert.Equal(t, `hostssl all all all cert  clientcert="verify-full" map="b" x="y"`,
+		NewHBAFromRule(v1beta1.PostgresHBARule{
+			Connection: "hostssl",
+			Method:     "cert",
+			Options:    map[string]string{"x": "y", "map": "b", "clientcert": "verify-full"},
+		}).String())
+
+	// Address keywords are not quoted.
+	assert.Equal(t, `host all all samenet trust`,
+		NewHBAFromRule(v1beta1.PostgresHBARule{
+			Connection: "host", Address: "samenet", Method: "trust",
+		}).String())
+	assert.Equal(t, `host all all all trust`,
+		NewHBAFromRule(v1beta1.PostgresHBARule{
+			Connection: "host", Address: "all", Method: "trust",
+		}).String())
+}
+
+func TestValidateHBARule(t *testing.T) {
+	path := field.NewPath("rule")
+
+	for _, tt := range []struct {
+		rule   v1beta1.PostgresHBARule
+		fields []string
+	}{
+		{rule: v1beta1.PostgresHBARule{Connection: "host", Method: "md5"}},
+		{rule: v1beta1.PostgresHBARule{Connection: "host", Address: "10.0.0.0/8", Method: "md5"}},
+		{rule: v1beta1.PostgresHBARule{Connection: "host", Address: "::1/128", Method: "md5"}},
+		{rule: v1beta1.PostgresHBARule{Connection: "host", Address: ".example.com", Method: "md5"}},
+		{rule: v1beta1.PostgresHBARule{Connection: "host", Address: "samehost", Method: "md5"}},
+		{rule: v1beta1.PostgresHBARule{Connection: "local", Address: "ignored!", Method: "peer"}},
+		{
+			rule: v1beta1.PostgresHBARule{
+				Connection: "hostssl", Method: "ldap",
+				Options: map[string]string{"ldapserver": "ldap.example.com"},
+			},
+		},
+		{
+			rule:   v1beta1.PostgresHBARule{Connection: "host", Address: "10.0.0.0/99", Method: "md5"},
+			fields: []string{"rule.address"},
+		},
+		{
+			rule:   v1beta1.PostgresHBARule{Connection: "host", Address: "no spaces", Method: "md5"},
+			fields: []string{"rule.address"},
+		},
+		{
+			rule:   v1beta1.PostgresHBARule{Connection: "host", Method: "trust reject"},
+			fields: []string{"rule.method"},
+		},
+		{
+			rule:   v1beta1.PostgresHBARule{Connection: "host"},
+			fields: []string{"rule.method"},
+		},
+		{
+			rule: v1beta1.PostgresHBARule{
+				Connection: "host", Method: "md5",
+				Users:     []v1beta1.PostgresIdentifier{"ok", "new\nline"},
+				Databases: []v1beta1.PostgresIdentifier{"new\nline"},
+			},
+			fields: []string{"rule.databases[0]", "rule.users[1]"},
+		},
+		{
+			rule: v1beta1.PostgresHBARule{
+				Connection: "host", Method: "md5",
+				Options: map[string]string{"a=b": "c", "ok": "new\nline"},
+			},
+			fields: []string{"rule.options[a=b]", "rule.options[ok]"},
+		},
+	} {
+		errs := ValidateHBARule(tt.rule, path)
+
+		fields := make([]string, len(errs))
+		for i := range errs {
+			fields[i] = errs[i].Field
+		}
+		if len(fields) == 0 {
+			fields = nil
+		}
+		assert.DeepEqual(t, fields, tt.fields)
+	}
+}
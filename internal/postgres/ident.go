@@ -0,0 +1,96 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// NewIdents returns UserNameMap records required by this package.
+func NewIdents() Idents {
+	return Idents{}
+}
+
+// Idents is a pairing of UserNameMap records.
+type Idents struct{ Mandatory, Default []UserNameMap }
+
+// UserNameMap represents a single record for pg_ident.conf.
+// - https://www.postgresql.org/docs/current/auth-username-maps.html
+type UserNameMap struct {
+	name, system, database string
+}
+
+// NewIdent returns a record in the map called name.
+func NewIdent(name string) *UserNameMap {
+	ident := new(UserNameMap)
+	ident.name = ident.quote(name)
+	return ident
+}
+
+// NewIdentFromMap returns a record that matches the user names described
+// by spec.
+func NewIdentFromMap(spec v1beta1.PostgresUserNameMap) *UserNameMap {
+	return NewIdent(spec.Name).SystemUser(spec.SystemUser).DatabaseUser(spec.DatabaseUser)
+}
+
+// ValidateIdentMap returns any problems that would prevent spec from being
+// written as a valid record in pg_ident.conf.
+func ValidateIdentMap(spec v1beta1.PostgresUserNameMap, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for _, value := range []struct {
+		child, value string
+	}{
+		{"name", spec.Name},
+		{"systemUser", spec.SystemUser},
+		{"databaseUser", spec.DatabaseUser},
+	} {
+		if value.value == "" || strings.Contains(value.value, "\n") {
+			errs = append(errs, field.Invalid(path.Child(value.child), value.value,
+				"must be a single, non-empty line"))
+		}
+	}
+
+	return errs
+}
+
+func (UserNameMap) quote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// DatabaseUser makes ident allow connections as a specific PostgreSQL user.
+func (ident *UserNameMap) DatabaseUser(name string) *UserNameMap {
+	ident.database = ident.quote(name)
+	return ident
+}
+
+// SystemUser makes ident match a specific user name from the operating system
+// or client certificate. When name begins with a slash (/), the remainder is
+// a regular expression.
+func (ident *UserNameMap) SystemUser(name string) *UserNameMap {
+	ident.system = ident.quote(name)
+	return ident
+}
+
+// String returns ident formatted for the pg_ident.conf file without a newline.
+func (ident UserNameMap) String() string {
+	return fmt.Sprintf("%s %s %s", ident.name, ident.system, ident.database)
+}
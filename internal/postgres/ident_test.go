@@ -0,0 +1,80 @@
+/*
+ Copyright 2021 - 2022 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestNewIdents(t *testing.T) {
+	idents := NewIdents()
+	assert.Equal(t, len(idents.Mandatory), 0)
+	assert.Equal(t, len(idents.Default), 0)
+}
+
+func TestUserNameMap(t *testing.T) {
+	assert.Equal(t, `"certs" "CN=alice" "alice"`,
+		NewIdent("certs").SystemUser("CN=alice").DatabaseUser("alice").String())
+
+	assert.Equal(t, `"pgo" "/^(.*)@example\.com$" "\1"`,
+		NewIdent("pgo").SystemUser(`/^(.*)@example\.com$`).DatabaseUser(`\1`).String())
+
+	assert.Equal(t, `"has""quote" "postgres" "all"`,
+		NewIdent(`has"quote`).SystemUser("postgres").DatabaseUser("all").String())
+}
+
+func TestNewIdentFromMap(t *testing.T) {
+	assert.Equal(t, `"local" "root" "postgres"`,
+		NewIdentFromMap(v1beta1.PostgresUserNameMap{
+			Name: "local", SystemUser: "root", DatabaseUser: "postgres",
+		}).String())
+}
+
+func TestValidateIdentMap(t *testing.T) {
+	path := field.NewPath("map")
+
+	for _, tt := range []struct {
+		spec   v1beta1.PostgresUserNameMap
+		fields []string
+	}{
+		{spec: v1beta1.PostgresUserNameMap{Name: "a", SystemUser: "b", DatabaseUser: "c"}},
+		{spec: v1beta1.PostgresUserNameMap{Name: "a", SystemUser: `/^(.*)$`, DatabaseUser: `\1`}},
+		{
+			spec:   v1beta1.PostgresUserNameMap{},
+			fields: []string{"map.name", "map.systemUser", "map.databaseUser"},
+		},
+		{
+			spec:   v1beta1.PostgresUserNameMap{Name: "a", SystemUser: "new\nline", DatabaseUser: "c"},
+			fields: []string{"map.systemUser"},
+		},
+	} {
+		errs := ValidateIdentMap(tt.spec, path)
+
+		fields := make([]string, len(errs))
+		for i := range errs {
+			fields[i] = errs[i].Field
+		}
+		if len(fields) == 0 {
+			fields = nil
+		}
+		assert.DeepEqual(t, fields, tt.fields)
+	}
+}
@@ -16,7 +16,11 @@
 package postgres
 
 import (
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // NewParameters returns ParameterSets required by this package.
@@ -59,9 +63,141 @@ func NewParameters() Parameters {
 	return parameters
 }
 
+// TLSParameters sets the parameters that restrict the TLS connections to
+// PostgreSQL according to spec.tls of cluster.
+func TLSParameters(cluster *v1beta1.PostgresCluster, outParameters *Parameters) {
+	if cluster.Spec.TLS == nil {
+		return
+	}
+
+	// PostgreSQL 12 is the first to have this parameter. Earlier versions
+	// refuse to start when it is set.
+	// PostgreSQL must be reloaded when changing this value.
+	if version := cluster.Spec.TLS.MinProtocol; version != "" &&
+		cluster.Spec.PostgresVersion >= 12 {
+		outParameters.Mandatory.Add("ssl_min_protocol_version", version)
+	}
+
+	// PostgreSQL must be reloaded when changing this value.
+	if ciphers := cluster.Spec.TLS.Ciphers; ciphers != "" {
+		outParameters.Mandatory.Add("ssl_ciphers", ciphers)
+	}
+}
+
+// restartParameters are the parameters that PostgreSQL reads only when the
+// server starts, i.e. those with a "postmaster" context in pg_settings.
+// - https://www.postgresql.org/docs/current/view-pg-settings.html
+var restartParameters = map[string]bool{
+	"archive_mode":                        true,
+	"autovacuum_freeze_max_age":           true,
+	"autovacuum_max_workers":              true,
+	"autovacuum_multixact_freeze_max_age": true,
+	"bonjour":                             true,
+	"bonjour_name":                        true,
+	"cluster_name":                        true,
+	"dynamic_shared_memory_type":          true,
+	"event_source":                        true,
+	"hba_file":                            true,
+	"hot_standby":                         true,
+	"huge_page_size":                      true,
+	"huge_pages":                          true,
+	"ident_file":                          true,
+	"jit_provider":                        true,
+	"listen_addresses":                    true,
+	"logging_collector":                   true,
+	"max_connections":                     true,
+	"max_files_per_process":               true,
+	"max_locks_per_transaction":           true,
+	"max_logical_replication_workers":     true,
+	"max_pred_locks_per_transaction":      true,
+	"max_prepared_transactions":           true,
+	"max_replication_slots":               true,
+	"max_wal_senders":                     true,
+	"max_worker_processes":                true,
+	"min_dynamic_shared_memory":           true,
+	"old_snapshot_threshold":              true,
+	"port":                                true,
+	"shared_buffers":                      true,
+	"shared_memory_type":                  true,
+	"shared_preload_libraries":            true,
+	"superuser_reserved_connections":      true,
+	"track_activity_query_size":           true,
+	"track_commit_timestamp":              true,
+	"unix_socket_directories":             true,
+	"unix_socket_group":                   true,
+	"unix_socket_permissions":             true,
+	"wal_buffers":                         true,
+	"wal_level":                           true,
+	"wal_log_hints":                       true,
+}
+
+// ParameterRequiresRestart returns whether or not PostgreSQL must be restarted
+// to change parameter name. Other parameters change when PostgreSQL reloads.
+func ParameterRequiresRestart(name string) bool {
+	return restartParameters[strings.ToLower(name)]
+}
+
 // Parameters is a pairing of ParameterSets.
 type Parameters struct{ Mandatory, Default *ParameterSet }
 
+// Conflicts returns the names of parameters in other that have a mandatory
+// value in p and a different value in other. The names are sorted.
+func (p Parameters) Conflicts(other *ParameterSet) []string {
+	var names []string
+	if p.Mandatory == nil || other == nil {
+		return names
+	}
+
+	for name, value := range other.values {
+		if mandatory, ok := p.Mandatory.Get(name); ok && !SameParameterValue(mandatory, value) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// SameParameterValue returns whether or not a and b are the same value for a
+// PostgreSQL parameter. Booleans can be spelled many ways, and numbers can come
+// from JSON or YAML with a different format.
+// - https://www.postgresql.org/docs/current/config-setting.html
+func SameParameterValue(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if x, ok := parseBool(a); ok {
+		if y, ok := parseBool(b); ok {
+			return x == y
+		}
+	}
+	if x, err := strconv.ParseFloat(strings.TrimSpace(a), 64); err == nil {
+		if y, err := strconv.ParseFloat(strings.TrimSpace(b), 64); err == nil {
+			return x == y
+		}
+	}
+	return false
+}
+
+// parseBool interprets s the same way PostgreSQL interprets a boolean: any
+// unambiguous prefix of "true", "false", "yes", "no", "on", "off", or the
+// numbers one and zero, without regard to case.
+// - https://git.postgresql.org/gitweb/?p=postgresql.git;f=src/backend/utils/adt/bool.c;hb=REL_14_0
+func parseBool(s string) (value, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	prefix := func(word string, minimum int) bool {
+		return len(s) >= minimum && strings.HasPrefix(word, s)
+	}
+
+	switch {
+	case s == "1", prefix("true", 1), prefix("yes", 1), prefix("on", 2):
+		return true, true
+	case s == "0", prefix("false", 1), prefix("no", 1), prefix("off", 2):
+		return false, true
+	}
+	return false, false
+}
+
 // ParameterSet is a collection of PostgreSQL parameters.
 // - https://www.postgresql.org/docs/current/config-setting.html
 type ParameterSet struct {
@@ -114,6 +250,11 @@ func (ParameterSet) normalize(name string) string {
 	return strings.ToLower(name)
 }
 
+// Remove deletes parameter name from ps, if it is present.
+func (ps *ParameterSet) Remove(name string) {
+	delete(ps.values, ps.normalize(name))
+}
+
 // Value returns empty string or the value of parameter name if it is present in ps.
 func (ps ParameterSet) Value(name string) string {
 	value, _ := ps.Get(name)
@@ -19,6 +19,8 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestNewParameters(t *testing.T) {
@@ -41,6 +43,131 @@ func TestNewParameters(t *testing.T) {
 	})
 }
 
+func TestTLSParameters(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 13
+
+	t.Run("Unset", func(t *testing.T) {
+		parameters := Parameters{Mandatory: NewParameterSet(), Default: NewParameterSet()}
+		TLSParameters(cluster, &parameters)
+
+		assert.DeepEqual(t, parameters.Mandatory.AsMap(), map[string]string{})
+		assert.DeepEqual(t, parameters.Default.AsMap(), map[string]string{})
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.TLS = &v1beta1.TLSSpec{MinProtocol: "TLSv1.3", Ciphers: "HIGH:!aNULL"}
+
+		parameters := Parameters{Mandatory: NewParameterSet(), Default: NewParameterSet()}
+		TLSParameters(cluster, &parameters)
+
+		assert.DeepEqual(t, parameters.Mandatory.AsMap(), map[string]string{
+			"ssl_ciphers":              "HIGH:!aNULL",
+			"ssl_min_protocol_version": "TLSv1.3",
+		})
+		assert.DeepEqual(t, parameters.Default.AsMap(), map[string]string{})
+	})
+
+	t.Run("PostgreSQL11", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.PostgresVersion = 11
+		cluster.Spec.TLS = &v1beta1.TLSSpec{MinProtocol: "TLSv1.2", Ciphers: "HIGH"}
+
+		parameters := Parameters{Mandatory: NewParameterSet(), Default: NewParameterSet()}
+		TLSParameters(cluster, &parameters)
+
+		// PostgreSQL 11 does not have "ssl_min_protocol_version".
+		assert.DeepEqual(t, parameters.Mandatory.AsMap(), map[string]string{
+			"ssl_ciphers": "HIGH",
+		})
+	})
+}
+
+func TestParametersConflicts(t *testing.T) {
+	parameters := NewParameters()
+
+	user := NewParameterSet()
+	assert.Assert(t, len(parameters.Conflicts(user)) == 0)
+
+	// Parameters without mandatory values and mandatory values repeated
+	// exactly are not conflicts.
+	user.Add("jit", "on")
+	user.Add("shared_buffers", "1GB")
+	user.Add("ssl", "on")
+	assert.Assert(t, len(parameters.Conflicts(user)) == 0)
+
+	user.Add("SSL", "off")
+	user.Add("ssl_cert_file", "/tmp/elsewhere.crt")
+	user.Add("ssl_key_file", "/pgconf/tls/tls.key")
+	assert.DeepEqual(t, parameters.Conflicts(user), []string{"ssl", "ssl_cert_file"})
+
+	// The mandatory values are unchanged.
+	assert.Equal(t, parameters.Mandatory.Value("ssl"), "on")
+	assert.Equal(t, parameters.Mandatory.Value("ssl_cert_file"), "/pgconf/tls/tls.crt")
+
+	assert.Assert(t, len(Parameters{}.Conflicts(user)) == 0)
+	assert.Assert(t, len(parameters.Conflicts(nil)) == 0)
+
+	t.Run("SameValue", func(t *testing.T) {
+		user := NewParameterSet()
+		user.Add("ssl", "true")
+		assert.Assert(t, len(parameters.Conflicts(user)) == 0)
+
+		user.Add("ssl", "0")
+		assert.DeepEqual(t, parameters.Conflicts(user), []string{"ssl"})
+	})
+}
+
+func TestSameParameterValue(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		same bool
+	}{
+		{a: "on", b: "on", same: true},
+		{a: "on", b: "true", same: true},
+		{a: "on", b: "ON", same: true},
+		{a: "on", b: "1", same: true},
+		{a: "on", b: "y", same: true},
+		{a: "off", b: "false", same: true},
+		{a: "off", b: "of", same: true},
+		{a: "off", b: "0", same: true},
+		{a: "on", b: "off", same: false},
+		{a: "on", b: "o", same: false},
+		{a: "on", b: "enabled", same: false},
+
+		{a: "10", b: "10", same: true},
+		{a: "10", b: "10.0", same: true},
+		{a: "1000000", b: "1e+06", same: true},
+		{a: "1", b: "1.0", same: true},
+		{a: "10", b: "11", same: false},
+		{a: "10", b: "10MB", same: false},
+
+		{a: "logical", b: "logical", same: true},
+		{a: "logical", b: "Logical", same: false},
+		{a: "/pgconf/tls/tls.crt", b: "/tmp/tls.crt", same: false},
+	} {
+		assert.Equal(t, SameParameterValue(tt.a, tt.b), tt.same, "%q and %q", tt.a, tt.b)
+		assert.Equal(t, SameParameterValue(tt.b, tt.a), tt.same, "%q and %q", tt.b, tt.a)
+	}
+}
+
+func TestParameterRequiresRestart(t *testing.T) {
+	for _, name := range []string{
+		"max_connections", "shared_buffers", "shared_preload_libraries",
+		"wal_level", "Max_WAL_Senders", "hba_file", "ident_file",
+	} {
+		assert.Assert(t, ParameterRequiresRestart(name), "expected %q", name)
+	}
+
+	for _, name := range []string{
+		"work_mem", "log_min_duration_statement", "ssl", "archive_command",
+		"password_encryption", "unknown",
+	} {
+		assert.Assert(t, !ParameterRequiresRestart(name), "expected not %q", name)
+	}
+}
+
 func TestParameterSet(t *testing.T) {
 	ps := NewParameterSet()
 
@@ -67,4 +194,19 @@ func TestParameterSet(t *testing.T) {
 
 	ps2.Add("x", "n")
 	assert.Assert(t, ps2.Value("x") != ps.Value("x"))
+
+	ps2.Remove("X")
+	assert.Assert(t, !ps2.Has("x"))
+	assert.Assert(t, ps.Has("x"), "expected copies to be independent")
+
+	v, ok = ps2.Get("x")
+	assert.Assert(t, !ok)
+	assert.Equal(t, v, "")
+
+	// Removing a missing parameter does nothing.
+	ps2.Remove("missing")
+	assert.DeepEqual(t, ps2.AsMap(), map[string]string{"abc": "j'l"})
+
+	ps2.Add("x", "again")
+	assert.Equal(t, ps2.Value("X"), "again")
 }
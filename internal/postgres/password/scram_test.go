@@ -17,9 +17,12 @@ package password
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -130,6 +133,83 @@ func TestSCRAMBuild(t *testing.T) {
 	})
 }
 
+func TestSCRAMBuildRFC7677(t *testing.T) {
+	// The SCRAM-SHA-256 authentication exchange in RFC 7677, Section 3. A server
+	// holding the verifier must accept the client proof and produce the server
+	// signature shown there.
+	// - https://datatracker.ietf.org/doc/html/rfc7677#section-3
+	const (
+		salt            = "W22ZaJ0SNY7soEsUEjb6gQ=="
+		authMessage     = "n=user,r=rOprNGfwEbeRWgbNEkqO,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096,c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0"
+		clientProof     = "dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+		serverSignature = "6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+	)
+
+	scram := NewSCRAMPassword("pencil")
+	scram.generateSalt = func(int) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(salt)
+	}
+
+	verifier, err := scram.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var storedKey, serverKey []byte
+	if parts := strings.Split(verifier, "$"); len(parts) != 3 {
+		t.Fatalf("unexpected verifier: %q", verifier)
+	} else if parts[1] != "4096:"+salt {
+		t.Fatalf("unexpected iterations and salt: %q", parts[1])
+	} else if keys := strings.Split(parts[2], ":"); len(keys) != 2 {
+		t.Fatalf("unexpected keys: %q", parts[2])
+	} else {
+		storedKey, _ = base64.StdEncoding.DecodeString(keys[0])
+		serverKey, _ = base64.StdEncoding.DecodeString(keys[1])
+	}
+
+	signature := func(key []byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(authMessage))
+		return mac.Sum(nil)
+	}
+
+	t.Run("ClientProof", func(t *testing.T) {
+		// The server recovers the client key from the proof and compares its
+		// hash to the stored key.
+		clientKey, _ := base64.StdEncoding.DecodeString(clientProof)
+		for i, b := range signature(storedKey) {
+			clientKey[i] ^= b
+		}
+
+		if hashed := sha256.Sum256(clientKey); !bytes.Equal(hashed[:], storedKey) {
+			t.Errorf("client proof was not accepted")
+		}
+	})
+
+	t.Run("ServerSignature", func(t *testing.T) {
+		if actual := base64.StdEncoding.EncodeToString(signature(serverKey)); actual != serverSignature {
+			t.Errorf("expected: %q actual %q", serverSignature, actual)
+		}
+	})
+
+	t.Run("Iterations", func(t *testing.T) {
+		scram := NewSCRAMPassword("pencil")
+		scram.Iterations = 10000
+		scram.generateSalt = func(int) ([]byte, error) {
+			return base64.StdEncoding.DecodeString(salt)
+		}
+
+		verifier, err := scram.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(verifier, "SCRAM-SHA-256$10000:"+salt+"$") {
+			t.Errorf("unexpected verifier: %q", verifier)
+		}
+	})
+}
+
 func TestSCRAMEncode(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
 		scram := SCRAMPassword{}
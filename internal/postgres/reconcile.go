@@ -247,6 +247,42 @@ func InstancePod(ctx context.Context,
 		outInstancePod.Volumes = append(outInstancePod.Volumes, walVolume)
 	}
 
+	// Some storage providers present volumes that only root can write, and
+	// "securityContext.fsGroup" does not apply to every kind of volume. Give
+	// the volumes to the PostgreSQL user before the startup container runs.
+	// The container needs root, which OpenShift does not allow. OpenShift
+	// assigns its own UID and GID anyway.
+	// - https://issue.k8s.io/93802
+	//
+	// Files written before a custom UID or GID was set belong to the previous
+	// one, so give the volumes to a custom owner even when this is disabled.
+	var permissions *corev1.Container
+	uid, gid := volumeOwner(inInstanceSpec)
+	if (inCluster.Spec.OpenShift == nil || !*inCluster.Spec.OpenShift) &&
+		(inInstanceSpec.DisableVolumePermissions == nil || !*inInstanceSpec.DisableVolumePermissions ||
+			uid != postgresUserID || gid != postgresGroupID) {
+		mounts := []corev1.VolumeMount{dataVolumeMount}
+		directories := []string{dataVolumeMount.MountPath, DataDirectory(inCluster)}
+		if inWALVolume != nil {
+			mounts = append(mounts, WALVolumeMount())
+			directories = append(directories, WALVolumeMount().MountPath)
+		}
+		directories = append(directories, WALDirectory(inCluster, inInstanceSpec))
+
+		permissions = &corev1.Container{
+			Name: naming.ContainerPostgresPermissions,
+
+			Command: permissionsCommand(uid, gid, directories...),
+
+			Image:           container.Image,
+			ImagePullPolicy: container.ImagePullPolicy,
+			Resources:       container.Resources,
+			SecurityContext: permissionsSecurityContext(),
+
+			VolumeMounts: mounts,
+		}
+	}
+
 	outInstancePod.Containers = []corev1.Container{container, reloader}
 
 	// If the InstanceSidecars feature gate is enabled and instance sidecars are
@@ -257,6 +293,29 @@ func InstancePod(ctx context.Context,
 	}
 
 	outInstancePod.InitContainers = []corev1.Container{startup}
+	if permissions != nil {
+		outInstancePod.InitContainers = []corev1.Container{*permissions, startup}
+	}
+
+	// If the InstanceSidecars feature gate is enabled and instance init
+	// containers are defined, run them after the startup container.
+	if util.DefaultMutableFeatureGate.Enabled(util.InstanceSidecars) &&
+		inInstanceSpec.InitContainers != nil {
+		outInstancePod.InitContainers = append(outInstancePod.InitContainers, inInstanceSpec.InitContainers...)
+	}
+}
+
+// AddReloadDirectory mounts volume into the container of pod that reloads
+// PostgreSQL and has it reload PostgreSQL when the directory changes. Use it
+// for files that PostgreSQL reads during reload, e.g. pg_ident.conf.
+func AddReloadDirectory(pod *corev1.PodSpec, mount corev1.VolumeMount) {
+	for i := range pod.Containers {
+		if pod.Containers[i].Name == naming.ContainerClientCertCopy {
+			mount.ReadOnly = true
+			pod.Containers[i].Command = append(pod.Containers[i].Command, mount.MountPath)
+			pod.Containers[i].VolumeMounts = append(pod.Containers[i].VolumeMounts, mount)
+		}
+	}
 }
 
 // PodSecurityContext returns a v1.PodSecurityContext for cluster that can write
@@ -281,8 +340,46 @@ func PodSecurityContext(cluster *v1beta1.PostgresCluster) *corev1.PodSecurityCon
 	// - https://docs.k8s.io/tasks/configure-pod-container/security-context/
 	// - https://docs.openshift.com/container-platform/4.8/authentication/managing-security-context-constraints.html
 	if cluster.Spec.OpenShift == nil || !*cluster.Spec.OpenShift {
-		podSecurityContext.FSGroup = initialize.Int64(26)
+		podSecurityContext.FSGroup = initialize.Int64(postgresGroupID)
 	}
 
 	return podSecurityContext
 }
+
+// volumeOwner returns the UID and GID that should own the PostgreSQL volumes
+// of instance. These come from its security settings, when they are not root,
+// or are those of the "postgres" user in Crunchy PostgreSQL images.
+func volumeOwner(instance *v1beta1.PostgresInstanceSetSpec) (uid, gid int64) {
+	uid, gid = postgresUserID, postgresGroupID
+
+	if pod := instance.SecurityContext; pod != nil {
+		if pod.RunAsUser != nil && *pod.RunAsUser != 0 {
+			uid = *pod.RunAsUser
+		}
+		if pod.FSGroup != nil && *pod.FSGroup != 0 {
+			gid = *pod.FSGroup
+		}
+	}
+	if container := instance.ContainerSecurityContext; container != nil {
+		if container.RunAsUser != nil && *container.RunAsUser != 0 {
+			uid = *container.RunAsUser
+		}
+	}
+
+	return uid, gid
+}
+
+// permissionsSecurityContext returns a v1.SecurityContext for a container that
+// changes the owner and mode of files it does not own. It runs as root with
+// only the capabilities to do that.
+// - https://man7.org/linux/man-pages/man7/capabilities.7.html
+func permissionsSecurityContext() *corev1.SecurityContext {
+	securityContext := initialize.RestrictedSecurityContext()
+	securityContext.Capabilities.Add = []corev1.Capability{
+		"CHOWN", "DAC_READ_SEARCH", "FOWNER",
+	}
+	securityContext.RunAsNonRoot = initialize.Bool(false)
+	securityContext.RunAsUser = initialize.Int64(0)
+
+	return securityContext
+}
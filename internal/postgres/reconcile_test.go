@@ -175,8 +175,16 @@ containers:
         exec {fd}>&- && exec {fd}<> <(:)
         stat --format='Loaded certificates dated %y' "${directory}"
       fi
+      for config; do
+        if [ "${config}" -nt "/proc/self/fd/${fd}" ] &&
+          pkill -HUP --exact --parent=1 postgres
+        then
+          exec {fd}>&- && exec {fd}<> <(:)
+          stat --format='Loaded configuration dated %y' "${config}"
+        fi
+      done
     done
-    }; export -f monitor; exec -a "$0" bash -ceu monitor
+    }; export -f monitor; exec -a "$0" bash -ceu 'monitor "$@"' "$0" "$@"
   - replication-cert-copy
   imagePullPolicy: Always
   name: replication-cert-copy
@@ -196,6 +204,47 @@ containers:
     name: cert-volume
     readOnly: true
 initContainers:
+- command:
+  - bash
+  - -ceu
+  - --
+  - |-
+    declare -r uid="$1" gid="$2"; shift 2
+    results() { printf '::postgres-operator: %s::%s\n' "$@"; }
+    for directory; do
+      [ -d "${directory}" ] || continue
+      [ "$(stat --format='%u:%g' "${directory}")" = "${uid}:${gid}" ] ||
+        chown --recursive --no-dereference "${uid}:${gid}" "${directory}"
+      chmod u+rwx "${directory}"
+      results 'directory' "${directory}" 'owner' "$(stat --format='%u:%g %A' "${directory}")"
+    done
+  - permissions
+  - "26"
+  - "26"
+  - /pgdata
+  - /pgdata/pg11
+  - /pgdata/pg11_wal
+  imagePullPolicy: Always
+  name: postgres-permissions
+  resources:
+    requests:
+      cpu: 9m
+  securityContext:
+    allowPrivilegeEscalation: false
+    capabilities:
+      add:
+      - CHOWN
+      - DAC_READ_SEARCH
+      - FOWNER
+      drop:
+      - ALL
+    privileged: false
+    readOnlyRootFilesystem: true
+    runAsNonRoot: false
+    runAsUser: 0
+  volumeMounts:
+  - mountPath: /pgdata
+    name: postgres-data
 - command:
   - bash
   - -ceu
@@ -348,14 +397,14 @@ volumes:
   name: postgres-wal`), "expected WAL and downwardAPI mounts in %q container", pod.Containers[0].Name)
 
 		// InitContainer has all mountPaths, except downwardAPI
-		assert.Assert(t, marshalMatches(pod.InitContainers[0].VolumeMounts, `
+		assert.Assert(t, marshalMatches(pod.InitContainers[1].VolumeMounts, `
 - mountPath: /pgconf/tls
   name: cert-volume
   readOnly: true
 - mountPath: /pgdata
   name: postgres-data
 - mountPath: /pgwal
-  name: postgres-wal`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[0].Name)
+  name: postgres-wal`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[1].Name)
 
 		assert.Assert(t, marshalMatches(pod.Volumes, `
 - name: cert-volume
@@ -418,8 +467,17 @@ volumes:
 		`), "expected WAL volume")
 
 		// Startup moves WAL files to data volume.
-		assert.DeepEqual(t, pod.InitContainers[0].Command[4:],
+		assert.DeepEqual(t, pod.InitContainers[1].Command[4:],
 			[]string{"startup", "11", "/pgdata/pg11_wal", "/pgdata/pgbackrest/log"})
+
+		// Permissions include the WAL volume.
+		assert.Assert(t, marshalMatches(pod.InitContainers[0].VolumeMounts, `
+- mountPath: /pgdata
+  name: postgres-data
+- mountPath: /pgwal
+  name: postgres-wal`), "expected WAL mount in %q container", pod.InitContainers[0].Name)
+		assert.DeepEqual(t, pod.InitContainers[0].Command[4:],
+			[]string{"permissions", "26", "26", "/pgdata", "/pgdata/pg11", "/pgwal", "/pgdata/pg11_wal"})
 	})
 
 	t.Run("WithAdditionalConfigFiles", func(t *testing.T) {
@@ -457,12 +515,12 @@ volumes:
   readOnly: true`), "expected WAL and downwardAPI mounts in %q container", pod.Containers[0].Name)
 
 		// InitContainer has all mountPaths, except downwardAPI and additionalConfig
-		assert.Assert(t, marshalMatches(pod.InitContainers[0].VolumeMounts, `
+		assert.Assert(t, marshalMatches(pod.InitContainers[1].VolumeMounts, `
 - mountPath: /pgconf/tls
   name: cert-volume
   readOnly: true
 - mountPath: /pgdata
-  name: postgres-data`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[0].Name)
+  name: postgres-data`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[1].Name)
 	})
 
 	t.Run("WithCustomSidecarContainer", func(t *testing.T) {
@@ -470,12 +528,16 @@ volumes:
 		sidecarInstance.Containers = []corev1.Container{
 			{Name: "customsidecar1"},
 		}
+		sidecarInstance.InitContainers = []corev1.Container{
+			{Name: "custominit1"},
+		}
 
 		t.Run("SidecarNotEnabled", func(t *testing.T) {
 			InstancePod(ctx, cluster, sidecarInstance,
 				serverSecretProjection, clientSecretProjection, dataVolume, nil, pod)
 
 			assert.Equal(t, len(pod.Containers), 2, "expected 2 containers in Pod, got %d", len(pod.Containers))
+			assert.Equal(t, len(pod.InitContainers), 2, "expected 2 init containers in Pod, got %d", len(pod.InitContainers))
 		})
 
 		t.Run("SidecarEnabled", func(t *testing.T) {
@@ -493,6 +555,12 @@ volumes:
 				}
 			}
 			assert.Assert(t, found, "expected custom sidecar 'customsidecar1', but container not found")
+
+			// Custom init containers run after the startup container.
+			assert.Equal(t, len(pod.InitContainers), 3, "expected 3 init containers in Pod, got %d", len(pod.InitContainers))
+			assert.Equal(t, pod.InitContainers[0].Name, "postgres-permissions")
+			assert.Equal(t, pod.InitContainers[1].Name, "postgres-startup")
+			assert.Equal(t, pod.InitContainers[2].Name, "custominit1")
 		})
 	})
 
@@ -522,14 +590,14 @@ volumes:
 - mountPath: /pgwal
   name: postgres-wal`), "expected WAL and downwardAPI mounts in %q container", pod.Containers[0].Name)
 
-		assert.Assert(t, marshalMatches(pod.InitContainers[0].VolumeMounts, `
+		assert.Assert(t, marshalMatches(pod.InitContainers[1].VolumeMounts, `
 - mountPath: /pgconf/tls
   name: cert-volume
   readOnly: true
 - mountPath: /pgdata
   name: postgres-data
 - mountPath: /pgwal
-  name: postgres-wal`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[0].Name)
+  name: postgres-wal`), "expected WAL mount, no downwardAPI mount in %q container", pod.InitContainers[1].Name)
 
 		assert.Assert(t, marshalMatches(pod.Volumes, `
 - name: cert-volume
@@ -592,8 +660,88 @@ volumes:
 		`), "expected WAL volume")
 
 		// Startup moves WAL files to WAL volume.
-		assert.DeepEqual(t, pod.InitContainers[0].Command[4:],
+		assert.DeepEqual(t, pod.InitContainers[1].Command[4:],
 			[]string{"startup", "11", "/pgwal/pg11_wal", "/pgdata/pgbackrest/log"})
+
+		// Permissions include the WAL volume.
+		assert.DeepEqual(t, pod.InitContainers[0].Command[4:],
+			[]string{"permissions", "26", "26", "/pgdata", "/pgdata/pg11", "/pgwal", "/pgwal/pg11_wal"})
+	})
+
+	t.Run("WithoutVolumePermissions", func(t *testing.T) {
+		instance := new(v1beta1.PostgresInstanceSetSpec)
+		instance.DisableVolumePermissions = initialize.Bool(true)
+
+		pod := new(corev1.PodSpec)
+		InstancePod(ctx, cluster, instance,
+			serverSecretProjection, clientSecretProjection, dataVolume, nil, pod)
+
+		assert.Equal(t, len(pod.InitContainers), 1)
+		assert.Equal(t, pod.InitContainers[0].Name, "postgres-startup")
+
+		// The remaining containers satisfy the "restricted" Pod Security
+		// Standard: they run as a user other than root without added
+		// capabilities.
+		// - https://docs.k8s.io/concepts/security/pod-security-standards/
+		for _, container := range append(pod.InitContainers, pod.Containers...) {
+			sc := container.SecurityContext
+			assert.Assert(t, sc != nil, "container %q", container.Name)
+			assert.Assert(t, sc.RunAsNonRoot != nil && *sc.RunAsNonRoot, "container %q", container.Name)
+			assert.Assert(t, sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation,
+				"container %q", container.Name)
+			assert.Assert(t, sc.Capabilities == nil || len(sc.Capabilities.Add) == 0,
+				"container %q", container.Name)
+		}
+	})
+
+	t.Run("WithSecurityContext", func(t *testing.T) {
+		instance := new(v1beta1.PostgresInstanceSetSpec)
+		instance.SecurityContext = &corev1.PodSecurityContext{
+			RunAsUser: initialize.Int64(1000),
+			FSGroup:   initialize.Int64(2000),
+		}
+
+		pod := new(corev1.PodSpec)
+		InstancePod(ctx, cluster, instance,
+			serverSecretProjection, clientSecretProjection, dataVolume, nil, pod)
+
+		// Volumes belong to the custom user and filesystem group.
+		assert.Equal(t, pod.InitContainers[0].Name, "postgres-permissions")
+		assert.DeepEqual(t, pod.InitContainers[0].Command[4:7],
+			[]string{"permissions", "1000", "2000"})
+
+		// A container user takes precedence; root is ignored.
+		instance.ContainerSecurityContext = &corev1.SecurityContext{
+			RunAsUser: initialize.Int64(3000),
+		}
+		instance.SecurityContext.FSGroup = initialize.Int64(0)
+
+		InstancePod(ctx, cluster, instance,
+			serverSecretProjection, clientSecretProjection, dataVolume, nil, pod)
+		assert.DeepEqual(t, pod.InitContainers[0].Command[4:7],
+			[]string{"permissions", "3000", "26"})
+
+		// Volumes written by the default user go to a custom one even when
+		// the container is disabled.
+		instance.DisableVolumePermissions = initialize.Bool(true)
+
+		InstancePod(ctx, cluster, instance,
+			serverSecretProjection, clientSecretProjection, dataVolume, nil, pod)
+		assert.Equal(t, pod.InitContainers[0].Name, "postgres-permissions")
+		assert.DeepEqual(t, pod.InitContainers[0].Command[4:7],
+			[]string{"permissions", "3000", "26"})
+	})
+
+	t.Run("OpenShift", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.OpenShift = initialize.Bool(true)
+
+		pod := new(corev1.PodSpec)
+		InstancePod(ctx, cluster, new(v1beta1.PostgresInstanceSetSpec),
+			serverSecretProjection, clientSecretProjection, dataVolume, nil, pod)
+
+		assert.Equal(t, len(pod.InitContainers), 1)
+		assert.Equal(t, pod.InitContainers[0].Name, "postgres-startup")
 	})
 }
 
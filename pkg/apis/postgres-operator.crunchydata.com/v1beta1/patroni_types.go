@@ -53,6 +53,12 @@ type PatroniSpec struct {
 	// +optional
 	Switchover *PatroniSwitchover `json:"switchover,omitempty"`
 
+	// Synchronous replication settings. These take precedence over the
+	// synchronous settings in dynamicConfiguration.
+	// More info: https://patroni.readthedocs.io/en/latest/replication_modes.html
+	// +optional
+	SyncReplication *PatroniSyncReplication `json:"syncReplication,omitempty"`
+
 	// TODO(cbandy): Add UseConfigMaps bool, default false.
 	// TODO(cbandy): Allow other DCS: etcd, raft, etc?
 	// N.B. changing this will cause downtime.
@@ -67,7 +73,8 @@ type PatroniSwitchover struct {
 
 	// The instance that should become primary during a switchover. This field is
 	// optional when Type is "Switchover" and required when Type is "Failover".
-	// When it is not specified, a healthy replica is automatically selected.
+	// It must be a running replica. When it is not specified, a healthy replica
+	// is automatically selected.
 	// +optional
 	TargetInstance *string `json:"targetInstance,omitempty"`
 
@@ -82,6 +89,28 @@ type PatroniSwitchover struct {
 	Type string `json:"type,omitempty"`
 }
 
+type PatroniSyncReplication struct {
+
+	// Whether or not transactions on the primary wait for synchronous replicas
+	// before they are committed. Synchronous replicas are candidates for
+	// promotion during failover.
+	// +required
+	Enabled bool `json:"enabled"`
+
+	// The number of replicas that are synchronous with the primary. When there
+	// are fewer replicas than this, those that exist are synchronous.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	NodeCount *int32 `json:"nodeCount,omitempty"`
+
+	// Whether or not the primary stops accepting writes when no synchronous
+	// replica is available. When false, transactions commit without
+	// synchronous replicas until one is available again.
+	// +optional
+	Strict bool `json:"strict,omitempty"`
+}
+
 // PatroniSwitchover types.
 const (
 	PatroniSwitchoverTypeFailover   = "Failover"
@@ -93,6 +122,7 @@ const (
 // - Lock Lease Duration
 // - Patroni's API port
 // - Frequency of syncing with Kube API
+// - Number of synchronous replicas
 func (s *PatroniSpec) Default() {
 	if s.LeaderLeaseDurationSeconds == nil {
 		s.LeaderLeaseDurationSeconds = new(int32)
@@ -106,6 +136,10 @@ func (s *PatroniSpec) Default() {
 		s.SyncPeriodSeconds = new(int32)
 		*s.SyncPeriodSeconds = 10
 	}
+	if s.SyncReplication != nil && s.SyncReplication.NodeCount == nil {
+		s.SyncReplication.NodeCount = new(int32)
+		*s.SyncReplication.NodeCount = 1
+	}
 }
 
 type PatroniStatus struct {
@@ -125,4 +159,8 @@ type PatroniStatus struct {
 	// Tracks the current timeline during switchovers
 	// +optional
 	SwitchoverTimeline *int64 `json:"switchoverTimeline,omitempty"`
+
+	// Tracks the execution of restart requests.
+	// +optional
+	Restart *string `json:"restart,omitempty"`
 }
@@ -273,6 +273,27 @@ type PGBackRestBackupSchedules struct {
 	Incremental *string `json:"incremental,omitempty"`
 }
 
+// PGBackRestRetention defines how many pgBackRest backups to keep in a repository
+type PGBackRestRetention struct {
+
+	// The number of full backups to keep. Older full backups, and the backups
+	// that depend on them, are expired when a new backup completes.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-full
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Full *int32 `json:"full,omitempty"`
+
+	// The number of differential backups to keep. Older differential backups,
+	// and the incremental backups that depend on them, are expired when a new
+	// backup completes.
+	// https://pgbackrest.org/configuration.html#section-repository/option-repo-retention-diff
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Differential *int32 `json:"differential,omitempty"`
+}
+
 // PGBackRestStatus defines the status of pgBackRest within a PostgresCluster
 type PGBackRestStatus struct {
 
@@ -316,6 +337,12 @@ type PGBackRestRepo struct {
 	// +optional
 	BackupSchedules *PGBackRestBackupSchedules `json:"schedules,omitempty"`
 
+	// Defines how many backups to keep in the repository. Backups beyond these
+	// limits are removed by the expire that follows the next backup, not when
+	// the limits change.
+	// +optional
+	Retention *PGBackRestRetention `json:"retention,omitempty"`
+
 	// Represents a pgBackRest repository that is created using Azure storage
 	// +optional
 	Azure *RepoAzure `json:"azure,omitempty"`
@@ -35,11 +35,34 @@ type PGBouncerConfiguration struct {
 	// NOTE(cbandy): map[string]string fields are not presented in the OpenShift
 	// web console: https://github.com/openshift/console/issues/9538
 
-	// Settings that apply to the entire PgBouncer process.
+	// Settings that apply to the entire PgBouncer process. Common settings
+	// include "max_client_conn", "default_pool_size", "reserve_pool_size",
+	// and "reserve_pool_timeout". Settings that the operator depends on, such
+	// as "auth_file", "auth_query", "conffile", and TLS file paths, cannot be
+	// changed. Changing settings that PgBouncer reads only when it starts, such
+	// as "listen_addr" and "unix_socket_dir", causes PgBouncer to restart.
+	// Changes to other settings are automatically reloaded.
 	// More info: https://www.pgbouncer.org/config.html
 	// +optional
 	Global map[string]string `json:"global,omitempty"`
 
+	// Whether or not to restart PgBouncer when any of its generated
+	// configuration changes, including the users file. By default, PgBouncer
+	// reloads changes and restarts only for settings it reads when it starts.
+	// Restarting closes client connections, but every Pod applies the change
+	// when it rolls out rather than when Kubernetes updates its files.
+	// +optional
+	RestartOnChange bool `json:"restartOnChange,omitempty"`
+
+	// Host-based authentication rules for clients of PgBouncer. When
+	// specified, PgBouncer checks these rules in order and authenticates
+	// clients using the first that matches. Connections that match no rule
+	// are rejected.
+	// More info: https://www.pgbouncer.org/config.html#hba-file-format
+	// +listType=atomic
+	// +optional
+	HBA []PostgresHBARule `json:"hba,omitempty"`
+
 	// PgBouncer database definitions. The key is the database requested by a
 	// client while the value is a libpq-styled connection string. The special
 	// key "*" acts as a fallback. When this field is empty, PgBouncer is
@@ -60,8 +83,16 @@ type PGBouncerPodSpec struct {
 	// +optional
 	Metadata *Metadata `json:"metadata,omitempty"`
 
-	// Scheduling constraints of a PgBouncer pod. Changing this value causes
-	// PgBouncer to restart.
+	// PostgreSQL users that are allowed to connect to the PgBouncer admin
+	// console and run any command there. Changes to this value are
+	// automatically reloaded.
+	// More info: https://www.pgbouncer.org/config.html#admin_users
+	// +optional
+	AdminUsers []string `json:"adminUsers,omitempty"`
+
+	// Scheduling constraints of a PgBouncer pod. When unset, PgBouncer pods
+	// prefer to run on different nodes unless default pod scheduling is
+	// disabled. Changing this value causes PgBouncer to restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node
 	// +optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
@@ -78,6 +109,15 @@ type PGBouncerPodSpec struct {
 	// +optional
 	Containers []corev1.Container `json:"containers,omitempty"`
 
+	// Security settings applied to the containers the operator manages in
+	// PgBouncer pods. Only runAsUser, runAsGroup, seLinuxOptions, and
+	// seccompProfile are used; settings that would run as root or loosen
+	// the operator defaults are ignored. Changing this value causes PgBouncer
+	// to restart.
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
 	// A secret projection containing a certificate and key with which to encrypt
 	// connections to PgBouncer. The "tls.crt", "tls.key", and "ca.crt" paths must
 	// be PEM-encoded certificates and keys. Changing this value causes PgBouncer
@@ -86,6 +126,22 @@ type PGBouncerPodSpec struct {
 	// +optional
 	CustomTLSSecret *corev1.SecretProjection `json:"customTLSSecret,omitempty"`
 
+	// Settings for a Prometheus exporter of PgBouncer metrics. When specified,
+	// each PgBouncer pod runs pgbouncer_exporter, which reads the admin console
+	// as a stats user. Changing this value causes PgBouncer to restart.
+	// More info: https://github.com/prometheus-community/pgbouncer_exporter
+	// +optional
+	Exporter *PGBouncerExporterSpec `json:"exporter,omitempty"`
+
+	// Startup parameters that PgBouncer ignores rather than rejects when a
+	// client sends them. These are appended to any "ignore_startup_parameters"
+	// in config.global, and "extra_float_digits" is always included. Changes
+	// to this value are automatically reloaded.
+	// More info: https://www.pgbouncer.org/config.html#ignore_startup_parameters
+	// +listType=set
+	// +optional
+	IgnoreStartupParameters []string `json:"ignoreStartupParameters,omitempty"`
+
 	// Name of a container image that can run PgBouncer 1.15 or newer. Changing
 	// this value causes PgBouncer to restart. The image may also be set using
 	// the RELATED_IMAGE_PGBOUNCER environment variable.
@@ -93,6 +149,29 @@ type PGBouncerPodSpec struct {
 	// +optional
 	Image string `json:"image,omitempty"`
 
+	// Logging settings of the PgBouncer process. When specified, connections
+	// and disconnections are not logged unless enabled here. Global settings
+	// take precedence over these. Changes to this value are automatically
+	// reloaded.
+	// +optional
+	Logging *PGBouncerLogging `json:"logging,omitempty"`
+
+	// Run multiple PgBouncer processes in each pod that share the listening
+	// port and forward cancel requests to one another. This requires PgBouncer
+	// 1.19 or newer. Changing this value causes PgBouncer to restart.
+	// More info: https://www.pgbouncer.org/config.html#section-peers
+	// +optional
+	Peering *PGBouncerPeering `json:"peering,omitempty"`
+
+	// How a server connection is returned to the pool of connections. Defaults
+	// to "session" unless "pool_mode" is set in config.global. In "transaction"
+	// and "statement" modes, "server_reset_query" defaults to empty. Changes
+	// to this value are automatically reloaded.
+	// More info: https://www.pgbouncer.org/config.html#pool_mode
+	// +optional
+	// +kubebuilder:validation:Enum={session,transaction,statement}
+	PoolMode string `json:"poolMode,omitempty"`
+
 	// Port on which PgBouncer should listen for client connections. Changing
 	// this value causes PgBouncer to restart.
 	// +optional
@@ -101,12 +180,14 @@ type PGBouncerPodSpec struct {
 	Port *int32 `json:"port,omitempty"`
 
 	// Priority class name for the pgBouncer pod. Changing this value causes
-	// PostgreSQL to restart.
+	// PgBouncer to restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/pod-priority-preemption/
 	// +optional
 	PriorityClassName *string `json:"priorityClassName,omitempty"`
 
-	// Number of desired PgBouncer pods.
+	// Number of desired PgBouncer pods. Each pod keeps its own connection
+	// pools, so the number of server connections to PostgreSQL can reach
+	// this many times the pool size set in the PgBouncer configuration.
 	// +optional
 	// +kubebuilder:default=1
 	// +kubebuilder:validation:Minimum=0
@@ -123,14 +204,39 @@ type PGBouncerPodSpec struct {
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
+	// Settings of the connections from PgBouncer to PostgreSQL.
+	// +optional
+	Server *PGBouncerServerSpec `json:"server,omitempty"`
+
 	// Specification of the service that exposes PgBouncer.
 	// +optional
 	Service *ServiceSpec `json:"service,omitempty"`
 
+	// Security settings of PgBouncer pods. These are merged with the operator
+	// defaults; settings that would run as root are ignored. Changing this
+	// value causes PgBouncer to restart.
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
 	// Configuration for pgBouncer sidecar containers
 	// +optional
 	Sidecars *PGBouncerSidecars `json:"sidecars,omitempty"`
 
+	// PostgreSQL users that are allowed to connect to the PgBouncer admin
+	// console and run read-only commands, such as SHOW POOLS. Changes to this
+	// value are automatically reloaded.
+	// More info: https://www.pgbouncer.org/config.html#stats_users
+	// +optional
+	StatsUsers []string `json:"statsUsers,omitempty"`
+
+	// Timeouts of client and server connections. PgBouncer closes server
+	// connections that are idle for 10 minutes and applies no other timeouts
+	// by default. Global settings take precedence over these. Changes to this
+	// value are automatically reloaded.
+	// +optional
+	Timeouts *PGBouncerTimeouts `json:"timeouts,omitempty"`
+
 	// Tolerations of a PgBouncer pod. Changing this value causes PgBouncer to
 	// restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
@@ -144,6 +250,99 @@ type PGBouncerPodSpec struct {
 	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
 }
 
+// PGBouncerExporterSpec defines the configuration of a Prometheus exporter
+// of PgBouncer metrics.
+type PGBouncerExporterSpec struct {
+	// Name of a container image that can run pgbouncer_exporter 0.5 or newer.
+	// Changing this value causes PgBouncer to restart. The image may also be
+	// set using the RELATED_IMAGE_PGBOUNCER_EXPORTER environment variable.
+	// More info: https://kubernetes.io/docs/concepts/containers/images
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Compute resources of the exporter container. Changing this value causes
+	// PgBouncer to restart.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// PGBouncerLogging defines what PgBouncer writes to its log.
+type PGBouncerLogging struct {
+	// Whether or not to log successful logins.
+	// More info: https://www.pgbouncer.org/config.html#log_connections
+	// +optional
+	Connections *bool `json:"connections,omitempty"`
+
+	// Whether or not to log disconnections and their reasons.
+	// More info: https://www.pgbouncer.org/config.html#log_disconnections
+	// +optional
+	Disconnections *bool `json:"disconnections,omitempty"`
+
+	// Whether or not to log error messages that PgBouncer sends to clients.
+	// More info: https://www.pgbouncer.org/config.html#log_pooler_errors
+	// +optional
+	PoolerErrors *bool `json:"poolerErrors,omitempty"`
+
+	// How much debug information to log. Zero is the least verbose.
+	// More info: https://www.pgbouncer.org/config.html#verbose
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Verbose *int32 `json:"verbose,omitempty"`
+}
+
+// PGBouncerTimeouts defines when PgBouncer closes connections. Each is in
+// seconds and zero disables it.
+type PGBouncerTimeouts struct {
+	// How long a client connection can be idle before it is closed. A short
+	// timeout frees pooler resources but drops clients that keep connections
+	// open without using them.
+	// More info: https://www.pgbouncer.org/config.html#client_idle_timeout
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ClientIdle *int32 `json:"clientIdle,omitempty"`
+
+	// How long a query can run before PgBouncer cancels it and closes its
+	// connections. This should be longer than the statement_timeout of
+	// PostgreSQL so that slow network or server trouble is caught here.
+	// More info: https://www.pgbouncer.org/config.html#query_timeout
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Query *int32 `json:"query,omitempty"`
+
+	// How long a server connection can be idle before it is closed. A short
+	// timeout returns server slots to PostgreSQL sooner, at the cost of more
+	// connections being opened when clients return.
+	// More info: https://www.pgbouncer.org/config.html#server_idle_timeout
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ServerIdle *int32 `json:"serverIdle,omitempty"`
+}
+
+// PGBouncerPeering defines how many PgBouncer processes share a pod.
+type PGBouncerPeering struct {
+	// Number of PgBouncer processes in each pod. Each process has its own
+	// pools and limits, such as max_client_conn and default_pool_size.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	Processes int32 `json:"processes"`
+}
+
+// PGBouncerServerSpec defines how PgBouncer connects to PostgreSQL.
+type PGBouncerServerSpec struct {
+	// How PgBouncer encrypts connections to PostgreSQL. Defaults to
+	// "verify-full", which requires TLS and checks the certificate and name of
+	// the server. Use "disable" only when the network is otherwise trusted,
+	// such as a service mesh with mutual TLS; PostgreSQL then accepts
+	// connections from PgBouncer without TLS. Every other value requires TLS,
+	// so "allow" is not supported. Changes to this value are automatically
+	// reloaded.
+	// More info: https://www.pgbouncer.org/config.html#server_tls_sslmode
+	// +optional
+	// +kubebuilder:validation:Enum={disable,prefer,require,verify-ca,verify-full}
+	TLSMode string `json:"tlsMode,omitempty"`
+}
+
 // PGBouncerSidecars defines the configuration for pgBouncer sidecar containers
 type PGBouncerSidecars struct {
 	// Defines the configuration for the pgBouncer config sidecar container
@@ -159,6 +358,11 @@ func (s *PGBouncerPodSpec) Default() {
 		*s.Port = 5432
 	}
 
+	// PgBouncer uses "session" mode when "pool_mode" is not configured.
+	if _, ok := s.Config.Global["pool_mode"]; !ok && s.PoolMode == "" {
+		s.PoolMode = "session"
+	}
+
 	if s.Replicas == nil {
 		s.Replicas = new(int32)
 		*s.Replicas = 1
@@ -22,6 +22,83 @@ package v1beta1
 // +kubebuilder:validation:MaxLength=63
 type PostgresIdentifier string
 
+type PostgresAuthenticationSpec struct {
+	// Additional host-based authentication rules. PostgreSQL compares each new
+	// connection to its rules in order, and the first rule that matches decides
+	// how that connection must authenticate. These rules come after those the
+	// operator requires and before its defaults. They are ignored when
+	// "patroni.dynamicConfiguration" defines "postgresql.pg_hba".
+	// More info: https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+	// +listType=atomic
+	// +optional
+	Rules []PostgresHBARule `json:"rules,omitempty"`
+
+	// User name maps for authentication methods that receive a user name from
+	// outside PostgreSQL, such as "cert" and "peer". Rules refer to these by
+	// name in their "map" option. They are ignored when
+	// "patroni.dynamicConfiguration" defines "postgresql.pg_ident".
+	// More info: https://www.postgresql.org/docs/current/auth-username-maps.html
+	// +listType=atomic
+	// +optional
+	Maps []PostgresUserNameMap `json:"maps,omitempty"`
+}
+
+// PostgresHBARule represents a single host-based authentication record.
+// More info: https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+type PostgresHBARule struct {
+	// The connection transport this rule matches. Typical values are "host"
+	// for any network connection and "hostssl" for network connections
+	// encrypted using TLS.
+	// +kubebuilder:validation:Enum={local,host,hostssl,hostnossl}
+	Connection string `json:"connection"`
+
+	// Which databases this rule matches. When omitted or empty, this rule
+	// matches all databases.
+	// +listType=atomic
+	// +optional
+	Databases []PostgresIdentifier `json:"databases,omitempty"`
+
+	// Which user names this rule matches. When omitted or empty, this rule
+	// matches all users.
+	// +listType=atomic
+	// +optional
+	Users []PostgresIdentifier `json:"users,omitempty"`
+
+	// The block of client IP addresses this rule matches in CIDR notation,
+	// a host name, or one of the keywords "all", "samehost", or "samenet".
+	// When omitted, this rule matches all addresses. This field is ignored
+	// for "local" connections.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// The authentication method to use when a connection matches this rule.
+	// +kubebuilder:validation:MinLength=1
+	Method string `json:"method"`
+
+	// Additional settings for this rule or its authentication method.
+	// +optional
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// PostgresUserNameMap represents a single user name map record.
+// More info: https://www.postgresql.org/docs/current/auth-username-maps.html
+type PostgresUserNameMap struct {
+	// The name of the map this record belongs to.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// The user name reported by the operating system or in the client
+	// certificate. When this begins with a slash (/), the remainder is a
+	// regular expression.
+	// +kubebuilder:validation:MinLength=1
+	SystemUser string `json:"systemUser"`
+
+	// The PostgreSQL user that SystemUser may connect as. When SystemUser is
+	// a regular expression, "\1" is replaced by its first parenthesized match.
+	// +kubebuilder:validation:MinLength=1
+	DatabaseUser string `json:"databaseUser"`
+}
+
 type PostgresPasswordSpec struct {
 	// Type of password to generate. Defaults to ASCII. Valid options are ASCII
 	// and AlphaNumeric.
@@ -38,6 +115,29 @@ const (
 	PostgresPasswordTypeASCII        = "ASCII"
 )
 
+type PostgresDatabaseSpec struct {
+
+	// The name of this PostgreSQL database.
+	Name PostgresIdentifier `json:"name"`
+
+	// The role that owns this database. This role is created without the LOGIN
+	// option when it does not exist, unless it is also listed in spec.users.
+	// When omitted, the database is owned by the "postgres" superuser.
+	// +optional
+	Owner PostgresIdentifier `json:"owner,omitempty"`
+}
+
+type PostgresExtensionSpec struct {
+
+	// The name of this PostgreSQL extension.
+	Name PostgresIdentifier `json:"name"`
+
+	// The schema in which to create the extension. The schema is created when
+	// it does not exist. When omitted, PostgreSQL chooses the schema.
+	// +optional
+	Schema PostgresIdentifier `json:"schema,omitempty"`
+}
+
 type PostgresUserSpec struct {
 
 	// This value goes into the name of a corev1.Secret and a label value, so
@@ -46,19 +146,18 @@ type PostgresUserSpec struct {
 
 	// The name of this PostgreSQL user. The value may contain only lowercase
 	// letters, numbers, and hyphen so that it fits into Kubernetes metadata.
+	// The "postgres" superuser is reserved and cannot be listed.
 	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
 	// +kubebuilder:validation:Type=string
 	Name PostgresIdentifier `json:"name"`
 
 	// Databases to which this user can connect and create objects. Removing a
-	// database from this list does NOT revoke access. This field is ignored for
-	// the "postgres" user.
+	// database from this list does NOT revoke access.
 	// +listType=set
 	// +optional
 	Databases []PostgresIdentifier `json:"databases,omitempty"`
 
-	// ALTER ROLE options except for PASSWORD. This field is ignored for the
-	// "postgres" user.
+	// ALTER ROLE options except for PASSWORD.
 	// More info: https://www.postgresql.org/docs/current/role-attributes.html
 	// +kubebuilder:validation:Pattern=`^[^;]*$`
 	// +optional
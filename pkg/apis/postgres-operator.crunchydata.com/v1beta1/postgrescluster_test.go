@@ -128,11 +128,39 @@ status:
 		assert.DeepEqual(t, string(b), strings.TrimSpace(`
 pgBouncer:
   config: {}
+  poolMode: session
   port: 5432
   replicas: 1
   resources: {}
 		`)+"\n")
 	})
+
+	t.Run("PgBouncer pool_mode", func(t *testing.T) {
+		var cluster PostgresCluster
+		cluster.Spec.Proxy = &PostgresProxySpec{PGBouncer: &PGBouncerPodSpec{}}
+		cluster.Spec.Proxy.PGBouncer.Config.Global = map[string]string{"pool_mode": "statement"}
+		cluster.Default()
+
+		// The setting in config.global is left to take effect.
+		assert.Equal(t, cluster.Spec.Proxy.PGBouncer.PoolMode, "")
+	})
+
+	t.Run("Patroni sync replication", func(t *testing.T) {
+		var cluster PostgresCluster
+		cluster.Spec.Patroni = &PatroniSpec{SyncReplication: &PatroniSyncReplication{}}
+		cluster.Default()
+
+		b, err := yaml.Marshal(cluster.Spec.Patroni)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, string(b), strings.TrimSpace(`
+leaderLeaseDurationSeconds: 30
+port: 8008
+syncPeriodSeconds: 10
+syncReplication:
+  enabled: false
+  nodeCount: 1
+		`)+"\n")
+	})
 }
 
 func TestPostgresInstanceSetSpecDefault(t *testing.T) {
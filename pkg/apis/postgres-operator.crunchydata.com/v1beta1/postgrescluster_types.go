@@ -32,6 +32,10 @@ type PostgresClusterSpec struct {
 	// +optional
 	DataSource *DataSource `json:"dataSource,omitempty"`
 
+	// Authentication settings for the PostgreSQL server
+	// +optional
+	Authentication *PostgresAuthenticationSpec `json:"authentication,omitempty"`
+
 	// PostgreSQL backup configuration
 	// +kubebuilder:validation:Required
 	Backups Backups `json:"backups"`
@@ -57,6 +61,10 @@ type PostgresClusterSpec struct {
 	// +optional
 	CustomReplicationClientTLSSecret *corev1.SecretProjection `json:"customReplicationTLSSecret,omitempty"`
 
+	// Settings for the TLS certificates that the operator generates.
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+
 	// DatabaseInitSQL defines a ConfigMap containing custom SQL that will
 	// be run after the cluster is initialized. This ConfigMap must be in the same
 	// namespace as the cluster.
@@ -69,6 +77,24 @@ type PostgresClusterSpec struct {
 	// +optional
 	DisableDefaultPodScheduling *bool `json:"disableDefaultPodScheduling,omitempty"`
 
+	// The DNS configuration of every Pod in this cluster. These are combined
+	// with the settings from DNSPolicy.
+	// More info: https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/#pod-dns-config
+	// +optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// The DNS policy of every Pod in this cluster. Defaults to "ClusterFirst".
+	// More info: https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/#pod-s-dns-policy
+	// +kubebuilder:validation:Enum={ClusterFirstWithHostNet,ClusterFirst,Default,None}
+	// +optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// Entries to add to the hosts file of every Pod in this cluster.
+	// More info: https://kubernetes.io/docs/tasks/network/customize-hosts-file-for-pods/
+	// +listType=atomic
+	// +optional
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
 	// The image name to use for PostgreSQL containers. When omitted, the value
 	// comes from an operator environment variable. For standard PostgreSQL images,
 	// the format is RELATED_IMAGE_POSTGRES_{postgresVersion},
@@ -110,7 +136,8 @@ type PostgresClusterSpec struct {
 	Patroni *PatroniSpec `json:"patroni,omitempty"`
 
 	// Suspends the rollout and reconciliation of changes made to the
-	// PostgresCluster spec.
+	// PostgresCluster spec. While paused, objects owned by the PostgresCluster
+	// are left as they are and the "Progressing" condition has reason "Paused".
 	// +optional
 	Paused *bool `json:"paused,omitempty"`
 
@@ -146,7 +173,19 @@ type PostgresClusterSpec struct {
 
 	// Specification of the service that exposes the PostgreSQL primary instance.
 	// +optional
-	Service *ServiceSpec `json:"service,omitempty"`
+	Service *PostgresServiceSpec `json:"service,omitempty"`
+
+	// Specification of the service that exposes PostgreSQL replica instances.
+	// +optional
+	ReadReplicaService *PostgresReadReplicaServiceSpec `json:"readReplicaService,omitempty"`
+
+	// Annotations for the ServiceAccount of this cluster. Postgres instances,
+	// PgBouncer, pgBackRest, and restore Jobs use this ServiceAccount, which
+	// makes it suitable for a cloud identity.
+	// - https://cloud.google.com/kubernetes-engine/docs/how-to/workload-identity
+	// - https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts.html
+	// +optional
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
 
 	// Whether or not the PostgreSQL cluster should be stopped.
 	// When this is true, workloads are scaled to zero and CronJobs
@@ -165,15 +204,37 @@ type PostgresClusterSpec struct {
 	// +optional
 	SupplementalGroups []int64 `json:"supplementalGroups,omitempty"`
 
+	// Major version upgrades of PostgreSQL. Increasing spec.postgresVersion
+	// stops the cluster and runs pg_upgrade only when this is enabled.
+	// +optional
+	Upgrade *PostgresUpgradeSpec `json:"upgrade,omitempty"`
+
 	// Users to create inside PostgreSQL and the databases they should access.
 	// The default creates one user that can access one database matching the
-	// PostgresCluster name. An empty list creates no users. Removing a user
-	// from this list does NOT drop the user nor revoke their access.
+	// PostgresCluster name, unless that name is "postgres". An empty list
+	// creates no users. Removing a user from this list does NOT drop the user
+	// nor revoke their access.
 	// +listType=map
 	// +listMapKey=name
 	// +optional
 	Users []PostgresUserSpec `json:"users,omitempty"`
 
+	// Databases to create inside PostgreSQL in addition to those listed in
+	// spec.users. Removing a database from this list does NOT drop the database.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Databases []PostgresDatabaseSpec `json:"databases,omitempty"`
+
+	// Extensions to create in every database inside PostgreSQL. The pg_cron
+	// extension is created only in the database named by cron.database_name.
+	// Extensions that must be loaded when PostgreSQL starts are added to
+	// shared_preload_libraries. Removing an extension from this list does NOT drop the extension.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Extensions []PostgresExtensionSpec `json:"extensions,omitempty"`
+
 	Config PostgresAdditionalConfig `json:"config,omitempty"`
 }
 
@@ -327,6 +388,12 @@ type PostgresClusterStatus struct {
 	// Identifies the databases that have been installed into PostgreSQL.
 	DatabaseRevision string `json:"databaseRevision,omitempty"`
 
+	// The names of the databases in spec.databases that have been created in
+	// PostgreSQL.
+	// +listType=set
+	// +optional
+	Databases []PostgresIdentifier `json:"databases,omitempty"`
+
 	// Current state of PostgreSQL instances.
 	// +listType=map
 	// +listMapKey=name
@@ -379,8 +446,9 @@ type PostgresClusterStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
 	// conditions represent the observations of postgrescluster's current state.
-	// Known .status.conditions.type are: "PersistentVolumeResizing",
-	// "Progressing", "ProxyAvailable"
+	// Known .status.conditions.type are: "MajorVersionUpgrade",
+	// "PersistentVolumeResizing", "PostgresRestartPending", "PrimaryReady",
+	// "Progressing", "ProxyAvailable", "Shutdown"
 	// +optional
 	// +listType=map
 	// +listMapKey=type
@@ -390,8 +458,12 @@ type PostgresClusterStatus struct {
 
 // PostgresClusterStatus condition types.
 const (
+	MajorVersionUpgrade        = "MajorVersionUpgrade"
 	PersistentVolumeResizing   = "PersistentVolumeResizing"
 	PostgresClusterProgressing = "Progressing"
+	PostgresRestartPending     = "PostgresRestartPending"
+	PostgresClusterShutdown    = "Shutdown"
+	PrimaryReady               = "PrimaryReady"
 	ProxyAvailable             = "ProxyAvailable"
 )
 
@@ -422,28 +494,101 @@ type PostgresInstanceSetSpec struct {
 	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
 	Name string `json:"name"`
 
-	// Scheduling constraints of a PostgreSQL pod. Changing this value causes
-	// PostgreSQL to restart.
+	// Scheduling constraints of a PostgreSQL pod. When unset, pods of this set
+	// prefer to run on different nodes unless default pod scheduling is
+	// disabled. Changing this value causes PostgreSQL to restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node
 	// +optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 
-	// Custom sidecars for PostgreSQL instance pods. Changing this value causes
+	// Custom sidecars for PostgreSQL instance pods. Their names must differ from
+	// those of the containers the operator manages. Changing this value causes
 	// PostgreSQL to restart.
 	// +optional
 	Containers []corev1.Container `json:"containers,omitempty"`
 
+	// Security settings applied to the containers the operator manages in
+	// PostgreSQL pods. Only runAsUser, runAsGroup, seLinuxOptions, and
+	// seccompProfile are used; settings that would run as root or loosen
+	// the operator defaults are ignored. Changing this value causes
+	// PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
 	// Defines a PersistentVolumeClaim for PostgreSQL data.
 	// More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes
 	// +kubebuilder:validation:Required
 	DataVolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"dataVolumeClaimSpec"`
 
+	// Whether or not to skip the init container that gives ownership of the
+	// PostgreSQL volumes to the PostgreSQL user. That container runs as root
+	// with only the capabilities it needs. Disable it when pods of this set may
+	// not run as root, such as under the "restricted" Pod Security Standard.
+	// It still runs when securityContext or containerSecurityContext sets a
+	// user or filesystem group other than 26, so that files written by the
+	// previous one are given to it. It never runs on OpenShift. Changing this
+	// value causes PostgreSQL to restart.
+	// +optional
+	DisableVolumePermissions *bool `json:"disableVolumePermissions,omitempty"`
+
+	// Environment variables to add to the PostgreSQL container, after those
+	// of the operator. Names the operator sets, such as PGDATA or those that
+	// begin with PATRONI, are not allowed. Changing this value causes
+	// PostgreSQL to restart.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Sources of environment variables for the PostgreSQL container. Variables
+	// set by the operator or in env take precedence over these. Sources must
+	// not contain names the operator manages, such as those that begin with
+	// PATRONI, and prefixes are not allowed. Changing this value causes
+	// PostgreSQL to restart.
+	// +listType=atomic
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Custom init containers for PostgreSQL instance pods. These run after the
+	// PostgreSQL startup container, and their names must differ from those of
+	// the containers the operator manages. Changing this value causes
+	// PostgreSQL to restart.
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// Timing of the liveness probe of the PostgreSQL container. Fields that
+	// are unset keep the defaults derived from spec.patroni. The probe itself
+	// is managed by the operator. The defaults fail the probe before the
+	// Patroni leader lock expires. A longer periodSeconds × failureThreshold
+	// tolerates slow storage, but an unresponsive primary may keep accepting
+	// writes after a replica is promoted. Changing this value causes
+	// PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/configure-liveness-readiness-startup-probes
+	// +optional
+	LivenessProbe *ProbeTiming `json:"livenessProbe,omitempty"`
+
+	// Labels that a node must have for a PostgreSQL pod to be scheduled there.
+	// These are in addition to any affinity. Changing this value causes
+	// PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node/#nodeselector
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
 	// Priority class name for the PostgreSQL pod. Changing this value causes
 	// PostgreSQL to restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/pod-priority-preemption/
 	// +optional
 	PriorityClassName *string `json:"priorityClassName,omitempty"`
 
+	// Timing of the readiness probe of the PostgreSQL container. Fields that
+	// are unset keep the defaults derived from spec.patroni. The probe itself
+	// is managed by the operator. Changing this value causes PostgreSQL to
+	// restart.
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/configure-liveness-readiness-startup-probes
+	// +optional
+	ReadinessProbe *ProbeTiming `json:"readinessProbe,omitempty"`
+
 	// Number of desired PostgreSQL pods.
 	// +optional
 	// +kubebuilder:default=1
@@ -455,25 +600,55 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
 
-	// Compute resources of a PostgreSQL container.
+	// Compute resources of a PostgreSQL container. Changing this value causes
+	// PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
+	// Security settings of PostgreSQL pods. These are merged with the operator
+	// defaults; settings that would run as root are ignored. Changing this
+	// value causes PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
 	// Configuration for instance sidecar containers
 	// +optional
 	Sidecars *InstanceSidecars `json:"sidecars,omitempty"`
 
+	// Number of seconds a PostgreSQL pod has to stop after it is asked to
+	// terminate. PostgreSQL writes a checkpoint as it shuts down; when that
+	// takes longer than this period, the next start requires crash recovery.
+	// Defaults to 60 seconds. Changing this value causes PostgreSQL to restart.
+	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#pod-termination
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
 	// Tolerations of a PostgreSQL pod. Changing this value causes PostgreSQL to restart.
 	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
 	// +optional
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 
-	// Topology spread constraints of a PostgreSQL pod. Changing this value causes
-	// PostgreSQL to restart.
+	// Topology spread constraints of a PostgreSQL pod. These are added to the
+	// default constraints, which spread pods across nodes and zones, unless
+	// default pod scheduling is disabled. Changing this value causes PostgreSQL
+	// to restart.
 	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-topology-spread-constraints/
 	// +optional
 	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
 
+	// How pods of this set are replaced after a change that requires
+	// PostgreSQL to restart. "RollingUpdate" replaces one pod at a time,
+	// replicas first; the primary is replaced last, after a switchover to an
+	// updated replica. "OnDelete" replaces a pod only after it is deleted by
+	// something other than the operator.
+	// +optional
+	// +kubebuilder:default=RollingUpdate
+	// +kubebuilder:validation:Enum={RollingUpdate,OnDelete}
+	UpdateStrategy string `json:"updateStrategy,omitempty"`
+
 	// Defines a separate PersistentVolumeClaim for PostgreSQL's write-ahead log.
 	// More info: https://www.postgresql.org/docs/current/wal.html
 	// +optional
@@ -487,6 +662,32 @@ type InstanceSidecars struct {
 	ReplicaCertCopy *Sidecar `json:"replicaCertCopy,omitempty"`
 }
 
+// ProbeTiming defines the timing and thresholds of a probe that is otherwise
+// managed by the operator.
+type ProbeTiming struct {
+	// Number of seconds after the container has started before the probe is
+	// initiated.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// Number of seconds after which the probe times out.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// How often, in seconds, to perform the probe.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// Minimum consecutive failures for the probe to be considered failed after
+	// having succeeded.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
 // Default sets the default values for an instance set spec, including the name
 // suffix and number of replicas.
 func (s *PostgresInstanceSetSpec) Default(i int) {
@@ -513,6 +714,43 @@ type PostgresInstanceSetStatus struct {
 	// Total number of pods that have the desired specification.
 	// +optional
 	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// The instances of this set as reported by Patroni. This is refreshed
+	// on every reconcile and is empty when Patroni cannot be reached.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Instances []PostgresInstanceStatus `json:"instances,omitempty"`
+}
+
+// PostgresInstanceStatus describes one PostgreSQL instance as a member of the
+// Patroni cluster.
+type PostgresInstanceStatus struct {
+	// The name of the instance.
+	Name string `json:"name"`
+
+	// The role of the instance in Patroni, such as "leader" or "replica".
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// The state of PostgreSQL as reported by Patroni, such as "running" or
+	// "streaming".
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// The PostgreSQL timeline of the instance.
+	// +optional
+	Timeline int64 `json:"timeline,omitempty"`
+
+	// How far the instance is behind the leader, in bytes of WAL. This is
+	// absent for the leader and when Patroni does not know.
+	// +optional
+	ReplicationLagBytes *int64 `json:"replicationLagBytes,omitempty"`
+
+	// Whether or not PostgreSQL must restart to apply changes to its
+	// parameters, as reported by Patroni.
+	// +optional
+	PendingRestart bool `json:"pendingRestart,omitempty"`
 }
 
 // PostgresProxySpec is a union of the supported PostgreSQL proxies.
@@ -533,6 +771,31 @@ type PostgresProxyStatus struct {
 	PGBouncer PGBouncerPodStatus `json:"pgBouncer,omitempty"`
 }
 
+// PostgresServiceSpec defines the Services that expose the PostgreSQL primary.
+type PostgresServiceSpec struct {
+	ServiceSpec `json:",inline"`
+
+	// The port on which the primary Services accept connections. Connections
+	// are forwarded to the port of the PostgreSQL container, which may differ.
+	// Defaults to the port of the container. The replica Service always uses
+	// the port of the container.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *int32 `json:"port,omitempty"`
+}
+
+// PostgresReadReplicaServiceSpec defines which replicas receive connections
+// through the replica Service.
+type PostgresReadReplicaServiceSpec struct {
+	// The most replication lag, in bytes, a replica may have and still receive
+	// connections through the replica Service. Lagging replicas are removed from
+	// the Service until they catch up. When empty, no replica is removed.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxLagBytes *int64 `json:"maxLagBytes,omitempty"`
+}
+
 // PostgresStandbySpec defines if/how the cluster should be a hot standby.
 type PostgresStandbySpec struct {
 	// Whether or not the PostgreSQL cluster should be read-only. When this is
@@ -557,6 +820,28 @@ type PostgresStandbySpec struct {
 	Port *int32 `json:"port,omitempty"`
 }
 
+// PostgresUpgradeSpec defines how a PostgresCluster moves to a new major
+// version of PostgreSQL.
+type PostgresUpgradeSpec struct {
+	// Whether or not an increase of spec.postgresVersion should upgrade the
+	// existing data directory using pg_upgrade. The cluster is shut down while
+	// the upgrade runs.
+	// +optional
+	// +kubebuilder:default=false
+	Enabled bool `json:"enabled"`
+
+	// The image name to use for the pg_upgrade Job. It must contain the
+	// binaries of both the current and the new PostgreSQL major versions.
+	// The image may also be set using the RELATED_IMAGE_PGUPGRADE environment
+	// variable.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Resource requirements for the pg_upgrade container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
 // UserInterfaceSpec is a union of the supported PostgreSQL user interfaces.
 type UserInterfaceSpec struct {
 
@@ -580,9 +865,49 @@ type PostgresUserInterfaceStatus struct {
 }
 
 type PostgresAdditionalConfig struct {
+	// Files to mount in the "/etc/postgres" directory of the database container.
+	// Those ending in ".conf" directly in that directory are included in
+	// "postgresql.conf" before the settings generated by the operator, so
+	// those cannot be overridden. Changing this value restarts PostgreSQL.
+	// +optional
 	Files []corev1.VolumeProjection `json:"files,omitempty"`
 }
 
+type TLSSpec struct {
+	// A Secret containing the certificate authority that signs the leaf
+	// certificates of this cluster. The Secret must contain the certificate
+	// in the "tls.crt" key and its ECDSA private key in the "tls.key" key.
+	// When omitted, the operator generates a self-signed authority.
+	// +optional
+	RootCA *corev1.LocalObjectReference `json:"rootCA,omitempty"`
+
+	// How long the leaf certificates that the operator generates are valid,
+	// e.g. "2160h" for 90 days. Defaults to one year.
+	// +optional
+	CertificateDuration *metav1.Duration `json:"certificateDuration,omitempty"`
+
+	// How long before they expire that generated leaf certificates are
+	// renewed, e.g. "720h" for 30 days. This must be less than the certificate
+	// duration. Defaults to one third of the certificate duration.
+	// +optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+
+	// The oldest version of TLS that PostgreSQL and PgBouncer accept from
+	// clients. Requires PostgreSQL 12 or newer; it is ignored by earlier
+	// versions. When omitted, PostgreSQL and PgBouncer use their defaults.
+	// - https://www.postgresql.org/docs/current/runtime-config-connection.html#GUC-SSL-MIN-PROTOCOL-VERSION
+	// +optional
+	// +kubebuilder:validation:Enum={TLSv1.2,TLSv1.3}
+	MinProtocol string `json:"minProtocol,omitempty"`
+
+	// The OpenSSL cipher list that PostgreSQL and PgBouncer allow on client
+	// connections using TLSv1.2 and older, e.g. "HIGH:!aNULL:!MD5".
+	// - https://www.postgresql.org/docs/current/runtime-config-connection.html#GUC-SSL-CIPHERS
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	Ciphers string `json:"ciphers,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +operator-sdk:csv:customresourcedefinitions:resources={{ConfigMap,v1},{Secret,v1},{Service,v1},{CronJob,v1beta1},{Deployment,v1},{Job,v1},{StatefulSet,v1},{PersistentVolumeClaim,v1}}
@@ -660,6 +985,12 @@ func (meta *Metadata) GetAnnotationsOrNil() map[string]string {
 type MonitoringSpec struct {
 	// +optional
 	PGMonitor *PGMonitorSpec `json:"pgmonitor,omitempty"`
+
+	// Whether or not to collect query statistics with pg_stat_statements.
+	// When enabled, the extension is created like those in spec.extensions.
+	// More info: https://www.postgresql.org/docs/current/pgstatstatements.html
+	// +optional
+	EnablePGStatStatements bool `json:"enablePGStatStatements,omitempty"`
 }
 
 // MonitoringStatus is the current state of PostgreSQL cluster monitoring tool
@@ -49,6 +49,44 @@ type ServiceSpec struct {
 	// +optional
 	NodePort *int32 `json:"nodePort,omitempty"`
 
+	// The IP family policy of this Service. When unspecified, Kubernetes
+	// chooses according to its configuration.
+	// - https://kubernetes.io/docs/concepts/services-networking/dual-stack/#services
+	// +optional
+	// +kubebuilder:validation:Enum={SingleStack,PreferDualStack,RequireDualStack}
+	IPFamilyPolicy *corev1.IPFamilyPolicyType `json:"ipFamilyPolicy,omitempty"`
+
+	// The IP families of this Service, in order of preference. When
+	// unspecified, Kubernetes chooses according to its configuration.
+	// - https://kubernetes.io/docs/concepts/services-networking/dual-stack/#services
+	// +optional
+	// +kubebuilder:validation:MaxItems=2
+	// +listType=atomic
+	IPFamilies []corev1.IPFamily `json:"ipFamilies,omitempty"`
+
+	// The client IP ranges, in CIDR notation, that may connect when type is
+	// LoadBalancer. These are ignored by other types. When unspecified, all
+	// clients may connect, unless the cloud provider restricts them.
+	// - https://kubernetes.io/docs/tasks/access-application-cluster/create-external-load-balancer/#restrict-access-for-loadbalancer-service
+	// +optional
+	// +listType=atomic
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Whether or not connections from one client go to the same Pod. When
+	// ClientIP, connections from the same client IP address go to the same Pod
+	// until none arrive for sessionAffinityTimeoutSeconds. Defaults to None.
+	// - https://kubernetes.io/docs/concepts/services-networking/service/#session-affinity
+	// +optional
+	// +kubebuilder:validation:Enum={None,ClientIP}
+	SessionAffinity string `json:"sessionAffinity,omitempty"`
+
+	// The number of seconds that connections from a client stay with the same
+	// Pod when sessionAffinity is ClientIP. Defaults to 10800 (3 hours).
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+
 	// More info: https://kubernetes.io/docs/concepts/services-networking/service/#publishing-services-service-types
 	//
 	// +optional
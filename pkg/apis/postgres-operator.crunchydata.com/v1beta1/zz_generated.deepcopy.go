@@ -578,6 +578,11 @@ func (in *PGBackRestRepo) DeepCopyInto(out *PGBackRestRepo) {
 		*out = new(PGBackRestBackupSchedules)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(PGBackRestRetention)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Azure != nil {
 		in, out := &in.Azure, &out.Azure
 		*out = new(RepoAzure)
@@ -685,6 +690,31 @@ func (in *PGBackRestRestore) DeepCopy() *PGBackRestRestore {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRetention) DeepCopyInto(out *PGBackRestRetention) {
+	*out = *in
+	if in.Full != nil {
+		in, out := &in.Full, &out.Full
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Differential != nil {
+		in, out := &in.Differential, &out.Differential
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRetention.
+func (in *PGBackRestRetention) DeepCopy() *PGBackRestRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestScheduledBackupStatus) DeepCopyInto(out *PGBackRestScheduledBackupStatus) {
 	*out = *in
@@ -792,6 +822,13 @@ func (in *PGBouncerConfiguration) DeepCopyInto(out *PGBouncerConfiguration) {
 			(*out)[key] = val
 		}
 	}
+	if in.HBA != nil {
+		in, out := &in.HBA, &out.HBA
+		*out = make([]PostgresHBARule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Databases != nil {
 		in, out := &in.Databases, &out.Databases
 		*out = make(map[string]string, len(*in))
@@ -818,6 +855,72 @@ func (in *PGBouncerConfiguration) DeepCopy() *PGBouncerConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerExporterSpec) DeepCopyInto(out *PGBouncerExporterSpec) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerExporterSpec.
+func (in *PGBouncerExporterSpec) DeepCopy() *PGBouncerExporterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerExporterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerLogging) DeepCopyInto(out *PGBouncerLogging) {
+	*out = *in
+	if in.Connections != nil {
+		in, out := &in.Connections, &out.Connections
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Disconnections != nil {
+		in, out := &in.Disconnections, &out.Disconnections
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PoolerErrors != nil {
+		in, out := &in.PoolerErrors, &out.PoolerErrors
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Verbose != nil {
+		in, out := &in.Verbose, &out.Verbose
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerLogging.
+func (in *PGBouncerLogging) DeepCopy() *PGBouncerLogging {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerLogging)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerPeering) DeepCopyInto(out *PGBouncerPeering) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerPeering.
+func (in *PGBouncerPeering) DeepCopy() *PGBouncerPeering {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerPeering)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBouncerPodSpec) DeepCopyInto(out *PGBouncerPodSpec) {
 	*out = *in
@@ -826,6 +929,11 @@ func (in *PGBouncerPodSpec) DeepCopyInto(out *PGBouncerPodSpec) {
 		*out = new(Metadata)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AdminUsers != nil {
+		in, out := &in.AdminUsers, &out.AdminUsers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Affinity != nil {
 		in, out := &in.Affinity, &out.Affinity
 		*out = new(v1.Affinity)
@@ -839,11 +947,36 @@ func (in *PGBouncerPodSpec) DeepCopyInto(out *PGBouncerPodSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CustomTLSSecret != nil {
 		in, out := &in.CustomTLSSecret, &out.CustomTLSSecret
 		*out = new(v1.SecretProjection)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Exporter != nil {
+		in, out := &in.Exporter, &out.Exporter
+		*out = new(PGBouncerExporterSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IgnoreStartupParameters != nil {
+		in, out := &in.IgnoreStartupParameters, &out.IgnoreStartupParameters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Logging != nil {
+		in, out := &in.Logging, &out.Logging
+		*out = new(PGBouncerLogging)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Peering != nil {
+		in, out := &in.Peering, &out.Peering
+		*out = new(PGBouncerPeering)
+		**out = **in
+	}
 	if in.Port != nil {
 		in, out := &in.Port, &out.Port
 		*out = new(int32)
@@ -865,16 +998,36 @@ func (in *PGBouncerPodSpec) DeepCopyInto(out *PGBouncerPodSpec) {
 		**out = **in
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Server != nil {
+		in, out := &in.Server, &out.Server
+		*out = new(PGBouncerServerSpec)
+		**out = **in
+	}
 	if in.Service != nil {
 		in, out := &in.Service, &out.Service
 		*out = new(ServiceSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Sidecars != nil {
 		in, out := &in.Sidecars, &out.Sidecars
 		*out = new(PGBouncerSidecars)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.StatsUsers != nil {
+		in, out := &in.StatsUsers, &out.StatsUsers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeouts != nil {
+		in, out := &in.Timeouts, &out.Timeouts
+		*out = new(PGBouncerTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Tolerations != nil {
 		in, out := &in.Tolerations, &out.Tolerations
 		*out = make([]v1.Toleration, len(*in))
@@ -916,6 +1069,21 @@ func (in *PGBouncerPodStatus) DeepCopy() *PGBouncerPodStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerServerSpec) DeepCopyInto(out *PGBouncerServerSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerServerSpec.
+func (in *PGBouncerServerSpec) DeepCopy() *PGBouncerServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBouncerSidecars) DeepCopyInto(out *PGBouncerSidecars) {
 	*out = *in
@@ -936,6 +1104,36 @@ func (in *PGBouncerSidecars) DeepCopy() *PGBouncerSidecars {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerTimeouts) DeepCopyInto(out *PGBouncerTimeouts) {
+	*out = *in
+	if in.ClientIdle != nil {
+		in, out := &in.ClientIdle, &out.ClientIdle
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Query != nil {
+		in, out := &in.Query, &out.Query
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ServerIdle != nil {
+		in, out := &in.ServerIdle, &out.ServerIdle
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerTimeouts.
+func (in *PGBouncerTimeouts) DeepCopy() *PGBouncerTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGMonitorSpec) DeepCopyInto(out *PGMonitorSpec) {
 	*out = *in
@@ -980,6 +1178,11 @@ func (in *PatroniSpec) DeepCopyInto(out *PatroniSpec) {
 		*out = new(PatroniSwitchover)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SyncReplication != nil {
+		in, out := &in.SyncReplication, &out.SyncReplication
+		*out = new(PatroniSyncReplication)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSpec.
@@ -1005,6 +1208,11 @@ func (in *PatroniStatus) DeepCopyInto(out *PatroniStatus) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.Restart != nil {
+		in, out := &in.Restart, &out.Restart
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniStatus.
@@ -1037,6 +1245,26 @@ func (in *PatroniSwitchover) DeepCopy() *PatroniSwitchover {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniSyncReplication) DeepCopyInto(out *PatroniSyncReplication) {
+	*out = *in
+	if in.NodeCount != nil {
+		in, out := &in.NodeCount, &out.NodeCount
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSyncReplication.
+func (in *PatroniSyncReplication) DeepCopy() *PatroniSyncReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniSyncReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresAdditionalConfig) DeepCopyInto(out *PostgresAdditionalConfig) {
 	*out = *in
@@ -1059,6 +1287,33 @@ func (in *PostgresAdditionalConfig) DeepCopy() *PostgresAdditionalConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresAuthenticationSpec) DeepCopyInto(out *PostgresAuthenticationSpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]PostgresHBARule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Maps != nil {
+		in, out := &in.Maps, &out.Maps
+		*out = make([]PostgresUserNameMap, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresAuthenticationSpec.
+func (in *PostgresAuthenticationSpec) DeepCopy() *PostgresAuthenticationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresAuthenticationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresCluster) DeepCopyInto(out *PostgresCluster) {
 	*out = *in
@@ -1169,6 +1424,11 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(DataSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Authentication != nil {
+		in, out := &in.Authentication, &out.Authentication
+		*out = new(PostgresAuthenticationSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Backups.DeepCopyInto(&out.Backups)
 	if in.CustomTLSSecret != nil {
 		in, out := &in.CustomTLSSecret, &out.CustomTLSSecret
@@ -1180,6 +1440,11 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(v1.SecretProjection)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.DatabaseInitSQL != nil {
 		in, out := &in.DatabaseInitSQL, &out.DatabaseInitSQL
 		*out = new(DatabaseInitSQL)
@@ -1190,6 +1455,18 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]v1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.ImagePullSecrets != nil {
 		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
 		*out = make([]v1.LocalObjectReference, len(*in))
@@ -1239,9 +1516,21 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 	}
 	if in.Service != nil {
 		in, out := &in.Service, &out.Service
-		*out = new(ServiceSpec)
+		*out = new(PostgresServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadReplicaService != nil {
+		in, out := &in.ReadReplicaService, &out.ReadReplicaService
+		*out = new(PostgresReadReplicaServiceSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Shutdown != nil {
 		in, out := &in.Shutdown, &out.Shutdown
 		*out = new(bool)
@@ -1257,6 +1546,11 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = make([]int64, len(*in))
 		copy(*out, *in)
 	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(PostgresUpgradeSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Users != nil {
 		in, out := &in.Users, &out.Users
 		*out = make([]PostgresUserSpec, len(*in))
@@ -1264,6 +1558,16 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresDatabaseSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.Extensions != nil {
+		in, out := &in.Extensions, &out.Extensions
+		*out = make([]PostgresExtensionSpec, len(*in))
+		copy(*out, *in)
+	}
 	in.Config.DeepCopyInto(&out.Config)
 }
 
@@ -1280,10 +1584,17 @@ func (in *PostgresClusterSpec) DeepCopy() *PostgresClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
 	if in.InstanceSets != nil {
 		in, out := &in.InstanceSets, &out.InstanceSets
 		*out = make([]PostgresInstanceSetStatus, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	in.Patroni.DeepCopyInto(&out.Patroni)
 	if in.PGBackRest != nil {
@@ -1322,6 +1633,68 @@ func (in *PostgresClusterStatus) DeepCopy() *PostgresClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresDatabaseSpec) DeepCopyInto(out *PostgresDatabaseSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresDatabaseSpec.
+func (in *PostgresDatabaseSpec) DeepCopy() *PostgresDatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresDatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresExtensionSpec) DeepCopyInto(out *PostgresExtensionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresExtensionSpec.
+func (in *PostgresExtensionSpec) DeepCopy() *PostgresExtensionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresExtensionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresHBARule) DeepCopyInto(out *PostgresHBARule) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresHBARule.
+func (in *PostgresHBARule) DeepCopy() *PostgresHBARule {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresHBARule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 	*out = *in
@@ -1342,12 +1715,60 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 	in.DataVolumeClaimSpec.DeepCopyInto(&out.DataVolumeClaimSpec)
+	if in.DisableVolumePermissions != nil {
+		in, out := &in.DisableVolumePermissions, &out.DisableVolumePermissions
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]v1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(ProbeTiming)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.PriorityClassName != nil {
 		in, out := &in.PriorityClassName, &out.PriorityClassName
 		*out = new(string)
 		**out = **in
 	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(ProbeTiming)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -1359,11 +1780,21 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 		**out = **in
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Sidecars != nil {
 		in, out := &in.Sidecars, &out.Sidecars
 		*out = new(InstanceSidecars)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
 	if in.Tolerations != nil {
 		in, out := &in.Tolerations, &out.Tolerations
 		*out = make([]v1.Toleration, len(*in))
@@ -1398,6 +1829,13 @@ func (in *PostgresInstanceSetSpec) DeepCopy() *PostgresInstanceSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresInstanceSetStatus) DeepCopyInto(out *PostgresInstanceSetStatus) {
 	*out = *in
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]PostgresInstanceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresInstanceSetStatus.
@@ -1410,6 +1848,26 @@ func (in *PostgresInstanceSetStatus) DeepCopy() *PostgresInstanceSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresInstanceStatus) DeepCopyInto(out *PostgresInstanceStatus) {
+	*out = *in
+	if in.ReplicationLagBytes != nil {
+		in, out := &in.ReplicationLagBytes, &out.ReplicationLagBytes
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresInstanceStatus.
+func (in *PostgresInstanceStatus) DeepCopy() *PostgresInstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresInstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresPasswordSpec) DeepCopyInto(out *PostgresPasswordSpec) {
 	*out = *in
@@ -1461,6 +1919,47 @@ func (in *PostgresProxyStatus) DeepCopy() *PostgresProxyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresReadReplicaServiceSpec) DeepCopyInto(out *PostgresReadReplicaServiceSpec) {
+	*out = *in
+	if in.MaxLagBytes != nil {
+		in, out := &in.MaxLagBytes, &out.MaxLagBytes
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresReadReplicaServiceSpec.
+func (in *PostgresReadReplicaServiceSpec) DeepCopy() *PostgresReadReplicaServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresReadReplicaServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresServiceSpec) DeepCopyInto(out *PostgresServiceSpec) {
+	*out = *in
+	in.ServiceSpec.DeepCopyInto(&out.ServiceSpec)
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresServiceSpec.
+func (in *PostgresServiceSpec) DeepCopy() *PostgresServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresStandbySpec) DeepCopyInto(out *PostgresStandbySpec) {
 	*out = *in
@@ -1481,6 +1980,22 @@ func (in *PostgresStandbySpec) DeepCopy() *PostgresStandbySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUpgradeSpec) DeepCopyInto(out *PostgresUpgradeSpec) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUpgradeSpec.
+func (in *PostgresUpgradeSpec) DeepCopy() *PostgresUpgradeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUpgradeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresUserInterfaceStatus) DeepCopyInto(out *PostgresUserInterfaceStatus) {
 	*out = *in
@@ -1497,6 +2012,21 @@ func (in *PostgresUserInterfaceStatus) DeepCopy() *PostgresUserInterfaceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserNameMap) DeepCopyInto(out *PostgresUserNameMap) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserNameMap.
+func (in *PostgresUserNameMap) DeepCopy() *PostgresUserNameMap {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserNameMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 	*out = *in
@@ -1522,6 +2052,41 @@ func (in *PostgresUserSpec) DeepCopy() *PostgresUserSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeTiming) DeepCopyInto(out *ProbeTiming) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTiming.
+func (in *ProbeTiming) DeepCopy() *ProbeTiming {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeTiming)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoAzure) DeepCopyInto(out *RepoAzure) {
 	*out = *in
@@ -1636,6 +2201,26 @@ func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.IPFamilyPolicy != nil {
+		in, out := &in.IPFamilyPolicy, &out.IPFamilyPolicy
+		*out = new(v1.IPFamilyPolicyType)
+		**out = **in
+	}
+	if in.IPFamilies != nil {
+		in, out := &in.IPFamilies, &out.IPFamilies
+		*out = make([]v1.IPFamily, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SessionAffinityTimeoutSeconds != nil {
+		in, out := &in.SessionAffinityTimeoutSeconds, &out.SessionAffinityTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.
@@ -1668,6 +2253,36 @@ func (in *Sidecar) DeepCopy() *Sidecar {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSSpec) DeepCopyInto(out *TLSSpec) {
+	*out = *in
+	if in.RootCA != nil {
+		in, out := &in.RootCA, &out.RootCA
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.CertificateDuration != nil {
+		in, out := &in.CertificateDuration, &out.CertificateDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RenewBefore != nil {
+		in, out := &in.RenewBefore, &out.RenewBefore
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSSpec.
+func (in *TLSSpec) DeepCopy() *TLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UserInterfaceSpec) DeepCopyInto(out *UserInterfaceSpec) {
 	*out = *in